@@ -1,41 +1,142 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
 
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/migrations"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/db"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/migrate"
 )
 
+const usage = `Usage: go run cmd/migrate/main.go [-path dir] <command> [arg]
+
+Commands:
+  up [N]       apply all pending migrations, or only the next N
+  down [N]     revert all applied migrations, or only the last N
+  goto V       migrate up or down to version V (0 = empty schema)
+  force V      mark version V as applied and clean without running SQL
+  status       list migrations and show the current version
+`
+
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("Usage: go run cmd/migrate/main.go [up|down]")
+	dir := flag.String("path", "", "read migrations from this directory instead of the embedded set")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
 	}
 
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, relying on real environment")
+	}
 	cfg := config.Load()
-	direction := os.Args[1]
 
-	switch direction {
+	var src fs.FS = migrations.FS
+	if *dir != "" {
+		src = os.DirFS(*dir)
+	}
+	list, err := migrate.Load(src)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	conn, err := db.Open(cfg)
+	if err != nil {
+		log.Fatalf("❌ DB init failed: %v", err)
+	}
+	defer conn.Close()
+
+	m := migrate.New(conn.DB, list)
+	m.Log = func(format string, args ...any) { fmt.Printf("  "+format+"\n", args...) }
+	// Databases set up by the old init script have the schema of versions
+	// 1-6 but no record of it. The script could stop part-way, so every
+	// table those versions create has to be there.
+	m.Baseline = &migrate.Baseline{Version: 6, Tables: []string{
+		"users", "achievements", "user_achievements", "friend_requests", "friends",
+		"activities", "activity_goals", "user_steps", "step_goals",
+		"meals", "calorie_goals", "water_logs", "water_goals",
+		"post_activities", "messages",
+		"challenges", "challenge_participants",
+		"workout_schedules", "hydration_settings",
+	}}
+
+	cmd, arg := flag.Arg(0), flag.Arg(1)
+	switch cmd {
 	case "up":
-		runMigrationsUp(cfg)
+		fmt.Println("🔄 Running migrations UP")
+		err = m.Up(optionalCount(arg))
 	case "down":
-		runMigrationsDown(cfg)
+		fmt.Println("🔄 Running migrations DOWN")
+		err = m.Down(optionalCount(arg))
+	case "goto":
+		fmt.Printf("🔄 Migrating to version %s\n", arg)
+		err = m.Goto(requiredVersion(arg))
+	case "force":
+		err = m.Force(requiredVersion(arg))
+	case "status":
+		err = printStatus(m)
 	default:
-		log.Fatal("Invalid direction. Use 'up' or 'down'")
+		flag.Usage()
+		os.Exit(2)
 	}
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if cmd != "status" {
+		fmt.Println("✅ Migrations completed successfully")
+	}
+}
+
+func printStatus(m *migrate.Migrator) error {
+	list, cur, err := m.Status()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Current version: %d\n", cur)
+	for _, st := range list {
+		if st.Dirty {
+			fmt.Printf("Dirty at version %d, repair it and run force\n", st.Version)
+		}
+	}
+	fmt.Println()
+	for _, st := range list {
+		state := "pending"
+		switch {
+		case st.Dirty:
+			state = "DIRTY"
+		case st.Applied:
+			state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("  %04d  %-32s %s\n", st.Version, st.Name, state)
+	}
+	return nil
 }
 
-func runMigrationsUp(cfg *config.Config) {
-	fmt.Printf("🔄 Running migrations UP against %s\n", cfg.DatabaseURL)
-	// TODO: Implement actual migration logic
-	// This would typically use a migration library like golang-migrate
-	fmt.Println("✅ Migrations completed successfully")
+func optionalCount(arg string) int {
+	if arg == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		log.Fatalf("❌ N must be a positive number, got %q", arg)
+	}
+	return n
 }
 
-func runMigrationsDown(cfg *config.Config) {
-	fmt.Printf("🔄 Running migrations DOWN against %s\n", cfg.DatabaseURL)
-	// TODO: Implement actual migration logic
-	// This would typically use a migration library like golang-migrate
-	fmt.Println("✅ Migrations rollback completed successfully")
+func requiredVersion(arg string) int64 {
+	v, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || v < 0 {
+		log.Fatalf("❌ a version number is required, got %q", arg)
+	}
+	return v
 }
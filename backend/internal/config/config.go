@@ -4,30 +4,126 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all configuration values
 type Config struct {
 	Env         string
 	Port        string
+	Storage     string // "postgres" (default) or "memory"
 	DatabaseURL string
 	JWTSecret   string
 	CORSOrigins string
+
+	AccessTokenTTL  time.Duration // lifetime of a signed JWT
+	RefreshTokenTTL time.Duration // lifetime of one refresh token
+
+	AppURL       string // base URL of the web app, used in mailed links
+	Mailer       string // "file" (default), "log" or "smtp"
+	MailFrom     string
+	MailDir      string // where the file mailer drops .eml files; empty logs them
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+
+	PasswordMinLength     int
+	BreachedPasswordsFile string // replaces the bundled breached-password list
+	TOTPIssuer            string // account label in authenticator apps
+	AdminEmails           []string
+
+	// Requests per minute; 0 turns a limit off.
+	AuthRateLimit int // per IP and per email on sign-in, sign-up and password reset
+	APIRateLimit  int // per user on authenticated routes
+	// LoginMaxFailures failed logins within LoginLockout lock the account
+	// for LoginLockout.
+	LoginMaxFailures int
+	LoginLockout     time.Duration
+
+	// AccountDeletionGrace is how long a deleted account can still be
+	// restored before its data is erased.
+	AccountDeletionGrace time.Duration
+
+	// OIDCProviders are the OpenID Connect providers users can sign in
+	// with, named in OIDC_PROVIDERS.
+	OIDCProviders []OIDCProvider
+}
+
+// OIDCProvider is one OpenID Connect provider. Its settings are read from
+// OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and _REDIRECT_URL.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // defaults to APP_URL/auth/oidc/<name>/callback
 }
 
+// Storage backends accepted in STORAGE.
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
 // Load reads configuration from environment variables
 // and fails immediately if any *required* value is missing.
+// DATABASE_URL is only required when STORAGE is "postgres".
 func Load() *Config {
-	return &Config{
-		Env:  getEnv("ENV", "development"),
-		Port: getEnv("PORT", "8080"),
-
-		DatabaseURL: getEnvRequired("DATABASE_URL"),
+	cfg := &Config{
+		Env:     getEnv("ENV", "development"),
+		Port:    getEnv("PORT", "8080"),
+		Storage: getEnv("STORAGE", StoragePostgres),
 
-		JWTSecret: getEnv("JWT_SECRET", "your-jwt-secret-key"),
+		JWTSecret:       getEnv("JWT_SECRET", "your-jwt-secret-key"),
+		AccessTokenTTL:  getEnvAsDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getEnvAsDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
 
 		CORSOrigins: getEnv("CORS_ORIGINS", "http://localhost:3000"),
+
+		AppURL:       getEnv("APP_URL", "http://localhost:3000"),
+		Mailer:       getEnv("MAILER", "file"),
+		MailFrom:     getEnv("MAIL_FROM", "no-reply@localhost"),
+		MailDir:      getEnv("MAIL_DIR", ""),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		PasswordMinLength:     getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+		BreachedPasswordsFile: getEnv("PASSWORD_BREACHED_LIST", ""),
+		TOTPIssuer:            getEnv("TOTP_ISSUER", "Healthy Summer"),
+		AdminEmails:           getEnvAsList("ADMIN_EMAILS"),
+
+		AuthRateLimit:    getEnvAsInt("RATE_LIMIT_AUTH", 10),
+		APIRateLimit:     getEnvAsInt("RATE_LIMIT_API", 300),
+		LoginMaxFailures: getEnvAsInt("LOGIN_MAX_FAILURES", 5),
+		LoginLockout:     getEnvAsDuration("LOGIN_LOCKOUT", 15*time.Minute),
+
+		AccountDeletionGrace: getEnvAsDuration("ACCOUNT_DELETION_GRACE", 7*24*time.Hour),
+	}
+
+	for _, name := range getEnvAsList("OIDC_PROVIDERS") {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		cfg.OIDCProviders = append(cfg.OIDCProviders, OIDCProvider{
+			Name:         strings.ToLower(name),
+			Issuer:       getEnvRequired(prefix + "ISSUER"),
+			ClientID:     getEnvRequired(prefix + "CLIENT_ID"),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", cfg.AppURL+"/auth/oidc/"+strings.ToLower(name)+"/callback"),
+		})
 	}
+
+	switch cfg.Storage {
+	case StoragePostgres:
+		cfg.DatabaseURL = getEnvRequired("DATABASE_URL")
+	case StorageMemory:
+		cfg.DatabaseURL = getEnv("DATABASE_URL", "")
+	default:
+		log.Fatalf("❌ Unknown STORAGE %q, use %q or %q", cfg.Storage, StoragePostgres, StorageMemory)
+	}
+	return cfg
 }
 
 // getEnv returns the value of the env var or the fallback if unset.
@@ -56,6 +152,18 @@ func getEnvAsInt(name string, fallback int) int {
 	return fallback
 }
 
+// getEnvAsList splits a comma-separated environment variable, dropping
+// empty items.
+func getEnvAsList(name string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 // getEnvAsBool gets an environment variable as bool with a fallback value
 func getEnvAsBool(name string, fallback bool) bool {
 	valueStr := getEnv(name, "")
@@ -64,3 +172,13 @@ func getEnvAsBool(name string, fallback bool) bool {
 	}
 	return fallback
 }
+
+// getEnvAsDuration gets an environment variable as time.Duration
+// ("15m", "720h") with a fallback value
+func getEnvAsDuration(name string, fallback time.Duration) time.Duration {
+	valueStr := getEnv(name, "")
+	if value, err := time.ParseDuration(valueStr); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
@@ -3,10 +3,12 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
-	// Test default values
+	// Test default values; in-memory storage needs no DATABASE_URL
+	t.Setenv("STORAGE", StorageMemory)
 	cfg := Load()
 
 	if cfg.Env != "development" {
@@ -24,6 +26,7 @@ func TestLoad(t *testing.T) {
 
 func TestLoadWithEnvVars(t *testing.T) {
 	// Set environment variables
+	t.Setenv("DATABASE_URL", "postgres://localhost/test")
 	os.Setenv("ENV", "test")
 	os.Setenv("PORT", "9000")
 	os.Setenv("JWT_SECRET", "test-secret")
@@ -48,6 +51,14 @@ func TestLoadWithEnvVars(t *testing.T) {
 	if cfg.JWTSecret != "test-secret" {
 		t.Errorf("Expected JWT secret to be 'test-secret', got '%s'", cfg.JWTSecret)
 	}
+
+	if cfg.Storage != StoragePostgres {
+		t.Errorf("Expected default storage to be 'postgres', got '%s'", cfg.Storage)
+	}
+
+	if cfg.DatabaseURL != "postgres://localhost/test" {
+		t.Errorf("Expected database URL to be read from env, got '%s'", cfg.DatabaseURL)
+	}
 }
 
 func TestGetEnvAsInt(t *testing.T) {
@@ -83,3 +94,16 @@ func TestGetEnvAsBool(t *testing.T) {
 		t.Errorf("Expected fallback value false, got %v", result)
 	}
 }
+
+func TestGetEnvAsDuration(t *testing.T) {
+	t.Setenv("TEST_TTL", "90s")
+
+	if got := getEnvAsDuration("TEST_TTL", time.Minute); got != 90*time.Second {
+		t.Errorf("Expected 90s, got %v", got)
+	}
+
+	t.Setenv("TEST_TTL", "soon")
+	if got := getEnvAsDuration("TEST_TTL", time.Minute); got != time.Minute {
+		t.Errorf("Expected fallback value 1m, got %v", got)
+	}
+}
@@ -5,12 +5,13 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
 )
 
 type UserService interface {
-	AddActivity(userID, typ, name string, duration int, intensity string, calories int, location string) error
+	AddActivity(userID, typ, name string, duration int, intensity string, calories int, location string, distance int, idempotencyKey string) (models.Activity, error)
 	ListActivities(userID string, filter *string) ([]models.Activity, error)
 	SetActivityGoal(userID string, goal int) error
 	GetActivityGoal(userID string) (int, error)
@@ -18,17 +19,18 @@ type UserService interface {
 	GetWeeklyStats(userID string) ([]services.ActivityStats, error)
 }
 
-var userService UserService = services.User
+var userService UserService
 
 func ResetUserService(svc UserService) {
 	userService = svc
 }
 
 type ChallengeService interface {
-	BumpProgress(userID, metric string, amount int) error
+	RecordActivity(a models.Activity) error
+	RecountDays(userID string) error
 }
 
-var challengeService ChallengeService = services.Challenge
+var challengeService ChallengeService
 
 func ResetChallengeService(svc ChallengeService) {
 	challengeService = svc
@@ -43,17 +45,19 @@ func AddActivity(c *gin.Context) {
 		Intensity string `json:"intensity"`
 		Calories  int    `json:"calories"`
 		Location  string `json:"location"`
+		Distance  int    `json:"distance" binding:"min=0"` // meters
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := userService.AddActivity(userID, req.Type, req.Name, req.Duration, req.Intensity, req.Calories, req.Location); err != nil {
+	act, err := userService.AddActivity(userID, req.Type, req.Name, req.Duration, req.Intensity, req.Calories, req.Location, req.Distance, handlers.IdempotencyKey(c))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log activity"})
 		return
 	}
-	if err := challengeService.BumpProgress(userID, "workouts", 1); err != nil {
-		log.Printf("[Challenge] bump workouts: %v", err)
+	if err := challengeService.RecordActivity(act); err != nil {
+		log.Printf("[Challenge] record activity: %v", err)
 	}
 	c.Status(http.StatusOK)
 }
@@ -84,10 +88,13 @@ func SetActivityGoal(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid body"})
 		return
 	}
+	before, _ := userService.GetActivityGoal(userID)
 	if err := userService.SetActivityGoal(userID, input.Goal); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set goal"})
 		return
 	}
+	handlers.Audit(c, services.AuditGoalUpdate, "goal", string(models.GoalActivity),
+		gin.H{"goal": before}, gin.H{"goal": input.Goal})
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -12,12 +13,14 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
 )
 
 type mockUserSvc struct {
 	addCalled     bool
 	addErr        error
+	added         []models.Activity
 	listCalled    bool
 	listRes       []models.Activity
 	listErr       error
@@ -34,15 +37,52 @@ type mockUserSvc struct {
 	weeklyErr     error
 }
 
-type mockChallengeSvc struct{}
+type mockAuditSvc struct {
+	entries []services.AuditEntry
+}
+
+func (m *mockAuditSvc) Record(e services.AuditEntry) {
+	m.entries = append(m.entries, e)
+}
+func (m *mockAuditSvc) ListForUser(userID string, limit, offset int) ([]models.AuditEvent, error) {
+	return nil, nil
+}
+func (m *mockAuditSvc) List(f repository.AuditFilter) ([]models.AuditEvent, error) {
+	return nil, nil
+}
 
-func (m *mockChallengeSvc) BumpProgress(userID, metric string, amount int) error {
+type mockChallengeSvc struct {
+	recounts   int
+	activities []models.Activity
+}
+
+func (m *mockChallengeSvc) RecordActivity(a models.Activity) error {
+	m.activities = append(m.activities, a)
+	return nil
+}
+func (m *mockChallengeSvc) RecountDays(userID string) error {
+	m.recounts++
 	return nil
 }
 
-func (m *mockUserSvc) AddActivity(userID, typ, name string, duration int, intensity string, calories int, location string) error {
+// AddActivity returns the activity logged before under the same key, the
+// way the store replays a retried request.
+func (m *mockUserSvc) AddActivity(userID, typ, name string, duration int, intensity string, calories int, location string, distance int, idempotencyKey string) (models.Activity, error) {
 	m.addCalled = true
-	return m.addErr
+	if m.addErr != nil {
+		return models.Activity{}, m.addErr
+	}
+	for _, a := range m.added {
+		if idempotencyKey != "" && a.IdempotencyKey == idempotencyKey {
+			return a, nil
+		}
+	}
+	a := models.Activity{
+		ID: fmt.Sprintf("act-%d", len(m.added)+1), UserID: userID, Type: typ, Name: name,
+		Duration: duration, Calories: calories, Distance: distance, IdempotencyKey: idempotencyKey,
+	}
+	m.added = append(m.added, a)
+	return a, nil
 }
 
 func (m *mockUserSvc) ListActivities(userID string, filter *string) ([]models.Activity, error) {
@@ -74,6 +114,7 @@ func setupTest(t *testing.T, mock *mockUserSvc, req *http.Request) (*gin.Context
 	gin.SetMode(gin.TestMode)
 	ResetUserService(mock)
 	ResetChallengeService(&mockChallengeSvc{})
+	services.Audit = &mockAuditSvc{}
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Request = req
@@ -94,6 +135,30 @@ func TestAddActivity_Success(t *testing.T) {
 	assert.True(t, mock.addCalled, "AddActivity should call service")
 }
 
+func TestAddActivity_RecordsProgressUnderIdempotencyKey(t *testing.T) {
+	users := &mockUserSvc{}
+	challenges := &mockChallengeSvc{}
+	for _, key := range []string{"", "retry-1", "retry-1"} {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"type":"run","name":"x","duration":30,"distance":5000}`))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		c, w := setupTest(t, users, req)
+		ResetChallengeService(challenges)
+		AddActivity(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	assert.Len(t, users.added, 2, "a retry must not log the activity again")
+	if assert.Len(t, challenges.activities, 3) {
+		assert.Equal(t, "retry-1", users.added[1].IdempotencyKey)
+		assert.Equal(t, challenges.activities[1].ID, challenges.activities[2].ID, "a retry records the same activity")
+		assert.Equal(t, 30, challenges.activities[1].Duration)
+		assert.Equal(t, 5000, challenges.activities[1].Distance)
+	}
+}
+
 func TestAddActivity_ServiceError(t *testing.T) {
 	mock := &mockUserSvc{addErr: errors.New("oops")}
 	body := `{"type":"run","name":"x","duration":10,"intensity":"high","calories":100,"location":""}`
@@ -142,10 +207,18 @@ func TestSetActivityGoal_Success(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	c, w := setupTest(t, mock, req)
+	audit := &mockAuditSvc{}
+	services.Audit = audit
 	SetActivityGoal(c)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.True(t, mock.goalCalled, "SetActivityGoal should call service")
+	if assert.Len(t, audit.entries, 1) {
+		assert.Equal(t, services.AuditGoalUpdate, audit.entries[0].Action)
+		assert.Equal(t, "activity", audit.entries[0].TargetID)
+		assert.Equal(t, "user-1", audit.entries[0].SubjectID)
+		assert.Equal(t, gin.H{"goal": 100}, audit.entries[0].After)
+	}
 
 	var resp map[string]bool
 	err := json.Unmarshal(w.Body.Bytes(), &resp)
@@ -1,16 +1,25 @@
 package activity
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
 )
 
 type StepService interface {
 	AddOrUpdateSteps(userID string, steps int) error
+	SaveStepDays(userID, source string, days []services.StepDay) error
+	SaveStepHours(userID, source string, hours []services.StepHour) error
+	ReplaceStepDay(userID string, day services.StepDay) error
+	DeleteStepDay(userID, day string) error
+	GetStepBreakdown(userID string, days int, bySource, byHour bool) ([]services.StepDayBreakdown, error)
 	AwardStepAchievements(userID string) error
 	GetStepStats(userID string, goal int) (services.StepStats, error)
 	GetStepAnalytics(userID string, days int) ([]services.StepDay, error)
@@ -18,37 +27,109 @@ type StepService interface {
 	GetStepGoal(userID string) (int, error)
 }
 
-var stepService StepService = services.Step
+var stepService StepService
 
 func ResetStepService(svc StepService) {
 	stepService = svc
 }
 
-// AddSteps saves today's steps and awards achievements.
+// AddSteps saves what the source device counted: a day's total (today's
+// by default, or the given date's), a batch of daily totals uploaded after
+// the device was offline, or hourly counts.
 func AddSteps(c *gin.Context) {
 	userID := c.GetString("userID")
 	var req struct {
-		Steps int `json:"steps"`
+		Source  string              `json:"source"`
+		Steps   int                 `json:"steps"`
+		Date    string              `json:"date"`
+		Entries []services.StepDay  `json:"entries" binding:"max=90"`
+		Hours   []services.StepHour `json:"hours" binding:"max=2160"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := stepService.AddOrUpdateSteps(userID, req.Steps); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save steps"})
+	forms := 0
+	for _, used := range []bool{len(req.Entries) > 0, len(req.Hours) > 0, req.Date != "" || req.Steps != 0} {
+		if used {
+			forms++
+		}
+	}
+	if forms > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "send one of entries, hours, or steps with an optional date"})
 		return
 	}
 
-	_ = stepService.AwardStepAchievements(userID)
+	var err error
+	switch {
+	case len(req.Entries) > 0:
+		err = stepService.SaveStepDays(userID, req.Source, req.Entries)
+	case len(req.Hours) > 0:
+		err = stepService.SaveStepHours(userID, req.Source, req.Hours)
+	case req.Date != "" || req.Source != "":
+		err = stepService.SaveStepDays(userID, req.Source, []services.StepDay{{Day: req.Date, Steps: req.Steps}})
+	default:
+		err = stepService.AddOrUpdateSteps(userID, req.Steps)
+	}
+	if !stepsSaved(c, err) {
+		return
+	}
+	c.Status(http.StatusOK)
+}
 
-	if err := challengeService.BumpProgress(userID, "steps", req.Steps); err != nil {
-		log.Printf("[Challenge] bump steps: %v", err)
+// UpdateStepDay replaces the total of the day in the path, dropping what
+// the sources counted.
+func UpdateStepDay(c *gin.Context) {
+	var req struct {
+		Steps *int `json:"steps" binding:"required"`
 	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	day := services.StepDay{Day: c.Param("day"), Steps: *req.Steps}
+	if !stepsSaved(c, stepService.ReplaceStepDay(c.GetString("userID"), day)) {
+		return
+	}
+	c.JSON(http.StatusOK, day)
+}
 
+// DeleteStepDay removes the total of the day in the path.
+func DeleteStepDay(c *gin.Context) {
+	err := stepService.DeleteStepDay(c.GetString("userID"), c.Param("day"))
+	if errors.Is(err, services.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no steps logged that day"})
+		return
+	}
+	if !stepsSaved(c, err) {
+		return
+	}
 	c.Status(http.StatusOK)
 }
 
+// stepsSaved answers a failed write of step totals, or, after a successful
+// one, brings achievements and steps challenges up to date. Challenge
+// progress is recounted from the totals rather than bumped by the amount
+// sent, so repeated uploads and edits of a day never count twice.
+func stepsSaved(c *gin.Context, err error) bool {
+	if errors.Is(err, services.ErrInvalidStepDay) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save steps"})
+		return false
+	}
+
+	userID := c.GetString("userID")
+	_ = stepService.AwardStepAchievements(userID)
+	if err := challengeService.RecountDays(userID); err != nil {
+		log.Printf("[Challenge] recount days: %v", err)
+	}
+	return true
+}
+
 // GetStepStats returns today's and aggregated step stats.
 func GetStepStats(c *gin.Context) {
 	userID := c.GetString("userID")
@@ -63,7 +144,9 @@ func GetStepStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
-// GetStepAnalytics returns the last N days of step data.
+// GetStepAnalytics returns the last N days of step data. breakdown=source
+// and/or breakdown=hour (comma separated) add per-source and per-hour
+// counts to every day.
 func GetStepAnalytics(c *gin.Context) {
 	userID := c.GetString("userID")
 	days := 30
@@ -73,6 +156,28 @@ func GetStepAnalytics(c *gin.Context) {
 		}
 	}
 
+	if b := c.Query("breakdown"); b != "" {
+		var bySource, byHour bool
+		for _, part := range strings.Split(b, ",") {
+			switch strings.TrimSpace(part) {
+			case "source":
+				bySource = true
+			case "hour":
+				byHour = true
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "breakdown must be source, hour or both"})
+				return
+			}
+		}
+		result, err := stepService.GetStepBreakdown(userID, days, bySource, byHour)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get analytics"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
 	result, err := stepService.GetStepAnalytics(userID, days)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get analytics"})
@@ -93,10 +198,16 @@ func SetStepGoal(c *gin.Context) {
 		return
 	}
 
+	before, _ := stepService.GetStepGoal(userID)
 	if err := stepService.SetStepGoal(userID, input.Goal); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set goal"})
 		return
 	}
+	handlers.Audit(c, services.AuditGoalUpdate, "goal", string(models.GoalSteps),
+		gin.H{"goal": before}, gin.H{"goal": input.Goal})
+	if err := challengeService.RecountDays(userID); err != nil {
+		log.Printf("[Challenge] recount days: %v", err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -16,6 +17,13 @@ import (
 
 type mockStepSvc struct {
 	updateErr    error
+	saved        []services.StepDay
+	sources      []string
+	hours        []services.StepHour
+	replaced     []services.StepDay
+	saveErr      error
+	breakdown    [2]bool
+	deleteErr    error
 	statsRes     services.StepStats
 	statsErr     error
 	analyticsRes []services.StepDay
@@ -28,6 +36,23 @@ type mockStepSvc struct {
 func (m *mockStepSvc) AddOrUpdateSteps(userID string, steps int) error {
 	return m.updateErr
 }
+func (m *mockStepSvc) SaveStepDays(userID, source string, days []services.StepDay) error {
+	m.saved = append(m.saved, days...)
+	m.sources = append(m.sources, source)
+	return m.saveErr
+}
+func (m *mockStepSvc) SaveStepHours(userID, source string, hours []services.StepHour) error {
+	m.hours = append(m.hours, hours...)
+	m.sources = append(m.sources, source)
+	return m.saveErr
+}
+func (m *mockStepSvc) ReplaceStepDay(userID string, day services.StepDay) error {
+	m.replaced = append(m.replaced, day)
+	return m.saveErr
+}
+func (m *mockStepSvc) DeleteStepDay(userID, day string) error {
+	return m.deleteErr
+}
 func (m *mockStepSvc) AwardStepAchievements(userID string) error { return nil }
 func (m *mockStepSvc) GetStepStats(userID string, goal int) (services.StepStats, error) {
 	return m.statsRes, m.statsErr
@@ -35,6 +60,10 @@ func (m *mockStepSvc) GetStepStats(userID string, goal int) (services.StepStats,
 func (m *mockStepSvc) GetStepAnalytics(userID string, days int) ([]services.StepDay, error) {
 	return m.analyticsRes, m.analyticsErr
 }
+func (m *mockStepSvc) GetStepBreakdown(userID string, days int, bySource, byHour bool) ([]services.StepDayBreakdown, error) {
+	m.breakdown = [2]bool{bySource, byHour}
+	return []services.StepDayBreakdown{{Day: "X", Steps: 1}}, m.analyticsErr
+}
 func (m *mockStepSvc) SetStepGoal(userID string, goal int) error {
 	return m.setGoalErr
 }
@@ -45,6 +74,7 @@ func (m *mockStepSvc) GetStepGoal(userID string) (int, error) {
 func setupStepsTest(mock *mockStepSvc, req *http.Request) (*gin.Context, *httptest.ResponseRecorder) {
 	gin.SetMode(gin.TestMode)
 	ResetStepService(mock)
+	services.Audit = &mockAuditSvc{}
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Request = req
@@ -251,3 +281,89 @@ func TestGetStepGoal_ServiceError(t *testing.T) {
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
+
+func TestAddSteps_BackfillAndBatch(t *testing.T) {
+	challenges := &mockChallengeSvc{}
+	ResetChallengeService(challenges)
+	mock := &mockStepSvc{}
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		c, w := setupStepsTest(mock, req)
+		AddSteps(c)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, post(`{"steps":500,"date":"2025-07-01"}`).Code)
+	assert.Equal(t, http.StatusOK, post(`{"entries":[{"day":"2025-07-02","steps":700},{"day":"2025-07-03","steps":900}]}`).Code)
+	assert.Equal(t, []services.StepDay{{Day: "2025-07-01", Steps: 500}, {Day: "2025-07-02", Steps: 700}, {Day: "2025-07-03", Steps: 900}}, mock.saved)
+	assert.Equal(t, 2, challenges.recounts)
+
+	assert.Equal(t, http.StatusBadRequest, post(`{"date":"2025-07-01","entries":[{"day":"2025-07-02","steps":1}]}`).Code)
+
+	mock.saveErr = fmt.Errorf("%w: 2999-01-01 is in the future", services.ErrInvalidStepDay)
+	w := post(`{"steps":500,"date":"2999-01-01"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "in the future")
+	assert.Equal(t, 2, challenges.recounts)
+}
+
+func TestUpdateAndDeleteStepDay(t *testing.T) {
+	ResetChallengeService(&mockChallengeSvc{})
+	mock := &mockStepSvc{}
+	do := func(method, body string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		c, w := setupStepsTest(mock, req)
+		c.Params = gin.Params{{Key: "day", Value: "2025-07-01"}}
+		handler(c)
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, `{}`, UpdateStepDay).Code)
+	w := do(http.MethodPut, `{"steps":0}`, UpdateStepDay)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []services.StepDay{{Day: "2025-07-01", Steps: 0}}, mock.replaced)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "", DeleteStepDay).Code)
+	mock.deleteErr = services.ErrNotFound
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "", DeleteStepDay).Code)
+}
+
+func TestAddSteps_SourcesAndHours(t *testing.T) {
+	challenges := &mockChallengeSvc{}
+	ResetChallengeService(challenges)
+	mock := &mockStepSvc{}
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		c, w := setupStepsTest(mock, req)
+		AddSteps(c)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, post(`{"source":"watch","steps":800}`).Code)
+	assert.Equal(t, http.StatusOK, post(`{"source":"phone","hours":[{"start":"2025-07-01T09:00:00Z","steps":300}]}`).Code)
+	assert.Equal(t, []services.StepDay{{Steps: 800}}, mock.saved)
+	assert.Len(t, mock.hours, 1)
+	assert.Equal(t, []string{"watch", "phone"}, mock.sources)
+	assert.Equal(t, 2, challenges.recounts)
+
+	assert.Equal(t, http.StatusBadRequest, post(`{"steps":5,"hours":[{"start":"2025-07-01T09:00:00Z","steps":300}]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"entries":[{"day":"2025-07-02","steps":1}],"hours":[{"start":"2025-07-01T09:00:00Z","steps":300}]}`).Code)
+}
+
+func TestGetStepAnalytics_Breakdown(t *testing.T) {
+	mock := &mockStepSvc{}
+	get := func(query string) *httptest.ResponseRecorder {
+		c, w := setupStepsTest(mock, httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+		GetStepAnalytics(c)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, get("breakdown=source,hour").Code)
+	assert.Equal(t, [2]bool{true, true}, mock.breakdown)
+	assert.Equal(t, http.StatusOK, get("breakdown=hour").Code)
+	assert.Equal(t, [2]bool{false, true}, mock.breakdown)
+	assert.Equal(t, http.StatusBadRequest, get("breakdown=device").Code)
+}
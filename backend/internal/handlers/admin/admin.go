@@ -0,0 +1,180 @@
+// Package admin serves the /api/admin routes. Every route requires the
+// admin role; changes are recorded by services.Admin.
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+)
+
+type reasonReq struct {
+	Reason string `json:"reason"`
+}
+
+// ListUsers searches users by name or email (?q=, ?limit=, ?offset=).
+func ListUsers(c *gin.Context) {
+	limit := queryInt(c, "limit", 50, 200)
+	offset := queryInt(c, "offset", 0, -1)
+	list, err := services.Admin.ListUsers(c.Query("q"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot load users"})
+		return
+	}
+	if list == nil {
+		list = []services.AdminUser{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// SuspendUser blocks a user from signing in and ends their sessions.
+func SuspendUser(c *gin.Context) {
+	var req reasonReq
+	_ = c.ShouldBindJSON(&req)
+	err := services.Admin.SuspendUser(c.GetString("userID"), c.Param("id"), req.Reason)
+	if writeError(c, err, "cannot suspend user") {
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func ReinstateUser(c *gin.Context) {
+	err := services.Admin.ReinstateUser(c.GetString("userID"), c.Param("id"))
+	if writeError(c, err, "cannot reinstate user") {
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func ListAchievements(c *gin.Context) {
+	list, err := services.Admin.ListAchievements()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot load achievements"})
+		return
+	}
+	if list == nil {
+		list = []models.Achievement{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func CreateAchievement(c *gin.Context) {
+	var req services.AchievementInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	a, err := services.Admin.CreateAchievement(c.GetString("userID"), req)
+	if writeError(c, err, "cannot create achievement") {
+		return
+	}
+	c.JSON(http.StatusCreated, a)
+}
+
+func UpdateAchievement(c *gin.Context) {
+	var req services.AchievementInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	a, err := services.Admin.UpdateAchievement(c.GetString("userID"), c.Param("id"), req)
+	if writeError(c, err, "cannot update achievement") {
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+func DeleteAchievement(c *gin.Context) {
+	err := services.Admin.DeleteAchievement(c.GetString("userID"), c.Param("id"))
+	if writeError(c, err, "cannot delete achievement") {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func DeleteChallenge(c *gin.Context) {
+	var req reasonReq
+	_ = c.ShouldBindJSON(&req)
+	err := services.Admin.DeleteChallenge(c.GetString("userID"), c.Param("id"), req.Reason)
+	if writeError(c, err, "cannot delete challenge") {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func DeletePost(c *gin.Context) {
+	var req reasonReq
+	_ = c.ShouldBindJSON(&req)
+	err := services.Admin.DeletePost(c.GetString("userID"), c.Param("id"), req.Reason)
+	if writeError(c, err, "cannot delete post") {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListActions returns the admin action log, newest first (?limit=).
+func ListActions(c *gin.Context) {
+	list, err := services.Admin.ListActions(queryInt(c, "limit", 100, 500))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot load actions"})
+		return
+	}
+	if list == nil {
+		list = []models.AdminAction{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// ListAuditEvents searches the audit log of every user (?user=, ?action=,
+// ?limit=, ?offset=).
+func ListAuditEvents(c *gin.Context) {
+	list, err := services.Audit.List(repository.AuditFilter{
+		SubjectID: c.Query("user"),
+		Action:    c.Query("action"),
+		Limit:     queryInt(c, "limit", 100, 500),
+		Offset:    queryInt(c, "offset", 0, -1),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot load audit log"})
+		return
+	}
+	if list == nil {
+		list = []models.AuditEvent{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// writeError answers err, if any, and reports whether it did.
+func writeError(c *gin.Context, err error, msg string) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, services.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	case errors.Is(err, services.ErrAchievementExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSuspendSelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+	}
+	return true
+}
+
+// queryInt reads a non-negative query parameter, falling back to def
+// and capping at max (a negative max means no cap).
+func queryInt(c *gin.Context, key string, def, max int) int {
+	n, err := strconv.Atoi(c.Query(key))
+	if err != nil || n < 0 {
+		return def
+	}
+	if max >= 0 && n > max {
+		return max
+	}
+	return n
+}
@@ -0,0 +1,226 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+)
+
+type mockAdminSvc struct {
+	users      []services.AdminUser
+	usersErr   error
+	query      string
+	limit      int
+	offset     int
+	suspendErr error
+	reason     string
+	achErr     error
+	deleteErr  error
+	actions    []models.AdminAction
+}
+
+func (m *mockAdminSvc) ListUsers(query string, limit, offset int) ([]services.AdminUser, error) {
+	m.query, m.limit, m.offset = query, limit, offset
+	return m.users, m.usersErr
+}
+func (m *mockAdminSvc) SuspendUser(adminID, userID, reason string) error {
+	m.reason = reason
+	return m.suspendErr
+}
+func (m *mockAdminSvc) ReinstateUser(adminID, userID string) error {
+	return m.suspendErr
+}
+func (m *mockAdminSvc) ListAchievements() ([]models.Achievement, error) {
+	return nil, nil
+}
+func (m *mockAdminSvc) CreateAchievement(adminID string, in services.AchievementInput) (models.Achievement, error) {
+	return models.Achievement{ID: "a1", Title: in.Title}, m.achErr
+}
+func (m *mockAdminSvc) UpdateAchievement(adminID, id string, in services.AchievementInput) (models.Achievement, error) {
+	return models.Achievement{ID: id, Title: in.Title}, m.achErr
+}
+func (m *mockAdminSvc) DeleteAchievement(adminID, id string) error {
+	return m.deleteErr
+}
+func (m *mockAdminSvc) DeleteChallenge(adminID, id, reason string) error {
+	m.reason = reason
+	return m.deleteErr
+}
+func (m *mockAdminSvc) DeletePost(adminID, id, reason string) error {
+	return m.deleteErr
+}
+func (m *mockAdminSvc) ListActions(limit int) ([]models.AdminAction, error) {
+	m.limit = limit
+	return m.actions, nil
+}
+
+type mockAuditSvc struct {
+	filter repository.AuditFilter
+}
+
+func (m *mockAuditSvc) Record(e services.AuditEntry) {}
+func (m *mockAuditSvc) ListForUser(userID string, limit, offset int) ([]models.AuditEvent, error) {
+	return nil, nil
+}
+func (m *mockAuditSvc) List(f repository.AuditFilter) ([]models.AuditEvent, error) {
+	m.filter = f
+	return nil, nil
+}
+
+func setupRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("userID", "admin1") })
+	r.GET("/users", ListUsers)
+	r.POST("/users/:id/suspend", SuspendUser)
+	r.POST("/users/:id/reinstate", ReinstateUser)
+	r.GET("/achievements", ListAchievements)
+	r.POST("/achievements", CreateAchievement)
+	r.PUT("/achievements/:id", UpdateAchievement)
+	r.DELETE("/achievements/:id", DeleteAchievement)
+	r.DELETE("/challenges/:id", DeleteChallenge)
+	r.DELETE("/posts/:id", DeletePost)
+	r.GET("/actions", ListActions)
+	r.GET("/audit", ListAuditEvents)
+	return r
+}
+
+func perform(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestListUsers(t *testing.T) {
+	mock := &mockAdminSvc{}
+	services.Admin = mock
+	r := setupRouter()
+
+	w := perform(r, "GET", "/users?q=ann&limit=1000&offset=5", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+	assert.Equal(t, "ann", mock.query)
+	assert.Equal(t, 200, mock.limit, "limit is capped")
+	assert.Equal(t, 5, mock.offset)
+
+	w = perform(r, "GET", "/users?limit=x", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 50, mock.limit)
+
+	mock.usersErr = assert.AnError
+	w = perform(r, "GET", "/users", "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestSuspendUser(t *testing.T) {
+	mock := &mockAdminSvc{}
+	services.Admin = mock
+	r := setupRouter()
+
+	w := perform(r, "POST", "/users/u1/suspend", `{"reason":"spam"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "spam", mock.reason)
+
+	// the reason is optional
+	w = perform(r, "POST", "/users/u1/suspend", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	for err, code := range map[error]int{
+		services.ErrNotFound:    http.StatusNotFound,
+		services.ErrSuspendSelf: http.StatusBadRequest,
+		assert.AnError:          http.StatusInternalServerError,
+	} {
+		mock.suspendErr = err
+		w = perform(r, "POST", "/users/u1/suspend", "")
+		assert.Equal(t, code, w.Code, err.Error())
+	}
+
+	mock.suspendErr = services.ErrNotFound
+	w = perform(r, "POST", "/users/u1/reinstate", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAchievements(t *testing.T) {
+	mock := &mockAdminSvc{}
+	services.Admin = mock
+	r := setupRouter()
+
+	w := perform(r, "GET", "/achievements", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+
+	w = perform(r, "POST", "/achievements", `{"description":"no title"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = perform(r, "POST", "/achievements", `{"title":"Early bird"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var a models.Achievement
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &a))
+	assert.Equal(t, "Early bird", a.Title)
+
+	w = perform(r, "PUT", "/achievements/a1", `{"title":"Night owl"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mock.achErr = services.ErrAchievementExists
+	w = perform(r, "POST", "/achievements", `{"title":"Early bird"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mock.achErr = services.ErrNotFound
+	w = perform(r, "PUT", "/achievements/a9", `{"title":"Night owl"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = perform(r, "DELETE", "/achievements/a1", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestDeleteContent(t *testing.T) {
+	mock := &mockAdminSvc{}
+	services.Admin = mock
+	r := setupRouter()
+
+	w := perform(r, "DELETE", "/challenges/c1", `{"reason":"abuse"}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "abuse", mock.reason)
+	w = perform(r, "DELETE", "/posts/p1", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	mock.deleteErr = services.ErrNotFound
+	assert.Equal(t, http.StatusNotFound, perform(r, "DELETE", "/challenges/c1", "").Code)
+	assert.Equal(t, http.StatusNotFound, perform(r, "DELETE", "/posts/p1", "").Code)
+	mock.deleteErr = assert.AnError
+	assert.Equal(t, http.StatusInternalServerError, perform(r, "DELETE", "/posts/p1", "").Code)
+}
+
+func TestListActions(t *testing.T) {
+	mock := &mockAdminSvc{actions: []models.AdminAction{{ID: "x", Action: services.ActionDeletePost}}}
+	services.Admin = mock
+	r := setupRouter()
+
+	w := perform(r, "GET", "/actions", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 100, mock.limit)
+	var list []models.AdminAction
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, services.ActionDeletePost, list[0].Action)
+}
+
+func TestListAuditEvents(t *testing.T) {
+	audit := &mockAuditSvc{}
+	services.Audit = audit
+	r := setupRouter()
+
+	w := perform(r, "GET", "/audit?user=u1&action=auth.login&offset=10", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+	assert.Equal(t, repository.AuditFilter{SubjectID: "u1", Action: "auth.login", Limit: 100, Offset: 10}, audit.filter)
+}
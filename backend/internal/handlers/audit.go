@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+)
+
+// Audit records that the signed-in user changed their own data. before and
+// after are snapshots of the target; either may be nil.
+func Audit(c *gin.Context, action, targetType, targetID string, before, after interface{}) {
+	userID := c.GetString("userID")
+	services.Audit.Record(services.AuditEntry{
+		ActorID:    userID,
+		SubjectID:  userID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Client:     services.ClientInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()},
+		Before:     before,
+		After:      after,
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
 )
 
@@ -19,13 +21,15 @@ func AddMeal(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := services.Nutrition.AddMeal(userID, req); err != nil {
+	req.IdempotencyKey = handlers.IdempotencyKey(c)
+	meal, err := services.Nutrition.AddMeal(userID, req)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add meal"})
 		return
 	}
 
-	if err := services.Challenge.BumpProgress(userID, "calories", int(req.Calories)); err != nil {
-		log.Printf("[Challenge] bump kcal: %v", err)
+	if err := services.Challenge.RecordMeal(meal); err != nil {
+		log.Printf("[Challenge] record meal: %v", err)
 	}
 
 	c.Status(http.StatusOK)
@@ -75,10 +79,14 @@ func AddWaterLog(c *gin.Context) {
 		return
 	}
 
-	if err := services.Nutrition.AddWaterLog(userID, req.Amount); err != nil {
+	l, err := services.Nutrition.AddWaterLog(userID, req.Amount, handlers.IdempotencyKey(c))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log water"})
 		return
 	}
+	if err := services.Challenge.RecordWater(l); err != nil {
+		log.Printf("[Challenge] record water: %v", err)
+	}
 	c.Status(http.StatusOK)
 }
 
@@ -121,11 +129,17 @@ func SetWaterGoal(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "Invalid body"})
 		return
 	}
+	before, _ := services.Nutrition.GetWaterGoal(userID)
 	err := services.Nutrition.SetWaterGoal(userID, input.GoalML)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Failed to set goal"})
 		return
 	}
+	handlers.Audit(c, services.AuditGoalUpdate, "goal", string(models.GoalWater),
+		gin.H{"goal_ml": before}, gin.H{"goal_ml": input.GoalML})
+	if err := services.Challenge.RecountDays(userID); err != nil {
+		log.Printf("[Challenge] recount days: %v", err)
+	}
 	c.JSON(200, gin.H{"success": true})
 }
 
@@ -148,11 +162,14 @@ func SetCalorieGoal(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "Invalid body"})
 		return
 	}
+	before, _ := services.Nutrition.GetCalorieGoal(userID)
 	err := services.Nutrition.SetCalorieGoal(userID, input.Goal)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Failed to set goal"})
 		return
 	}
+	handlers.Audit(c, services.AuditGoalUpdate, "goal", string(models.GoalCalories),
+		gin.H{"goal": before}, gin.H{"goal": input.Goal})
 	c.JSON(200, gin.H{"success": true})
 }
 
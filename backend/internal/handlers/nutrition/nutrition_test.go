@@ -6,11 +6,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
 )
 
@@ -35,8 +38,9 @@ type mockNutritionSvc struct {
 	getCalorieGoalErr   error
 }
 
-func (m *mockNutritionSvc) AddMeal(userID string, meal services.Meal) error {
-	return m.addMealErr
+func (m *mockNutritionSvc) AddMeal(userID string, meal services.Meal) (services.Meal, error) {
+	meal.UserID = userID
+	return meal, m.addMealErr
 }
 func (m *mockNutritionSvc) ListMeals(userID string) ([]services.Meal, error) {
 	return m.listMealsRes, m.listMealsErr
@@ -47,8 +51,8 @@ func (m *mockNutritionSvc) GetNutritionStats(userID string) (services.NutritionS
 func (m *mockNutritionSvc) GetWeeklyNutritionStats(userID string) ([]services.NutritionStats, error) {
 	return m.weeklyStatsRes, m.weeklyStatsErr
 }
-func (m *mockNutritionSvc) AddWaterLog(userID string, amt int) error {
-	return m.addWaterLogErr
+func (m *mockNutritionSvc) AddWaterLog(userID string, amt int, key string) (services.WaterLog, error) {
+	return services.WaterLog{UserID: userID, AmountML: amt, IdempotencyKey: key}, m.addWaterLogErr
 }
 func (m *mockNutritionSvc) GetTodayWaterStats(userID string) (services.DailyWaterStats, error) {
 	return m.todayWaterStatsRes, m.todayWaterStatsErr
@@ -69,41 +73,78 @@ func (m *mockNutritionSvc) GetCalorieGoal(userID string) (int, error) {
 	return m.getCalorieGoalRes, m.getCalorieGoalErr
 }
 
+// mockChallengeSvc records what the nutrition handlers put on the
+// progress ledger; the challenge handlers have their own fake.
 type mockChallengeSvc struct {
-	createRes      models.Challenge
-	createErr      error
-	joinErr        error
-	leaderboardRes []models.ChallengeParticipant
-	leaderboardErr error
-	listForUserRes []models.Challenge
-	listForUserErr error
-	bumpErr        error
+	recordErr error
+	meals     []models.Meal
+	water     []models.WaterLog
+	recounts  int
 }
 
-func (m *mockChallengeSvc) Create(
-	creatorID, title, ctype string, target int, friends []string,
-) (models.Challenge, error) {
-	return m.createRes, m.createErr
+func (m *mockChallengeSvc) Create(creatorID string, in services.ChallengeInput) (models.Challenge, error) {
+	return models.Challenge{}, nil
 }
 
 func (m *mockChallengeSvc) Join(chID, userID string) error {
-	return m.joinErr
+	return nil
 }
 
-func (m *mockChallengeSvc) UpdateProgress(userID, activityType string, delta int) {
-	// no return
+func (m *mockChallengeSvc) Invite(chID, inviterID string, invitees []string) ([]models.ChallengeInvitation, error) {
+	return nil, nil
 }
 
-func (m *mockChallengeSvc) Leaderboard(chID string) ([]models.ChallengeParticipant, error) {
-	return m.leaderboardRes, m.leaderboardErr
+func (m *mockChallengeSvc) Invitations(userID, status string) ([]models.ChallengeInvitation, error) {
+	return nil, nil
+}
+
+func (m *mockChallengeSvc) RespondInvitation(userID, invitationID string, accept bool) error {
+	return nil
+}
+
+func (m *mockChallengeSvc) Leaderboard(chID, viewerID string, q services.LeaderboardQuery) (services.LeaderboardPage, error) {
+	return services.LeaderboardPage{}, nil
+}
+
+func (m *mockChallengeSvc) TeamLeaderboard(chID, viewerID string) ([]services.TeamStanding, error) {
+	return nil, nil
+}
+
+func (m *mockChallengeSvc) Discover(userID string, q services.DiscoverQuery) (services.DiscoverPage, error) {
+	return services.DiscoverPage{}, nil
 }
 
 func (m *mockChallengeSvc) ListForUser(userID string) ([]models.Challenge, error) {
-	return m.listForUserRes, m.listForUserErr
+	return nil, nil
+}
+
+func (m *mockChallengeSvc) RecordActivity(a models.Activity) error { return m.recordErr }
+func (m *mockChallengeSvc) RecordMeal(meal models.Meal) error {
+	m.meals = append(m.meals, meal)
+	return m.recordErr
+}
+func (m *mockChallengeSvc) RecordWater(l models.WaterLog) error {
+	m.water = append(m.water, l)
+	return m.recordErr
+}
+func (m *mockChallengeSvc) RecountDays(userID string) error {
+	m.recounts++
+	return nil
 }
+func (m *mockChallengeSvc) Advance(now time.Time) error { return nil }
 
-func (m *mockChallengeSvc) BumpProgress(userID, ctype string, delta int) error {
-	return m.bumpErr
+type mockAuditSvc struct {
+	entries []services.AuditEntry
+}
+
+func (m *mockAuditSvc) Record(e services.AuditEntry) {
+	m.entries = append(m.entries, e)
+}
+func (m *mockAuditSvc) ListForUser(userID string, limit, offset int) ([]models.AuditEvent, error) {
+	return nil, nil
+}
+func (m *mockAuditSvc) List(f repository.AuditFilter) ([]models.AuditEvent, error) {
+	return nil, nil
 }
 
 func setup(r *gin.Engine, body []byte, method, path string, userID interface{}) (*httptest.ResponseRecorder, *gin.Context) {
@@ -132,6 +173,8 @@ func TestAddMeal_Success(t *testing.T) {
 
 	w, _ := setup(r, b, "POST", "/", "u1")
 	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, mockC.meals, 1)
+	assert.Equal(t, 123.0, mockC.meals[0].Calories)
 }
 
 func TestAddMeal_BadJSON(t *testing.T) {
@@ -234,6 +277,8 @@ func TestAddWaterLog(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockN := &mockNutritionSvc{}
 	services.Nutrition = mockN
+	mockC := &mockChallengeSvc{}
+	services.Challenge = mockC
 	r := gin.New()
 	r.POST("/", AddWaterLog)
 
@@ -241,6 +286,9 @@ func TestAddWaterLog(t *testing.T) {
 	b, _ := json.Marshal(waterLogRequest{Amount: 500})
 	w, _ := setup(r, b, "POST", "/", "u1")
 	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.Len(t, mockC.water, 1) {
+		assert.Equal(t, 500, mockC.water[0].AmountML)
+	}
 
 	// bad JSON
 	w, _ = setup(r, []byte(`bad`), "POST", "/", "u1")
@@ -290,6 +338,10 @@ func TestSetWaterGoal(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockN := &mockNutritionSvc{}
 	services.Nutrition = mockN
+	audit := &mockAuditSvc{}
+	services.Audit = audit
+	mockC := &mockChallengeSvc{}
+	services.Challenge = mockC
 	r := gin.New()
 	r.POST("/", SetWaterGoal)
 
@@ -297,6 +349,11 @@ func TestSetWaterGoal(t *testing.T) {
 	b, _ := json.Marshal(map[string]int{"goal_ml": 1500})
 	w, _ := setup(r, b, "POST", "/", "u1")
 	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, mockC.recounts, "days goal met are recounted")
+	if assert.Len(t, audit.entries, 1) {
+		assert.Equal(t, "water", audit.entries[0].TargetID)
+		assert.Equal(t, gin.H{"goal_ml": 1500}, audit.entries[0].After)
+	}
 	var resp map[string]bool
 	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
 	assert.True(t, resp["success"])
@@ -337,6 +394,8 @@ func TestSetCalorieGoal(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockN := &mockNutritionSvc{}
 	services.Nutrition = mockN
+	audit := &mockAuditSvc{}
+	services.Audit = audit
 	r := gin.New()
 	r.POST("/", SetCalorieGoal)
 
@@ -344,6 +403,9 @@ func TestSetCalorieGoal(t *testing.T) {
 	b, _ := json.Marshal(map[string]int{"goal": 1800})
 	w, _ := setup(r, b, "POST", "/", "u1")
 	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.Len(t, audit.entries, 1) {
+		assert.Equal(t, "calories", audit.entries[0].TargetID)
+	}
 	var resp map[string]bool
 	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
 	assert.True(t, resp["success"])
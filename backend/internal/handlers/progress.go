@@ -0,0 +1,11 @@
+package handlers
+
+import "github.com/gin-gonic/gin"
+
+// IdempotencyKey returns the client's Idempotency-Key header, or "" when
+// it sent none. Logging requests store it with the entry, so a retried
+// request returns the entry the first attempt logged and neither the log
+// nor challenge progress counts it twice.
+func IdempotencyKey(c *gin.Context) string {
+	return c.GetHeader("Idempotency-Key")
+}
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+)
+
+// ListAccessTokens returns the user's active personal access tokens and
+// the scopes a new one can be granted.
+func ListAccessTokens(c *gin.Context) {
+	list, err := services.AccessTokens.List(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot load access tokens"})
+		return
+	}
+	if list == nil {
+		list = []models.AccessToken{}
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": list, "scopes": models.AllScopes})
+}
+
+// CreateAccessToken issues a personal access token. The secret is only
+// part of this response.
+func CreateAccessToken(c *gin.Context) {
+	var in services.AccessTokenInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t, err := services.AccessTokens.Create(c.GetString("userID"), in)
+	switch {
+	case errors.Is(err, services.ErrInvalidScope), errors.Is(err, services.ErrInvalidExpiry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot create access token"})
+		return
+	}
+	handlers.Audit(c, services.AuditAccessTokenCreate, "access_token", t.ID, nil,
+		gin.H{"name": t.Name, "scopes": t.Scopes, "expiresAt": t.ExpiresAt})
+	c.JSON(http.StatusCreated, t)
+}
+
+// RevokeAccessToken revokes one of the user's personal access tokens.
+func RevokeAccessToken(c *gin.Context) {
+	id := c.Param("id")
+	err := services.AccessTokens.Revoke(c.GetString("userID"), id)
+	if errors.Is(err, services.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "access token not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot revoke access token"})
+		return
+	}
+	handlers.Audit(c, services.AuditAccessTokenRevoke, "access_token", id, nil, nil)
+	c.Status(http.StatusOK)
+}
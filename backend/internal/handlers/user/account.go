@@ -0,0 +1,113 @@
+package user
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+)
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ForgotPassword mails a reset link in the background. It answers 202
+// whether or not the address has an account.
+func ForgotPassword(c *gin.Context) {
+	var req forgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	services.User.RequestPasswordReset(req.Email)
+	c.Status(http.StatusAccepted)
+}
+
+// ResetPassword sets a new password using a mailed token.
+func ResetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err := services.User.ResetPassword(req.Token, req.Password)
+	if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrWeakPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot reset password"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// ChangePassword replaces the current user's password. Other sessions are
+// signed out; the one making the request stays signed in.
+func ChangePassword(c *gin.Context) {
+	var req changePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err := services.User.ChangePassword(c.GetString("userID"), c.GetString("sessionID"), req.CurrentPassword, req.NewPassword)
+	switch {
+	case errors.Is(err, services.ErrWrongPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrWeakPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("ChangePassword error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot change password"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// VerifyEmail confirms the address the mailed token was sent to.
+func VerifyEmail(c *gin.Context) {
+	var req verifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err := services.User.VerifyEmail(req.Token)
+	if errors.Is(err, services.ErrInvalidToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot verify email"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// ResendVerification mails a new verification link to the current user.
+func ResendVerification(c *gin.Context) {
+	userID := c.GetString("userID")
+	if err := services.User.SendVerificationEmail(userID); err != nil {
+		log.Printf("SendVerificationEmail error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot send verification email"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,32 @@
+package user
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+)
+
+// ListAuditEvents returns the audit trail of the user's account, newest
+// first (?limit=, ?offset=).
+func ListAuditEvents(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	list, err := services.Audit.ListForUser(c.GetString("userID"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot load audit log"})
+		return
+	}
+	if list == nil {
+		list = []models.AuditEvent{}
+	}
+	c.JSON(http.StatusOK, list)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
 )
 
@@ -53,6 +54,8 @@ func AcceptFriendRequest(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot accept request"})
 		return
 	}
+	handlers.Audit(c, services.AuditFriendAccept, "friend_request", requestID,
+		gin.H{"status": "pending"}, gin.H{"status": "accepted"})
 	log.Println("Friend request accepted for user", userID)
 	c.Status(http.StatusOK)
 }
@@ -65,6 +68,8 @@ func DeclineFriendRequest(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot decline request"})
 		return
 	}
+	handlers.Audit(c, services.AuditFriendDecline, "friend_request", requestID,
+		gin.H{"status": "pending"}, gin.H{"status": "declined"})
 	c.Status(http.StatusOK)
 }
 
@@ -2,6 +2,7 @@
 package user
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -20,13 +21,22 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	token, err := services.User.Authenticate(req.Email, req.Password)
+	res, err := services.User.Authenticate(req.Email, req.Password, clientInfo(c))
+	if errors.Is(err, services.ErrAccountSuspended) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
-	//log.Printf("JWT after login for %s: %s", req.Email, token)
 
-	// Return the token in JSON so the client can store it
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	// With 2FA on, the client has to send a code along with the challenge
+	if res.Challenge != nil {
+		c.JSON(http.StatusAccepted, res.Challenge)
+		return
+	}
+
+	// Return the access and refresh tokens so the client can store them
+	c.JSON(http.StatusOK, res.Tokens)
 }
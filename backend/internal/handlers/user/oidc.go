@@ -0,0 +1,102 @@
+package user
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+)
+
+type oidcCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// ListOIDCProviders names the external providers users can sign in with.
+func ListOIDCProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": services.User.OIDCProviders()})
+}
+
+// StartOIDC returns the provider URL the client should open to sign in.
+func StartOIDC(c *gin.Context) {
+	url, err := services.User.StartOIDC(c.Param("provider"))
+	if errors.Is(err, services.ErrUnknownProvider) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("StartOIDC error: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "provider unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"authUrl": url})
+}
+
+// FinishOIDC takes the code and state the provider redirected back with
+// and answers like Login.
+func FinishOIDC(c *gin.Context) {
+	var req oidcCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := services.User.FinishOIDC(c.Param("provider"), req.State, req.Code, clientInfo(c))
+	switch {
+	case errors.Is(err, services.ErrUnknownProvider):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrAccountSuspended):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrOIDCFailed), errors.Is(err, services.ErrEmailNotVerified):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrProviderLinked), errors.Is(err, services.ErrEmailTaken),
+		errors.Is(err, services.ErrAccountUnverified):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("FinishOIDC error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot sign in"})
+		return
+	}
+
+	if res.Challenge != nil {
+		c.JSON(http.StatusAccepted, res.Challenge)
+		return
+	}
+	c.JSON(http.StatusOK, res.Tokens)
+}
+
+// ListIdentities returns the provider accounts linked to the user.
+func ListIdentities(c *gin.Context) {
+	list, err := services.User.ListIdentities(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot load linked accounts"})
+		return
+	}
+	if list == nil {
+		list = []models.Identity{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// UnlinkIdentity removes a linked provider account.
+func UnlinkIdentity(c *gin.Context) {
+	err := services.User.UnlinkIdentity(c.GetString("userID"), c.Param("id"))
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "linked account not found"})
+		return
+	case errors.Is(err, services.ErrLastLoginMethod):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot unlink account"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
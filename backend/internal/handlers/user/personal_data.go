@@ -0,0 +1,67 @@
+package user
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+)
+
+type deleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// ExportData streams a ZIP of everything stored about the user.
+func ExportData(c *gin.Context) {
+	data, err := services.PersonalData.Export(c.GetString("userID"))
+	if err != nil {
+		log.Printf("ExportData error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot export data"})
+		return
+	}
+	name := "healthy-summer-export-" + time.Now().UTC().Format("20060102") + ".zip"
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	c.Status(http.StatusOK)
+	if err := services.WriteArchive(c.Writer, data); err != nil {
+		// headers are gone already; the client sees a truncated archive
+		log.Printf("ExportData write error: %v", err)
+	}
+}
+
+// DeleteAccount schedules the account for erasure after the grace period.
+// Until then it keeps working and RestoreAccount undoes the request.
+func DeleteAccount(c *gin.Context) {
+	var req deleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	at, err := services.PersonalData.RequestDeletion(c.GetString("userID"), req.Password)
+	switch {
+	case errors.Is(err, services.ErrWrongPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("DeleteAccount error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot delete account"})
+		return
+	}
+	handlers.Audit(c, services.AuditDeletionRequested, "user", c.GetString("userID"), nil, gin.H{"deleteAfter": at})
+	c.JSON(http.StatusAccepted, gin.H{"deleteAfter": at})
+}
+
+// RestoreAccount cancels a pending deletion.
+func RestoreAccount(c *gin.Context) {
+	if err := services.PersonalData.CancelDeletion(c.GetString("userID")); err != nil {
+		log.Printf("RestoreAccount error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot restore account"})
+		return
+	}
+	handlers.Audit(c, services.AuditDeletionCancelled, "user", c.GetString("userID"), nil, nil)
+	c.Status(http.StatusOK)
+}
@@ -1,9 +1,11 @@
 package user
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
 )
 
@@ -24,9 +26,22 @@ func UpdateProfile(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := services.User.UpdateProfile(userID, input); err != nil {
+	before, err := services.User.GetProfile(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot load profile"})
+		return
+	}
+	if err := services.User.UpdateProfile(userID, input); errors.Is(err, services.ErrInvalidTimezone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot update"})
 		return
 	}
+	after, err := services.User.GetProfile(userID)
+	if err != nil {
+		after = before
+	}
+	handlers.Audit(c, services.AuditProfileUpdate, "user", userID, before, after)
 	c.Status(http.StatusOK)
 }
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"errors"
 	"log"
 	"net/http"
 
@@ -14,7 +15,7 @@ type registerRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// Register handles user registration and returns a JWT
+// Register handles user registration and returns an access/refresh token pair
 func Register(c *gin.Context) {
 	var req registerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -24,6 +25,14 @@ func Register(c *gin.Context) {
 
 	// Create the user
 	userID, err := services.User.CreateUser(req.Name, req.Email, req.Password)
+	if errors.Is(err, services.ErrWeakPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		log.Printf("CreateUser error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -36,14 +45,16 @@ func Register(c *gin.Context) {
 	log.Printf("Awarded 'Welcome!' achievement to user %s", req.Email)
 
 	// Authenticate to generate JWT
-	token, err := services.User.Authenticate(req.Email, req.Password)
+	res, err := services.User.Authenticate(req.Email, req.Password, clientInfo(c))
+	if err == nil && res.Tokens == nil {
+		err = errors.New("new account asked for a second factor")
+	}
 	if err != nil {
 		log.Printf("Authenticate after register error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 		return
 	}
-	//log.Printf("JWT after registration for %s: %s", req.Email, token)
 
-	// Return token in response
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	// Return tokens in response
+	c.JSON(http.StatusOK, res.Tokens)
 }
@@ -0,0 +1,35 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+)
+
+// ListSessions returns the devices the user is signed in on.
+func ListSessions(c *gin.Context) {
+	userID := c.GetString("userID")
+	list, err := services.User.ListSessions(userID, c.GetString("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot load sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// RevokeSession signs one device out and closes its live connections.
+func RevokeSession(c *gin.Context) {
+	userID := c.GetString("userID")
+	err := services.User.RevokeSession(userID, c.Param("id"))
+	if errors.Is(err, services.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot revoke session"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,59 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// RefreshToken exchanges a refresh token for a new access/refresh pair.
+// The old refresh token stops working.
+func RefreshToken(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := services.User.RefreshToken(req.RefreshToken, clientInfo(c))
+	if errors.Is(err, services.ErrInvalidRefreshToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	if errors.Is(err, services.ErrAccountSuspended) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot refresh token"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout revokes the refresh token and every token rotated from it.
+// Access tokens already handed out stay valid until they expire.
+func Logout(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.User.Logout(req.RefreshToken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot log out"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// clientInfo describes the device behind the request for session records.
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
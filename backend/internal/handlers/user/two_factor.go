@@ -0,0 +1,119 @@
+package user
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+)
+
+type loginTwoFactorRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type codeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type disableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// LoginTwoFactor completes a login that answered with a challenge, taking
+// a code from the authenticator app or a recovery code.
+func LoginTwoFactor(c *gin.Context) {
+	var req loginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tokens, err := services.User.VerifyLogin(req.ChallengeToken, req.Code, clientInfo(c))
+	if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrInvalidCode) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		twoFactorError(c, err, "cannot log in")
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// EnrollTwoFactor returns a new TOTP secret and its otpauth URI.
+func EnrollTwoFactor(c *gin.Context) {
+	enrollment, err := services.User.EnrollTOTP(c.GetString("userID"))
+	if err != nil {
+		twoFactorError(c, err, "cannot enroll")
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTwoFactor turns 2FA on with a first code from the app and returns
+// the recovery codes.
+func ConfirmTwoFactor(c *gin.Context) {
+	var req codeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	codes, err := services.User.ConfirmTOTP(c.GetString("userID"), req.Code)
+	if err != nil {
+		twoFactorError(c, err, "cannot confirm")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// DisableTwoFactor turns 2FA off; it needs the password and a code.
+func DisableTwoFactor(c *gin.Context) {
+	var req disableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.User.DisableTOTP(c.GetString("userID"), req.Password, req.Code); err != nil {
+		twoFactorError(c, err, "cannot disable")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req codeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	codes, err := services.User.RegenerateRecoveryCodes(c.GetString("userID"), req.Code)
+	if err != nil {
+		twoFactorError(c, err, "cannot create recovery codes")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// twoFactorError answers with the status matching a 2FA service error.
+func twoFactorError(c *gin.Context, err error, msg string) {
+	var locked *services.LockedError
+	switch {
+	case errors.As(err, &locked):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrWrongPassword), errors.Is(err, services.ErrInvalidCode),
+		errors.Is(err, services.ErrAccountSuspended):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTwoFactorEnabled), errors.Is(err, services.ErrTwoFactorDisabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("two-factor error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+	}
+}
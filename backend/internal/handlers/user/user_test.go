@@ -2,16 +2,20 @@
 package user
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
 )
 
@@ -29,8 +33,28 @@ type mockUserSvc struct {
 	pendingRequests []services.Friend
 	pendingErr      error
 
-	authToken string
-	authErr   error
+	authToken    string
+	authErr      error
+	refreshToken string
+	refreshErr   error
+	logoutErr    error
+	challenge    *services.LoginChallenge
+
+	recoveryCodes []string
+	twoFactorErr  error
+
+	oidcErr    error
+	identities []models.Identity
+	unlinkErr  error
+
+	sessions    []services.Session
+	sessionsErr error
+	revokeErr   error
+
+	resetErr  error
+	resendErr error
+	verifyErr error
+	changeErr error
 
 	profile    services.UserProfile
 	profileErr error
@@ -62,8 +86,70 @@ func (m *mockUserSvc) DeclineFriendRequest(userID, requestID string) error {
 func (m *mockUserSvc) ListPendingFriendRequests(userID string) ([]services.Friend, error) {
 	return m.pendingRequests, m.pendingErr
 }
-func (m *mockUserSvc) Authenticate(email, pass string) (string, error) {
-	return m.authToken, m.authErr
+func (m *mockUserSvc) Authenticate(email, pass string, client services.ClientInfo) (services.LoginResult, error) {
+	if m.challenge != nil {
+		return services.LoginResult{Challenge: m.challenge}, m.authErr
+	}
+	return services.LoginResult{Tokens: &services.TokenPair{Token: m.authToken, RefreshToken: m.refreshToken}}, m.authErr
+}
+func (m *mockUserSvc) VerifyLogin(challengeToken, code string, client services.ClientInfo) (services.TokenPair, error) {
+	return services.TokenPair{Token: m.authToken}, m.twoFactorErr
+}
+func (m *mockUserSvc) OIDCProviders() []string { return []string{"mock"} }
+func (m *mockUserSvc) StartOIDC(provider string) (string, error) {
+	return "https://issuer.test/authorize?state=s", m.oidcErr
+}
+func (m *mockUserSvc) FinishOIDC(provider, state, code string, client services.ClientInfo) (services.LoginResult, error) {
+	return m.Authenticate("", "", client)
+}
+func (m *mockUserSvc) ListIdentities(userID string) ([]models.Identity, error) {
+	return m.identities, nil
+}
+func (m *mockUserSvc) UnlinkIdentity(userID, id string) error {
+	return m.unlinkErr
+}
+func (m *mockUserSvc) EnrollTOTP(userID string) (services.TOTPEnrollment, error) {
+	return services.TOTPEnrollment{Secret: "SECRET", URI: "otpauth://totp/x"}, m.twoFactorErr
+}
+func (m *mockUserSvc) ConfirmTOTP(userID, code string) ([]string, error) {
+	return m.recoveryCodes, m.twoFactorErr
+}
+func (m *mockUserSvc) DisableTOTP(userID, pass, code string) error {
+	return m.twoFactorErr
+}
+func (m *mockUserSvc) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	return m.recoveryCodes, m.twoFactorErr
+}
+func (m *mockUserSvc) RefreshToken(refreshToken string, client services.ClientInfo) (services.TokenPair, error) {
+	return services.TokenPair{Token: m.authToken, RefreshToken: m.refreshToken}, m.refreshErr
+}
+func (m *mockUserSvc) Logout(refreshToken string) error {
+	return m.logoutErr
+}
+func (m *mockUserSvc) CheckSession(sessionID, ip string) error {
+	return nil
+}
+func (m *mockUserSvc) ListSessions(userID, currentID string) ([]services.Session, error) {
+	return m.sessions, m.sessionsErr
+}
+func (m *mockUserSvc) RevokeSession(userID, sessionID string) error {
+	return m.revokeErr
+}
+func (m *mockUserSvc) SignOutEverywhere(userID string) error {
+	return nil
+}
+func (m *mockUserSvc) RequestPasswordReset(email string) {}
+func (m *mockUserSvc) ResetPassword(token, newPassword string) error {
+	return m.resetErr
+}
+func (m *mockUserSvc) ChangePassword(userID, sessionID, current, next string) error {
+	return m.changeErr
+}
+func (m *mockUserSvc) SendVerificationEmail(userID string) error {
+	return m.resendErr
+}
+func (m *mockUserSvc) VerifyEmail(token string) error {
+	return m.verifyErr
 }
 func (m *mockUserSvc) GetProfile(userID string) (services.UserProfile, error) {
 	return m.profile, m.profileErr
@@ -78,8 +164,8 @@ func (m *mockUserSvc) AwardAchievementToUserID(userID, title string) error {
 	return m.awardErr
 }
 
-func (m *mockUserSvc) AddActivity(userID, activityType, name string, duration int, intensity string, calories int, location string) error {
-	return nil
+func (m *mockUserSvc) AddActivity(userID, activityType, name string, duration int, intensity string, calories int, location string, distance int, idempotencyKey string) (models.Activity, error) {
+	return models.Activity{}, nil
 }
 func (m *mockUserSvc) ListActivities(userID string, filterType *string) ([]models.Activity, error) {
 	return nil, nil
@@ -103,8 +189,63 @@ func (m *mockUserSvc) IDByEmail(email string) (string, error) {
 	return "", nil
 }
 
+type mockAuditSvc struct {
+	entries []services.AuditEntry
+	events  []models.AuditEvent
+}
+
+func (m *mockAuditSvc) Record(e services.AuditEntry) {
+	m.entries = append(m.entries, e)
+}
+func (m *mockAuditSvc) ListForUser(userID string, limit, offset int) ([]models.AuditEvent, error) {
+	return m.events, nil
+}
+func (m *mockAuditSvc) List(f repository.AuditFilter) ([]models.AuditEvent, error) {
+	return m.events, nil
+}
+
+type mockPersonalDataSvc struct {
+	data      models.PersonalData
+	deleteErr error
+	cancelled bool
+}
+
+func (m *mockPersonalDataSvc) Export(userID string) (models.PersonalData, error) {
+	return m.data, nil
+}
+func (m *mockPersonalDataSvc) RequestDeletion(userID, password string) (time.Time, error) {
+	return time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC), m.deleteErr
+}
+func (m *mockPersonalDataSvc) CancelDeletion(userID string) error {
+	m.cancelled = true
+	return nil
+}
+func (m *mockPersonalDataSvc) PurgeDue(now time.Time) (int, error) { return 0, nil }
+func (m *mockPersonalDataSvc) StartPurger()                        {}
+
+type mockAccessTokenSvc struct {
+	tokens    []models.AccessToken
+	created   services.AccessTokenInput
+	createErr error
+	revokeErr error
+}
+
+func (m *mockAccessTokenSvc) Create(userID string, in services.AccessTokenInput) (services.NewAccessToken, error) {
+	m.created = in
+	t := models.AccessToken{ID: "t1", UserID: userID, Name: in.Name, Scopes: in.Scopes}
+	return services.NewAccessToken{AccessToken: t, Token: "hsp_secret"}, m.createErr
+}
+func (m *mockAccessTokenSvc) List(userID string) ([]models.AccessToken, error) {
+	return m.tokens, nil
+}
+func (m *mockAccessTokenSvc) Revoke(userID, id string) error { return m.revokeErr }
+func (m *mockAccessTokenSvc) Authenticate(secret string) (models.AccessToken, models.User, error) {
+	return models.AccessToken{}, models.User{}, services.ErrInvalidAccessToken
+}
+
 func setupRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
+	services.Audit = &mockAuditSvc{}
 	r := gin.New()
 
 	r.GET("/achievements/all", ListAllAchievements)
@@ -117,9 +258,35 @@ func setupRouter() *gin.Engine {
 	r.GET("/friends", ListFriends)
 
 	r.POST("/login", Login)
+	r.POST("/login/2fa", LoginTwoFactor)
+	r.POST("/2fa/enroll", EnrollTwoFactor)
+	r.POST("/2fa/confirm", ConfirmTwoFactor)
+	r.POST("/2fa/disable", DisableTwoFactor)
+	r.POST("/2fa/recovery-codes", RegenerateRecoveryCodes)
 	r.GET("/profile", GetProfile)
 	r.PUT("/profile", UpdateProfile)
 	r.POST("/register", Register)
+	r.POST("/token/refresh", RefreshToken)
+	r.POST("/logout", Logout)
+	r.GET("/sessions", ListSessions)
+	r.POST("/password/forgot", ForgotPassword)
+	r.POST("/password/reset", ResetPassword)
+	r.PUT("/password", ChangePassword)
+	r.POST("/email/verify", VerifyEmail)
+	r.POST("/email/verify/resend", ResendVerification)
+	r.DELETE("/sessions/:id", RevokeSession)
+	r.GET("/audit", ListAuditEvents)
+	r.GET("/export", ExportData)
+	r.GET("/oidc", ListOIDCProviders)
+	r.GET("/oidc/:provider/start", StartOIDC)
+	r.POST("/oidc/:provider/callback", FinishOIDC)
+	r.GET("/identities", ListIdentities)
+	r.DELETE("/identities/:id", UnlinkIdentity)
+	r.DELETE("/me", DeleteAccount)
+	r.POST("/me/restore", RestoreAccount)
+	r.GET("/tokens", ListAccessTokens)
+	r.POST("/tokens", CreateAccessToken)
+	r.DELETE("/tokens/:id", RevokeAccessToken)
 
 	return r
 }
@@ -206,9 +373,15 @@ func TestAcceptDeclineFriendRequest(t *testing.T) {
 	mock := &mockUserSvc{}
 	services.User = mock
 	r := setupRouter()
+	audit := &mockAuditSvc{}
+	services.Audit = audit
 
 	w := performRequest(r, "POST", "/friends/requests/r1/accept", nil, "u1")
 	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.Len(t, audit.entries, 1) {
+		assert.Equal(t, services.AuditFriendAccept, audit.entries[0].Action)
+		assert.Equal(t, "r1", audit.entries[0].TargetID)
+	}
 
 	mock.acceptErr = errors.New("fail")
 	w = performRequest(r, "POST", "/friends/requests/r1/accept", nil, "u1")
@@ -255,7 +428,7 @@ func TestLogin(t *testing.T) {
 	// success with valid email
 	w := performRequest(r, "POST", "/login", gin.H{"email": "a@b.com", "password": "p"}, "")
 	assert.Equal(t, http.StatusOK, w.Code)
-	var tok map[string]string
+	var tok map[string]interface{}
 	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &tok))
 	assert.Equal(t, "tok", tok["token"])
 
@@ -267,6 +440,87 @@ func TestLogin(t *testing.T) {
 	mock.authErr = errors.New("fail")
 	w = performRequest(r, "POST", "/login", gin.H{"email": "a@b.com", "password": "p"}, "")
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// suspended account
+	mock.authErr = services.ErrAccountSuspended
+	w = performRequest(r, "POST", "/login", gin.H{"email": "a@b.com", "password": "p"}, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 2FA on: a challenge instead of tokens
+	mock.authErr = nil
+	mock.challenge = &services.LoginChallenge{TwoFactorRequired: true, ChallengeToken: "ch"}
+	w = performRequest(r, "POST", "/login", gin.H{"email": "a@b.com", "password": "p"}, "")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var challenge map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &challenge))
+	assert.Equal(t, "ch", challenge["challengeToken"])
+	assert.Nil(t, challenge["token"])
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	mock := &mockUserSvc{authToken: "tok"}
+	services.User = mock
+	r := setupRouter()
+	body := gin.H{"challengeToken": "ch", "code": "123456"}
+
+	w := performRequest(r, "POST", "/login/2fa", body, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var tok map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &tok))
+	assert.Equal(t, "tok", tok["token"])
+
+	w = performRequest(r, "POST", "/login/2fa", gin.H{"challengeToken": "ch"}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mock.twoFactorErr = &services.LockedError{RetryAfter: time.Minute}
+	w = performRequest(r, "POST", "/login/2fa", body, "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	for err, code := range map[error]int{
+		services.ErrInvalidToken:     http.StatusUnauthorized,
+		services.ErrInvalidCode:      http.StatusUnauthorized,
+		services.ErrAccountSuspended: http.StatusForbidden,
+		errors.New("db"):             http.StatusInternalServerError,
+	} {
+		mock.twoFactorErr = err
+		w = performRequest(r, "POST", "/login/2fa", body, "")
+		assert.Equal(t, code, w.Code, err.Error())
+	}
+}
+
+func TestTwoFactorSetup(t *testing.T) {
+	mock := &mockUserSvc{recoveryCodes: []string{"aaaaa-bbbbb"}}
+	services.User = mock
+	r := setupRouter()
+
+	w := performRequest(r, "POST", "/2fa/enroll", nil, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var enrollment services.TOTPEnrollment
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &enrollment))
+	assert.Equal(t, "SECRET", enrollment.Secret)
+
+	w = performRequest(r, "POST", "/2fa/confirm", gin.H{"code": "123456"}, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var out struct{ RecoveryCodes []string }
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+	assert.Equal(t, []string{"aaaaa-bbbbb"}, out.RecoveryCodes)
+
+	w = performRequest(r, "POST", "/2fa/recovery-codes", gin.H{"code": "123456"}, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(r, "POST", "/2fa/disable", gin.H{"password": "p", "code": "123456"}, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(r, "POST", "/2fa/disable", gin.H{"code": "123456"}, "u1")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mock.twoFactorErr = services.ErrTwoFactorEnabled
+	assert.Equal(t, http.StatusConflict, performRequest(r, "POST", "/2fa/enroll", nil, "u1").Code)
+	mock.twoFactorErr = services.ErrInvalidCode
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "POST", "/2fa/confirm", gin.H{"code": "1"}, "u1").Code)
+	mock.twoFactorErr = services.ErrWrongPassword
+	assert.Equal(t, http.StatusForbidden, performRequest(r, "POST", "/2fa/disable", gin.H{"password": "x", "code": "1"}, "u1").Code)
+	mock.twoFactorErr = services.ErrTwoFactorDisabled
+	assert.Equal(t, http.StatusConflict, performRequest(r, "POST", "/2fa/recovery-codes", gin.H{"code": "1"}, "u1").Code)
 }
 
 func TestGetUpdateProfile(t *testing.T) {
@@ -288,15 +542,36 @@ func TestGetUpdateProfile(t *testing.T) {
 	w = performRequest(r, "GET", "/profile", nil, "u1")
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 
-	// PUT profile success
+	// PUT profile success, audited with snapshots
+	audit := &mockAuditSvc{}
+	services.Audit = audit
 	mock.profileErr = nil
 	w = performRequest(r, "PUT", "/profile", gin.H{"name": "X"}, "u1")
 	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.Len(t, audit.entries, 1) {
+		assert.Equal(t, services.AuditProfileUpdate, audit.entries[0].Action)
+		assert.Equal(t, mock.profile, audit.entries[0].Before)
+	}
 
-	// PUT profile error
+	// PUT profile error is not audited
 	mock.updateErr = errors.New("fail")
 	w = performRequest(r, "PUT", "/profile", gin.H{"name": "X"}, "u1")
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Len(t, audit.entries, 1)
+}
+
+func TestListAuditEvents(t *testing.T) {
+	r := setupRouter()
+	w := performRequest(r, "GET", "/audit", nil, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+
+	services.Audit = &mockAuditSvc{events: []models.AuditEvent{{ID: "e1", Action: services.AuditLogin}}}
+	w = performRequest(r, "GET", "/audit?limit=5", nil, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list []models.AuditEvent
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, services.AuditLogin, list[0].Action)
 }
 
 func TestRegister(t *testing.T) {
@@ -312,7 +587,7 @@ func TestRegister(t *testing.T) {
 		"name": "N", "email": "e@x.com", "password": "p",
 	}, "")
 	assert.Equal(t, http.StatusOK, w.Code)
-	var out map[string]string
+	var out map[string]interface{}
 	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
 	assert.Equal(t, "tok", out["token"])
 
@@ -327,6 +602,18 @@ func TestRegister(t *testing.T) {
 	}, "")
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 
+	// weak password and taken email
+	mock.createErr = services.ErrWeakPassword
+	w = performRequest(r, "POST", "/register", gin.H{
+		"name": "N", "email": "e@x.com", "password": "p",
+	}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mock.createErr = services.ErrEmailTaken
+	w = performRequest(r, "POST", "/register", gin.H{
+		"name": "N", "email": "e@x.com", "password": "p",
+	}, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
 	// auth error
 	mock.createErr = nil
 	mock.authErr = errors.New("fail")
@@ -335,3 +622,258 @@ func TestRegister(t *testing.T) {
 	}, "")
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
+
+func TestRefreshTokenAndLogout(t *testing.T) {
+	mock := &mockUserSvc{authToken: "tok2", refreshToken: "ref2"}
+	services.User = mock
+	r := setupRouter()
+
+	w := performRequest(r, "POST", "/token/refresh", gin.H{"refreshToken": "ref1"}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var out services.TokenPair
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+	assert.Equal(t, "tok2", out.Token)
+	assert.Equal(t, "ref2", out.RefreshToken)
+
+	w = performRequest(r, "POST", "/token/refresh", gin.H{}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mock.refreshErr = services.ErrInvalidRefreshToken
+	w = performRequest(r, "POST", "/token/refresh", gin.H{"refreshToken": "ref1"}, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	mock.refreshErr = errors.New("db")
+	w = performRequest(r, "POST", "/token/refresh", gin.H{"refreshToken": "ref1"}, "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = performRequest(r, "POST", "/logout", gin.H{"refreshToken": "ref2"}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mock.logoutErr = errors.New("db")
+	w = performRequest(r, "POST", "/logout", gin.H{"refreshToken": "ref2"}, "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestListRevokeSessions(t *testing.T) {
+	mock := &mockUserSvc{
+		sessions: []services.Session{{ID: "s1", UserAgent: "phone", Current: true}},
+	}
+	services.User = mock
+	r := setupRouter()
+
+	w := performRequest(r, "GET", "/sessions", nil, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got []services.Session
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Len(t, got, 1)
+
+	mock.sessionsErr = errors.New("db")
+	w = performRequest(r, "GET", "/sessions", nil, "u1")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = performRequest(r, "DELETE", "/sessions/s1", nil, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mock.revokeErr = services.ErrSessionNotFound
+	w = performRequest(r, "DELETE", "/sessions/s2", nil, "u1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mock.revokeErr = errors.New("db")
+	w = performRequest(r, "DELETE", "/sessions/s1", nil, "u1")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestPasswordResetAndVerification(t *testing.T) {
+	mock := &mockUserSvc{}
+	services.User = mock
+	r := setupRouter()
+
+	w := performRequest(r, "POST", "/password/forgot", gin.H{"email": "a@b.com"}, "")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	w = performRequest(r, "POST", "/password/forgot", gin.H{"email": "nope"}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performRequest(r, "POST", "/password/reset", gin.H{"token": "t", "password": "new"}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	mock.resetErr = services.ErrInvalidToken
+	w = performRequest(r, "POST", "/password/reset", gin.H{"token": "t", "password": "new"}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mock.resetErr = fmt.Errorf("%w: too short", services.ErrWeakPassword)
+	w = performRequest(r, "POST", "/password/reset", gin.H{"token": "t", "password": "new"}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performRequest(r, "POST", "/email/verify", gin.H{"token": "t"}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	mock.verifyErr = services.ErrInvalidToken
+	w = performRequest(r, "POST", "/email/verify", gin.H{"token": "t"}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mock.verifyErr = errors.New("db")
+	w = performRequest(r, "POST", "/email/verify", gin.H{"token": "t"}, "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = performRequest(r, "POST", "/email/verify/resend", nil, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	mock.resendErr = errors.New("smtp down")
+	w = performRequest(r, "POST", "/email/verify/resend", nil, "u1")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestChangePassword(t *testing.T) {
+	mock := &mockUserSvc{}
+	services.User = mock
+	r := setupRouter()
+	body := gin.H{"currentPassword": "old", "newPassword": "new"}
+
+	w := performRequest(r, "PUT", "/password", body, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = performRequest(r, "PUT", "/password", gin.H{"newPassword": "new"}, "u1")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mock.changeErr = services.ErrWrongPassword
+	w = performRequest(r, "PUT", "/password", body, "u1")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	mock.changeErr = fmt.Errorf("%w: too short", services.ErrWeakPassword)
+	w = performRequest(r, "PUT", "/password", body, "u1")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mock.changeErr = errors.New("db")
+	w = performRequest(r, "PUT", "/password", body, "u1")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestExportData(t *testing.T) {
+	services.PersonalData = &mockPersonalDataSvc{data: models.PersonalData{
+		User:  models.User{ID: "u1", Email: "ann@test.com"},
+		Steps: []models.StepDay{{Day: "2025-07-01", Steps: 1200}},
+	}}
+	r := setupRouter()
+
+	w := performRequest(r, "GET", "/export", nil, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	assert.NoError(t, err)
+	names := map[string]bool{}
+	for _, f := range zr.File {
+		names[f.Name] = true
+	}
+	assert.True(t, names["profile.json"])
+	assert.True(t, names["steps.csv"])
+}
+
+func TestDeleteAndRestoreAccount(t *testing.T) {
+	mock := &mockPersonalDataSvc{}
+	services.PersonalData = mock
+	r := setupRouter()
+
+	w := performRequest(r, "DELETE", "/me", gin.H{}, "u1")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performRequest(r, "DELETE", "/me", gin.H{"password": "secret"}, "u1")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), "2025-08-01")
+
+	mock.deleteErr = services.ErrWrongPassword
+	w = performRequest(r, "DELETE", "/me", gin.H{"password": "nope"}, "u1")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = performRequest(r, "POST", "/me/restore", nil, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, mock.cancelled)
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	mock := &mockUserSvc{authToken: "tok"}
+	services.User = mock
+	r := setupRouter()
+
+	w := performRequest(r, "GET", "/oidc", nil, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "mock")
+
+	w = performRequest(r, "GET", "/oidc/mock/start", nil, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "authUrl")
+
+	mock.oidcErr = services.ErrUnknownProvider
+	w = performRequest(r, "GET", "/oidc/other/start", nil, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = performRequest(r, "POST", "/oidc/mock/callback", gin.H{"code": "c"}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performRequest(r, "POST", "/oidc/mock/callback", gin.H{"code": "c", "state": "s"}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "tok")
+
+	for err, code := range map[error]int{
+		services.ErrOIDCFailed:        http.StatusUnauthorized,
+		services.ErrEmailNotVerified:  http.StatusUnauthorized,
+		services.ErrProviderLinked:    http.StatusConflict,
+		services.ErrAccountUnverified: http.StatusConflict,
+		services.ErrAccountSuspended:  http.StatusForbidden,
+	} {
+		mock.authErr = err
+		w = performRequest(r, "POST", "/oidc/mock/callback", gin.H{"code": "c", "state": "s"}, "")
+		assert.Equal(t, code, w.Code, err.Error())
+	}
+}
+
+func TestListUnlinkIdentities(t *testing.T) {
+	mock := &mockUserSvc{identities: []models.Identity{{ID: "i1", Provider: "mock", Email: "a@b.com"}}}
+	services.User = mock
+	r := setupRouter()
+
+	w := performRequest(r, "GET", "/identities", nil, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"provider":"mock"`)
+
+	w = performRequest(r, "DELETE", "/identities/i1", nil, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mock.unlinkErr = services.ErrLastLoginMethod
+	w = performRequest(r, "DELETE", "/identities/i1", nil, "u1")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	mock.unlinkErr = services.ErrNotFound
+	w = performRequest(r, "DELETE", "/identities/nope", nil, "u1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAccessTokens(t *testing.T) {
+	mock := &mockAccessTokenSvc{tokens: []models.AccessToken{{ID: "t0", Name: "watch", Scopes: models.ScopeList{models.ScopeStepsWrite}}}}
+	services.AccessTokens = mock
+	r := setupRouter()
+	audit := services.Audit.(*mockAuditSvc)
+
+	w := performRequest(r, "GET", "/tokens", nil, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"watch"`)
+	assert.Contains(t, w.Body.String(), models.ScopeWellnessRead)
+
+	w = performRequest(r, "POST", "/tokens", gin.H{"name": "watch"}, "u1")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performRequest(r, "POST", "/tokens", gin.H{"name": "watch", "scopes": []string{"steps:write"}}, "u1")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"token":"hsp_secret"`)
+	assert.NotContains(t, w.Body.String(), "tokenHash")
+	assert.Len(t, audit.entries, 1)
+	assert.Equal(t, services.AuditAccessTokenCreate, audit.entries[0].Action)
+
+	mock.createErr = services.ErrInvalidScope
+	w = performRequest(r, "POST", "/tokens", gin.H{"name": "watch", "scopes": []string{"admin"}}, "u1")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performRequest(r, "DELETE", "/tokens/t1", nil, "u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, services.AuditAccessTokenRevoke, audit.entries[1].Action)
+
+	mock.revokeErr = services.ErrNotFound
+	w = performRequest(r, "DELETE", "/tokens/nope", nil, "u1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -1,51 +1,148 @@
 package wellness
 
 import (
+	"errors"
+
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
 )
 
-type createReq struct {
-	Title        string   `json:"title"   binding:"required"`
-	Type         string   `json:"type"    binding:"required,oneof=steps workouts calories"`
-	Target       int      `json:"target"  binding:"required,min=1"`
-	Participants []string `json:"participants"   binding:"required"`
-}
-
 func CreateChallenge(c *gin.Context) {
 	userID := c.GetString("userID")
-	var req struct {
-		Title        string   `json:"title" binding:"required"`
-		Type         string   `json:"type"  binding:"required"`
-		Target       int      `json:"target" binding:"required"`
-		Participants []string `json:"participants"`
-	}
+	var req services.ChallengeInput
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	ch, err := services.Challenge.Create(
-		userID, req.Title, req.Type, req.Target, req.Participants,
-	)
+	ch, err := services.Challenge.Create(userID, req)
+	if errors.Is(err, services.ErrInvalidChallenge) || errors.Is(err, services.ErrInvalidChallengeWindow) || errors.Is(err, services.ErrInvalidTeams) ||
+		errors.Is(err, services.ErrUnknownMetric) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": "cannot create"})
 		return
 	}
+	handlers.Audit(c, services.AuditChallengeCreate, "challenge", ch.ID, nil, ch)
 	c.JSON(201, ch)
 }
 
 func JoinChallenge(c *gin.Context) {
 	userID := c.GetString("userID")
 	chID := c.Param("id")
-	if err := services.Challenge.Join(chID, userID); err != nil {
+	switch err := services.Challenge.Join(chID, userID); {
+	case errors.Is(err, services.ErrNotFound):
+		c.JSON(404, gin.H{"error": "challenge not found"})
+		return
+	case errors.Is(err, services.ErrChallengeForbidden):
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrChallengeFinished):
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		c.JSON(500, gin.H{"error": "cannot join"})
 		return
 	}
+	handlers.Audit(c, services.AuditChallengeJoin, "challenge", chID, nil, gin.H{"participant": userID})
 	c.Status(200)
 }
 
+func InviteToChallenge(c *gin.Context) {
+	userID := c.GetString("userID")
+	chID := c.Param("id")
+	var req struct {
+		Invitees []string `json:"invitees" binding:"required,min=1,max=50"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	list, err := services.Challenge.Invite(chID, userID, req.Invitees)
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		c.JSON(404, gin.H{"error": "challenge not found"})
+		return
+	case errors.Is(err, services.ErrChallengeForbidden):
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrChallengeFinished):
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": "cannot invite"})
+		return
+	}
+	invitees := make([]string, len(list))
+	for i, inv := range list {
+		invitees[i] = inv.InviteeID
+	}
+	handlers.Audit(c, services.AuditChallengeInvite, "challenge", chID, nil, gin.H{"invitees": invitees})
+	// only the count: who was skipped, and why, is not the inviter's business
+	c.JSON(201, gin.H{"invited": len(list)})
+}
+
+// ListInvitations lists the user's invitations, the pending ones unless
+// ?status= asks for accepted, declined or all of them.
+func ListInvitations(c *gin.Context) {
+	userID := c.GetString("userID")
+	status := c.DefaultQuery("status", models.InvitationPending)
+	switch status {
+	case models.InvitationPending, models.InvitationAccepted, models.InvitationDeclined:
+	case "all":
+		status = ""
+	default:
+		c.JSON(400, gin.H{"error": "status must be pending, accepted, declined or all"})
+		return
+	}
+
+	list, err := services.Challenge.Invitations(userID, status)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "cannot load"})
+		return
+	}
+	if list == nil {
+		list = []models.ChallengeInvitation{}
+	}
+	c.JSON(200, list)
+}
+
+func AcceptInvitation(c *gin.Context) { respondInvitation(c, true) }
+
+func DeclineInvitation(c *gin.Context) { respondInvitation(c, false) }
+
+func respondInvitation(c *gin.Context, accept bool) {
+	userID := c.GetString("userID")
+	id := c.Param("id")
+	switch err := services.Challenge.RespondInvitation(userID, id, accept); {
+	case errors.Is(err, services.ErrNotFound):
+		c.JSON(404, gin.H{"error": "invitation not found"})
+		return
+	case errors.Is(err, services.ErrInvitationAnswered), errors.Is(err, services.ErrChallengeFinished):
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": "cannot respond"})
+		return
+	}
+	if accept {
+		handlers.Audit(c, services.AuditChallengeJoin, "challenge_invitation", id, nil, gin.H{"participant": userID})
+	} else {
+		handlers.Audit(c, services.AuditInviteDecline, "challenge_invitation", id, nil, nil)
+	}
+	c.Status(200)
+}
+
+// ListChallengeMetrics lists what challenges can track, by type.
+func ListChallengeMetrics(c *gin.Context) {
+	c.JSON(200, services.ChallengeMetrics())
+}
+
 func ListChallenges(c *gin.Context) {
 	userID := c.GetString("userID")
 	list, err := services.Challenge.ListForUser(userID)
@@ -59,12 +156,69 @@ func ListChallenges(c *gin.Context) {
 	c.JSON(200, list)
 }
 
+// DiscoverChallenges pages through public challenges; see
+// services.DiscoverQuery for the filters.
+func DiscoverChallenges(c *gin.Context) {
+	userID := c.GetString("userID")
+	var q services.DiscoverQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := services.Challenge.Discover(userID, q)
+	if errors.Is(err, services.ErrInvalidDiscovery) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "cannot load"})
+		return
+	}
+	c.JSON(200, page)
+}
+
+// GetLeaderboard pages through the ranked participants, by default
+// around the caller, or with ?view=teams ranks the teams of a team
+// challenge along with what each member contributed.
 func GetLeaderboard(c *gin.Context) {
+	userID := c.GetString("userID")
 	chID := c.Param("id")
-	lb, err := services.Challenge.Leaderboard(chID)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "cannot lb"})
+	switch c.Query("view") {
+	case "", "individual":
+	case "teams":
+		teams, err := services.Challenge.TeamLeaderboard(chID, userID)
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			c.JSON(404, gin.H{"error": "challenge not found"})
+		case errors.Is(err, services.ErrChallengeForbidden):
+			c.JSON(403, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrNotTeamChallenge):
+			c.JSON(400, gin.H{"error": err.Error()})
+		case err != nil:
+			c.JSON(500, gin.H{"error": "cannot lb"})
+		default:
+			c.JSON(200, teams)
+		}
+		return
+	default:
+		c.JSON(400, gin.H{"error": "view must be individual or teams"})
 		return
 	}
-	c.JSON(200, lb)
+
+	var q services.LeaderboardQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	lb, err := services.Challenge.Leaderboard(chID, userID, q)
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		c.JSON(404, gin.H{"error": "challenge not found"})
+	case errors.Is(err, services.ErrChallengeForbidden):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(500, gin.H{"error": "cannot lb"})
+	default:
+		c.JSON(200, lb)
+	}
 }
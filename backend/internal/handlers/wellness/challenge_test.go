@@ -0,0 +1,225 @@
+package wellness
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+)
+
+// fakeChallengeSvc returns canned results and remembers the queries the
+// handlers passed on.
+type fakeChallengeSvc struct {
+	err         error
+	leaderboard services.LeaderboardPage
+	teams       []services.TeamStanding
+	discover    services.DiscoverPage
+	invitations []models.ChallengeInvitation
+
+	leaderboardQuery *services.LeaderboardQuery
+	discoverQuery    *services.DiscoverQuery
+}
+
+func (f *fakeChallengeSvc) Create(creatorID string, in services.ChallengeInput) (models.Challenge, error) {
+	return models.Challenge{ID: "ch-1", CreatorID: creatorID, Title: in.Title, Type: in.Type, Target: in.Target}, f.err
+}
+func (f *fakeChallengeSvc) Join(chID, userID string) error { return f.err }
+func (f *fakeChallengeSvc) Invite(chID, inviterID string, invitees []string) ([]models.ChallengeInvitation, error) {
+	return f.invitations, f.err
+}
+func (f *fakeChallengeSvc) Invitations(userID, status string) ([]models.ChallengeInvitation, error) {
+	return nil, f.err
+}
+func (f *fakeChallengeSvc) RespondInvitation(userID, invitationID string, accept bool) error {
+	return f.err
+}
+func (f *fakeChallengeSvc) Leaderboard(chID, viewerID string, q services.LeaderboardQuery) (services.LeaderboardPage, error) {
+	f.leaderboardQuery = &q
+	return f.leaderboard, f.err
+}
+func (f *fakeChallengeSvc) TeamLeaderboard(chID, viewerID string) ([]services.TeamStanding, error) {
+	return f.teams, f.err
+}
+func (f *fakeChallengeSvc) ListForUser(userID string) ([]models.Challenge, error) {
+	return nil, f.err
+}
+func (f *fakeChallengeSvc) Discover(userID string, q services.DiscoverQuery) (services.DiscoverPage, error) {
+	f.discoverQuery = &q
+	return f.discover, f.err
+}
+func (f *fakeChallengeSvc) RecordActivity(a models.Activity) error { return f.err }
+func (f *fakeChallengeSvc) RecordMeal(m models.Meal) error         { return f.err }
+func (f *fakeChallengeSvc) RecordWater(l models.WaterLog) error    { return f.err }
+func (f *fakeChallengeSvc) RecountDays(userID string) error        { return f.err }
+func (f *fakeChallengeSvc) Advance(now time.Time) error            { return f.err }
+
+type mockAuditSvc struct {
+	entries []services.AuditEntry
+}
+
+func (m *mockAuditSvc) Record(e services.AuditEntry) {
+	m.entries = append(m.entries, e)
+}
+func (m *mockAuditSvc) ListForUser(userID string, limit, offset int) ([]models.AuditEvent, error) {
+	return nil, nil
+}
+func (m *mockAuditSvc) List(f repository.AuditFilter) ([]models.AuditEvent, error) {
+	return nil, nil
+}
+
+// serve routes one request through the challenge handlers against fake.
+func serve(fake *fakeChallengeSvc, method, target string, body interface{}) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	services.Challenge = fake
+	services.Audit = &mockAuditSvc{}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("userID", "u1") })
+	r.POST("/challenges", CreateChallenge)
+	r.GET("/challenges/discover", DiscoverChallenges)
+	r.POST("/challenges/:id/join", JoinChallenge)
+	r.POST("/challenges/:id/invite", InviteToChallenge)
+	r.GET("/challenges/:id/leaderboard", GetLeaderboard)
+
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, target, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestChallengeHandlers_MapAccessErrors(t *testing.T) {
+	invite := map[string]interface{}{"invitees": []string{"bob@example.com"}}
+	requests := []struct {
+		method, target string
+		body           interface{}
+	}{
+		{http.MethodPost, "/challenges/ch-1/join", nil},
+		{http.MethodPost, "/challenges/ch-1/invite", invite},
+		{http.MethodGet, "/challenges/ch-1/leaderboard", nil},
+		{http.MethodGet, "/challenges/ch-1/leaderboard?view=teams", nil},
+	}
+	for _, tc := range []struct {
+		err  error
+		code int
+	}{
+		{fmt.Errorf("%w: challenge", services.ErrNotFound), http.StatusNotFound},
+		{services.ErrChallengeForbidden, http.StatusForbidden},
+		{errors.New("db down"), http.StatusInternalServerError},
+	} {
+		for _, req := range requests {
+			w := serve(&fakeChallengeSvc{err: tc.err}, req.method, req.target, req.body)
+			assert.Equal(t, tc.code, w.Code, "%s %s: %v", req.method, req.target, tc.err)
+		}
+	}
+}
+
+func TestInviteToChallenge_AnswersOnlyTheCount(t *testing.T) {
+	fake := &fakeChallengeSvc{invitations: []models.ChallengeInvitation{
+		{ID: "inv-1", ChallengeID: "ch-1", InviterID: "u1", InviteeID: "u2"},
+		{ID: "inv-2", ChallengeID: "ch-1", InviterID: "u1", InviteeID: "u3"},
+	}}
+	w := serve(fake, http.MethodPost, "/challenges/ch-1/invite", map[string]interface{}{"invitees": []string{"bob@example.com", "cat@example.com"}})
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"invited":2}`, w.Body.String())
+}
+
+func TestGetLeaderboard_TeamsOfIndividualChallenge(t *testing.T) {
+	w := serve(&fakeChallengeSvc{err: services.ErrNotTeamChallenge}, http.MethodGet, "/challenges/ch-1/leaderboard?view=teams", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(&fakeChallengeSvc{}, http.MethodGet, "/challenges/ch-1/leaderboard?view=podium", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateChallenge_RejectsInvalidChallenges(t *testing.T) {
+	body := map[string]interface{}{"title": "Run", "type": "workouts", "target": 3}
+	for _, err := range []error{services.ErrInvalidChallenge, services.ErrInvalidChallengeWindow, services.ErrInvalidTeams, services.ErrUnknownMetric} {
+		w := serve(&fakeChallengeSvc{err: fmt.Errorf("%w: detail", err)}, http.MethodPost, "/challenges", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, err)
+	}
+
+	w := serve(&fakeChallengeSvc{}, http.MethodPost, "/challenges", body)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestDiscoverChallenges_RejectsCursor(t *testing.T) {
+	fake := &fakeChallengeSvc{err: fmt.Errorf("%w: bad cursor", services.ErrInvalidDiscovery)}
+	w := serve(fake, http.MethodGet, "/challenges/discover?cursor=forged", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	require.NotNil(t, fake.discoverQuery)
+	assert.Equal(t, "forged", fake.discoverQuery.Cursor)
+
+	// bad filters never reach the service
+	for _, query := range []string{"sort=newest", "status=paused", "limit=-1"} {
+		fake := &fakeChallengeSvc{}
+		w := serve(fake, http.MethodGet, "/challenges/discover?"+query, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Nil(t, fake.discoverQuery, query)
+	}
+}
+
+func TestDiscoverChallenges_ReturnsNextCursor(t *testing.T) {
+	fake := &fakeChallengeSvc{discover: services.DiscoverPage{
+		Challenges: []models.ChallengeSummary{{Challenge: models.Challenge{ID: "ch-1"}, Participants: 3}},
+		NextCursor: "next",
+	}}
+	w := serve(fake, http.MethodGet, "/challenges/discover?sort=popular&limit=1&cursor=abc", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, services.DiscoverQuery{Sort: "popular", Limit: 1, Cursor: "abc"}, *fake.discoverQuery)
+
+	var page struct {
+		Challenges []struct{ ID string }
+		NextCursor string `json:"next_cursor"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Challenges, 1)
+	assert.Equal(t, "ch-1", page.Challenges[0].ID)
+	assert.Equal(t, "next", page.NextCursor)
+}
+
+func TestGetLeaderboard_ReturnsPage(t *testing.T) {
+	you := services.LeaderboardEntry{Rank: 7, UserID: "u1", Name: "Ann", Progress: 2, Percent: 40, You: true}
+	fake := &fakeChallengeSvc{leaderboard: services.LeaderboardPage{
+		Entries: []services.LeaderboardEntry{
+			{Rank: 1, UserID: "u2", Name: "Bob", Progress: 5, Percent: 100},
+			{Rank: 1, UserID: "u3", Name: "Cat", Progress: 5, Percent: 100},
+		},
+		Offset: 0,
+		Total:  12,
+		You:    &you,
+	}}
+	w := serve(fake, http.MethodGet, "/challenges/ch-1/leaderboard?offset=0&limit=2", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, fake.leaderboardQuery.Offset)
+	assert.Equal(t, 0, *fake.leaderboardQuery.Offset)
+	assert.Equal(t, 2, fake.leaderboardQuery.Limit)
+
+	var page services.LeaderboardPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, fake.leaderboard, page)
+
+	// without an offset the service centres the page on the viewer
+	w = serve(fake, http.MethodGet, "/challenges/ch-1/leaderboard", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, fake.leaderboardQuery.Offset)
+
+	w = serve(fake, http.MethodGet, "/challenges/ch-1/leaderboard?offset=-1", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
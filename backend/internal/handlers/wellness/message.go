@@ -10,7 +10,6 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
-	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/auth"
 )
 
 var wsUpgrader = websocket.Upgrader{
@@ -84,16 +83,8 @@ func PostMessage(c *gin.Context) {
 
 // WebSocketHandler upgrades HTTP to WebSocket for real-time messaging
 func WebSocketHandler(c *gin.Context) {
-	tokenStr := c.Query("token")
-	if tokenStr == "" {
-		log.Println("[WS] Missing token query param")
-		c.Writer.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-	claims, err := auth.ParseToken(tokenStr)
-	if err != nil {
-		log.Printf("[WS] Invalid token: %v", err)
-		c.Writer.WriteHeader(http.StatusUnauthorized)
+	claims := socketClaims(c)
+	if claims == nil {
 		return
 	}
 	userID := claims.UserID
@@ -105,7 +96,7 @@ func WebSocketHandler(c *gin.Context) {
 		return
 	}
 	log.Printf("[WS] WebSocket CONNECTED: %v", userID)
-	services.ActivityHub.Register(userID, conn)
+	services.ActivityHub.Register(userID, claims.SessionID, conn)
 	defer func() {
 		services.ActivityHub.Unregister(userID, conn)
 		log.Printf("[WS] WebSocket DISCONNECTED: %v", userID)
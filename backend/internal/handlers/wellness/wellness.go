@@ -3,13 +3,10 @@ package wellness
 import (
 	"log"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
-	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/db"
 )
 
 func PostActivity(c *gin.Context) {
@@ -22,19 +19,8 @@ func PostActivity(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	activity := models.PostActivity{
-		ID:        uuid.NewString(),
-		UserID:    userID,
-		Type:      req.Type,
-		Message:   req.Message,
-		CreatedAt: time.Now(),
-	}
-
 	// Save to DB
-	_, err := db.DB.Exec(`
-        INSERT INTO post_activities (id, user_id, type, message, created_at)
-        VALUES ($1, $2, $3, $4, $5)
-    `, activity.ID, activity.UserID, activity.Type, activity.Message, activity.CreatedAt)
+	activity, err := services.Post.CreateActivity(userID, req.Type, req.Message)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot save activity"})
 		return
@@ -17,18 +17,36 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func ActivitySocket(c *gin.Context) {
+// socketClaims authenticates a WebSocket upgrade from its ?token= query
+// parameter, which browsers use instead of an Authorization header. It
+// answers 401 itself and returns nil for missing, invalid or expired
+// tokens and for revoked sessions.
+func socketClaims(c *gin.Context) *auth.Claims {
 	tokenStr := c.Query("token")
 	if tokenStr == "" {
+		log.Println("[WS] Missing token query param")
 		c.Writer.WriteHeader(http.StatusUnauthorized)
-		return
+		return nil
 	}
-	log.Println("Token received:", tokenStr)
-
 	claims, err := auth.ParseToken(tokenStr)
 	if err != nil {
-		log.Println("JWT Parse error:", err)
+		log.Printf("[WS] Invalid token: %v", err)
 		c.Writer.WriteHeader(http.StatusUnauthorized)
+		return nil
+	}
+	if claims.SessionID != "" {
+		if err := services.User.CheckSession(claims.SessionID, c.ClientIP()); err != nil {
+			log.Printf("[WS] Session %s rejected: %v", claims.SessionID, err)
+			c.Writer.WriteHeader(http.StatusUnauthorized)
+			return nil
+		}
+	}
+	return claims
+}
+
+func ActivitySocket(c *gin.Context) {
+	claims := socketClaims(c)
+	if claims == nil {
 		return
 	}
 	userID := claims.UserID
@@ -38,7 +56,7 @@ func ActivitySocket(c *gin.Context) {
 		log.Println("Failed to upgrade connection: ", err)
 		return
 	}
-	services.ActivityHub.Register(userID, conn)
+	services.ActivityHub.Register(userID, claims.SessionID, conn)
 	defer services.ActivityHub.Unregister(userID, conn)
 
 	type ChatMessage struct {
@@ -1,15 +1,26 @@
 package middleware
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/auth"
 )
 
-func Auth() gin.HandlerFunc {
+// Scopes are the personal access token scopes a route group accepts: Read
+// for GET and HEAD requests, Write for everything else.
+type Scopes struct {
+	Read, Write string
+}
+
+// Auth accepts session JWTs and, on groups given scopes, personal access
+// tokens holding the scope for the request method. Groups without scopes
+// refuse personal access tokens.
+func Auth(scopes ...Scopes) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 1. Grab the header
 		header := c.GetHeader("Authorization")
@@ -26,19 +37,94 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 		tokenStr := parts[1]
+		if services.IsAccessToken(tokenStr) {
+			accessTokenAuth(c, tokenStr, scopes)
+			return
+		}
 
 		// 3. Parse & validate token
 		claims, err := auth.ParseToken(tokenStr)
+		if auth.IsExpired(err) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
+			return
+		}
 		if err != nil {
+			log.Println("Auth: rejected token:", err)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			return
 		}
 
-		log.Println("Token part extracted:", tokenStr)
+		// 4. Reject tokens of revoked sessions
+		if claims.SessionID != "" {
+			if err := services.User.CheckSession(claims.SessionID, c.ClientIP()); err != nil {
+				if !errors.Is(err, services.ErrSessionRevoked) {
+					log.Println("Auth: session check failed:", err)
+				}
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session revoked"})
+				return
+			}
+		}
 
-		// 4. Inject userID into context for handlers
+		// 5. Inject userID, sessionID and role into context for handlers
 		c.Set("userID", claims.UserID)
+		c.Set("sessionID", claims.SessionID)
+		c.Set("role", claims.Role)
 
 		c.Next()
 	}
 }
+
+// RequireRole lets requests through only when Auth found one of roles in
+// the token. It must run after Auth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, r := range roles {
+			if r == role {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient role"})
+	}
+}
+
+// accessTokenAuth authenticates a personal access token and checks it holds
+// one of the scopes the route accepts for the request method.
+func accessTokenAuth(c *gin.Context, secret string, scopes []Scopes) {
+	if len(scopes) == 0 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Personal access tokens are not accepted here"})
+		return
+	}
+	t, user, err := services.AccessTokens.Authenticate(secret)
+	if err != nil {
+		if !errors.Is(err, services.ErrInvalidAccessToken) && !errors.Is(err, services.ErrAccountSuspended) {
+			log.Println("Auth: access token check failed:", err)
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	read := c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead
+	allowed := false
+	for _, s := range scopes {
+		need := s.Write
+		if read {
+			need = s.Read
+		}
+		if t.Scopes.Has(need) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token lacks the required scope"})
+		return
+	}
+
+	c.Set("userID", user.ID)
+	c.Set("sessionID", "")
+	c.Set("role", user.Role)
+	c.Set("accessTokenID", t.ID)
+	c.Next()
+}
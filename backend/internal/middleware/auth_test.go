@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository/memory"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+)
+
+func TestAuth_AccessTokenScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := memory.NewStore()
+	require.NoError(t, store.Users.Create(&models.User{ID: "u1", Name: "Ann", Email: "ann@test.com", Role: models.RoleUser}))
+	services.AccessTokens = services.NewAccessTokenService(store)
+	tok, err := services.AccessTokens.Create("u1", services.AccessTokenInput{Name: "watch", Scopes: []string{models.ScopeStepsWrite}})
+	require.NoError(t, err)
+
+	r := gin.New()
+	ok := func(c *gin.Context) { c.String(http.StatusOK, c.GetString("userID")) }
+	steps := r.Group("/steps", Auth(Scopes{Read: models.ScopeStepsRead, Write: models.ScopeStepsWrite}))
+	steps.GET("", ok)
+	steps.POST("", ok)
+	r.GET("/profile", Auth(), ok)
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/steps", tok.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "u1", w.Body.String())
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/steps", tok.Token).Code, "write does not imply read")
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/profile", tok.Token).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/steps", tok.Token+"x").Code)
+
+	list, err := services.AccessTokens.List("u1")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.NotNil(t, list[0].LastUsedAt)
+
+	require.NoError(t, services.AccessTokens.Revoke("u1", tok.ID))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/steps", tok.Token).Code)
+
+	_, err = services.AccessTokens.Create("u1", services.AccessTokenInput{Name: "x", Scopes: []string{"admin"}})
+	assert.ErrorIs(t, err, services.ErrInvalidScope)
+	_, err = services.AccessTokens.Create("u1", services.AccessTokenInput{Name: "x", Scopes: []string{models.ScopeStepsRead}, ExpiresInDays: 400})
+	assert.ErrorIs(t, err, services.ErrInvalidExpiry)
+
+	now := time.Now()
+	require.NoError(t, store.Users.SetSuspended("u1", &now))
+	other, _ := services.AccessTokens.Create("u1", services.AccessTokenInput{Name: "y", Scopes: []string{models.ScopeStepsWrite}})
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/steps", other.Token).Code)
+}
@@ -23,7 +23,7 @@ func CORS() gin.HandlerFunc {
 		}
 
 		c.Writer.Header().Set("Access-Control-Allow-Headers",
-			"Authorization, Content-Type, X-CSRF-Token, X-Requested-With, Accept, Origin, Cache-Control, Content-Length",
+			"Authorization, Content-Type, X-CSRF-Token, X-Requested-With, Idempotency-Key, Accept, Origin, Cache-Control, Content-Length",
 		)
 		c.Writer.Header().Set("Access-Control-Allow-Methods",
 			"GET, POST, PUT, PATCH, DELETE, OPTIONS",
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/ratelimit"
+)
+
+// KeyFunc names the client a request counts against. An empty key exempts
+// the request from the rule.
+type KeyFunc func(c *gin.Context) string
+
+// ByIP keys requests by client address.
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser keys requests by the user set by Auth, so it must run after it.
+func ByUser(c *gin.Context) string {
+	if id := c.GetString("userID"); id != "" {
+		return "user:" + id
+	}
+	return ""
+}
+
+// maxKeyBody bounds the bodies ByEmail reads. Sign-in forms are far
+// smaller.
+const maxKeyBody = 8 << 10
+
+// ByEmail keys requests by the "email" field of a JSON body. The body is
+// put back for the handler. A body over maxKeyBody is answered with 413.
+func ByEmail(c *gin.Context) string {
+	if c.Request.Body == nil || c.IsAborted() {
+		return ""
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxKeyBody))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+		return ""
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	var in struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(body, &in) != nil || in.Email == "" {
+		return ""
+	}
+	return "email:" + strings.ToLower(strings.TrimSpace(in.Email))
+}
+
+// RateRule gives every key its own token bucket of Limit.
+type RateRule struct {
+	Key   KeyFunc
+	Limit ratelimit.Limit
+}
+
+// RateLimit spends one token per rule and answers 429 with Retry-After
+// when any bucket is empty. Buckets are named after group, so each route
+// group can be limited separately. With a nil store it does nothing.
+func RateLimit(store repository.RateLimitRepository, group string, rules ...RateRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil {
+			c.Next()
+			return
+		}
+		now := time.Now()
+		for _, rule := range rules {
+			key := rule.Key(c)
+			if key == "" || !rule.Limit.Enabled() {
+				continue
+			}
+			wait, err := store.Take(group+":"+key, rule.Limit, now)
+			if err != nil {
+				// fail open: an unavailable store should not take the API down
+				log.Println("RateLimit: store error:", err)
+				continue
+			}
+			if wait > 0 {
+				tooManyRequests(c, wait, "Too many requests")
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// Lockout rejects requests whose key was locked by earlier failures. A 401
+// from the handler counts as a failure and a 200 clears the count. Other
+// 2xx answers, like the 202 of a login waiting for its second factor,
+// leave it for whatever completes the request to clear.
+func Lockout(store repository.RateLimitRepository, group string, key KeyFunc, policy ratelimit.Lockout) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k := key(c)
+		if store == nil || k == "" || policy.MaxFailures <= 0 {
+			c.Next()
+			return
+		}
+		k = group + ":" + k
+
+		f, err := store.Failures(k)
+		if err != nil {
+			log.Println("Lockout: store error:", err)
+		} else if wait := f.Locked(time.Now()); wait > 0 {
+			tooManyRequests(c, wait, "Too many failed attempts, try again later")
+			return
+		}
+
+		c.Next()
+
+		var saveErr error
+		switch status := c.Writer.Status(); {
+		case status == http.StatusUnauthorized:
+			_, saveErr = store.AddFailure(k, policy, time.Now())
+		case status == http.StatusOK && f.Count > 0:
+			saveErr = store.ClearFailures(k)
+		}
+		if saveErr != nil {
+			log.Println("Lockout: store error:", saveErr)
+		}
+	}
+}
+
+// SweepRateLimits deletes the buckets and failure counts of store that sat
+// idle for longer than idle, once every idle. A bucket left that long is
+// full again, so idle has to exceed the refill time of every limit and
+// every lockout window. With a nil store it does nothing.
+func SweepRateLimits(store repository.RateLimitRepository, idle time.Duration) {
+	if store == nil {
+		return
+	}
+	ticker := time.NewTicker(idle)
+	go func() {
+		for now := range ticker.C {
+			n, err := store.Sweep(now.Add(-idle))
+			if err != nil {
+				log.Println("SweepRateLimits: store error:", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("SweepRateLimits: deleted %d idle key(s)", n)
+			}
+		}
+	}()
+}
+
+func tooManyRequests(c *gin.Context, wait time.Duration, msg string) {
+	secs := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(secs))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": msg, "retryAfter": secs})
+}
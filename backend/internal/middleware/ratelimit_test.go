@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository/memory"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/ratelimit"
+)
+
+func post(r *gin.Engine, path, ip, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	req.RemoteAddr = ip + ":1234"
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit_PerIPAndEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := memory.NewStore().RateLimits
+	r := gin.New()
+	r.POST("/login", RateLimit(store, "auth",
+		RateRule{Key: ByIP, Limit: ratelimit.PerMinute(3)},
+		RateRule{Key: ByEmail, Limit: ratelimit.PerMinute(2)},
+	), func(c *gin.Context) {
+		var in struct{ Email string }
+		// the handler still sees the body ByEmail read
+		assert.NoError(t, c.ShouldBindJSON(&in))
+		c.String(http.StatusOK, in.Email)
+	})
+
+	w := post(r, "/login", "10.0.0.1", `{"email":"Ann@test.com"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Ann@test.com", w.Body.String())
+	assert.Equal(t, http.StatusOK, post(r, "/login", "10.0.0.2", `{"email":"ann@test.com"}`).Code)
+
+	// same email from a third address: the email bucket is empty
+	w = post(r, "/login", "10.0.0.3", `{"email":"ann@test.com"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	// the first address has one token left
+	assert.Equal(t, http.StatusOK, post(r, "/login", "10.0.0.1", `{"email":"bob@test.com"}`).Code)
+	assert.Equal(t, http.StatusOK, post(r, "/login", "10.0.0.1", `{"email":"eve@test.com"}`).Code)
+	w = post(r, "/login", "10.0.0.1", `{"email":"joe@test.com"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "20", w.Header().Get("Retry-After"))
+}
+
+func TestByEmail_RejectsLargeBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := memory.NewStore().RateLimits
+	r := gin.New()
+	reached := false
+	r.POST("/login", RateLimit(store, "auth", RateRule{Key: ByEmail, Limit: ratelimit.PerMinute(5)}),
+		Lockout(store, "login", ByEmail, ratelimit.Lockout{MaxFailures: 3, Window: time.Minute, Duration: time.Minute}),
+		func(c *gin.Context) { reached = true })
+
+	padding := strings.Repeat(" ", maxKeyBody)
+	w := post(r, "/login", "10.0.0.1", `{"email":"ann@test.com"}`+padding)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.JSONEq(t, `{"error":"Request body too large"}`, w.Body.String())
+	assert.False(t, reached)
+}
+
+func TestRateLimit_NilStoreAllows(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/x", RateLimit(nil, "auth", RateRule{Key: ByIP, Limit: ratelimit.PerMinute(1)}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, post(r, "/x", "10.0.0.1", "").Code)
+	}
+}
+
+func TestLockout_AfterRepeatedFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := memory.NewStore().RateLimits
+	r := gin.New()
+	r.POST("/login", Lockout(store, "login", ByEmail, ratelimit.Lockout{
+		MaxFailures: 2, Window: time.Minute, Duration: time.Minute,
+	}), func(c *gin.Context) {
+		var in struct{ Password string }
+		c.ShouldBindJSON(&in)
+		if in.Password != "right" {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	// a success clears earlier failures
+	assert.Equal(t, http.StatusUnauthorized, post(r, "/login", "10.0.0.1", `{"email":"a@b.c","password":"x"}`).Code)
+	assert.Equal(t, http.StatusOK, post(r, "/login", "10.0.0.1", `{"email":"a@b.c","password":"right"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, post(r, "/login", "10.0.0.1", `{"email":"a@b.c","password":"x"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, post(r, "/login", "10.0.0.2", `{"email":"a@b.c","password":"x"}`).Code)
+
+	// now locked, even with the right password and from another address
+	w := post(r, "/login", "10.0.0.3", `{"email":"a@b.c","password":"right"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, post(r, "/login", "10.0.0.1", `{"email":"other@b.c","password":"right"}`).Code)
+}
+
+func TestLockout_AcceptedLoginKeepsFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := memory.NewStore().RateLimits
+	r := gin.New()
+	r.POST("/login", Lockout(store, services.LoginLockoutGroup, ByEmail, ratelimit.Lockout{
+		MaxFailures: 3, Window: time.Minute, Duration: time.Minute,
+	}), func(c *gin.Context) {
+		var in struct{ Password string }
+		c.ShouldBindJSON(&in)
+		if in.Password != "right" {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		// the password is right but a second factor is still due
+		c.Status(http.StatusAccepted)
+	})
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusUnauthorized, post(r, "/login", "10.0.0.1", `{"email":"A@b.c","password":"x"}`).Code)
+	}
+	assert.Equal(t, http.StatusAccepted, post(r, "/login", "10.0.0.1", `{"email":"a@b.c","password":"right"}`).Code)
+
+	// the services clear the count under the same key once 2FA passes
+	f, err := store.Failures(services.LoginFailuresKey(" A@B.c"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, f.Count)
+
+	assert.Equal(t, http.StatusUnauthorized, post(r, "/login", "10.0.0.1", `{"email":"a@b.c","password":"x"}`).Code)
+	assert.Equal(t, http.StatusTooManyRequests, post(r, "/login", "10.0.0.1", `{"email":"a@b.c","password":"right"}`).Code)
+}
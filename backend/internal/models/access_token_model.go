@@ -0,0 +1,75 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scopes a personal access token can be granted.
+const (
+	ScopeStepsRead       = "steps:read"
+	ScopeStepsWrite      = "steps:write"
+	ScopeActivitiesRead  = "activities:read"
+	ScopeActivitiesWrite = "activities:write"
+	ScopeNutritionRead   = "nutrition:read"
+	ScopeNutritionWrite  = "nutrition:write"
+	ScopeWellnessRead    = "wellness:read"
+	ScopeWellnessWrite   = "wellness:write"
+)
+
+// AllScopes lists every scope in the order clients should show them.
+var AllScopes = []string{
+	ScopeStepsRead, ScopeStepsWrite,
+	ScopeActivitiesRead, ScopeActivitiesWrite,
+	ScopeNutritionRead, ScopeNutritionWrite,
+	ScopeWellnessRead, ScopeWellnessWrite,
+}
+
+// ScopeList is stored as one space separated TEXT column.
+type ScopeList []string
+
+// Has reports whether scope is in the list.
+func (l ScopeList) Has(scope string) bool {
+	for _, s := range l {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (l ScopeList) Value() (driver.Value, error) {
+	return strings.Join(l, " "), nil
+}
+
+func (l *ScopeList) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case string:
+		*l = strings.Fields(v)
+	case []byte:
+		*l = strings.Fields(string(v))
+	case nil:
+		*l = nil
+	default:
+		return fmt.Errorf("scopes: cannot scan %T", src)
+	}
+	return nil
+}
+
+// AccessToken is a personal access token in `personal_access_tokens`.
+// The token itself is only shown on creation; Prefix lets users tell
+// their tokens apart.
+type AccessToken struct {
+	ID         string     `db:"id"           json:"id"`
+	UserID     string     `db:"user_id"      json:"-"`
+	Name       string     `db:"name"         json:"name"`
+	Prefix     string     `db:"prefix"       json:"prefix"`
+	TokenHash  string     `db:"token_hash"   json:"-"`
+	Scopes     ScopeList  `db:"scopes"       json:"scopes"`
+	ExpiresAt  time.Time  `db:"expires_at"   json:"expiresAt"`
+	CreatedAt  time.Time  `db:"created_at"   json:"createdAt"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `db:"revoked_at"   json:"revokedAt,omitempty"`
+}
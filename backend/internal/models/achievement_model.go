@@ -4,9 +4,11 @@ import "time"
 
 // Achievement is the master list in `achievements`.
 type Achievement struct {
-	ID        string    `db:"id" json:"id"`
-	Title     string    `db:"title" json:"title"`
-	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	ID          string    `db:"id" json:"id"`
+	Title       string    `db:"title" json:"title"`
+	Description string    `db:"description" json:"description"`
+	IconURL     string    `db:"icon_url" json:"iconUrl"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
 }
 
 // UserAchievement links users to unlocked achievements in `user_achievements`.
@@ -11,5 +11,24 @@ type Activity struct {
 	Intensity   string    `db:"intensity" json:"intensity"`
 	Calories    int       `db:"calories" json:"calories"`
 	Location    string    `db:"location" json:"location"`
+	Distance    int       `db:"distance" json:"distance"` // meters
 	PerformedAt time.Time `db:"performed_at" json:"performedAt"`
+	// IdempotencyKey is the client's Idempotency-Key for the request that
+	// logged the activity.
+	IdempotencyKey string `db:"idempotency_key" json:"-"`
+}
+
+// StepDay is one row of the `user_steps` daily totals.
+type StepDay struct {
+	Day   string `db:"day" json:"day"`
+	Steps int    `db:"steps" json:"steps"`
+}
+
+// StepBucket is what one source (a phone, a watch) counted in an hour of
+// the user's local day, or over the whole day when Hour is nil.
+type StepBucket struct {
+	Source string `db:"source" json:"source"`
+	Day    string `db:"day" json:"day"`
+	Hour   *int   `db:"hour" json:"hour"`
+	Steps  int    `db:"steps" json:"steps"`
 }
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// AdminAction records one change an admin made through the admin API, in
+// `admin_actions`.
+type AdminAction struct {
+	ID         string    `db:"id"          json:"id"`
+	AdminID    string    `db:"admin_id"    json:"adminId"`
+	Action     string    `db:"action"      json:"action"`
+	TargetType string    `db:"target_type" json:"targetType"`
+	TargetID   string    `db:"target_id"   json:"targetId"`
+	Details    string    `db:"details"     json:"details,omitempty"`
+	CreatedAt  time.Time `db:"created_at"  json:"createdAt"`
+}
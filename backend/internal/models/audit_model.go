@@ -0,0 +1,22 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEvent is one row of the append-only `audit_events` table: who did
+// what to whose data, from where, and the state before and after.
+type AuditEvent struct {
+	ID         string           `db:"id"           json:"id"`
+	ActorID    string           `db:"actor_id"     json:"actorId,omitempty"`
+	SubjectID  string           `db:"subject_id"   json:"subjectId,omitempty"`
+	Action     string           `db:"action"       json:"action"`
+	TargetType string           `db:"target_type"  json:"targetType,omitempty"`
+	TargetID   string           `db:"target_id"    json:"targetId,omitempty"`
+	IP         string           `db:"ip"           json:"ip,omitempty"`
+	UserAgent  string           `db:"user_agent"   json:"userAgent,omitempty"`
+	Before     *json.RawMessage `db:"state_before" json:"before,omitempty"`
+	After      *json.RawMessage `db:"state_after"  json:"after,omitempty"`
+	CreatedAt  time.Time        `db:"created_at"   json:"createdAt"`
+}
@@ -2,13 +2,82 @@ package models
 
 import "time"
 
+// Challenge states: scheduled until StartsAt, active until EndsAt and then
+// finished, with the standings frozen.
+const (
+	ChallengeScheduled = "scheduled"
+	ChallengeActive    = "active"
+	ChallengeFinished  = "finished"
+)
+
 type Challenge struct {
 	ID        string    `db:"id"         json:"id"`
 	CreatorID string    `db:"creator_id" json:"creator_id"`
 	Type      string    `db:"type"       json:"type"`
 	Target    int       `db:"target"    json:"target"`
 	Title     string    `db:"title"     json:"title"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	StartsAt  time.Time `db:"starts_at"  json:"starts_at"`
+	EndsAt    time.Time `db:"ends_at"    json:"ends_at"`
+	Status    string    `db:"status"     json:"status"`
+	// TeamScoring is how team challenges rank their teams, by the "sum" or
+	// the "average" of the members' progress; empty for individual ones.
+	TeamScoring string    `db:"team_scoring" json:"team_scoring,omitempty"`
+	Visibility  string    `db:"visibility" json:"visibility"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+// Challenge visibilities: who may join without an invitation. Nobody for
+// private challenges, the creator's friends for friends-only ones and
+// everyone for public ones.
+const (
+	ChallengePrivate = "private"
+	ChallengeFriends = "friends"
+	ChallengePublic  = "public"
+)
+
+// Invitation states.
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+)
+
+// ChallengeInvitation asks the invitee to take part in a challenge, on
+// TeamID when the creator put them on a team.
+type ChallengeInvitation struct {
+	ID          string     `db:"id"           json:"id"`
+	ChallengeID string     `db:"challenge_id" json:"challenge_id"`
+	InviterID   string     `db:"inviter_id"   json:"inviter_id"`
+	InviteeID   string     `db:"invitee_id"   json:"invitee_id"`
+	TeamID      string     `db:"team_id"      json:"team_id,omitempty"`
+	Status      string     `db:"status"       json:"status"`
+	CreatedAt   time.Time  `db:"created_at"   json:"created_at"`
+	RespondedAt *time.Time `db:"responded_at" json:"responded_at,omitempty"`
+	// Title and InviterName are filled in for the invitee's list.
+	Title       string `db:"title"        json:"title,omitempty"`
+	InviterName string `db:"inviter_name" json:"inviter_name,omitempty"`
+}
+
+// ChallengeSummary is a challenge as listed for discovery: with its
+// number of participants and whether the viewer is one of them.
+type ChallengeSummary struct {
+	Challenge
+	Participants int  `db:"participants" json:"participants"`
+	Joined       bool `db:"joined"       json:"joined"`
+}
+
+// Team scorings.
+const (
+	TeamScoringSum     = "sum"
+	TeamScoringAverage = "average"
+)
+
+// ChallengeTeam is a named team of a team challenge, in creation order.
+type ChallengeTeam struct {
+	ID          string `db:"id"           json:"id"`
+	ChallengeID string `db:"challenge_id" json:"challenge_id"`
+	Name        string `db:"name"         json:"name"`
+	Position    int    `db:"position"     json:"-"`
 }
 
 type ChallengeParticipant struct {
@@ -16,4 +85,62 @@ type ChallengeParticipant struct {
 	UserID      string `db:"user_id"      json:"user_id"`
 	Progress    int    `db:"progress"     json:"progress"`
 	JoinedAt    string `db:"joined_at"    json:"joined_at"`
+	TeamID      string `db:"team_id"      json:"team_id,omitempty"`
+	// FinalRank is the placement once the challenge has finished. Ranks
+	// are dense: equal progress shares a rank and the next one follows
+	// without a gap.
+	FinalRank *int `db:"final_rank" json:"final_rank,omitempty"`
+	// Name and AvatarURL are filled in for the standings.
+	Name      string `db:"name"       json:"name,omitempty"`
+	AvatarURL string `db:"avatar_url" json:"avatar_url,omitempty"`
+}
+
+// ParticipantProgress is a participant row joined with its challenge.
+type ParticipantProgress struct {
+	ChallengeID string    `db:"challenge_id"`
+	UserID      string    `db:"user_id"`
+	Title       string    `db:"title"`
+	Target      int       `db:"target"`
+	Progress    int       `db:"progress"`
+	EndsAt      time.Time `db:"ends_at"`
+	Timezone    string    `db:"timezone"`
+}
+
+// ProgressEvent is an entry of the challenge progress ledger: amount of
+// metric the user achieved at OccurredAt, or over the period from
+// OccurredAt to LastsUntil, reported by source. The idempotency key is
+// unique per user; recording it again replaces the entry.
+type ProgressEvent struct {
+	ID             string     `db:"id"              json:"id"`
+	UserID         string     `db:"user_id"         json:"user_id"`
+	Metric         string     `db:"metric"          json:"metric"`
+	Source         string     `db:"source"          json:"source"`
+	Amount         int        `db:"amount"          json:"amount"`
+	IdempotencyKey string     `db:"idempotency_key" json:"idempotency_key"`
+	OccurredAt     time.Time  `db:"occurred_at"     json:"occurred_at"`
+	LastsUntil     *time.Time `db:"lasts_until"     json:"lasts_until,omitempty"`
+	RecordedAt     time.Time  `db:"recorded_at"     json:"recorded_at"`
+}
+
+// Share returns how much of the amount falls inside [from, until): all of
+// it for an instant inside the window, and for a period the part of the
+// period that overlaps the window.
+func (e ProgressEvent) Share(from, until time.Time) float64 {
+	if e.LastsUntil == nil || !e.LastsUntil.After(e.OccurredAt) {
+		if e.OccurredAt.Before(from) || !e.OccurredAt.Before(until) {
+			return 0
+		}
+		return float64(e.Amount)
+	}
+	start, end := e.OccurredAt, *e.LastsUntil
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(until) {
+		end = until
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return float64(e.Amount) * float64(end.Sub(start)) / float64(e.LastsUntil.Sub(e.OccurredAt))
 }
@@ -17,3 +17,11 @@ type Friend struct {
 	FriendID  string    `db:"friend_id" json:"friendId"`
 	CreatedAt time.Time `db:"created_at" json:"createdAt"`
 }
+
+// IncomingFriendRequest is a pending request joined with the requester's
+// name and email, as shown to the recipient.
+type IncomingFriendRequest struct {
+	ID    string `db:"id" json:"id"`
+	Name  string `db:"name" json:"name"`
+	Email string `db:"email" json:"email"`
+}
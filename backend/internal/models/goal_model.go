@@ -0,0 +1,11 @@
+package models
+
+// GoalKind names one of the per-user daily goal tables.
+type GoalKind string
+
+const (
+	GoalSteps    GoalKind = "steps"    // step_goals.goal
+	GoalActivity GoalKind = "activity" // activity_goals.goal (kcal burned)
+	GoalWater    GoalKind = "water"    // water_goals.goal_ml
+	GoalCalories GoalKind = "calories" // calorie_goals.goal (kcal eaten)
+)
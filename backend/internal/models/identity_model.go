@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Identity links a user to an account at an external OpenID Connect
+// provider in `user_identities`.
+type Identity struct {
+	ID          string     `db:"id"            json:"id"`
+	UserID      string     `db:"user_id"       json:"userId"`
+	Provider    string     `db:"provider"      json:"provider"`
+	Subject     string     `db:"subject"       json:"subject"`
+	Email       string     `db:"email"         json:"email"`
+	CreatedAt   time.Time  `db:"created_at"    json:"createdAt"`
+	LastLoginAt *time.Time `db:"last_login_at" json:"lastLoginAt,omitempty"`
+}
+
+// OIDCLogin is a sign-in waiting for the provider's callback in
+// `oidc_logins`. The state is only stored hashed.
+type OIDCLogin struct {
+	StateHash    string    `db:"state_hash"`
+	Provider     string    `db:"provider"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
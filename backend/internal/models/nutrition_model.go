@@ -4,16 +4,19 @@ import "time"
 
 type Meal struct {
 	ID          string    `db:"id" json:"id"`
-	UserID      string    `db:"user_id" json:"-"`
-	Description string    `db:"description" json:"description"`
+	UserID      string    `db:"user_id" json:"user_id"`
 	FdcID       int       `db:"fdc_id" json:"fdcId"`
+	Description string    `db:"description" json:"description"`
 	Calories    float64   `db:"calories" json:"calories"`
 	Protein     float64   `db:"protein" json:"protein"`
 	Fat         float64   `db:"fat" json:"fat"`
 	Carbs       float64   `db:"carbs" json:"carbs"`
 	Quantity    float64   `db:"quantity" json:"quantity"`
 	Unit        string    `db:"unit" json:"unit"`
-	EatenAt     time.Time `db:"eaten_at" json:"eatenAt"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	// IdempotencyKey is the client's Idempotency-Key for the request that
+	// logged the meal.
+	IdempotencyKey string `db:"idempotency_key" json:"-"`
 }
 
 type WaterLog struct {
@@ -21,6 +24,9 @@ type WaterLog struct {
 	UserID    string    `db:"user_id" json:"user_id"`
 	AmountML  int       `db:"amount_ml" json:"amount_ml"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	// IdempotencyKey is the client's Idempotency-Key for the request that
+	// logged the water.
+	IdempotencyKey string `db:"idempotency_key" json:"-"`
 }
 
 type DailyWaterStats struct {
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// PersonalData is everything stored about one user, as handed out by the
+// data export. Secrets (password and token hashes, the TOTP secret) are
+// left out.
+type PersonalData struct {
+	User           User                   `json:"user"`
+	Goals          map[GoalKind]int       `json:"goals"`
+	TwoFactorSince *time.Time             `json:"twoFactorSince,omitempty"`
+	Friends        []Friend               `json:"friends"`
+	FriendRequests []FriendRequest        `json:"friendRequests"`
+	Achievements   []UserAchievement      `json:"achievements"`
+	Activities     []Activity             `json:"activities"`
+	Steps          []StepDay              `json:"steps"`
+	StepBuckets    []StepBucket           `json:"stepBuckets"`
+	Meals          []Meal                 `json:"meals"`
+	WaterLogs      []WaterLog             `json:"waterLogs"`
+	Posts          []PostActivity         `json:"posts"`
+	Messages       []Message              `json:"messages"`
+	Challenges     []Challenge            `json:"challenges"`
+	Participations []ChallengeParticipant `json:"participations"`
+	Progress       []ProgressEvent        `json:"challengeProgress"`
+	Invitations    []ChallengeInvitation  `json:"challengeInvitations"`
+	Workouts       []WorkoutSchedule      `json:"workouts"`
+	Hydration      []HydrationSetting     `json:"hydration"`
+	Sessions       []Session              `json:"sessions"`
+	Identities     []Identity             `json:"identities"`
+	AccessTokens   []AccessToken          `json:"accessTokens"`
+	AuditEvents    []AuditEvent           `json:"auditEvents"`
+}
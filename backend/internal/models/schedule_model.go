@@ -14,3 +14,11 @@ type WorkoutSchedule struct {
 	Title     string    `db:"title"      json:"title"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
+
+// HydrationSetting matches the hydration_settings table.
+type HydrationSetting struct {
+	UserID   string `db:"user_id"  json:"user_id"`
+	Interval int    `db:"interval" json:"interval"` // minutes
+	// Timezone is the owner's, which reminders are timed in.
+	Timezone string `db:"timezone" json:"-"`
+}
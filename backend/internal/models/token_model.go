@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// RefreshToken is one row of `refresh_tokens`. The token itself is never
+// stored, only its hash.
+type RefreshToken struct {
+	ID        string     `db:"id"`
+	UserID    string     `db:"user_id"`
+	FamilyID  string     `db:"family_id"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	CreatedAt time.Time  `db:"created_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
+
+// Session is one signed-in device in `sessions`. Its ID doubles as the
+// family of the refresh tokens issued to that device.
+type Session struct {
+	ID         string     `db:"id"           json:"id"`
+	UserID     string     `db:"user_id"      json:"userId"`
+	UserAgent  string     `db:"user_agent"   json:"userAgent"`
+	IP         string     `db:"ip"           json:"ip"`
+	CreatedAt  time.Time  `db:"created_at"   json:"createdAt"`
+	LastSeenAt time.Time  `db:"last_seen_at" json:"lastSeenAt"`
+	RevokedAt  *time.Time `db:"revoked_at"   json:"revokedAt,omitempty"`
+}
+
+// Purposes of a UserToken.
+const (
+	TokenPasswordReset  = "password_reset"
+	TokenEmailVerify    = "email_verify"
+	TokenLoginChallenge = "login_challenge"
+)
+
+// UserToken is a single-use token handed to the user, by mail or as the
+// second step of a login, stored hashed in `user_tokens`.
+type UserToken struct {
+	ID        string     `db:"id"`
+	UserID    string     `db:"user_id"`
+	Purpose   string     `db:"purpose"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	CreatedAt time.Time  `db:"created_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// TOTP is a user's authenticator app enrollment in `user_totp`. It only
+// guards logins once ConfirmedAt is set.
+type TOTP struct {
+	UserID      string     `db:"user_id"`
+	Secret      string     `db:"secret"`
+	ConfirmedAt *time.Time `db:"confirmed_at"`
+	LastStep    int64      `db:"last_step"` // newest time step accepted, against replays
+	CreatedAt   time.Time  `db:"created_at"`
+}
@@ -1,5 +1,13 @@
 package models
 
+import "time"
+
+// Roles a user can have. Every account is a RoleUser unless promoted.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID           string   `db:"id" json:"id"`
 	Name         string   `db:"name" json:"name"`
@@ -8,4 +16,14 @@ type User struct {
 	AvatarURL    string   `db:"avatar_url" json:"avatarUrl"`
 	Weight       *float64 `db:"weight" json:"weight"`
 	Height       *float64 `db:"height" json:"height"`
+	// Timezone is the IANA zone the user's days are counted in.
+	Timezone string `db:"timezone" json:"timezone"`
+
+	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"emailVerifiedAt"`
+	Role            string     `db:"role" json:"role"`
+	// SuspendedAt is set while an admin has suspended the account.
+	SuspendedAt *time.Time `db:"suspended_at" json:"suspendedAt,omitempty"`
+	// DeleteAfter is set while the user has asked for the account to be
+	// deleted; it is erased once this time passes.
+	DeleteAfter *time.Time `db:"delete_after" json:"deleteAfter,omitempty"`
 }
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+type accessTokenRepo struct {
+	*data
+}
+
+func (r *accessTokenRepo) Create(t *models.AccessToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t.ID == "" {
+		t.ID = uuid.NewString()
+	}
+	stamp(&t.CreatedAt)
+	r.accessTokens = append(r.accessTokens, *t)
+	return nil
+}
+
+func (r *accessTokenRepo) ByHash(hash string) (models.AccessToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, t := range r.accessTokens {
+		if t.TokenHash == hash {
+			return t, nil
+		}
+	}
+	return models.AccessToken{}, repository.ErrNotFound
+}
+
+func (r *accessTokenRepo) ListForUser(userID string) ([]models.AccessToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.AccessToken
+	for _, t := range r.accessTokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			list = append(list, t)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+func (r *accessTokenRepo) Touch(id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.accessTokens {
+		if r.accessTokens[i].ID == id {
+			r.accessTokens[i].LastUsedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *accessTokenRepo) Revoke(userID, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, t := range r.accessTokens {
+		if t.ID == id && t.UserID == userID && t.RevokedAt == nil {
+			r.accessTokens[i].RevokedAt = &at
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
@@ -0,0 +1,247 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+type activityRepo struct {
+	*data
+}
+
+func (r *activityRepo) Create(a *models.Activity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, old := range r.activities {
+		if a.IdempotencyKey != "" && old.UserID == a.UserID && old.IdempotencyKey == a.IdempotencyKey {
+			*a = old
+			return nil
+		}
+	}
+	if a.ID == "" {
+		a.ID = uuid.NewString()
+	}
+	stamp(&a.PerformedAt)
+	r.activities = append(r.activities, *a)
+	return nil
+}
+
+func (r *activityRepo) List(userID string, activityType *string) ([]models.Activity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.Activity
+	for i := len(r.activities) - 1; i >= 0; i-- {
+		a := r.activities[i]
+		if a.UserID == userID && (activityType == nil || a.Type == *activityType) {
+			list = append(list, a)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].PerformedAt.After(list[j].PerformedAt) })
+	return list, nil
+}
+
+func (r *activityRepo) ListBetween(userID string, from, to time.Time) ([]models.Activity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.Activity
+	for _, a := range r.activities {
+		if a.UserID == userID && between(a.PerformedAt, from, to) {
+			list = append(list, a)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].PerformedAt.Before(list[j].PerformedAt) })
+	return list, nil
+}
+
+type stepRepo struct {
+	*data
+}
+
+func (r *stepRepo) Upsert(userID, day string, steps int) error {
+	if _, err := time.Parse("2006-01-02", day); err != nil {
+		return fmt.Errorf("invalid day %q: %w", day, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.steps[userID] == nil {
+		r.steps[userID] = map[string]int{}
+	}
+	r.steps[userID][day] = steps
+	return nil
+}
+
+func (r *stepRepo) ListSince(userID, fromDay string) ([]models.StepDay, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.StepDay
+	for day, steps := range r.steps[userID] {
+		if day >= fromDay {
+			list = append(list, models.StepDay{Day: day, Steps: steps})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Day > list[j].Day })
+	return list, nil
+}
+
+func (r *stepRepo) Total(userID string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	total := 0
+	for _, steps := range r.steps[userID] {
+		total += steps
+	}
+	return total, nil
+}
+
+func (r *stepRepo) Delete(userID, day string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stepBuckets[userID] = keep(r.stepBuckets[userID], func(b models.StepBucket) bool { return b.Day != day })
+	if _, ok := r.steps[userID][day]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.steps[userID], day)
+	return nil
+}
+
+func (r *stepRepo) SaveBuckets(userID string, buckets []models.StepBucket) error {
+	for _, b := range buckets {
+		if _, err := time.Parse("2006-01-02", b.Day); err != nil {
+			return fmt.Errorf("invalid day %q: %w", b.Day, err)
+		}
+		if b.Hour != nil && (*b.Hour < 0 || *b.Hour > 23) {
+			return fmt.Errorf("hour %d out of range", *b.Hour)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	days := map[string]bool{}
+	for _, b := range buckets {
+		if b.Hour != nil {
+			hour := *b.Hour
+			b.Hour = &hour
+		}
+		list := r.stepBuckets[userID]
+		replaced := false
+		for i, cur := range list {
+			if cur.Day == b.Day && cur.Source == b.Source && sameHour(cur.Hour, b.Hour) {
+				list[i] = b
+				replaced = true
+			}
+		}
+		if !replaced {
+			r.stepBuckets[userID] = append(list, b)
+		}
+		days[b.Day] = true
+	}
+	for day := range days {
+		r.mergeStepDay(userID, day)
+	}
+	return nil
+}
+
+func sameHour(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// mergeStepDay recomputes the daily total from the buckets of day, as
+// described on repository.StepRepository. Callers hold the lock.
+func (r *stepRepo) mergeStepDay(userID, day string) {
+	var hours [24]int
+	whole, hourly := 0, 0
+	for _, b := range r.stepBuckets[userID] {
+		switch {
+		case b.Day != day:
+		case b.Hour == nil:
+			whole = max(whole, b.Steps)
+		default:
+			hours[*b.Hour] = max(hours[*b.Hour], b.Steps)
+		}
+	}
+	for _, n := range hours {
+		hourly += n
+	}
+	if r.steps[userID] == nil {
+		r.steps[userID] = map[string]int{}
+	}
+	r.steps[userID][day] = max(hourly, whole)
+}
+
+func (r *stepRepo) Buckets(userID, fromDay string) ([]models.StepBucket, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.StepBucket
+	for _, b := range r.stepBuckets[userID] {
+		if b.Day >= fromDay {
+			list = append(list, b)
+		}
+	}
+	sortBuckets(list)
+	return list, nil
+}
+
+// sortBuckets orders buckets by day, source and hour, whole days first.
+func sortBuckets(list []models.StepBucket) {
+	hour := func(b models.StepBucket) int {
+		if b.Hour == nil {
+			return -1
+		}
+		return *b.Hour
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return hour(a) < hour(b)
+	})
+}
+
+type goalRepo struct {
+	*data
+}
+
+func (r *goalRepo) Get(userID string, kind models.GoalKind) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	goal, ok := r.goals[goalKey{userID, kind}]
+	if !ok {
+		return 0, repository.ErrNotFound
+	}
+	return goal, nil
+}
+
+func (r *goalRepo) Set(userID string, kind models.GoalKind, value int) error {
+	switch kind {
+	case models.GoalSteps, models.GoalActivity, models.GoalWater, models.GoalCalories:
+	default:
+		return fmt.Errorf("unknown goal kind %q", kind)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.goals[goalKey{userID, kind}] = value
+	return nil
+}
@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+)
+
+type adminActionRepo struct {
+	*data
+}
+
+func (r *adminActionRepo) Create(a *models.AdminAction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if a.ID == "" {
+		a.ID = uuid.NewString()
+	}
+	stamp(&a.CreatedAt)
+	r.adminActions = append(r.adminActions, *a)
+	return nil
+}
+
+func (r *adminActionRepo) List(limit int) ([]models.AdminAction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.AdminAction
+	for i := len(r.adminActions) - 1; i >= 0 && (limit < 0 || len(list) < limit); i-- {
+		list = append(list, r.adminActions[i])
+	}
+	return list, nil
+}
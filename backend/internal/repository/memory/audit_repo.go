@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+type auditRepo struct {
+	*data
+}
+
+func (r *auditRepo) Append(e *models.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	}
+	stamp(&e.CreatedAt)
+	r.auditEvents = append(r.auditEvents, *e)
+	return nil
+}
+
+func (r *auditRepo) List(f repository.AuditFilter) ([]models.AuditEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.AuditEvent
+	for i := len(r.auditEvents) - 1; i >= 0; i-- {
+		e := r.auditEvents[i]
+		if (f.SubjectID == "" || e.SubjectID == f.SubjectID) && (f.Action == "" || e.Action == f.Action) {
+			list = append(list, e)
+		}
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	return page(list, limit, f.Offset), nil
+}
@@ -0,0 +1,547 @@
+package memory
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+type challengeRepo struct {
+	*data
+}
+
+func (r *challengeRepo) Create(ch *models.Challenge, teams []models.ChallengeTeam, participants []models.ChallengeParticipant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if ch.ID == "" {
+		ch.ID = uuid.NewString()
+	}
+	stamp(&ch.CreatedAt)
+	if _, ok := r.challenges[ch.ID]; ok {
+		return repository.ErrConflict
+	}
+	names := map[string]bool{}
+	for i := range teams {
+		if names[teams[i].Name] {
+			return repository.ErrConflict
+		}
+		names[teams[i].Name] = true
+		if teams[i].ID == "" {
+			teams[i].ID = uuid.NewString()
+		}
+		teams[i].ChallengeID = ch.ID
+	}
+	for _, p := range participants {
+		if _, ok := r.users[p.UserID]; !ok {
+			return repository.ErrNotFound
+		}
+	}
+
+	r.challenges[ch.ID] = *ch
+	r.teams = append(r.teams, teams...)
+	joined := time.Now().Format(time.RFC3339Nano)
+	for i := range participants {
+		p := &participants[i]
+		p.ChallengeID, p.JoinedAt = ch.ID, joined
+		r.participants = append(r.participants, *p)
+	}
+	return nil
+}
+
+func (r *challengeRepo) ByID(id string) (models.Challenge, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ch, ok := r.challenges[id]
+	if !ok {
+		return models.Challenge{}, repository.ErrNotFound
+	}
+	return ch, nil
+}
+
+func (r *challengeRepo) AddParticipant(challengeID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.challenges[challengeID]; !ok {
+		return repository.ErrNotFound
+	}
+	if _, ok := r.users[userID]; !ok {
+		return repository.ErrNotFound
+	}
+	r.enroll(challengeID, userID, "")
+	return nil
+}
+
+// enroll adds the participant on teamID or, when that is empty, on the
+// team with the fewest members. Callers hold the lock.
+func (r *challengeRepo) enroll(challengeID, userID, teamID string) {
+	if r.participating(challengeID, userID) {
+		return
+	}
+	if teamID == "" {
+		teamID = r.smallestTeam(challengeID)
+	}
+	r.participants = append(r.participants, models.ChallengeParticipant{
+		ChallengeID: challengeID, UserID: userID, JoinedAt: time.Now().Format(time.RFC3339Nano),
+		TeamID: teamID,
+	})
+}
+
+// participating reports whether the user takes part in the challenge.
+// Callers hold the lock.
+func (r *challengeRepo) participating(challengeID, userID string) bool {
+	for _, p := range r.participants {
+		if p.ChallengeID == challengeID && p.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// smallestTeam returns the ID of the challenge's team with the fewest
+// members, or "" when it has no teams. Callers hold the lock.
+func (r *challengeRepo) smallestTeam(challengeID string) string {
+	teams := r.teamsOf(challengeID)
+	if len(teams) == 0 {
+		return ""
+	}
+	members := map[string]int{}
+	for _, p := range r.participants {
+		if p.ChallengeID == challengeID {
+			members[p.TeamID]++
+		}
+	}
+	best := teams[0]
+	for _, t := range teams[1:] {
+		if members[t.ID] < members[best.ID] {
+			best = t
+		}
+	}
+	return best.ID
+}
+
+// teamsOf returns the challenge's teams in creation order. Callers hold
+// the lock.
+func (r *challengeRepo) teamsOf(challengeID string) []models.ChallengeTeam {
+	var list []models.ChallengeTeam
+	for _, t := range r.teams {
+		if t.ChallengeID == challengeID {
+			list = append(list, t)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Position < list[j].Position })
+	return list
+}
+
+func (r *challengeRepo) Teams(challengeID string) ([]models.ChallengeTeam, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.teamsOf(challengeID), nil
+}
+
+func (r *challengeRepo) Invite(invitations []models.ChallengeInvitation) ([]models.ChallengeInvitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var stored []models.ChallengeInvitation
+	for _, inv := range invitations {
+		if _, ok := r.challenges[inv.ChallengeID]; !ok {
+			return stored, repository.ErrNotFound
+		}
+		if _, ok := r.users[inv.InviteeID]; !ok {
+			return stored, repository.ErrNotFound
+		}
+		if r.participating(inv.ChallengeID, inv.InviteeID) {
+			continue
+		}
+		if inv.ID == "" {
+			inv.ID = uuid.NewString()
+		}
+		inv.Status, inv.CreatedAt, inv.RespondedAt = models.InvitationPending, time.Now(), nil
+		i := r.invitationTo(inv.ChallengeID, inv.InviteeID)
+		switch {
+		case i < 0:
+			r.invitations = append(r.invitations, inv)
+		case r.invitations[i].Status == models.InvitationDeclined:
+			inv.ID = r.invitations[i].ID
+			r.invitations[i] = inv
+		default:
+			continue
+		}
+		stored = append(stored, inv)
+	}
+	return stored, nil
+}
+
+// invitationTo returns the index of the user's invitation to the
+// challenge, or -1. Callers hold the lock.
+func (r *challengeRepo) invitationTo(challengeID, userID string) int {
+	for i, inv := range r.invitations {
+		if inv.ChallengeID == challengeID && inv.InviteeID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *challengeRepo) Invitation(id string) (models.ChallengeInvitation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, inv := range r.invitations {
+		if inv.ID == id {
+			return inv, nil
+		}
+	}
+	return models.ChallengeInvitation{}, repository.ErrNotFound
+}
+
+func (r *challengeRepo) InvitationTo(challengeID, userID string) (models.ChallengeInvitation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.invitationTo(challengeID, userID)
+	if i < 0 {
+		return models.ChallengeInvitation{}, repository.ErrNotFound
+	}
+	return r.invitations[i], nil
+}
+
+func (r *challengeRepo) Invitations(userID, status string) ([]models.ChallengeInvitation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.ChallengeInvitation
+	for _, inv := range r.invitations {
+		if inv.InviteeID != userID || (status != "" && inv.Status != status) {
+			continue
+		}
+		inv.Title = r.challenges[inv.ChallengeID].Title
+		inv.InviterName = r.users[inv.InviterID].Name
+		list = append(list, inv)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+func (r *challengeRepo) RespondInvitation(id, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, inv := range r.invitations {
+		if inv.ID != id {
+			continue
+		}
+		if inv.Status != models.InvitationPending {
+			return repository.ErrConflict
+		}
+		now := time.Now()
+		r.invitations[i].Status, r.invitations[i].RespondedAt = status, &now
+		if status == models.InvitationAccepted {
+			r.enroll(inv.ChallengeID, inv.InviteeID, inv.TeamID)
+		}
+		return nil
+	}
+	return repository.ErrNotFound
+}
+
+func (r *challengeRepo) RecordProgress(events []models.ProgressEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for i := range events {
+		e := &events[i]
+		if e.ID == "" {
+			e.ID = uuid.NewString()
+		}
+		e.RecordedAt = now
+		replaced := false
+		for j, old := range r.progressEvents {
+			if old.UserID == e.UserID && old.IdempotencyKey == e.IdempotencyKey {
+				e.ID = old.ID
+				r.progressEvents[j] = *e
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			r.progressEvents = append(r.progressEvents, *e)
+		}
+	}
+	return nil
+}
+
+func (r *challengeRepo) ProgressEvents(userID, metric string, since time.Time) ([]models.ProgressEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.ProgressEvent
+	for _, e := range r.progressEvents {
+		if e.UserID == userID && e.Metric == metric && !e.OccurredAt.Before(since) {
+			list = append(list, e)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].OccurredAt.Before(list[j].OccurredAt) })
+	return list, nil
+}
+
+func (r *challengeRepo) RecountProgress(userID, metric string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for i, p := range r.participants {
+		ch := r.challenges[p.ChallengeID]
+		if p.UserID != userID || ch.Type != metric || ch.Status != models.ChallengeActive {
+			continue
+		}
+		if err := r.recount(i); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// recount sets the progress of participant i from the ledger, as described
+// on repository.ChallengeRepository. Callers hold the lock.
+func (r *challengeRepo) recount(i int) error {
+	p := r.participants[i]
+	ch := r.challenges[p.ChallengeID]
+	joined, err := time.Parse(time.RFC3339Nano, p.JoinedAt)
+	if err != nil {
+		return err
+	}
+	if joined.Before(ch.StartsAt) {
+		joined = ch.StartsAt
+	}
+	total := 0.0
+	for _, e := range r.progressEvents {
+		if e.UserID == p.UserID && e.Metric == ch.Type {
+			total += e.Share(joined, ch.EndsAt)
+		}
+	}
+	r.participants[i].Progress = min(int(math.Floor(total+1e-9)), ch.Target)
+	return nil
+}
+
+// advance moves the challenges in state from that are due at now to state
+// to, recounting their participants, and returns them. Callers hold the
+// lock.
+func (r *challengeRepo) advance(from, to string, due func(models.Challenge) bool) ([]models.Challenge, error) {
+	var list []models.Challenge
+	for id, ch := range r.challenges {
+		if ch.Status != from || !due(ch) {
+			continue
+		}
+		ch.Status = to
+		r.challenges[id] = ch
+		for i, p := range r.participants {
+			if p.ChallengeID != id {
+				continue
+			}
+			if err := r.recount(i); err != nil {
+				return list, err
+			}
+		}
+		list = append(list, ch)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, nil
+}
+
+func (r *challengeRepo) Activate(now time.Time) ([]models.Challenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.advance(models.ChallengeScheduled, models.ChallengeActive, func(ch models.Challenge) bool {
+		return !ch.StartsAt.After(now)
+	})
+}
+
+func (r *challengeRepo) Finish(now time.Time) ([]models.Challenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, err := r.advance(models.ChallengeActive, models.ChallengeFinished, func(ch models.Challenge) bool {
+		return !ch.EndsAt.After(now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, ch := range list {
+		var standings []int // indexes into participants
+		for i, p := range r.participants {
+			if p.ChallengeID == ch.ID {
+				standings = append(standings, i)
+			}
+		}
+		sort.SliceStable(standings, func(a, b int) bool {
+			return r.participants[standings[a]].Progress > r.participants[standings[b]].Progress
+		})
+		for n, i := range standings {
+			rank := 1
+			if n > 0 {
+				prev := r.participants[standings[n-1]]
+				rank = *prev.FinalRank
+				if prev.Progress != r.participants[i].Progress {
+					rank++
+				}
+			}
+			r.participants[i].FinalRank = &rank
+		}
+	}
+	return list, nil
+}
+
+func (r *challengeRepo) Participants(challengeID string) ([]models.ChallengeParticipant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.ChallengeParticipant
+	for _, p := range r.participants {
+		if p.ChallengeID == challengeID {
+			p.Name, p.AvatarURL = r.users[p.UserID].Name, r.users[p.UserID].AvatarURL
+			list = append(list, p)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Progress > list[j].Progress })
+	return list, nil
+}
+
+func (r *challengeRepo) ListForUser(userID string) ([]models.Challenge, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	mine := map[string]bool{}
+	for _, p := range r.participants {
+		if p.UserID == userID {
+			mine[p.ChallengeID] = true
+		}
+	}
+	var list []models.Challenge
+	for _, ch := range r.challenges {
+		if ch.CreatorID == userID || mine[ch.ID] {
+			list = append(list, ch)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+func (r *challengeRepo) Discover(f repository.ChallengeFilter) ([]models.ChallengeSummary, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	size := map[string]int{}
+	joined := map[string]bool{}
+	for _, p := range r.participants {
+		size[p.ChallengeID]++
+		if p.UserID == f.ViewerID {
+			joined[p.ChallengeID] = true
+		}
+	}
+	day := 24 * time.Hour
+	var list []models.ChallengeSummary
+	for _, ch := range r.challenges {
+		n, length := size[ch.ID], ch.EndsAt.Sub(ch.StartsAt)
+		switch {
+		case ch.Visibility != models.ChallengePublic,
+			f.Type != "" && ch.Type != f.Type,
+			f.Status == "" && ch.Status == models.ChallengeFinished,
+			f.Status != "" && ch.Status != f.Status,
+			f.MinDays > 0 && length < time.Duration(f.MinDays)*day,
+			f.MaxDays > 0 && length > time.Duration(f.MaxDays)*day,
+			f.MinSize > 0 && n < f.MinSize,
+			f.MaxSize > 0 && n > f.MaxSize:
+			continue
+		}
+		list = append(list, models.ChallengeSummary{Challenge: ch, Participants: n, Joined: joined[ch.ID]})
+	}
+
+	before := func(a, b models.ChallengeSummary) bool {
+		if f.ByStart && !a.StartsAt.Equal(b.StartsAt) {
+			return a.StartsAt.Before(b.StartsAt)
+		}
+		if !f.ByStart && a.Participants != b.Participants {
+			return a.Participants > b.Participants
+		}
+		return a.ID < b.ID
+	}
+	sort.Slice(list, func(i, j int) bool { return before(list[i], list[j]) })
+	if f.After != nil {
+		last := models.ChallengeSummary{Participants: f.After.Participants}
+		last.ID, last.StartsAt = f.After.ID, f.After.StartsAt
+		list = keep(list, func(c models.ChallengeSummary) bool { return before(last, c) })
+	}
+	if f.Limit > 0 && len(list) > f.Limit {
+		list = list[:f.Limit]
+	}
+	return list, nil
+}
+
+func (r *challengeRepo) Completed(userID string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ids []string
+	for _, p := range r.participants {
+		if p.UserID == userID && p.Progress >= r.challenges[p.ChallengeID].Target {
+			ids = append(ids, p.ChallengeID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *challengeRepo) EndingBefore(until time.Time) ([]models.ParticipantProgress, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.ParticipantProgress
+	for _, p := range r.participants {
+		ch := r.challenges[p.ChallengeID]
+		if ch.Status == models.ChallengeActive && !ch.EndsAt.After(until) && p.Progress < ch.Target {
+			list = append(list, models.ParticipantProgress{
+				ChallengeID: ch.ID, UserID: p.UserID, Title: ch.Title, Target: ch.Target,
+				Progress: p.Progress, EndsAt: ch.EndsAt, Timezone: r.zone(p.UserID).String(),
+			})
+		}
+	}
+	return list, nil
+}
+
+func (r *challengeRepo) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.challenges[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.challenges, id)
+	participants := r.participants[:0]
+	for _, p := range r.participants {
+		if p.ChallengeID != id {
+			participants = append(participants, p)
+		}
+	}
+	r.participants = participants
+	r.teams = keep(r.teams, func(t models.ChallengeTeam) bool { return t.ChallengeID != id })
+	r.invitations = keep(r.invitations, func(inv models.ChallengeInvitation) bool { return inv.ChallengeID != id })
+	awards := r.userAchievements[:0]
+	for _, ua := range r.userAchievements {
+		if ua.challengeID != id {
+			awards = append(awards, ua)
+		}
+	}
+	r.userAchievements = awards
+	return nil
+}
@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+type identityRepo struct {
+	*data
+}
+
+func (r *identityRepo) Create(i *models.Identity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, have := range r.identities {
+		if (have.Provider == i.Provider && have.Subject == i.Subject) ||
+			(have.Provider == i.Provider && have.UserID == i.UserID) {
+			return repository.ErrConflict
+		}
+	}
+	if i.ID == "" {
+		i.ID = uuid.NewString()
+	}
+	stamp(&i.CreatedAt)
+	r.identities = append(r.identities, *i)
+	return nil
+}
+
+func (r *identityRepo) ByProviderSubject(provider, subject string) (models.Identity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, i := range r.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return i, nil
+		}
+	}
+	return models.Identity{}, repository.ErrNotFound
+}
+
+func (r *identityRepo) ListForUser(userID string) ([]models.Identity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.Identity
+	for _, i := range r.identities {
+		if i.UserID == userID {
+			list = append(list, i)
+		}
+	}
+	return list, nil
+}
+
+func (r *identityRepo) Touch(id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for n := range r.identities {
+		if r.identities[n].ID == id {
+			r.identities[n].LastLoginAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *identityRepo) Delete(userID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for n, i := range r.identities {
+		if i.ID == id && i.UserID == userID {
+			r.identities = append(r.identities[:n], r.identities[n+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+func (r *identityRepo) SaveLogin(l *models.OIDCLogin) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for hash, have := range r.oidcLogins {
+		if have.ExpiresAt.Before(now) {
+			delete(r.oidcLogins, hash)
+		}
+	}
+	r.oidcLogins[l.StateHash] = *l
+	return nil
+}
+
+func (r *identityRepo) TakeLogin(stateHash string, at time.Time) (models.OIDCLogin, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, ok := r.oidcLogins[stateHash]
+	if !ok {
+		return models.OIDCLogin{}, repository.ErrNotFound
+	}
+	delete(r.oidcLogins, stateHash)
+	if !l.ExpiresAt.After(at) {
+		return models.OIDCLogin{}, repository.ErrNotFound
+	}
+	return l, nil
+}
@@ -0,0 +1,139 @@
+// Package memory implements the repository interfaces on plain Go maps and
+// slices. It keeps everything in process and is meant for tests and local
+// demos, selected with STORAGE=memory.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/ratelimit"
+)
+
+// data holds every "table". A single lock guards all of them, which keeps
+// multi-table operations (friendships, challenge creation) atomic.
+type data struct {
+	mu sync.RWMutex
+
+	users            map[string]models.User
+	friendRequests   []models.FriendRequest
+	friends          map[string][]string // user id -> friend ids, in insertion order
+	achievements     []models.Achievement
+	userAchievements []userAchievement
+	activities       []models.Activity
+	steps            map[string]map[string]int      // user id -> day -> steps
+	stepBuckets      map[string][]models.StepBucket // by user id
+	goals            map[goalKey]int
+	meals            []models.Meal
+	water            []models.WaterLog
+	messages         []models.Message
+	posts            []models.PostActivity
+	challenges       map[string]models.Challenge
+	participants     []models.ChallengeParticipant
+	teams            []models.ChallengeTeam
+	invitations      []models.ChallengeInvitation
+	progressEvents   []models.ProgressEvent
+	workouts         []models.WorkoutSchedule
+	hydration        []models.HydrationSetting
+	refreshTokens    []models.RefreshToken
+	sessions         []models.Session
+	userTokens       []models.UserToken
+	totp             map[string]models.TOTP // by user id
+	recoveryCodes    []recoveryCode
+	adminActions     []models.AdminAction
+	auditEvents      []models.AuditEvent
+	buckets          map[string]ratelimit.Bucket
+	failures         map[string]ratelimit.Failures
+	identities       []models.Identity
+	oidcLogins       map[string]models.OIDCLogin // by state hash
+	accessTokens     []models.AccessToken
+}
+
+type userAchievement struct {
+	userID, achievementID, challengeID string
+}
+
+type recoveryCode struct {
+	userID, hash string
+	usedAt       *time.Time
+}
+
+type goalKey struct {
+	userID string
+	kind   models.GoalKind
+}
+
+// NewStore returns a repository.Store whose repositories share one empty
+// in-memory dataset.
+func NewStore() *repository.Store {
+	d := &data{
+		users:       map[string]models.User{},
+		friends:     map[string][]string{},
+		steps:       map[string]map[string]int{},
+		stepBuckets: map[string][]models.StepBucket{},
+		goals:       map[goalKey]int{},
+		challenges:  map[string]models.Challenge{},
+		totp:        map[string]models.TOTP{},
+		buckets:     map[string]ratelimit.Bucket{},
+		failures:    map[string]ratelimit.Failures{},
+		oidcLogins:  map[string]models.OIDCLogin{},
+	}
+	return &repository.Store{
+		Users:         &userRepo{d},
+		Friends:       &friendRepo{d},
+		Achievements:  &achievementRepo{d},
+		Activities:    &activityRepo{d},
+		Steps:         &stepRepo{d},
+		Goals:         &goalRepo{d},
+		Nutrition:     &nutritionRepo{d},
+		Messages:      &messageRepo{d},
+		Posts:         &postRepo{d},
+		Challenges:    &challengeRepo{d},
+		Schedules:     &scheduleRepo{d},
+		RefreshTokens: &refreshTokenRepo{d},
+		Sessions:      &sessionRepo{d},
+		RateLimits:    &rateLimitRepo{d},
+		AdminActions:  &adminActionRepo{d},
+		Audit:         &auditRepo{d},
+		PersonalData:  &personalDataRepo{d},
+		Identities:    &identityRepo{d},
+		AccessTokens:  &accessTokenRepo{d},
+		TwoFactor:     &twoFactorRepo{d},
+		UserTokens:    &userTokenRepo{d},
+	}
+}
+
+// zone returns the user's time zone, UTC for unknown users or zones, the
+// way users.timezone defaults. Callers hold the lock.
+func (d *data) zone(userID string) *time.Location {
+	if loc, err := time.LoadLocation(d.users[userID].Timezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// stamp fills a zero creation time the way a DEFAULT now() column would.
+func stamp(t *time.Time) {
+	if t.IsZero() {
+		*t = time.Now()
+	}
+}
+
+// between reports whether t lies in [from, to).
+func between(t, from, to time.Time) bool {
+	return !t.Before(from) && t.Before(to)
+}
+
+// page applies LIMIT and OFFSET to list; a negative limit means no limit.
+func page[T any](list []T, limit, offset int) []T {
+	if offset >= len(list) {
+		return nil
+	}
+	list = list[offset:]
+	if limit >= 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
@@ -0,0 +1,684 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/ratelimit"
+)
+
+func TestUsers_UniqueEmailAndLookup(t *testing.T) {
+	store := NewStore()
+
+	u := models.User{Name: "Ann", Email: "ann@test.com"}
+	require.NoError(t, store.Users.Create(&u))
+	assert.NotEmpty(t, u.ID)
+
+	err := store.Users.Create(&models.User{Name: "Other", Email: "ann@test.com"})
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	got, err := store.Users.ByEmail("ann@test.com")
+	require.NoError(t, err)
+	assert.Equal(t, u.ID, got.ID)
+
+	_, err = store.Users.ByID("missing")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestFriends_RequestAndFriendship(t *testing.T) {
+	store := NewStore()
+	ann := models.User{Name: "Ann", Email: "ann@test.com"}
+	bob := models.User{Name: "Bob", Email: "bob@test.com"}
+	require.NoError(t, store.Users.Create(&ann))
+	require.NoError(t, store.Users.Create(&bob))
+
+	req := models.FriendRequest{RequesterID: ann.ID, RecipientID: bob.ID}
+	require.NoError(t, store.Friends.CreateRequest(&req))
+
+	incoming, err := store.Friends.ListIncoming(bob.ID)
+	require.NoError(t, err)
+	require.Len(t, incoming, 1)
+	assert.Equal(t, "ann@test.com", incoming[0].Email)
+
+	_, err = store.Friends.IncomingRequest(req.ID, ann.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	require.NoError(t, store.Friends.AddFriendship(bob.ID, ann.ID))
+	require.NoError(t, store.Friends.AddFriendship(bob.ID, ann.ID))
+	require.NoError(t, store.Friends.DeleteRequest(req.ID, bob.ID))
+
+	ids, err := store.Friends.FriendIDs(ann.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{bob.ID}, ids)
+	incoming, _ = store.Friends.ListIncoming(bob.ID)
+	assert.Empty(t, incoming)
+}
+
+func TestSteps_UpsertReplacesDay(t *testing.T) {
+	store := NewStore()
+
+	require.NoError(t, store.Steps.Upsert("u1", "2025-07-01", 1000))
+	require.NoError(t, store.Steps.Upsert("u1", "2025-07-02", 3000))
+	require.NoError(t, store.Steps.Upsert("u1", "2025-07-01", 2000))
+	assert.Error(t, store.Steps.Upsert("u1", "July 1st", 10))
+
+	list, err := store.Steps.ListSince("u1", "2025-07-01")
+	require.NoError(t, err)
+	assert.Equal(t, []models.StepDay{{Day: "2025-07-02", Steps: 3000}, {Day: "2025-07-01", Steps: 2000}}, list)
+
+	total, err := store.Steps.Total("u1")
+	require.NoError(t, err)
+	assert.Equal(t, 5000, total)
+}
+
+func TestNutrition_BetweenIsHalfOpen(t *testing.T) {
+	store := NewStore()
+	day := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, at := range []time.Time{day.Add(-time.Second), day, day.Add(23 * time.Hour), day.AddDate(0, 0, 1)} {
+		require.NoError(t, store.Nutrition.AddWater(&models.WaterLog{UserID: "u1", AmountML: 250, CreatedAt: at}))
+	}
+
+	list, err := store.Nutrition.WaterBetween("u1", day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+}
+
+func TestNutrition_RetriedLogReturnsFirstEntry(t *testing.T) {
+	store := NewStore()
+
+	first := models.WaterLog{UserID: "u1", AmountML: 250, IdempotencyKey: "k1"}
+	require.NoError(t, store.Nutrition.AddWater(&first))
+	retry := models.WaterLog{UserID: "u1", AmountML: 300, IdempotencyKey: "k1"}
+	require.NoError(t, store.Nutrition.AddWater(&retry))
+	other := models.WaterLog{UserID: "u2", AmountML: 250, IdempotencyKey: "k1"}
+	require.NoError(t, store.Nutrition.AddWater(&other))
+
+	assert.Equal(t, first, retry)
+	assert.NotEqual(t, first.ID, other.ID, "keys are per user")
+	list, err := store.Nutrition.WaterBetween("u1", time.Time{}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestChallenges_ProgressComesFromLedger(t *testing.T) {
+	store := NewStore()
+	ann := models.User{Name: "Ann", Email: "ann@test.com"}
+	require.NoError(t, store.Users.Create(&ann))
+
+	before := time.Now().Add(-time.Hour)
+	ch := models.Challenge{
+		CreatorID: ann.ID, Type: "steps", Target: 100, Title: "Walk",
+		StartsAt: before.Add(-time.Hour), EndsAt: time.Now().Add(time.Hour), Status: models.ChallengeActive,
+	}
+	require.NoError(t, store.Challenges.Create(&ch, nil, []models.ChallengeParticipant{{UserID: ann.ID}}))
+	assert.ErrorIs(t, store.Challenges.AddParticipant("missing", ann.ID), repository.ErrNotFound)
+
+	event := func(key string, amount int, at time.Time) models.ProgressEvent {
+		return models.ProgressEvent{UserID: ann.ID, Metric: "steps", Source: "steps", Amount: amount, IdempotencyKey: key, OccurredAt: at}
+	}
+	progress := func() int {
+		board, err := store.Challenges.Participants(ch.ID)
+		require.NoError(t, err)
+		require.Len(t, board, 1)
+		return board[0].Progress
+	}
+
+	require.NoError(t, store.Challenges.RecordProgress([]models.ProgressEvent{
+		event("early", 50, before),
+		event("a", 30, time.Now()),
+		event("a", 40, time.Now()),
+	}))
+	n, err := store.Challenges.RecountProgress(ann.ID, "steps")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 40, progress(), "events before joining do not count; a repeated key replaces")
+	n, _ = store.Challenges.RecountProgress(ann.ID, "calories")
+	assert.Equal(t, 0, n)
+
+	events, err := store.Challenges.ProgressEvents(ann.ID, "steps", before)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "early", events[0].IdempotencyKey)
+
+	require.NoError(t, store.Challenges.RecordProgress([]models.ProgressEvent{event("b", 70, time.Now())}))
+	_, _ = store.Challenges.RecountProgress(ann.ID, "steps")
+	assert.Equal(t, 100, progress())
+	done, err := store.Challenges.Completed(ann.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{ch.ID}, done)
+
+	require.NoError(t, store.Challenges.RecordProgress([]models.ProgressEvent{event("b", 0, time.Now())}))
+	_, _ = store.Challenges.RecountProgress(ann.ID, "steps")
+	assert.Equal(t, 40, progress())
+}
+
+func TestChallenges_LifecycleFreezesStandings(t *testing.T) {
+	store := NewStore()
+	var ids []string
+	var participants []models.ChallengeParticipant
+	for _, name := range []string{"ann", "bob", "cat", "dan"} {
+		u := models.User{Name: name, Email: name + "@test.com"}
+		require.NoError(t, store.Users.Create(&u))
+		ids = append(ids, u.ID)
+		participants = append(participants, models.ChallengeParticipant{UserID: u.ID})
+	}
+
+	now := time.Now()
+	start, end := now.Add(time.Hour), now.Add(3*time.Hour)
+	ch := models.Challenge{Type: "workouts", Target: 10, Title: "Train", StartsAt: start, EndsAt: end, Status: models.ChallengeScheduled}
+	require.NoError(t, store.Challenges.Create(&ch, nil, participants))
+
+	record := func(user int, key string, at time.Time) {
+		require.NoError(t, store.Challenges.RecordProgress([]models.ProgressEvent{{
+			UserID: ids[user], Metric: "workouts", Source: "activity", Amount: 1, IdempotencyKey: key, OccurredAt: at,
+		}}))
+	}
+	record(0, "early", now)                 // before the start
+	record(0, "a1", start.Add(time.Minute)) // inside
+	record(0, "a2", start.Add(time.Minute)) // inside
+	record(1, "b1", start.Add(time.Minute)) // inside
+	record(1, "b2", start.Add(time.Minute)) // inside
+	record(2, "c1", start.Add(time.Minute)) // inside
+	record(2, "late", end.Add(time.Minute)) // after the end
+	from, until := start.Add(-time.Hour), start.Add(time.Hour)
+	require.NoError(t, store.Challenges.RecordProgress([]models.ProgressEvent{{
+		UserID: ids[2], Metric: "workouts", Source: "test", Amount: 2, IdempotencyKey: "period",
+		OccurredAt: from, LastsUntil: &until,
+	}})) // a period half inside the window counts half
+
+	started, err := store.Challenges.Activate(now)
+	require.NoError(t, err)
+	assert.Empty(t, started, "not due yet")
+	started, err = store.Challenges.Activate(start)
+	require.NoError(t, err)
+	require.Len(t, started, 1)
+	assert.Equal(t, models.ChallengeActive, started[0].Status)
+
+	ending, err := store.Challenges.EndingBefore(start.Add(24 * time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, ending, 4)
+
+	finished, err := store.Challenges.Finish(end.Add(-time.Second))
+	require.NoError(t, err)
+	assert.Empty(t, finished)
+	finished, err = store.Challenges.Finish(end)
+	require.NoError(t, err)
+	require.Len(t, finished, 1)
+
+	board, err := store.Challenges.Participants(ch.ID)
+	require.NoError(t, err)
+	ranks := map[string]int{}
+	progress := map[string]int{}
+	for _, p := range board {
+		require.NotNil(t, p.FinalRank)
+		ranks[p.UserID], progress[p.UserID] = *p.FinalRank, p.Progress
+	}
+	assert.Equal(t, map[string]int{ids[0]: 2, ids[1]: 2, ids[2]: 2, ids[3]: 0}, progress)
+	assert.Equal(t, map[string]int{ids[0]: 1, ids[1]: 1, ids[2]: 1, ids[3]: 2}, ranks, "ranks are dense")
+
+	// finished standings no longer move
+	record(3, "d1", start.Add(time.Minute))
+	n, err := store.Challenges.RecountProgress(ids[3], "workouts")
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	ending, _ = store.Challenges.EndingBefore(end.Add(24 * time.Hour))
+	assert.Empty(t, ending)
+}
+
+func TestSchedules_TimeIsNormalised(t *testing.T) {
+	store := NewStore()
+
+	w := models.WorkoutSchedule{UserID: "u1", Weekday: 2, AtTime: "18:30", Title: "Run"}
+	require.NoError(t, store.Schedules.AddWorkout(&w))
+	assert.Error(t, store.Schedules.AddWorkout(&models.WorkoutSchedule{UserID: "u1", AtTime: "soon"}))
+
+	wednesday := time.Date(2025, 7, 2, 18, 30, 0, 0, time.UTC)
+	list, err := store.Schedules.WorkoutsDue(wednesday)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "18:30:00", list[0].AtTime)
+
+	require.NoError(t, store.Schedules.DeleteWorkout("u1", w.ID))
+	list, _ = store.Schedules.ListWorkouts("u1")
+	assert.Empty(t, list)
+}
+
+func TestSchedules_DueInOwnersZone(t *testing.T) {
+	store := NewStore()
+	ann := models.User{Name: "Ann", Email: "ann@test.com"}
+	require.NoError(t, store.Users.Create(&ann))
+	assert.Equal(t, "UTC", ann.Timezone)
+	require.NoError(t, store.Users.UpdateProfile(&models.User{ID: ann.ID, Name: "Ann", Timezone: "Asia/Tokyo"}))
+	got, _ := store.Users.ByID(ann.ID)
+	assert.Equal(t, "Asia/Tokyo", got.Timezone)
+	require.NoError(t, store.Users.UpdateProfile(&models.User{ID: ann.ID, Name: "Ann"}))
+	got, _ = store.Users.ByID(ann.ID)
+	assert.Equal(t, "Asia/Tokyo", got.Timezone, "an empty zone keeps the current one")
+
+	// Monday 07:00 in Tokyo is Sunday 22:00 UTC
+	require.NoError(t, store.Schedules.AddWorkout(&models.WorkoutSchedule{UserID: ann.ID, Weekday: 0, AtTime: "07:00", Title: "Swim"}))
+	list, err := store.Schedules.WorkoutsDue(time.Date(2025, 7, 6, 22, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+	list, _ = store.Schedules.WorkoutsDue(time.Date(2025, 7, 7, 7, 0, 0, 0, time.UTC))
+	assert.Empty(t, list)
+}
+
+func TestTwoFactor_StepsAndRecoveryCodes(t *testing.T) {
+	store := NewStore()
+
+	require.NoError(t, store.TwoFactor.Save(&models.TOTP{UserID: "u1", Secret: "S"}))
+	ok, err := store.TwoFactor.UseStep("u1", 10)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, _ = store.TwoFactor.UseStep("u1", 10)
+	assert.False(t, ok, "a step is accepted once")
+
+	require.NoError(t, store.TwoFactor.ReplaceRecoveryCodes("u1", []string{"h1", "h2"}))
+	require.NoError(t, store.TwoFactor.UseRecoveryCode("u1", "h1", time.Now()))
+	assert.ErrorIs(t, store.TwoFactor.UseRecoveryCode("u1", "h1", time.Now()), repository.ErrNotFound)
+	assert.ErrorIs(t, store.TwoFactor.UseRecoveryCode("u2", "h2", time.Now()), repository.ErrNotFound)
+
+	require.NoError(t, store.TwoFactor.Delete("u1"))
+	_, err = store.TwoFactor.ByUser("u1")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.ErrorIs(t, store.TwoFactor.UseRecoveryCode("u1", "h2", time.Now()), repository.ErrNotFound)
+}
+
+func TestUsers_SearchAndSuspend(t *testing.T) {
+	store := NewStore()
+	for _, u := range []models.User{{Name: "Ann", Email: "ann@test.com"}, {Name: "Bob", Email: "bob@test.com"}, {Name: "Joanna", Email: "jo@test.com"}} {
+		require.NoError(t, store.Users.Create(&u))
+	}
+
+	list, err := store.Users.Search("ANN", -1, 0)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "ann@test.com", list[0].Email)
+	assert.Equal(t, models.RoleUser, list[0].Role)
+	list, _ = store.Users.Search("", 1, 1)
+	require.Len(t, list, 1)
+	assert.Equal(t, "bob@test.com", list[0].Email)
+
+	now := time.Now()
+	require.NoError(t, store.Users.SetSuspended(list[0].ID, &now))
+	got, _ := store.Users.ByID(list[0].ID)
+	assert.NotNil(t, got.SuspendedAt)
+	require.NoError(t, store.Users.SetSuspended(list[0].ID, nil))
+	got, _ = store.Users.ByID(list[0].ID)
+	assert.Nil(t, got.SuspendedAt)
+	assert.ErrorIs(t, store.Users.SetSuspended("missing", nil), repository.ErrNotFound)
+}
+
+func TestAchievements_CatalogEdits(t *testing.T) {
+	store := NewStore()
+
+	a := models.Achievement{Title: "Early bird"}
+	require.NoError(t, store.Achievements.Create(&a))
+	assert.ErrorIs(t, store.Achievements.Create(&models.Achievement{Title: "Early bird"}), repository.ErrConflict)
+	b := models.Achievement{Title: "Night owl"}
+	require.NoError(t, store.Achievements.Create(&b))
+
+	assert.ErrorIs(t, store.Achievements.Update(&models.Achievement{ID: b.ID, Title: "Early bird"}), repository.ErrConflict)
+	require.NoError(t, store.Achievements.Update(&models.Achievement{ID: b.ID, Title: "Night owl", Description: "Log after 23:00"}))
+
+	require.NoError(t, store.Achievements.Award("u1", a.ID, ""))
+	require.NoError(t, store.Achievements.Delete(a.ID))
+	assert.ErrorIs(t, store.Achievements.Delete(a.ID), repository.ErrNotFound)
+	mine, err := store.Achievements.ListUnlocked("u1")
+	require.NoError(t, err)
+	assert.Empty(t, mine)
+}
+
+func TestChallenges_JoinersBalanceTeams(t *testing.T) {
+	store := NewStore()
+	var ids []string
+	for _, name := range []string{"ann", "bob", "cat", "dan"} {
+		u := models.User{Name: name, Email: name + "@test.com"}
+		require.NoError(t, store.Users.Create(&u))
+		ids = append(ids, u.ID)
+	}
+
+	now := time.Now()
+	ch := models.Challenge{Type: "workouts", Target: 10, Title: "Relay", StartsAt: now, EndsAt: now.Add(time.Hour),
+		Status: models.ChallengeActive, TeamScoring: models.TeamScoringSum}
+	dup := []models.ChallengeTeam{{Name: "Red"}, {Name: "Red", Position: 1}}
+	assert.ErrorIs(t, store.Challenges.Create(&ch, dup, nil), repository.ErrConflict)
+
+	teams := []models.ChallengeTeam{{ID: uuid.NewString(), Name: "Red"}, {ID: uuid.NewString(), Name: "Blue", Position: 1}}
+	require.NoError(t, store.Challenges.Create(&ch, teams, []models.ChallengeParticipant{{UserID: ids[0], TeamID: teams[1].ID}}))
+	for _, id := range ids[1:] {
+		require.NoError(t, store.Challenges.AddParticipant(ch.ID, id))
+	}
+
+	got, err := store.Challenges.Teams(ch.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "Red", got[0].Name)
+
+	board, err := store.Challenges.Participants(ch.ID)
+	require.NoError(t, err)
+	team := map[string]string{}
+	for _, p := range board {
+		team[p.UserID] = p.TeamID
+	}
+	red, blue := teams[0].ID, teams[1].ID
+	assert.Equal(t, map[string]string{ids[0]: blue, ids[1]: red, ids[2]: red, ids[3]: blue}, team)
+}
+
+func TestChallenges_InvitationsEnrollOnAccept(t *testing.T) {
+	store := NewStore()
+	var ids []string
+	for _, name := range []string{"ann", "bob", "cat"} {
+		u := models.User{Name: name, Email: name + "@test.com"}
+		require.NoError(t, store.Users.Create(&u))
+		ids = append(ids, u.ID)
+	}
+	ann, bob, cat := ids[0], ids[1], ids[2]
+
+	now := time.Now()
+	ch := models.Challenge{Type: "workouts", Target: 10, Title: "Duel", StartsAt: now, EndsAt: now.Add(time.Hour),
+		Status: models.ChallengeActive, TeamScoring: models.TeamScoringSum, Visibility: models.ChallengePrivate}
+	teams := []models.ChallengeTeam{{ID: uuid.NewString(), Name: "Red"}, {ID: uuid.NewString(), Name: "Blue", Position: 1}}
+	require.NoError(t, store.Challenges.Create(&ch, teams, []models.ChallengeParticipant{{UserID: ann, TeamID: teams[0].ID}}))
+
+	stored, err := store.Challenges.Invite([]models.ChallengeInvitation{
+		{ChallengeID: ch.ID, InviterID: ann, InviteeID: ann},
+		{ChallengeID: ch.ID, InviterID: ann, InviteeID: bob, TeamID: teams[0].ID},
+		{ChallengeID: ch.ID, InviterID: ann, InviteeID: cat},
+	})
+	require.NoError(t, err)
+	require.Len(t, stored, 2, "participants are skipped")
+
+	list, err := store.Challenges.Invitations(bob, models.InvitationPending)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "Duel", list[0].Title)
+	assert.Equal(t, "ann", list[0].InviterName)
+
+	require.NoError(t, store.Challenges.RespondInvitation(stored[0].ID, models.InvitationAccepted))
+	assert.ErrorIs(t, store.Challenges.RespondInvitation(stored[0].ID, models.InvitationDeclined), repository.ErrConflict)
+	require.NoError(t, store.Challenges.RespondInvitation(stored[1].ID, models.InvitationDeclined))
+
+	board, err := store.Challenges.Participants(ch.ID)
+	require.NoError(t, err)
+	require.Len(t, board, 2)
+	for _, p := range board {
+		assert.Equal(t, teams[0].ID, p.TeamID, "the invitation's team wins over balancing")
+	}
+
+	// a declined invitation can be sent again, an open one cannot
+	again, err := store.Challenges.Invite([]models.ChallengeInvitation{{ChallengeID: ch.ID, InviterID: ann, InviteeID: cat}})
+	require.NoError(t, err)
+	require.Len(t, again, 1)
+	assert.Equal(t, stored[1].ID, again[0].ID)
+	again, err = store.Challenges.Invite([]models.ChallengeInvitation{{ChallengeID: ch.ID, InviterID: ann, InviteeID: cat}})
+	require.NoError(t, err)
+	assert.Empty(t, again)
+	inv, err := store.Challenges.InvitationTo(ch.ID, cat)
+	require.NoError(t, err)
+	assert.Equal(t, models.InvitationPending, inv.Status)
+}
+
+func TestAudit_ListFiltersNewestFirst(t *testing.T) {
+	store := NewStore()
+	for _, e := range []models.AuditEvent{
+		{SubjectID: "u1", Action: "auth.login"},
+		{SubjectID: "u2", Action: "auth.login"},
+		{SubjectID: "u1", Action: "goal.update"},
+		{SubjectID: "u1", Action: "auth.login"},
+	} {
+		require.NoError(t, store.Audit.Append(&e))
+	}
+
+	list, err := store.Audit.List(repository.AuditFilter{SubjectID: "u1"})
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	assert.Equal(t, "auth.login", list[0].Action)
+	assert.Equal(t, "goal.update", list[1].Action)
+
+	list, _ = store.Audit.List(repository.AuditFilter{Action: "auth.login", Limit: 1, Offset: 1})
+	require.Len(t, list, 1)
+	assert.Equal(t, "u2", list[0].SubjectID)
+}
+
+func TestPersonalData_ExportAndErase(t *testing.T) {
+	store := NewStore()
+	ann := models.User{Name: "Ann", Email: "Ann@test.com"}
+	bob := models.User{Name: "Bob", Email: "bob@test.com"}
+	require.NoError(t, store.Users.Create(&ann))
+	require.NoError(t, store.Users.Create(&bob))
+
+	require.NoError(t, store.Friends.AddFriendship(ann.ID, bob.ID))
+	require.NoError(t, store.Steps.Upsert(ann.ID, "2025-07-02", 300))
+	require.NoError(t, store.Steps.Upsert(ann.ID, "2025-07-01", 100))
+	require.NoError(t, store.Nutrition.AddWater(&models.WaterLog{UserID: ann.ID, AmountML: 250}))
+	ch := models.Challenge{CreatorID: ann.ID, Type: "steps", Target: 100, Title: "Walk"}
+	require.NoError(t, store.Challenges.Create(&ch, nil, []models.ChallengeParticipant{{UserID: ann.ID}, {UserID: bob.ID}}))
+	require.NoError(t, store.Audit.Append(&models.AuditEvent{SubjectID: ann.ID, Action: "auth.login", IP: "10.0.0.1"}))
+
+	d, err := store.PersonalData.Export(ann.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Ann@test.com", d.User.Email)
+	assert.Equal(t, []models.StepDay{{Day: "2025-07-01", Steps: 100}, {Day: "2025-07-02", Steps: 300}}, d.Steps)
+	assert.Len(t, d.Friends, 1)
+	assert.Len(t, d.WaterLogs, 1)
+	assert.Len(t, d.Challenges, 1)
+	assert.Len(t, d.AuditEvents, 1)
+
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, store.Users.SetDeleteAfter(ann.ID, &past))
+	due, err := store.Users.DueForDeletion(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{ann.ID}, due)
+
+	require.NoError(t, store.PersonalData.Erase(ann.ID))
+	_, err = store.Users.ByID(ann.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.ErrorIs(t, store.PersonalData.Erase(ann.ID), repository.ErrNotFound)
+
+	ids, _ := store.Friends.FriendIDs(bob.ID)
+	assert.Empty(t, ids)
+	board, _ := store.Challenges.Participants(ch.ID)
+	require.Len(t, board, 1, "the challenge outlives its creator")
+	assert.Equal(t, bob.ID, board[0].UserID)
+	events, _ := store.Audit.List(repository.AuditFilter{SubjectID: ann.ID})
+	require.Len(t, events, 1)
+	assert.Empty(t, events[0].IP)
+}
+
+func TestIdentities_LinkAndPendingLogins(t *testing.T) {
+	store := NewStore()
+
+	i := models.Identity{UserID: "u1", Provider: "mock", Subject: "s1", Email: "ann@test.com"}
+	require.NoError(t, store.Identities.Create(&i))
+	assert.ErrorIs(t, store.Identities.Create(&models.Identity{UserID: "u2", Provider: "mock", Subject: "s1"}), repository.ErrConflict)
+	assert.ErrorIs(t, store.Identities.Create(&models.Identity{UserID: "u1", Provider: "mock", Subject: "s2"}), repository.ErrConflict)
+	require.NoError(t, store.Identities.Create(&models.Identity{UserID: "u1", Provider: "other", Subject: "s1"}))
+
+	got, err := store.Identities.ByProviderSubject("mock", "s1")
+	require.NoError(t, err)
+	assert.Equal(t, i.ID, got.ID)
+	assert.ErrorIs(t, store.Identities.Delete("u2", i.ID), repository.ErrNotFound)
+	require.NoError(t, store.Identities.Delete("u1", i.ID))
+	list, _ := store.Identities.ListForUser("u1")
+	assert.Len(t, list, 1)
+
+	now := time.Now()
+	require.NoError(t, store.Identities.SaveLogin(&models.OIDCLogin{StateHash: "h1", Provider: "mock", ExpiresAt: now.Add(time.Minute)}))
+	require.NoError(t, store.Identities.SaveLogin(&models.OIDCLogin{StateHash: "h2", Provider: "mock", ExpiresAt: now.Add(time.Minute)}))
+	l, err := store.Identities.TakeLogin("h1", now)
+	require.NoError(t, err)
+	assert.Equal(t, "mock", l.Provider)
+	_, err = store.Identities.TakeLogin("h1", now)
+	assert.ErrorIs(t, err, repository.ErrNotFound, "a login is taken once")
+	_, err = store.Identities.TakeLogin("h2", now.Add(2*time.Minute))
+	assert.ErrorIs(t, err, repository.ErrNotFound, "expired")
+}
+
+func TestAccessTokens_LookupAndRevoke(t *testing.T) {
+	store := NewStore()
+	now := time.Now()
+
+	old := models.AccessToken{UserID: "u1", Name: "old", TokenHash: "h1", Scopes: models.ScopeList{models.ScopeStepsRead}, ExpiresAt: now.Add(time.Hour), CreatedAt: now.Add(-time.Hour)}
+	require.NoError(t, store.AccessTokens.Create(&old))
+	fresh := models.AccessToken{UserID: "u1", Name: "fresh", TokenHash: "h2", ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, store.AccessTokens.Create(&fresh))
+
+	got, err := store.AccessTokens.ByHash("h1")
+	require.NoError(t, err)
+	assert.Equal(t, old.ID, got.ID)
+	assert.True(t, got.Scopes.Has(models.ScopeStepsRead))
+	_, err = store.AccessTokens.ByHash("nope")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	require.NoError(t, store.AccessTokens.Touch(old.ID, now))
+	list, _ := store.AccessTokens.ListForUser("u1")
+	require.Len(t, list, 2)
+	assert.Equal(t, "fresh", list[0].Name)
+	assert.NotNil(t, list[1].LastUsedAt)
+
+	assert.ErrorIs(t, store.AccessTokens.Revoke("u2", old.ID, now), repository.ErrNotFound)
+	require.NoError(t, store.AccessTokens.Revoke("u1", old.ID, now))
+	assert.ErrorIs(t, store.AccessTokens.Revoke("u1", old.ID, now), repository.ErrNotFound)
+	list, _ = store.AccessTokens.ListForUser("u1")
+	assert.Len(t, list, 1)
+}
+
+func TestSteps_BucketsMergeAcrossSources(t *testing.T) {
+	store := NewStore()
+	hour := func(h int) *int { return &h }
+	day := "2025-07-01"
+
+	require.NoError(t, store.Steps.SaveBuckets("u1", []models.StepBucket{
+		{Source: "phone", Day: day, Hour: hour(9), Steps: 1200},
+		{Source: "phone", Day: day, Hour: hour(10), Steps: 300},
+		{Source: "watch", Day: day, Hour: hour(9), Steps: 1500},
+	}))
+	total := func() int {
+		days, err := store.Steps.ListSince("u1", day)
+		require.NoError(t, err)
+		require.Len(t, days, 1)
+		return days[0].Steps
+	}
+	assert.Equal(t, 1800, total(), "the same hour from two devices counts once")
+
+	require.NoError(t, store.Steps.SaveBuckets("u1", []models.StepBucket{{Source: "phone", Day: day, Hour: hour(10), Steps: 500}}))
+	assert.Equal(t, 2000, total(), "a resent hour replaces the earlier count")
+
+	require.NoError(t, store.Steps.SaveBuckets("u1", []models.StepBucket{{Source: "manual", Day: day, Steps: 5000}}))
+	assert.Equal(t, 5000, total(), "a larger whole-day total wins")
+
+	buckets, err := store.Steps.Buckets("u1", day)
+	require.NoError(t, err)
+	require.Len(t, buckets, 4)
+	assert.Equal(t, "manual", buckets[0].Source)
+	assert.Error(t, store.Steps.SaveBuckets("u1", []models.StepBucket{{Source: "phone", Day: day, Hour: hour(24), Steps: 1}}))
+
+	require.NoError(t, store.Steps.Delete("u1", day))
+	assert.ErrorIs(t, store.Steps.Delete("u1", day), repository.ErrNotFound)
+	buckets, _ = store.Steps.Buckets("u1", day)
+	assert.Empty(t, buckets)
+}
+
+func TestChallenges_DiscoverFiltersAndPages(t *testing.T) {
+	store := NewStore()
+	users := map[string]string{}
+	for _, name := range []string{"ann", "bob", "cat", "dan"} {
+		u := models.User{Name: name, Email: name + "@test.com"}
+		require.NoError(t, store.Users.Create(&u))
+		users[name] = u.ID
+	}
+	now := time.Now()
+	create := func(title, visibility, status string, days int, names ...string) string {
+		ch := models.Challenge{Type: "distance", Target: 10, Title: title, StartsAt: now.AddDate(0, 0, len(names)), Status: status, Visibility: visibility}
+		ch.EndsAt = ch.StartsAt.AddDate(0, 0, days)
+		var participants []models.ChallengeParticipant
+		for _, name := range names {
+			participants = append(participants, models.ChallengeParticipant{UserID: users[name]})
+		}
+		require.NoError(t, store.Challenges.Create(&ch, nil, participants))
+		return ch.ID
+	}
+	big := create("Big", models.ChallengePublic, models.ChallengeActive, 7, "ann", "bob", "cat")
+	small := create("Small", models.ChallengePublic, models.ChallengeScheduled, 30, "bob")
+	mid := create("Mid", models.ChallengePublic, models.ChallengeActive, 14, "ann", "bob")
+	create("Hidden", models.ChallengePrivate, models.ChallengeActive, 7, "ann", "bob", "cat", "dan")
+	create("Done", models.ChallengePublic, models.ChallengeFinished, 7, "ann", "bob", "cat", "dan")
+
+	ids := func(list []models.ChallengeSummary) []string {
+		var out []string
+		for _, c := range list {
+			out = append(out, c.ID)
+		}
+		return out
+	}
+	list, err := store.Challenges.Discover(repository.ChallengeFilter{ViewerID: users["cat"]})
+	require.NoError(t, err)
+	assert.Equal(t, []string{big, mid, small}, ids(list), "most popular first, without private or finished ones")
+	assert.Equal(t, 3, list[0].Participants)
+	assert.True(t, list[0].Joined)
+	assert.False(t, list[1].Joined)
+
+	list, err = store.Challenges.Discover(repository.ChallengeFilter{ByStart: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{small, mid, big}, ids(list))
+
+	list, err = store.Challenges.Discover(repository.ChallengeFilter{MinDays: 10, MaxSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{mid, small}, ids(list))
+
+	list, err = store.Challenges.Discover(repository.ChallengeFilter{Status: models.ChallengeScheduled})
+	require.NoError(t, err)
+	assert.Equal(t, []string{small}, ids(list))
+
+	var paged []string
+	f := repository.ChallengeFilter{Limit: 2}
+	for {
+		list, err = store.Challenges.Discover(f)
+		require.NoError(t, err)
+		paged = append(paged, ids(list)...)
+		if len(list) < f.Limit {
+			break
+		}
+		last := list[len(list)-1]
+		f.After = &repository.ChallengeCursor{ID: last.ID, Participants: last.Participants, StartsAt: last.StartsAt}
+	}
+	assert.Equal(t, []string{big, mid, small}, paged)
+}
+
+func TestRateLimits_SweepDropsIdleKeys(t *testing.T) {
+	store := NewStore()
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	limit := ratelimit.PerMinute(5)
+	lockout := ratelimit.Lockout{MaxFailures: 2, Window: time.Minute, Duration: 2 * time.Hour}
+
+	_, err := store.RateLimits.Take("old", limit, now.Add(-2*time.Hour))
+	require.NoError(t, err)
+	_, err = store.RateLimits.Take("new", limit, now)
+	require.NoError(t, err)
+	_, err = store.RateLimits.AddFailure("stale", lockout, now.Add(-2*time.Hour))
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = store.RateLimits.AddFailure("locked", lockout, now.Add(-90*time.Minute))
+		require.NoError(t, err)
+	}
+
+	n, err := store.RateLimits.Sweep(now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	locked, err := store.RateLimits.Failures("locked")
+	require.NoError(t, err)
+	assert.NotZero(t, locked.Locked(now), "a lock outlives the sweep")
+	stale, err := store.RateLimits.Failures("stale")
+	require.NoError(t, err)
+	assert.Zero(t, stale.Count)
+}
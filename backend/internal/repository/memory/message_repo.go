@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+type messageRepo struct {
+	*data
+}
+
+func (r *messageRepo) Create(m *models.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m.ID == "" {
+		m.ID = uuid.NewString()
+	}
+	stamp(&m.CreatedAt)
+	r.messages = append(r.messages, *m)
+	return nil
+}
+
+func (r *messageRepo) Conversation(userID, friendID string) ([]models.Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.Message
+	for _, m := range r.messages {
+		if (m.SenderID == userID && m.ReceiverID == friendID) ||
+			(m.SenderID == friendID && m.ReceiverID == userID) {
+			list = append(list, m)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, nil
+}
+
+type postRepo struct {
+	*data
+}
+
+func (r *postRepo) Create(p *models.PostActivity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p.ID == "" {
+		p.ID = uuid.NewString()
+	}
+	stamp(&p.CreatedAt)
+	stored := *p
+	stored.UserName = ""
+	r.posts = append(r.posts, stored)
+	return nil
+}
+
+func (r *postRepo) Feed(userIDs []string, limit int) ([]models.PostActivity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := map[string]bool{}
+	for _, id := range userIDs {
+		wanted[id] = true
+	}
+	var list []models.PostActivity
+	for i := len(r.posts) - 1; i >= 0; i-- {
+		p := r.posts[i]
+		u, ok := r.users[p.UserID]
+		if !wanted[p.UserID] || !ok {
+			continue
+		}
+		p.UserName = u.Name
+		list = append(list, p)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	if limit >= 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (r *postRepo) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, p := range r.posts {
+		if p.ID == id {
+			r.posts = append(r.posts[:i], r.posts[i+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
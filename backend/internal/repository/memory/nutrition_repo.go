@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+)
+
+type nutritionRepo struct {
+	*data
+}
+
+func (r *nutritionRepo) AddMeal(m *models.Meal) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, old := range r.meals {
+		if m.IdempotencyKey != "" && old.UserID == m.UserID && old.IdempotencyKey == m.IdempotencyKey {
+			*m = old
+			return nil
+		}
+	}
+	if m.ID == "" {
+		m.ID = uuid.NewString()
+	}
+	stamp(&m.CreatedAt)
+	r.meals = append(r.meals, *m)
+	return nil
+}
+
+func (r *nutritionRepo) ListMeals(userID string) ([]models.Meal, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.Meal
+	for i := len(r.meals) - 1; i >= 0; i-- {
+		if r.meals[i].UserID == userID {
+			list = append(list, r.meals[i])
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+func (r *nutritionRepo) MealsBetween(userID string, from, to time.Time) ([]models.Meal, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.Meal
+	for _, m := range r.meals {
+		if m.UserID == userID && between(m.CreatedAt, from, to) {
+			list = append(list, m)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, nil
+}
+
+func (r *nutritionRepo) AddWater(l *models.WaterLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, old := range r.water {
+		if l.IdempotencyKey != "" && old.UserID == l.UserID && old.IdempotencyKey == l.IdempotencyKey {
+			*l = old
+			return nil
+		}
+	}
+	if l.ID == "" {
+		l.ID = uuid.NewString()
+	}
+	stamp(&l.CreatedAt)
+	r.water = append(r.water, *l)
+	return nil
+}
+
+func (r *nutritionRepo) WaterBetween(userID string, from, to time.Time) ([]models.WaterLog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.WaterLog
+	for _, l := range r.water {
+		if l.UserID == userID && between(l.CreatedAt, from, to) {
+			list = append(list, l)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, nil
+}
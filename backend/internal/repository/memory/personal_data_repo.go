@@ -0,0 +1,220 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+type personalDataRepo struct{ *data }
+
+func (r *personalDataRepo) Export(userID string) (models.PersonalData, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.users[userID]
+	if !ok {
+		return models.PersonalData{}, repository.ErrNotFound
+	}
+	d := models.PersonalData{User: u, Goals: map[models.GoalKind]int{}}
+
+	for k, goal := range r.goals {
+		if k.userID == userID {
+			d.Goals[k.kind] = goal
+		}
+	}
+	if t, ok := r.totp[userID]; ok && t.ConfirmedAt != nil {
+		d.TwoFactorSince = t.ConfirmedAt
+	}
+	for _, id := range r.friends[userID] {
+		d.Friends = append(d.Friends, models.Friend{UserID: userID, FriendID: id})
+	}
+	for _, fr := range r.friendRequests {
+		if fr.RequesterID == userID || fr.RecipientID == userID {
+			d.FriendRequests = append(d.FriendRequests, fr)
+		}
+	}
+	for _, ua := range r.userAchievements {
+		if ua.userID == userID {
+			d.Achievements = append(d.Achievements, models.UserAchievement{UserID: userID, AchievementID: ua.achievementID})
+		}
+	}
+	for _, a := range r.activities {
+		if a.UserID == userID {
+			d.Activities = append(d.Activities, a)
+		}
+	}
+	for day, steps := range r.steps[userID] {
+		d.Steps = append(d.Steps, models.StepDay{Day: day, Steps: steps})
+	}
+	sort.Slice(d.Steps, func(i, j int) bool { return d.Steps[i].Day < d.Steps[j].Day })
+	d.StepBuckets = append(d.StepBuckets, r.stepBuckets[userID]...)
+	sortBuckets(d.StepBuckets)
+	for _, m := range r.meals {
+		if m.UserID == userID {
+			d.Meals = append(d.Meals, m)
+		}
+	}
+	for _, w := range r.water {
+		if w.UserID == userID {
+			d.WaterLogs = append(d.WaterLogs, w)
+		}
+	}
+	for _, p := range r.posts {
+		if p.UserID == userID {
+			d.Posts = append(d.Posts, p)
+		}
+	}
+	for _, m := range r.messages {
+		if m.SenderID == userID || m.ReceiverID == userID {
+			d.Messages = append(d.Messages, m)
+		}
+	}
+	for _, ch := range r.challenges {
+		if ch.CreatorID == userID {
+			d.Challenges = append(d.Challenges, ch)
+		}
+	}
+	sort.Slice(d.Challenges, func(i, j int) bool { return d.Challenges[i].CreatedAt.Before(d.Challenges[j].CreatedAt) })
+	for _, p := range r.participants {
+		if p.UserID == userID {
+			d.Participations = append(d.Participations, p)
+		}
+	}
+	for _, e := range r.progressEvents {
+		if e.UserID == userID {
+			d.Progress = append(d.Progress, e)
+		}
+	}
+	sort.Slice(d.Progress, func(i, j int) bool { return d.Progress[i].OccurredAt.Before(d.Progress[j].OccurredAt) })
+	for _, inv := range r.invitations {
+		if inv.InviteeID == userID || inv.InviterID == userID {
+			d.Invitations = append(d.Invitations, inv)
+		}
+	}
+	sort.Slice(d.Invitations, func(i, j int) bool { return d.Invitations[i].CreatedAt.Before(d.Invitations[j].CreatedAt) })
+	for _, w := range r.workouts {
+		if w.UserID == userID {
+			d.Workouts = append(d.Workouts, w)
+		}
+	}
+	for _, h := range r.hydration {
+		if h.UserID == userID {
+			d.Hydration = append(d.Hydration, h)
+		}
+	}
+	for _, s := range r.sessions {
+		if s.UserID == userID {
+			d.Sessions = append(d.Sessions, s)
+		}
+	}
+	for _, i := range r.identities {
+		if i.UserID == userID {
+			d.Identities = append(d.Identities, i)
+		}
+	}
+	for _, t := range r.accessTokens {
+		if t.UserID == userID {
+			d.AccessTokens = append(d.AccessTokens, t)
+		}
+	}
+	for _, e := range r.auditEvents {
+		if e.SubjectID == userID {
+			d.AuditEvents = append(d.AuditEvents, e)
+		}
+	}
+	return d, nil
+}
+
+// keep returns the elements of list for which fn is true, reusing its
+// backing array.
+func keep[T any](list []T, fn func(T) bool) []T {
+	kept := list[:0]
+	for _, v := range list {
+		if fn(v) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+func (r *personalDataRepo) Erase(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[userID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+
+	for id, ch := range r.challenges {
+		if ch.CreatorID == userID {
+			ch.CreatorID = ""
+			r.challenges[id] = ch
+		}
+	}
+	for i, e := range r.auditEvents {
+		if e.SubjectID == userID || e.ActorID == userID {
+			e.IP, e.UserAgent, e.Before, e.After = "", "", nil, nil
+			r.auditEvents[i] = e
+		}
+	}
+
+	delete(r.friends, userID)
+	for id, ids := range r.friends {
+		r.friends[id] = keep(ids, func(f string) bool { return f != userID })
+	}
+	delete(r.steps, userID)
+	delete(r.stepBuckets, userID)
+	for k := range r.goals {
+		if k.userID == userID {
+			delete(r.goals, k)
+		}
+	}
+	delete(r.totp, userID)
+
+	r.friendRequests = keep(r.friendRequests, func(fr models.FriendRequest) bool {
+		return fr.RequesterID != userID && fr.RecipientID != userID
+	})
+	r.userAchievements = keep(r.userAchievements, func(ua userAchievement) bool { return ua.userID != userID })
+	r.activities = keep(r.activities, func(a models.Activity) bool { return a.UserID != userID })
+	r.meals = keep(r.meals, func(m models.Meal) bool { return m.UserID != userID })
+	r.water = keep(r.water, func(w models.WaterLog) bool { return w.UserID != userID })
+	r.posts = keep(r.posts, func(p models.PostActivity) bool { return p.UserID != userID })
+	r.messages = keep(r.messages, func(m models.Message) bool {
+		return m.SenderID != userID && m.ReceiverID != userID
+	})
+	for i, inv := range r.invitations {
+		if inv.InviterID == userID {
+			r.invitations[i].InviterID = ""
+		}
+	}
+	r.invitations = keep(r.invitations, func(inv models.ChallengeInvitation) bool { return inv.InviteeID != userID })
+	r.participants = keep(r.participants, func(p models.ChallengeParticipant) bool { return p.UserID != userID })
+	r.progressEvents = keep(r.progressEvents, func(e models.ProgressEvent) bool { return e.UserID != userID })
+	r.workouts = keep(r.workouts, func(w models.WorkoutSchedule) bool { return w.UserID != userID })
+	r.hydration = keep(r.hydration, func(h models.HydrationSetting) bool { return h.UserID != userID })
+	r.refreshTokens = keep(r.refreshTokens, func(t models.RefreshToken) bool { return t.UserID != userID })
+	r.sessions = keep(r.sessions, func(s models.Session) bool { return s.UserID != userID })
+	r.userTokens = keep(r.userTokens, func(t models.UserToken) bool { return t.UserID != userID })
+	r.recoveryCodes = keep(r.recoveryCodes, func(c recoveryCode) bool { return c.userID != userID })
+	r.identities = keep(r.identities, func(i models.Identity) bool { return i.UserID != userID })
+	r.accessTokens = keep(r.accessTokens, func(t models.AccessToken) bool { return t.UserID != userID })
+
+	userKey, emailKey := ":user:"+userID, ":email:"+strings.ToLower(u.Email)
+	for key := range r.buckets {
+		if strings.HasSuffix(key, userKey) || strings.HasSuffix(key, emailKey) {
+			delete(r.buckets, key)
+		}
+	}
+	for key := range r.failures {
+		if strings.HasSuffix(key, userKey) || strings.HasSuffix(key, emailKey) {
+			delete(r.failures, key)
+		}
+	}
+
+	delete(r.users, userID)
+	return nil
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/ratelimit"
+)
+
+type rateLimitRepo struct {
+	*data
+}
+
+func (r *rateLimitRepo) Take(key string, limit ratelimit.Limit, now time.Time) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := r.buckets[key]
+	wait := b.Take(limit, now)
+	r.buckets[key] = b
+	return wait, nil
+}
+
+func (r *rateLimitRepo) AddFailure(key string, policy ratelimit.Lockout, now time.Time) (ratelimit.Failures, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := r.failures[key]
+	f.Add(policy, now)
+	r.failures[key] = f
+	return f, nil
+}
+
+func (r *rateLimitRepo) Failures(key string) (ratelimit.Failures, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.failures[key], nil
+}
+
+func (r *rateLimitRepo) ClearFailures(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.failures, key)
+	return nil
+}
+
+func (r *rateLimitRepo) Sweep(before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for key, b := range r.buckets {
+		if b.UpdatedAt.Before(before) {
+			delete(r.buckets, key)
+			n++
+		}
+	}
+	for key, f := range r.failures {
+		if f.WindowStart.Before(before) && f.LockedUntil.Before(before) {
+			delete(r.failures, key)
+			n++
+		}
+	}
+	return n, nil
+}
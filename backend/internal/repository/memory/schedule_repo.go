@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+)
+
+type scheduleRepo struct {
+	*data
+}
+
+func (r *scheduleRepo) AddWorkout(w *models.WorkoutSchedule) error {
+	at, err := clock(w.AtTime)
+	if err != nil {
+		return err
+	}
+	if w.Weekday < 0 || w.Weekday > 6 {
+		return fmt.Errorf("weekday %d out of range", w.Weekday)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if w.ID == "" {
+		w.ID = uuid.NewString()
+	}
+	stamp(&w.CreatedAt)
+	stored := *w
+	stored.AtTime = at
+	r.workouts = append(r.workouts, stored)
+	return nil
+}
+
+// clock normalises "HH:MM" or "HH:MM:SS" to "HH:MM:SS" as the TIME column does.
+func clock(s string) (string, error) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("15:04:05"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid time of day %q", s)
+}
+
+func (r *scheduleRepo) ListWorkouts(userID string) ([]models.WorkoutSchedule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.WorkoutSchedule
+	for _, w := range r.workouts {
+		if w.UserID == userID {
+			list = append(list, w)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Weekday != list[j].Weekday {
+			return list[i].Weekday < list[j].Weekday
+		}
+		return list[i].AtTime < list[j].AtTime
+	})
+	return list, nil
+}
+
+func (r *scheduleRepo) DeleteWorkout(userID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.workouts[:0]
+	for _, w := range r.workouts {
+		if w.ID != id || w.UserID != userID {
+			kept = append(kept, w)
+		}
+	}
+	r.workouts = kept
+	return nil
+}
+
+func (r *scheduleRepo) WorkoutsDue(at time.Time) ([]models.WorkoutSchedule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.WorkoutSchedule
+	for _, w := range r.workouts {
+		local := at.In(r.zone(w.UserID))
+		weekday := int(local.Weekday()+6) % 7 // Monday = 0
+		if w.Weekday == weekday && strings.HasPrefix(w.AtTime, local.Format("15:04")+":") {
+			list = append(list, w)
+		}
+	}
+	return list, nil
+}
+
+func (r *scheduleRepo) HydrationSettings() ([]models.HydrationSetting, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := append([]models.HydrationSetting(nil), r.hydration...)
+	for i := range list {
+		list[i].Timezone = r.zone(list[i].UserID).String()
+	}
+	return list, nil
+}
@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+type refreshTokenRepo struct {
+	*data
+}
+
+func (r *refreshTokenRepo) Create(t *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t.ID == "" {
+		t.ID = uuid.NewString()
+	}
+	stamp(&t.CreatedAt)
+	for _, have := range r.refreshTokens {
+		if have.TokenHash == t.TokenHash {
+			return repository.ErrConflict
+		}
+	}
+	r.refreshTokens = append(r.refreshTokens, *t)
+	return nil
+}
+
+func (r *refreshTokenRepo) ByHash(hash string) (models.RefreshToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, t := range r.refreshTokens {
+		if t.TokenHash == hash {
+			return t, nil
+		}
+	}
+	return models.RefreshToken{}, repository.ErrNotFound
+}
+
+func (r *refreshTokenRepo) MarkUsed(id string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, t := range r.refreshTokens {
+		if t.ID == id && t.UsedAt == nil && t.RevokedAt == nil {
+			r.refreshTokens[i].UsedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *refreshTokenRepo) RevokeFamily(familyID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, t := range r.refreshTokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			r.refreshTokens[i].RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+type sessionRepo struct {
+	*data
+}
+
+func (r *sessionRepo) Create(s *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s.ID == "" {
+		s.ID = uuid.NewString()
+	}
+	stamp(&s.CreatedAt)
+	stamp(&s.LastSeenAt)
+	r.sessions = append(r.sessions, *s)
+	return nil
+}
+
+func (r *sessionRepo) ByID(id string) (models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, s := range r.sessions {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return models.Session{}, repository.ErrNotFound
+}
+
+func (r *sessionRepo) ListActive(userID string, since time.Time) ([]models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.Session
+	for _, s := range r.sessions {
+		if s.UserID == userID && s.RevokedAt == nil && !s.LastSeenAt.Before(since) {
+			list = append(list, s)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].LastSeenAt.After(list[j].LastSeenAt) })
+	return list, nil
+}
+
+func (r *sessionRepo) Touch(id, ip string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, s := range r.sessions {
+		if s.ID == id {
+			r.sessions[i].IP = ip
+			r.sessions[i].LastSeenAt = at
+		}
+	}
+	return nil
+}
+
+func (r *sessionRepo) Revoke(userID, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, s := range r.sessions {
+		if s.ID == id && s.UserID == userID && s.RevokedAt == nil {
+			r.sessions[i].RevokedAt = &at
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+type userTokenRepo struct {
+	*data
+}
+
+func (r *userTokenRepo) Create(t *models.UserToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t.ID == "" {
+		t.ID = uuid.NewString()
+	}
+	stamp(&t.CreatedAt)
+	for _, have := range r.userTokens {
+		if have.TokenHash == t.TokenHash {
+			return repository.ErrConflict
+		}
+	}
+	r.userTokens = append(r.userTokens, *t)
+	return nil
+}
+
+func (r *userTokenRepo) Consume(hash, purpose string, at time.Time) (models.UserToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, t := range r.userTokens {
+		if t.TokenHash == hash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(at) {
+			r.userTokens[i].UsedAt = &at
+			return r.userTokens[i], nil
+		}
+	}
+	return models.UserToken{}, repository.ErrNotFound
+}
+
+func (r *userTokenRepo) Peek(hash, purpose string, at time.Time) (models.UserToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, t := range r.userTokens {
+		if t.TokenHash == hash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(at) {
+			return t, nil
+		}
+	}
+	return models.UserToken{}, repository.ErrNotFound
+}
+
+func (r *userTokenRepo) Invalidate(userID, purpose string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, t := range r.userTokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			r.userTokens[i].UsedAt = &at
+		}
+	}
+	return nil
+}
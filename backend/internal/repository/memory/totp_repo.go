@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+type twoFactorRepo struct {
+	*data
+}
+
+func (r *twoFactorRepo) ByUser(userID string) (models.TOTP, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.totp[userID]
+	if !ok {
+		return models.TOTP{}, repository.ErrNotFound
+	}
+	return t, nil
+}
+
+func (r *twoFactorRepo) Save(t *models.TOTP) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&t.CreatedAt)
+	r.totp[t.UserID] = *t
+	return nil
+}
+
+func (r *twoFactorRepo) Confirm(userID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.totp[userID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	t.ConfirmedAt = &at
+	r.totp[userID] = t
+	return nil
+}
+
+func (r *twoFactorRepo) UseStep(userID string, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.totp[userID]
+	if !ok || t.LastStep >= step {
+		return false, nil
+	}
+	t.LastStep = step
+	r.totp[userID] = t
+	return true, nil
+}
+
+func (r *twoFactorRepo) Delete(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.totp, userID)
+	r.dropRecoveryCodes(userID)
+	return nil
+}
+
+func (r *twoFactorRepo) ReplaceRecoveryCodes(userID string, hashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.dropRecoveryCodes(userID)
+	for _, h := range hashes {
+		r.recoveryCodes = append(r.recoveryCodes, recoveryCode{userID: userID, hash: h})
+	}
+	return nil
+}
+
+func (r *twoFactorRepo) UseRecoveryCode(userID, hash string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, c := range r.recoveryCodes {
+		if c.userID == userID && c.hash == hash && c.usedAt == nil {
+			r.recoveryCodes[i].usedAt = &at
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+// dropRecoveryCodes expects the write lock to be held.
+func (r *twoFactorRepo) dropRecoveryCodes(userID string) {
+	kept := r.recoveryCodes[:0]
+	for _, c := range r.recoveryCodes {
+		if c.userID != userID {
+			kept = append(kept, c)
+		}
+	}
+	r.recoveryCodes = kept
+}
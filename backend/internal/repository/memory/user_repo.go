@@ -0,0 +1,392 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+type userRepo struct {
+	*data
+}
+
+func (r *userRepo) Create(u *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if u.ID == "" {
+		u.ID = uuid.NewString()
+	}
+	if _, ok := r.users[u.ID]; ok {
+		return repository.ErrConflict
+	}
+	for _, other := range r.users {
+		if other.Email == u.Email {
+			return repository.ErrConflict
+		}
+	}
+	if u.Role == "" {
+		u.Role = models.RoleUser
+	}
+	if u.Timezone == "" {
+		u.Timezone = "UTC"
+	}
+	r.users[u.ID] = *u
+	return nil
+}
+
+func (r *userRepo) ByID(id string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return models.User{}, repository.ErrNotFound
+	}
+	return u, nil
+}
+
+func (r *userRepo) ByEmail(email string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return models.User{}, repository.ErrNotFound
+}
+
+func (r *userRepo) UpdateProfile(u *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cur, ok := r.users[u.ID]
+	if !ok {
+		return nil
+	}
+	cur.Name, cur.AvatarURL, cur.Weight, cur.Height = u.Name, u.AvatarURL, u.Weight, u.Height
+	if u.Timezone != "" {
+		cur.Timezone = u.Timezone
+	}
+	r.users[u.ID] = cur
+	return nil
+}
+
+func (r *userRepo) SetPassword(id, passwordHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if u, ok := r.users[id]; ok {
+		u.PasswordHash = passwordHash
+		r.users[id] = u
+	}
+	return nil
+}
+
+func (r *userRepo) MarkEmailVerified(id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if u, ok := r.users[id]; ok && u.EmailVerifiedAt == nil {
+		u.EmailVerifiedAt = &at
+		r.users[id] = u
+	}
+	return nil
+}
+func (r *userRepo) SetRole(id, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if u, ok := r.users[id]; ok {
+		u.Role = role
+		r.users[id] = u
+	}
+	return nil
+}
+
+func (r *userRepo) SetSuspended(id string, at *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	u.SuspendedAt = at
+	r.users[id] = u
+	return nil
+}
+
+func (r *userRepo) SetDeleteAfter(id string, at *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	u.DeleteAfter = at
+	r.users[id] = u
+	return nil
+}
+
+func (r *userRepo) DueForDeletion(now time.Time) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ids []string
+	for id, u := range r.users {
+		if u.DeleteAfter != nil && !u.DeleteAfter.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (r *userRepo) Search(query string, limit, offset int) ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	q := strings.ToLower(query)
+	var list []models.User
+	for _, u := range r.users {
+		if strings.Contains(strings.ToLower(u.Name), q) || strings.Contains(strings.ToLower(u.Email), q) {
+			list = append(list, u)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Email < list[j].Email })
+	return page(list, limit, offset), nil
+}
+
+type friendRepo struct {
+	*data
+}
+
+func (r *friendRepo) CreateRequest(fr *models.FriendRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if fr.ID == "" {
+		fr.ID = uuid.NewString()
+	}
+	stamp(&fr.CreatedAt)
+	if _, ok := r.users[fr.RequesterID]; !ok {
+		return repository.ErrNotFound
+	}
+	if _, ok := r.users[fr.RecipientID]; !ok {
+		return repository.ErrNotFound
+	}
+	r.friendRequests = append(r.friendRequests, *fr)
+	return nil
+}
+
+func (r *friendRepo) IncomingRequest(id, recipientID string) (models.FriendRequest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, fr := range r.friendRequests {
+		if fr.ID == id && fr.RecipientID == recipientID {
+			return fr, nil
+		}
+	}
+	return models.FriendRequest{}, repository.ErrNotFound
+}
+
+func (r *friendRepo) DeleteRequest(id, recipientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.friendRequests[:0]
+	for _, fr := range r.friendRequests {
+		if fr.ID != id || fr.RecipientID != recipientID {
+			kept = append(kept, fr)
+		}
+	}
+	r.friendRequests = kept
+	return nil
+}
+
+func (r *friendRepo) ListIncoming(recipientID string) ([]models.IncomingFriendRequest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var reqs []models.FriendRequest
+	for _, fr := range r.friendRequests {
+		if fr.RecipientID == recipientID {
+			reqs = append(reqs, fr)
+		}
+	}
+	sort.SliceStable(reqs, func(i, j int) bool { return reqs[i].CreatedAt.Before(reqs[j].CreatedAt) })
+
+	var list []models.IncomingFriendRequest
+	for _, fr := range reqs {
+		u, ok := r.users[fr.RequesterID]
+		if !ok {
+			continue
+		}
+		list = append(list, models.IncomingFriendRequest{ID: fr.ID, Name: u.Name, Email: u.Email})
+	}
+	return list, nil
+}
+
+func (r *friendRepo) AddFriendship(userID, friendID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.link(userID, friendID)
+	r.link(friendID, userID)
+	return nil
+}
+
+func (r *friendRepo) link(userID, friendID string) {
+	for _, id := range r.friends[userID] {
+		if id == friendID {
+			return
+		}
+	}
+	r.friends[userID] = append(r.friends[userID], friendID)
+}
+
+func (r *friendRepo) ListFriends(userID string) ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.User
+	for _, id := range r.friends[userID] {
+		if u, ok := r.users[id]; ok {
+			list = append(list, u)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (r *friendRepo) FriendIDs(userID string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.friends[userID]...), nil
+}
+
+type achievementRepo struct {
+	*data
+}
+
+func (r *achievementRepo) List() ([]models.Achievement, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]models.Achievement(nil), r.achievements...), nil
+}
+
+func (r *achievementRepo) ListUnlocked(userID string) ([]models.Achievement, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	unlocked := map[string]bool{}
+	for _, ua := range r.userAchievements {
+		if ua.userID == userID {
+			unlocked[ua.achievementID] = true
+		}
+	}
+	var list []models.Achievement
+	for _, a := range r.achievements {
+		if unlocked[a.ID] {
+			list = append(list, a)
+		}
+	}
+	return list, nil
+}
+
+func (r *achievementRepo) ByTitle(title string) (models.Achievement, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, a := range r.achievements {
+		if a.Title == title {
+			return a, nil
+		}
+	}
+	return models.Achievement{}, repository.ErrNotFound
+}
+
+func (r *achievementRepo) Create(a *models.Achievement) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if a.ID == "" {
+		a.ID = uuid.NewString()
+	}
+	stamp(&a.CreatedAt)
+	for _, have := range r.achievements {
+		if have.Title == a.Title || have.ID == a.ID {
+			return repository.ErrConflict
+		}
+	}
+	r.achievements = append(r.achievements, *a)
+	return nil
+}
+
+func (r *achievementRepo) Update(a *models.Achievement) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	at := -1
+	for i, have := range r.achievements {
+		switch {
+		case have.ID == a.ID:
+			at = i
+		case have.Title == a.Title:
+			return repository.ErrConflict
+		}
+	}
+	if at < 0 {
+		return repository.ErrNotFound
+	}
+	cur := &r.achievements[at]
+	cur.Title, cur.Description, cur.IconURL = a.Title, a.Description, a.IconURL
+	return nil
+}
+
+func (r *achievementRepo) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, a := range r.achievements {
+		if a.ID != id {
+			continue
+		}
+		r.achievements = append(r.achievements[:i], r.achievements[i+1:]...)
+		kept := r.userAchievements[:0]
+		for _, ua := range r.userAchievements {
+			if ua.achievementID != id {
+				kept = append(kept, ua)
+			}
+		}
+		r.userAchievements = kept
+		return nil
+	}
+	return repository.ErrNotFound
+}
+
+func (r *achievementRepo) Award(userID, achievementID, challengeID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ua := userAchievement{userID: userID, achievementID: achievementID, challengeID: challengeID}
+	for _, have := range r.userAchievements {
+		if have == ua {
+			return nil
+		}
+	}
+	r.userAchievements = append(r.userAchievements, ua)
+	return nil
+}
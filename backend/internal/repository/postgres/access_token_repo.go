@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+const accessTokenColumns = `id, user_id, name, prefix, token_hash, scopes, expires_at, created_at, last_used_at, revoked_at`
+
+type accessTokenRepo struct {
+	db sqlx.Ext
+}
+
+func (r *accessTokenRepo) Create(t *models.AccessToken) error {
+	if t.ID == "" {
+		t.ID = uuid.NewString()
+	}
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+	_, err := sqlx.NamedExec(r.db, `
+		INSERT INTO personal_access_tokens (id, user_id, name, prefix, token_hash, scopes, expires_at, created_at)
+		VALUES (:id, :user_id, :name, :prefix, :token_hash, :scopes, :expires_at, :created_at)
+	`, t)
+	return err
+}
+
+func (r *accessTokenRepo) ByHash(hash string) (models.AccessToken, error) {
+	var t models.AccessToken
+	err := sqlx.Get(r.db, &t, `SELECT `+accessTokenColumns+` FROM personal_access_tokens WHERE token_hash = $1`, hash)
+	return t, notFound(err)
+}
+
+func (r *accessTokenRepo) ListForUser(userID string) ([]models.AccessToken, error) {
+	var list []models.AccessToken
+	err := sqlx.Select(r.db, &list, `
+		SELECT `+accessTokenColumns+` FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`, userID)
+	return list, err
+}
+
+func (r *accessTokenRepo) Touch(id string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE personal_access_tokens SET last_used_at = $2 WHERE id = $1`, id, at)
+	return err
+}
+
+func (r *accessTokenRepo) Revoke(userID, id string, at time.Time) error {
+	res, err := r.db.Exec(`
+		UPDATE personal_access_tokens SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, id, userID, at)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
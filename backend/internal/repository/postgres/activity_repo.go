@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+const activityColumns = `id, user_id, type, name, COALESCE(duration, 0) AS duration,
+	COALESCE(intensity, '') AS intensity, COALESCE(calories, 0) AS calories,
+	COALESCE(location, '') AS location, COALESCE(distance, 0) AS distance, performed_at`
+
+type activityRepo struct {
+	db sqlx.Ext
+}
+
+func (r *activityRepo) Create(a *models.Activity) error {
+	// a replayed key updates nothing but makes RETURNING yield the first row
+	return sqlx.Get(r.db, a, `
+		INSERT INTO activities (user_id, type, name, duration, intensity, calories, location, distance, idempotency_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''))
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE SET idempotency_key = EXCLUDED.idempotency_key
+		RETURNING `+activityColumns+`, COALESCE(idempotency_key, '') AS idempotency_key
+	`, a.UserID, a.Type, a.Name, a.Duration, a.Intensity, a.Calories, a.Location, a.Distance, a.IdempotencyKey)
+}
+
+func (r *activityRepo) List(userID string, activityType *string) ([]models.Activity, error) {
+	var list []models.Activity
+	query := `SELECT ` + activityColumns + ` FROM activities WHERE user_id = $1`
+	args := []interface{}{userID}
+	if activityType != nil {
+		query += ` AND type = $2`
+		args = append(args, *activityType)
+	}
+	query += ` ORDER BY performed_at DESC`
+	err := sqlx.Select(r.db, &list, query, args...)
+	return list, err
+}
+
+func (r *activityRepo) ListBetween(userID string, from, to time.Time) ([]models.Activity, error) {
+	var list []models.Activity
+	err := sqlx.Select(r.db, &list, `
+		SELECT `+activityColumns+`
+		FROM activities
+		WHERE user_id = $1 AND performed_at >= $2 AND performed_at < $3
+		ORDER BY performed_at
+	`, userID, from.UTC(), to.UTC())
+	return list, err
+}
+
+type stepRepo struct {
+	db sqlx.Ext
+}
+
+func (r *stepRepo) Upsert(userID, day string, steps int) error {
+	_, err := r.db.Exec(`
+		INSERT INTO user_steps (user_id, steps, day)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, day) DO UPDATE SET steps = EXCLUDED.steps
+	`, userID, steps, day)
+	return err
+}
+
+func (r *stepRepo) ListSince(userID, fromDay string) ([]models.StepDay, error) {
+	var list []models.StepDay
+	err := sqlx.Select(r.db, &list, `
+		SELECT TO_CHAR(day, 'YYYY-MM-DD') AS day, steps
+		FROM user_steps
+		WHERE user_id = $1 AND day >= $2
+		ORDER BY day DESC
+	`, userID, fromDay)
+	return list, err
+}
+
+func (r *stepRepo) Total(userID string) (int, error) {
+	var total int
+	err := sqlx.Get(r.db, &total, `SELECT COALESCE(SUM(steps), 0) FROM user_steps WHERE user_id = $1`, userID)
+	return total, err
+}
+
+func (r *stepRepo) Delete(userID, day string) error {
+	return withTx(r.db, func(tx sqlx.Ext) error {
+		if _, err := tx.Exec(`DELETE FROM step_buckets WHERE user_id = $1 AND day = $2`, userID, day); err != nil {
+			return err
+		}
+		res, err := tx.Exec(`DELETE FROM user_steps WHERE user_id = $1 AND day = $2`, userID, day)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return repository.ErrNotFound
+		}
+		return nil
+	})
+}
+
+const stepBucketColumns = `source, TO_CHAR(day, 'YYYY-MM-DD') AS day, hour, steps`
+
+// mergeStepDay recomputes the user_steps total of user $1 on day $2 from
+// its buckets.
+const mergeStepDay = `
+	INSERT INTO user_steps (user_id, day, steps)
+	SELECT $1, $2::date, GREATEST(
+	         COALESCE((SELECT SUM(top) FROM (
+	                     SELECT MAX(steps) AS top
+	                     FROM   step_buckets
+	                     WHERE  user_id = $1 AND day = $2 AND hour IS NOT NULL
+	                     GROUP  BY hour
+	                   ) hours), 0),
+	         COALESCE((SELECT MAX(steps)
+	                   FROM   step_buckets
+	                   WHERE  user_id = $1 AND day = $2 AND hour IS NULL), 0))
+	ON CONFLICT (user_id, day) DO UPDATE SET steps = EXCLUDED.steps`
+
+func (r *stepRepo) SaveBuckets(userID string, buckets []models.StepBucket) error {
+	return withTx(r.db, func(tx sqlx.Ext) error {
+		// one upload per user at a time, so that concurrent uploads from
+		// two devices both make it into the merged totals
+		if _, err := tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+			return err
+		}
+		days := map[string]bool{}
+		for _, b := range buckets {
+			if _, err := tx.Exec(`
+				INSERT INTO step_buckets (user_id, source, day, hour, steps)
+				VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (user_id, day, source, (COALESCE(hour, -1)))
+				DO UPDATE SET steps = EXCLUDED.steps, updated_at = NOW()
+			`, userID, b.Source, b.Day, b.Hour, b.Steps); err != nil {
+				return err
+			}
+			days[b.Day] = true
+		}
+		for day := range days {
+			if _, err := tx.Exec(mergeStepDay, userID, day); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *stepRepo) Buckets(userID, fromDay string) ([]models.StepBucket, error) {
+	var list []models.StepBucket
+	err := sqlx.Select(r.db, &list, `
+		SELECT `+stepBucketColumns+`
+		FROM   step_buckets
+		WHERE  user_id = $1 AND day >= $2
+		ORDER  BY day, source, hour NULLS FIRST
+	`, userID, fromDay)
+	return list, err
+}
+
+// goalTables maps every goal kind to its table and value column.
+var goalTables = map[models.GoalKind][2]string{
+	models.GoalSteps:    {"step_goals", "goal"},
+	models.GoalActivity: {"activity_goals", "goal"},
+	models.GoalWater:    {"water_goals", "goal_ml"},
+	models.GoalCalories: {"calorie_goals", "goal"},
+}
+
+type goalRepo struct {
+	db sqlx.Ext
+}
+
+func (r *goalRepo) Get(userID string, kind models.GoalKind) (int, error) {
+	t, ok := goalTables[kind]
+	if !ok {
+		return 0, fmt.Errorf("unknown goal kind %q", kind)
+	}
+	var goal int
+	err := sqlx.Get(r.db, &goal, `SELECT `+t[1]+` FROM `+t[0]+` WHERE user_id = $1`, userID)
+	return goal, notFound(err)
+}
+
+func (r *goalRepo) Set(userID string, kind models.GoalKind, value int) error {
+	t, ok := goalTables[kind]
+	if !ok {
+		return fmt.Errorf("unknown goal kind %q", kind)
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO `+t[0]+` (user_id, `+t[1]+`)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET `+t[1]+` = EXCLUDED.`+t[1]+`, updated_at = now()
+	`, userID, value)
+	return err
+}
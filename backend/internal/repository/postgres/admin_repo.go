@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+)
+
+type adminActionRepo struct {
+	db sqlx.Ext
+}
+
+func (r *adminActionRepo) Create(a *models.AdminAction) error {
+	if a.ID == "" {
+		a.ID = uuid.NewString()
+	}
+	_, err := sqlx.NamedExec(r.db, `
+		INSERT INTO admin_actions (id, admin_id, action, target_type, target_id, details, created_at)
+		VALUES (:id, :admin_id, :action, :target_type, :target_id, :details, :created_at)
+	`, a)
+	return err
+}
+
+func (r *adminActionRepo) List(limit int) ([]models.AdminAction, error) {
+	var list []models.AdminAction
+	err := sqlx.Select(r.db, &list, `
+		SELECT id, COALESCE(admin_id::text, '') AS admin_id, action, target_type, target_id, details, created_at
+		FROM admin_actions
+		ORDER BY created_at DESC
+		LIMIT $1
+	`, limit)
+	return list, err
+}
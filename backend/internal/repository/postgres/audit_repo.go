@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+const auditColumns = `id, COALESCE(actor_id::text, '') AS actor_id, COALESCE(subject_id::text, '') AS subject_id,
+	action, target_type, target_id, ip, user_agent, state_before, state_after, created_at`
+
+type auditRepo struct {
+	db sqlx.Ext
+}
+
+func (r *auditRepo) Append(e *models.AuditEvent) error {
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	}
+	_, err := sqlx.NamedExec(r.db, `
+		INSERT INTO audit_events
+			(id, actor_id, subject_id, action, target_type, target_id, ip, user_agent, state_before, state_after, created_at)
+		VALUES
+			(:id, NULLIF(:actor_id, '')::uuid, NULLIF(:subject_id, '')::uuid, :action, :target_type, :target_id,
+			 :ip, :user_agent, :state_before, :state_after, :created_at)
+	`, e)
+	return err
+}
+
+func (r *auditRepo) List(f repository.AuditFilter) ([]models.AuditEvent, error) {
+	// LIMIT NULL is LIMIT ALL
+	var limit interface{}
+	if f.Limit > 0 {
+		limit = f.Limit
+	}
+	var list []models.AuditEvent
+	err := sqlx.Select(r.db, &list, `
+		SELECT `+auditColumns+`
+		FROM audit_events
+		WHERE ($1 = '' OR subject_id::text = $1)
+		  AND ($2 = '' OR action = $2)
+		ORDER BY created_at DESC, id
+		LIMIT $3 OFFSET $4
+	`, f.SubjectID, f.Action, limit, f.Offset)
+	return list, err
+}
@@ -0,0 +1,408 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+type challengeRepo struct {
+	db sqlx.Ext
+}
+
+const challengeColumns = `c.id, COALESCE(c.creator_id::text, '') AS creator_id, c.type, c.target, c.title,
+	c.starts_at, c.ends_at, c.status, COALESCE(c.team_scoring, '') AS team_scoring, c.visibility, c.created_at`
+
+const participantColumns = `challenge_id, user_id, progress, joined_at, final_rank, COALESCE(team_id::text, '') AS team_id`
+
+// participantProgress is what the participant cp of challenge c has on the
+// ledger inside the window: from when they joined, or the start if later,
+// until the end, capped at the target. Periods count by the part that
+// overlaps the window, like models.ProgressEvent.Share.
+const participantProgress = `LEAST(c.target, FLOOR(COALESCE((
+	SELECT SUM(CASE
+	         WHEN e.lasts_until IS NULL OR e.lasts_until <= e.occurred_at THEN e.amount
+	         ELSE e.amount * GREATEST(0, EXTRACT(EPOCH FROM
+	                LEAST(e.lasts_until, c.ends_at) - GREATEST(e.occurred_at, cp.joined_at, c.starts_at)))
+	              / EXTRACT(EPOCH FROM e.lasts_until - e.occurred_at)
+	       END)
+	FROM   challenge_progress_events e
+	WHERE  e.user_id = cp.user_id
+	  AND  e.metric  = c.type
+	  AND  COALESCE(e.lasts_until, e.occurred_at) >= GREATEST(cp.joined_at, c.starts_at)
+	  AND  e.occurred_at < c.ends_at
+), 0) + 1e-9)::int)`
+
+func (r *challengeRepo) Create(ch *models.Challenge, teams []models.ChallengeTeam, participants []models.ChallengeParticipant) error {
+	return withTx(r.db, func(tx sqlx.Ext) error {
+		if _, err := sqlx.NamedExec(tx, `
+			INSERT INTO challenges (id, creator_id, type, target, title, starts_at, ends_at, status, team_scoring, visibility, created_at)
+			VALUES (:id, :creator_id, :type, :target, :title, :starts_at, :ends_at, :status, NULLIF(:team_scoring, ''), :visibility, :created_at)`, ch); err != nil {
+			return err
+		}
+		for i := range teams {
+			t := &teams[i]
+			if t.ID == "" {
+				t.ID = uuid.NewString()
+			}
+			t.ChallengeID = ch.ID
+			if _, err := tx.Exec(`
+				INSERT INTO challenge_teams (id, challenge_id, name, position) VALUES ($1, $2, $3, $4)
+			`, t.ID, t.ChallengeID, t.Name, t.Position); err != nil {
+				return conflict(err)
+			}
+		}
+		for i := range participants {
+			p := &participants[i]
+			p.ChallengeID = ch.ID
+			if _, err := tx.Exec(`
+				INSERT INTO challenge_participants (challenge_id, user_id, progress, joined_at, team_id)
+				VALUES ($1, $2, 0, $3, NULLIF($4, '')::uuid)`, ch.ID, p.UserID, time.Now(), p.TeamID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *challengeRepo) ByID(id string) (models.Challenge, error) {
+	var ch models.Challenge
+	err := sqlx.Get(r.db, &ch, `SELECT `+challengeColumns+` FROM challenges c WHERE c.id = $1`, id)
+	return ch, notFound(err)
+}
+
+func (r *challengeRepo) AddParticipant(challengeID, userID string) error {
+	return withTx(r.db, func(tx sqlx.Ext) error {
+		return enroll(tx, challengeID, userID, "")
+	})
+}
+
+// enroll adds the participant on teamID or, when that is empty, on the
+// team with the fewest members.
+func enroll(tx sqlx.Ext, challengeID, userID, teamID string) error {
+	// one join at a time per challenge keeps the teams balanced
+	if _, err := tx.Exec(`SELECT 1 FROM challenges WHERE id = $1 FOR UPDATE`, challengeID); err != nil {
+		return err
+	}
+	if teamID == "" {
+		var teamIDs []string
+		if err := sqlx.Select(tx, &teamIDs, `
+			SELECT t.id
+			FROM   challenge_teams t
+			LEFT   JOIN challenge_participants p ON p.team_id = t.id
+			WHERE  t.challenge_id = $1
+			GROUP  BY t.id, t.position
+			ORDER  BY COUNT(p.user_id), t.position
+			LIMIT  1
+		`, challengeID); err != nil {
+			return err
+		}
+		if len(teamIDs) > 0 {
+			teamID = teamIDs[0]
+		}
+	}
+	_, err := tx.Exec(`
+		INSERT INTO challenge_participants (challenge_id, user_id, team_id) VALUES ($1, $2, NULLIF($3, '')::uuid)
+		ON CONFLICT DO NOTHING
+	`, challengeID, userID, teamID)
+	return err
+}
+
+func (r *challengeRepo) Teams(challengeID string) ([]models.ChallengeTeam, error) {
+	var list []models.ChallengeTeam
+	err := sqlx.Select(r.db, &list, `
+		SELECT id, challenge_id, name, position
+		FROM   challenge_teams
+		WHERE  challenge_id = $1
+		ORDER  BY position
+	`, challengeID)
+	return list, err
+}
+
+const invitationColumns = `i.id, i.challenge_id, COALESCE(i.inviter_id::text, '') AS inviter_id, i.invitee_id,
+	COALESCE(i.team_id::text, '') AS team_id, i.status, i.created_at, i.responded_at`
+
+func (r *challengeRepo) Invite(invitations []models.ChallengeInvitation) ([]models.ChallengeInvitation, error) {
+	var stored []models.ChallengeInvitation
+	err := withTx(r.db, func(tx sqlx.Ext) error {
+		for _, inv := range invitations {
+			if inv.ID == "" {
+				inv.ID = uuid.NewString()
+			}
+			var list []models.ChallengeInvitation
+			if err := sqlx.Select(tx, &list, `
+				INSERT INTO challenge_invitations AS i (id, challenge_id, inviter_id, invitee_id, team_id, status)
+				SELECT $1::uuid, $2::uuid, $3::uuid, $4::uuid, NULLIF($5, '')::uuid, 'pending'
+				WHERE  NOT EXISTS (SELECT 1 FROM challenge_participants
+				                   WHERE challenge_id = $2::uuid AND user_id = $4::uuid)
+				ON CONFLICT (challenge_id, invitee_id) DO UPDATE
+				SET    inviter_id = EXCLUDED.inviter_id, team_id = EXCLUDED.team_id, status = 'pending',
+				       created_at = NOW(), responded_at = NULL
+				WHERE  i.status = 'declined'
+				RETURNING `+invitationColumns,
+				inv.ID, inv.ChallengeID, inv.InviterID, inv.InviteeID, inv.TeamID); err != nil {
+				return err
+			}
+			stored = append(stored, list...)
+		}
+		return nil
+	})
+	return stored, err
+}
+
+func (r *challengeRepo) Invitation(id string) (models.ChallengeInvitation, error) {
+	var inv models.ChallengeInvitation
+	err := sqlx.Get(r.db, &inv, `SELECT `+invitationColumns+` FROM challenge_invitations i WHERE i.id = $1`, id)
+	return inv, notFound(err)
+}
+
+func (r *challengeRepo) InvitationTo(challengeID, userID string) (models.ChallengeInvitation, error) {
+	var inv models.ChallengeInvitation
+	err := sqlx.Get(r.db, &inv, `
+		SELECT `+invitationColumns+`
+		FROM   challenge_invitations i
+		WHERE  i.challenge_id = $1 AND i.invitee_id = $2
+	`, challengeID, userID)
+	return inv, notFound(err)
+}
+
+func (r *challengeRepo) Invitations(userID, status string) ([]models.ChallengeInvitation, error) {
+	var list []models.ChallengeInvitation
+	err := sqlx.Select(r.db, &list, `
+		SELECT `+invitationColumns+`, c.title, COALESCE(u.name, '') AS inviter_name
+		FROM   challenge_invitations i
+		JOIN   challenges c ON c.id = i.challenge_id
+		LEFT   JOIN users u ON u.id = i.inviter_id
+		WHERE  i.invitee_id = $1 AND ($2::text = '' OR i.status = $2)
+		ORDER  BY i.created_at DESC
+	`, userID, status)
+	return list, err
+}
+
+func (r *challengeRepo) RespondInvitation(id, status string) error {
+	return withTx(r.db, func(tx sqlx.Ext) error {
+		var inv models.ChallengeInvitation
+		if err := sqlx.Get(tx, &inv, `
+			SELECT `+invitationColumns+` FROM challenge_invitations i WHERE i.id = $1 FOR UPDATE
+		`, id); err != nil {
+			return notFound(err)
+		}
+		if inv.Status != models.InvitationPending {
+			return repository.ErrConflict
+		}
+		if _, err := tx.Exec(`
+			UPDATE challenge_invitations SET status = $2, responded_at = NOW() WHERE id = $1
+		`, id, status); err != nil {
+			return err
+		}
+		if status != models.InvitationAccepted {
+			return nil
+		}
+		return enroll(tx, inv.ChallengeID, inv.InviteeID, inv.TeamID)
+	})
+}
+
+const progressEventColumns = `id, user_id, metric, source, amount, idempotency_key, occurred_at, lasts_until, recorded_at`
+
+func (r *challengeRepo) RecordProgress(events []models.ProgressEvent) error {
+	return withTx(r.db, func(tx sqlx.Ext) error {
+		for i := range events {
+			e := &events[i]
+			if e.ID == "" {
+				e.ID = uuid.NewString()
+			}
+			if err := sqlx.Get(tx, &e.RecordedAt, `
+				INSERT INTO challenge_progress_events (id, user_id, metric, source, amount, idempotency_key, occurred_at, lasts_until)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				ON CONFLICT (user_id, idempotency_key) DO UPDATE
+				SET    metric = EXCLUDED.metric, source = EXCLUDED.source, amount = EXCLUDED.amount,
+				       occurred_at = EXCLUDED.occurred_at, lasts_until = EXCLUDED.lasts_until, recorded_at = NOW()
+				RETURNING recorded_at
+			`, e.ID, e.UserID, e.Metric, e.Source, e.Amount, e.IdempotencyKey, e.OccurredAt, e.LastsUntil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *challengeRepo) ProgressEvents(userID, metric string, since time.Time) ([]models.ProgressEvent, error) {
+	var list []models.ProgressEvent
+	err := sqlx.Select(r.db, &list, `
+		SELECT `+progressEventColumns+`
+		FROM   challenge_progress_events
+		WHERE  user_id = $1 AND metric = $2 AND occurred_at >= $3
+		ORDER  BY occurred_at
+	`, userID, metric, since)
+	return list, err
+}
+
+func (r *challengeRepo) RecountProgress(userID, metric string) (int, error) {
+	res, err := r.db.Exec(`
+		UPDATE challenge_participants cp
+		SET    progress = `+participantProgress+`
+		FROM   challenges c
+		WHERE  cp.challenge_id = c.id
+		  AND  cp.user_id      = $1
+		  AND  c.type          = $2
+		  AND  c.status        = 'active'
+	`, userID, metric)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (r *challengeRepo) Activate(now time.Time) ([]models.Challenge, error) {
+	var list []models.Challenge
+	err := withTx(r.db, func(tx sqlx.Ext) error {
+		if err := sqlx.Select(tx, &list, `
+			UPDATE challenges c SET status = 'active'
+			WHERE  c.status = 'scheduled' AND c.starts_at <= $1
+			RETURNING `+challengeColumns, now); err != nil {
+			return err
+		}
+		for _, ch := range list {
+			if _, err := tx.Exec(`
+				UPDATE challenge_participants cp
+				SET    progress = `+participantProgress+`
+				FROM   challenges c
+				WHERE  cp.challenge_id = c.id AND c.id = $1
+			`, ch.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return list, err
+}
+
+func (r *challengeRepo) Finish(now time.Time) ([]models.Challenge, error) {
+	var list []models.Challenge
+	err := withTx(r.db, func(tx sqlx.Ext) error {
+		if err := sqlx.Select(tx, &list, `
+			UPDATE challenges c SET status = 'finished'
+			WHERE  c.status = 'active' AND c.ends_at <= $1
+			RETURNING `+challengeColumns, now); err != nil {
+			return err
+		}
+		for _, ch := range list {
+			if _, err := tx.Exec(`
+				UPDATE challenge_participants cp
+				SET    progress = `+participantProgress+`
+				FROM   challenges c
+				WHERE  cp.challenge_id = c.id AND c.id = $1
+			`, ch.ID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`
+				UPDATE challenge_participants cp
+				SET    final_rank = r.rank
+				FROM  (SELECT user_id, DENSE_RANK() OVER (ORDER BY progress DESC) AS rank
+				       FROM   challenge_participants
+				       WHERE  challenge_id = $1) r
+				WHERE  cp.challenge_id = $1 AND cp.user_id = r.user_id
+			`, ch.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return list, err
+}
+
+func (r *challengeRepo) Participants(challengeID string) ([]models.ChallengeParticipant, error) {
+	var list []models.ChallengeParticipant
+	err := sqlx.Select(r.db, &list, `
+		SELECT `+participantColumns+`, u.name, COALESCE(u.avatar_url, '') AS avatar_url
+		FROM challenge_participants
+		JOIN users u ON u.id = user_id
+		WHERE challenge_id = $1
+		ORDER BY progress DESC, joined_at, user_id
+	`, challengeID)
+	return list, err
+}
+
+func (r *challengeRepo) ListForUser(userID string) ([]models.Challenge, error) {
+	var list []models.Challenge
+	err := sqlx.Select(r.db, &list, `
+		SELECT DISTINCT `+challengeColumns+`
+		FROM challenges c
+		LEFT JOIN challenge_participants p ON p.challenge_id = c.id
+		WHERE c.creator_id = $1 OR p.user_id = $1
+		ORDER BY c.created_at DESC
+	`, userID)
+	return list, err
+}
+
+func (r *challengeRepo) Discover(f repository.ChallengeFilter) ([]models.ChallengeSummary, error) {
+	// LIMIT NULL is LIMIT ALL
+	var limit interface{}
+	if f.Limit > 0 {
+		limit = f.Limit
+	}
+	var after repository.ChallengeCursor
+	if f.After != nil {
+		after = *f.After
+	}
+	order := `s.participants DESC, s.id`
+	if f.ByStart {
+		order = `s.starts_at, s.id`
+	}
+	var list []models.ChallengeSummary
+	err := sqlx.Select(r.db, &list, `
+		SELECT *
+		FROM  (SELECT `+challengeColumns+`,
+		              COUNT(p.user_id) AS participants,
+		              COALESCE(BOOL_OR(p.user_id::text = $1), FALSE) AS joined
+		       FROM   challenges c
+		       LEFT   JOIN challenge_participants p ON p.challenge_id = c.id
+		       WHERE  c.visibility = 'public'
+		         AND  ($2 = '' OR c.type = $2)
+		         AND  (($3 = '' AND c.status <> 'finished') OR c.status = $3)
+		         AND  ($4 = 0 OR c.ends_at - c.starts_at >= make_interval(days => $4))
+		         AND  ($5 = 0 OR c.ends_at - c.starts_at <= make_interval(days => $5))
+		       GROUP  BY c.id) s
+		WHERE ($6 = 0 OR s.participants >= $6)
+		  AND ($7 = 0 OR s.participants <= $7)
+		  AND ($8 = '' OR CASE WHEN $9::boolean
+		        THEN s.starts_at > $10 OR (s.starts_at = $10 AND s.id > NULLIF($8, '')::uuid)
+		        ELSE s.participants < $11 OR (s.participants = $11 AND s.id > NULLIF($8, '')::uuid) END)
+		ORDER BY `+order+`
+		LIMIT $12
+	`, f.ViewerID, f.Type, f.Status, f.MinDays, f.MaxDays, f.MinSize, f.MaxSize,
+		after.ID, f.ByStart, after.StartsAt, after.Participants, limit)
+	return list, err
+}
+
+func (r *challengeRepo) Completed(userID string) ([]string, error) {
+	var ids []string
+	err := sqlx.Select(r.db, &ids, `
+		SELECT cp.challenge_id
+		FROM   challenge_participants cp
+		JOIN   challenges c ON c.id = cp.challenge_id
+		WHERE  cp.user_id = $1 AND cp.progress >= c.target
+	`, userID)
+	return ids, err
+}
+
+func (r *challengeRepo) EndingBefore(until time.Time) ([]models.ParticipantProgress, error) {
+	var list []models.ParticipantProgress
+	err := sqlx.Select(r.db, &list, `
+		SELECT cp.challenge_id, cp.user_id, c.title, c.target, cp.progress, c.ends_at, u.timezone
+		FROM   challenge_participants cp
+		JOIN   challenges c ON c.id = cp.challenge_id
+		JOIN   users u ON u.id = cp.user_id
+		WHERE  c.status = 'active' AND c.ends_at <= $1 AND cp.progress < c.target
+	`, until)
+	return list, err
+}
+
+func (r *challengeRepo) Delete(id string) error {
+	return deleteByID(r.db, "challenges", id)
+}
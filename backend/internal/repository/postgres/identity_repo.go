@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+const identityColumns = `id, user_id, provider, subject, email, created_at, last_login_at`
+
+type identityRepo struct {
+	db sqlx.Ext
+}
+
+func (r *identityRepo) Create(i *models.Identity) error {
+	if i.ID == "" {
+		i.ID = uuid.NewString()
+	}
+	if i.CreatedAt.IsZero() {
+		i.CreatedAt = time.Now()
+	}
+	_, err := sqlx.NamedExec(r.db, `
+		INSERT INTO user_identities (id, user_id, provider, subject, email, created_at, last_login_at)
+		VALUES (:id, :user_id, :provider, :subject, :email, :created_at, :last_login_at)
+	`, i)
+	return conflict(err)
+}
+
+func (r *identityRepo) ByProviderSubject(provider, subject string) (models.Identity, error) {
+	var i models.Identity
+	err := sqlx.Get(r.db, &i, `
+		SELECT `+identityColumns+` FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`, provider, subject)
+	return i, notFound(err)
+}
+
+func (r *identityRepo) ListForUser(userID string) ([]models.Identity, error) {
+	var list []models.Identity
+	err := sqlx.Select(r.db, &list, `
+		SELECT `+identityColumns+` FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	return list, err
+}
+
+func (r *identityRepo) Touch(id string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE user_identities SET last_login_at = $2 WHERE id = $1`, id, at)
+	return err
+}
+
+func (r *identityRepo) Delete(userID, id string) error {
+	res, err := r.db.Exec(`DELETE FROM user_identities WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *identityRepo) SaveLogin(l *models.OIDCLogin) error {
+	// abandoned sign-ins are cleared on the way
+	if _, err := r.db.Exec(`DELETE FROM oidc_logins WHERE expires_at < now()`); err != nil {
+		return err
+	}
+	_, err := sqlx.NamedExec(r.db, `
+		INSERT INTO oidc_logins (state_hash, provider, nonce, code_verifier, expires_at)
+		VALUES (:state_hash, :provider, :nonce, :code_verifier, :expires_at)
+	`, l)
+	return err
+}
+
+func (r *identityRepo) TakeLogin(stateHash string, at time.Time) (models.OIDCLogin, error) {
+	var l models.OIDCLogin
+	err := sqlx.Get(r.db, &l, `
+		DELETE FROM oidc_logins
+		WHERE state_hash = $1 AND expires_at > $2
+		RETURNING state_hash, provider, nonce, code_verifier, expires_at
+	`, stateHash, at)
+	return l, notFound(err)
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+)
+
+type messageRepo struct {
+	db sqlx.Ext
+}
+
+func (r *messageRepo) Create(m *models.Message) error {
+	_, err := r.db.Exec(
+		`INSERT INTO messages (id, sender_id, receiver_id, text, created_at) VALUES ($1,$2,$3,$4,$5)`,
+		m.ID, m.SenderID, m.ReceiverID, m.Text, m.CreatedAt,
+	)
+	return err
+}
+
+func (r *messageRepo) Conversation(userID, friendID string) ([]models.Message, error) {
+	var list []models.Message
+	err := sqlx.Select(r.db, &list, `
+		SELECT id, sender_id, receiver_id, text, created_at
+		FROM messages
+		WHERE (sender_id=$1 AND receiver_id=$2) OR (sender_id=$2 AND receiver_id=$1)
+		ORDER BY created_at
+	`, userID, friendID)
+	return list, err
+}
+
+type postRepo struct {
+	db sqlx.Ext
+}
+
+func (r *postRepo) Create(p *models.PostActivity) error {
+	_, err := r.db.Exec(`
+		INSERT INTO post_activities (id, user_id, type, message, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, p.ID, p.UserID, p.Type, p.Message, p.CreatedAt)
+	return err
+}
+
+func (r *postRepo) Feed(userIDs []string, limit int) ([]models.PostActivity, error) {
+	var list []models.PostActivity
+	err := sqlx.Select(r.db, &list, `
+		SELECT a.id, a.user_id, a.type, COALESCE(a.message, '') AS message, a.created_at, u.name AS user_name
+		FROM post_activities a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.user_id = ANY($1)
+		ORDER BY a.created_at DESC
+		LIMIT $2
+	`, pq.Array(userIDs), limit)
+	return list, err
+}
+
+func (r *postRepo) Delete(id string) error {
+	return deleteByID(r.db, "post_activities", id)
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+)
+
+const mealColumns = `id, user_id, COALESCE(fdc_id, 0) AS fdc_id, COALESCE(description, '') AS description,
+	COALESCE(calories, 0) AS calories, COALESCE(protein, 0) AS protein, COALESCE(fat, 0) AS fat,
+	COALESCE(carbs, 0) AS carbs, COALESCE(quantity, 0) AS quantity, COALESCE(unit, '') AS unit, created_at`
+
+type nutritionRepo struct {
+	db sqlx.Ext
+}
+
+func (r *nutritionRepo) AddMeal(m *models.Meal) error {
+	// a replayed key updates nothing but makes RETURNING yield the first row
+	return sqlx.Get(r.db, m, `
+		INSERT INTO meals (user_id, fdc_id, description, calories, protein, fat, carbs, quantity, unit, idempotency_key)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9, NULLIF($10, ''))
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE SET idempotency_key = EXCLUDED.idempotency_key
+		RETURNING `+mealColumns+`, COALESCE(idempotency_key, '') AS idempotency_key
+	`, m.UserID, m.FdcID, m.Description, m.Calories, m.Protein, m.Fat, m.Carbs, m.Quantity, m.Unit, m.IdempotencyKey)
+}
+
+func (r *nutritionRepo) ListMeals(userID string) ([]models.Meal, error) {
+	var list []models.Meal
+	err := sqlx.Select(r.db, &list, `
+		SELECT `+mealColumns+` FROM meals WHERE user_id = $1 ORDER BY created_at DESC
+	`, userID)
+	return list, err
+}
+
+func (r *nutritionRepo) MealsBetween(userID string, from, to time.Time) ([]models.Meal, error) {
+	var list []models.Meal
+	err := sqlx.Select(r.db, &list, `
+		SELECT `+mealColumns+`
+		FROM meals
+		WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at
+	`, userID, from, to)
+	return list, err
+}
+
+func (r *nutritionRepo) AddWater(l *models.WaterLog) error {
+	return sqlx.Get(r.db, l, `
+		INSERT INTO water_logs (user_id, amount_ml, idempotency_key) VALUES ($1, $2, NULLIF($3, ''))
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE SET idempotency_key = EXCLUDED.idempotency_key
+		RETURNING id, user_id, amount_ml, created_at, COALESCE(idempotency_key, '') AS idempotency_key
+	`, l.UserID, l.AmountML, l.IdempotencyKey)
+}
+
+func (r *nutritionRepo) WaterBetween(userID string, from, to time.Time) ([]models.WaterLog, error) {
+	var list []models.WaterLog
+	err := sqlx.Select(r.db, &list, `
+		SELECT id, user_id, amount_ml, created_at
+		FROM water_logs
+		WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at
+	`, userID, from.UTC(), to.UTC())
+	return list, err
+}
@@ -0,0 +1,158 @@
+package postgres
+
+import (
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+)
+
+type personalDataRepo struct {
+	db sqlx.Ext
+}
+
+// Export reads every table inside one transaction so the export is a
+// consistent snapshot.
+func (r *personalDataRepo) Export(userID string) (models.PersonalData, error) {
+	var d models.PersonalData
+	err := withTx(r.db, func(tx sqlx.Ext) error {
+		if err := sqlx.Get(tx, &d.User, `SELECT `+userColumns+` FROM users WHERE id = $1`, userID); err != nil {
+			return notFound(err)
+		}
+
+		d.Goals = map[models.GoalKind]int{}
+		for kind, t := range goalTables {
+			var goals []int
+			if err := sqlx.Select(tx, &goals, `SELECT `+t[1]+` FROM `+t[0]+` WHERE user_id = $1`, userID); err != nil {
+				return err
+			}
+			if len(goals) > 0 {
+				d.Goals[kind] = goals[0]
+			}
+		}
+		var since []models.TOTP
+		if err := sqlx.Select(tx, &since, `
+			SELECT user_id, '' AS secret, confirmed_at, last_step, created_at
+			FROM user_totp WHERE user_id = $1 AND confirmed_at IS NOT NULL
+		`, userID); err != nil {
+			return err
+		}
+		if len(since) > 0 {
+			d.TwoFactorSince = since[0].ConfirmedAt
+		}
+
+		queries := []struct {
+			dest  interface{}
+			query string
+		}{
+			{&d.Friends, `SELECT id, user_id, friend_id, created_at FROM friends
+				WHERE user_id = $1 ORDER BY created_at`},
+			{&d.FriendRequests, `SELECT id, requester_id, recipient_id, created_at FROM friend_requests
+				WHERE requester_id = $1 OR recipient_id = $1 ORDER BY created_at`},
+			{&d.Achievements, `SELECT id, user_id, achievement_id, unlocked_at FROM user_achievements
+				WHERE user_id = $1 ORDER BY unlocked_at`},
+			{&d.Activities, `SELECT ` + activityColumns + ` FROM activities
+				WHERE user_id = $1 ORDER BY performed_at`},
+			{&d.Steps, `SELECT TO_CHAR(day, 'YYYY-MM-DD') AS day, steps FROM user_steps
+				WHERE user_id = $1 ORDER BY day`},
+			{&d.StepBuckets, `SELECT ` + stepBucketColumns + ` FROM step_buckets
+				WHERE user_id = $1 ORDER BY day, source, hour NULLS FIRST`},
+			{&d.Meals, `SELECT ` + mealColumns + ` FROM meals
+				WHERE user_id = $1 ORDER BY created_at`},
+			{&d.WaterLogs, `SELECT id, user_id, amount_ml, created_at FROM water_logs
+				WHERE user_id = $1 ORDER BY created_at`},
+			{&d.Posts, `SELECT id, user_id, type, COALESCE(message, '') AS message, created_at FROM post_activities
+				WHERE user_id = $1 ORDER BY created_at`},
+			{&d.Messages, `SELECT id, sender_id, receiver_id, text, created_at FROM messages
+				WHERE sender_id = $1 OR receiver_id = $1 ORDER BY created_at`},
+			{&d.Challenges, `SELECT ` + challengeColumns + `
+				FROM challenges c WHERE c.creator_id = $1 ORDER BY c.created_at`},
+			{&d.Participations, `SELECT ` + participantColumns + ` FROM challenge_participants
+				WHERE user_id = $1 ORDER BY joined_at`},
+			{&d.Progress, `SELECT ` + progressEventColumns + ` FROM challenge_progress_events
+				WHERE user_id = $1 ORDER BY occurred_at`},
+			{&d.Invitations, `SELECT ` + invitationColumns + ` FROM challenge_invitations i
+				WHERE i.invitee_id = $1 OR i.inviter_id = $1 ORDER BY i.created_at`},
+			{&d.Workouts, `SELECT id, user_id, weekday, TO_CHAR(at_time, 'HH24:MI:SS') AS at_time, title, created_at
+				FROM workout_schedules WHERE user_id = $1 ORDER BY weekday, at_time`},
+			{&d.Hydration, `SELECT user_id, interval FROM hydration_settings WHERE user_id = $1`},
+			{&d.Sessions, `SELECT ` + sessionColumns + ` FROM sessions
+				WHERE user_id = $1 ORDER BY created_at`},
+			{&d.Identities, `SELECT ` + identityColumns + ` FROM user_identities
+				WHERE user_id = $1 ORDER BY created_at`},
+			{&d.AccessTokens, `SELECT ` + accessTokenColumns + ` FROM personal_access_tokens
+				WHERE user_id = $1 ORDER BY created_at`},
+			{&d.AuditEvents, `SELECT ` + auditColumns + ` FROM audit_events
+				WHERE subject_id = $1 ORDER BY created_at`},
+		}
+		for _, q := range queries {
+			if err := sqlx.Select(tx, q.dest, q.query, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return d, err
+}
+
+// eraseStatements run in order before the users row goes. Most tables
+// would cascade anyway; deleting explicitly keeps erasure complete even
+// where a foreign key does not.
+var eraseStatements = []string{
+	`UPDATE challenges SET creator_id = NULL WHERE creator_id = $1`,
+	`UPDATE audit_events SET ip = '', user_agent = '', state_before = NULL, state_after = NULL
+		WHERE (subject_id = $1 OR actor_id = $1)
+		  AND (ip <> '' OR user_agent <> '' OR state_before IS NOT NULL OR state_after IS NOT NULL)`,
+	`UPDATE challenge_invitations SET inviter_id = NULL WHERE inviter_id = $1`,
+	`DELETE FROM challenge_invitations WHERE invitee_id = $1`,
+	`DELETE FROM challenge_participants WHERE user_id = $1`,
+	`DELETE FROM challenge_progress_events WHERE user_id = $1`,
+	`DELETE FROM user_achievements WHERE user_id = $1`,
+	`DELETE FROM friend_requests WHERE requester_id = $1 OR recipient_id = $1`,
+	`DELETE FROM friends WHERE user_id = $1 OR friend_id = $1`,
+	`DELETE FROM activities WHERE user_id = $1`,
+	`DELETE FROM step_buckets WHERE user_id = $1`,
+	`DELETE FROM user_steps WHERE user_id = $1`,
+	`DELETE FROM step_goals WHERE user_id = $1`,
+	`DELETE FROM activity_goals WHERE user_id = $1`,
+	`DELETE FROM meals WHERE user_id = $1`,
+	`DELETE FROM calorie_goals WHERE user_id = $1`,
+	`DELETE FROM water_logs WHERE user_id = $1`,
+	`DELETE FROM water_goals WHERE user_id = $1`,
+	`DELETE FROM post_activities WHERE user_id = $1`,
+	`DELETE FROM messages WHERE sender_id = $1 OR receiver_id = $1`,
+	`DELETE FROM workout_schedules WHERE user_id = $1`,
+	`DELETE FROM hydration_settings WHERE user_id = $1`,
+	`DELETE FROM refresh_tokens WHERE user_id = $1`,
+	`DELETE FROM sessions WHERE user_id = $1`,
+	`DELETE FROM user_tokens WHERE user_id = $1`,
+	`DELETE FROM recovery_codes WHERE user_id = $1`,
+	`DELETE FROM user_totp WHERE user_id = $1`,
+	`DELETE FROM user_identities WHERE user_id = $1`,
+	`DELETE FROM personal_access_tokens WHERE user_id = $1`,
+	`DELETE FROM rate_limit_buckets WHERE key LIKE '%:user:' || $1`,
+	`DELETE FROM auth_failures WHERE key LIKE '%:user:' || $1`,
+}
+
+func (r *personalDataRepo) Erase(userID string) error {
+	return withTx(r.db, func(tx sqlx.Ext) error {
+		var email string
+		if err := sqlx.Get(tx, &email, `SELECT email FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+			return notFound(err)
+		}
+		for _, stmt := range eraseStatements {
+			if _, err := tx.Exec(stmt, userID); err != nil {
+				return err
+			}
+		}
+		// limits keyed by the sign-in email
+		emailKey := `%:email:` + escapeLike(strings.ToLower(email))
+		for _, table := range []string{"rate_limit_buckets", "auth_failures"} {
+			if _, err := tx.Exec(`DELETE FROM `+table+` WHERE key LIKE $1`, emailKey); err != nil {
+				return err
+			}
+		}
+		return deleteByID(tx, "users", userID)
+	})
+}
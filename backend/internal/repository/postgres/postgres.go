@@ -0,0 +1,93 @@
+// Package postgres implements the repository interfaces on top of sqlx.
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+// NewStore returns a repository.Store whose repositories share db.
+func NewStore(db *sqlx.DB) *repository.Store {
+	return &repository.Store{
+		Users:         &userRepo{db: db},
+		Friends:       &friendRepo{db: db},
+		Achievements:  &achievementRepo{db: db},
+		Activities:    &activityRepo{db: db},
+		Steps:         &stepRepo{db: db},
+		Goals:         &goalRepo{db: db},
+		Nutrition:     &nutritionRepo{db: db},
+		Messages:      &messageRepo{db: db},
+		Posts:         &postRepo{db: db},
+		Challenges:    &challengeRepo{db: db},
+		Schedules:     &scheduleRepo{db: db},
+		RefreshTokens: &refreshTokenRepo{db: db},
+		Sessions:      &sessionRepo{db: db},
+		RateLimits:    &rateLimitRepo{db: db},
+		AdminActions:  &adminActionRepo{db: db},
+		Audit:         &auditRepo{db: db},
+		PersonalData:  &personalDataRepo{db: db},
+		Identities:    &identityRepo{db: db},
+		AccessTokens:  &accessTokenRepo{db: db},
+		TwoFactor:     &twoFactorRepo{db: db},
+		UserTokens:    &userTokenRepo{db: db},
+	}
+}
+
+// notFound maps sql.ErrNoRows to repository.ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+// conflict maps a unique_violation to repository.ErrConflict.
+func conflict(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return repository.ErrConflict
+	}
+	return err
+}
+
+// deleteByID deletes the row of table with this id. It returns ErrNotFound
+// when there is none; rows referring to it go by ON DELETE CASCADE.
+func deleteByID(ext sqlx.Ext, table, id string) error {
+	res, err := ext.Exec(`DELETE FROM `+table+` WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// escapeLike quotes the LIKE wildcards in s so it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// withTx runs fn inside a transaction, or directly on ext when ext is
+// already a transaction.
+func withTx(ext sqlx.Ext, fn func(tx sqlx.Ext) error) error {
+	db, ok := ext.(*sqlx.DB)
+	if !ok {
+		return fn(ext)
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/ratelimit"
+)
+
+type rateLimitRepo struct {
+	db sqlx.Ext
+}
+
+// Take locks the bucket row so that concurrent requests, also from other
+// server instances, spend tokens one after another.
+func (r *rateLimitRepo) Take(key string, limit ratelimit.Limit, now time.Time) (time.Duration, error) {
+	var wait time.Duration
+	err := withTx(r.db, func(tx sqlx.Ext) error {
+		var b ratelimit.Bucket
+		err := tx.QueryRowx(`
+			SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE
+		`, key).Scan(&b.Tokens, &b.UpdatedAt)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		wait = b.Take(limit, now)
+		_, err = tx.Exec(`
+			INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO UPDATE SET tokens = EXCLUDED.tokens, updated_at = EXCLUDED.updated_at
+		`, key, b.Tokens, b.UpdatedAt)
+		return err
+	})
+	return wait, err
+}
+
+func (r *rateLimitRepo) AddFailure(key string, policy ratelimit.Lockout, now time.Time) (ratelimit.Failures, error) {
+	var f ratelimit.Failures
+	err := withTx(r.db, func(tx sqlx.Ext) error {
+		var err error
+		if f, err = r.failures(tx, key, true); err != nil {
+			return err
+		}
+		f.Add(policy, now)
+		_, err = tx.Exec(`
+			INSERT INTO auth_failures (key, count, window_start, locked_until) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (key) DO UPDATE
+			SET count = EXCLUDED.count, window_start = EXCLUDED.window_start, locked_until = EXCLUDED.locked_until
+		`, key, f.Count, f.WindowStart, nullTime(f.LockedUntil))
+		return err
+	})
+	return f, err
+}
+
+func (r *rateLimitRepo) Failures(key string) (ratelimit.Failures, error) {
+	return r.failures(r.db, key, false)
+}
+
+func (r *rateLimitRepo) ClearFailures(key string) error {
+	_, err := r.db.Exec(`DELETE FROM auth_failures WHERE key = $1`, key)
+	return err
+}
+
+func (r *rateLimitRepo) Sweep(before time.Time) (int, error) {
+	n := 0
+	err := withTx(r.db, func(tx sqlx.Ext) error {
+		for _, query := range []string{
+			`DELETE FROM rate_limit_buckets WHERE updated_at < $1`,
+			`DELETE FROM auth_failures WHERE window_start < $1 AND (locked_until IS NULL OR locked_until < $1)`,
+		} {
+			res, err := tx.Exec(query, before)
+			if err != nil {
+				return err
+			}
+			rows, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			n += int(rows)
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (r *rateLimitRepo) failures(q sqlx.Queryer, key string, forUpdate bool) (ratelimit.Failures, error) {
+	query := `SELECT count, window_start, locked_until FROM auth_failures WHERE key = $1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	var (
+		f      ratelimit.Failures
+		locked sql.NullTime
+	)
+	err := q.QueryRowx(query, key).Scan(&f.Count, &f.WindowStart, &locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ratelimit.Failures{}, nil
+	}
+	f.LockedUntil = locked.Time
+	return f, err
+}
+
+// nullTime stores a zero time as NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
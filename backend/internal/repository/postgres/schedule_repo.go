@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+)
+
+type scheduleRepo struct {
+	db sqlx.Ext
+}
+
+func (r *scheduleRepo) AddWorkout(w *models.WorkoutSchedule) error {
+	_, err := r.db.Exec(`
+		INSERT INTO workout_schedules (id, user_id, weekday, at_time, title)
+		VALUES ($1, $2, $3, $4, $5)
+	`, w.ID, w.UserID, w.Weekday, w.AtTime, w.Title)
+	return err
+}
+
+func (r *scheduleRepo) ListWorkouts(userID string) ([]models.WorkoutSchedule, error) {
+	var list []models.WorkoutSchedule
+	err := sqlx.Select(r.db, &list, `
+		SELECT id, user_id, weekday, TO_CHAR(at_time, 'HH24:MI:SS') AS at_time, title, created_at
+		FROM workout_schedules
+		WHERE user_id = $1
+		ORDER BY weekday, at_time
+	`, userID)
+	return list, err
+}
+
+func (r *scheduleRepo) DeleteWorkout(userID, id string) error {
+	_, err := r.db.Exec(`DELETE FROM workout_schedules WHERE id = $1 AND user_id = $2`, id, userID)
+	return err
+}
+
+func (r *scheduleRepo) WorkoutsDue(at time.Time) ([]models.WorkoutSchedule, error) {
+	var list []models.WorkoutSchedule
+	err := sqlx.Select(r.db, &list, `
+		SELECT ws.id, ws.user_id, ws.weekday, TO_CHAR(ws.at_time, 'HH24:MI:SS') AS at_time, ws.title, ws.created_at
+		FROM   workout_schedules ws
+		JOIN   users u ON u.id = ws.user_id
+		WHERE  ws.weekday = EXTRACT(ISODOW FROM $1::timestamptz AT TIME ZONE u.timezone)::int - 1
+		  AND  to_char(ws.at_time, 'HH24:MI') = to_char($1::timestamptz AT TIME ZONE u.timezone, 'HH24:MI')
+	`, at)
+	return list, err
+}
+
+func (r *scheduleRepo) HydrationSettings() ([]models.HydrationSetting, error) {
+	var list []models.HydrationSetting
+	err := sqlx.Select(r.db, &list, `
+		SELECT hs.user_id, hs.interval, u.timezone
+		FROM   hydration_settings hs
+		JOIN   users u ON u.id = hs.user_id
+	`)
+	return list, err
+}
@@ -0,0 +1,144 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+type refreshTokenRepo struct {
+	db sqlx.Ext
+}
+
+func (r *refreshTokenRepo) Create(t *models.RefreshToken) error {
+	_, err := sqlx.NamedExec(r.db, `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at)
+		VALUES (:id, :user_id, :family_id, :token_hash, :expires_at, :created_at)
+	`, t)
+	return err
+}
+
+func (r *refreshTokenRepo) ByHash(hash string) (models.RefreshToken, error) {
+	var t models.RefreshToken
+	err := sqlx.Get(r.db, &t, `
+		SELECT id, user_id, family_id, token_hash, expires_at, created_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`, hash)
+	return t, notFound(err)
+}
+
+func (r *refreshTokenRepo) MarkUsed(id string, at time.Time) (bool, error) {
+	res, err := r.db.Exec(`
+		UPDATE refresh_tokens SET used_at = $2
+		WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL
+	`, id, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *refreshTokenRepo) RevokeFamily(familyID string, at time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE refresh_tokens SET revoked_at = $2
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID, at)
+	return err
+}
+
+const sessionColumns = `id, user_id, user_agent, ip, created_at, last_seen_at, revoked_at`
+
+type sessionRepo struct {
+	db sqlx.Ext
+}
+
+func (r *sessionRepo) Create(s *models.Session) error {
+	_, err := sqlx.NamedExec(r.db, `
+		INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_seen_at)
+		VALUES (:id, :user_id, :user_agent, :ip, :created_at, :last_seen_at)
+	`, s)
+	return err
+}
+
+func (r *sessionRepo) ByID(id string) (models.Session, error) {
+	var s models.Session
+	err := sqlx.Get(r.db, &s, `SELECT `+sessionColumns+` FROM sessions WHERE id = $1`, id)
+	return s, notFound(err)
+}
+
+func (r *sessionRepo) ListActive(userID string, since time.Time) ([]models.Session, error) {
+	var list []models.Session
+	err := sqlx.Select(r.db, &list, `
+		SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND last_seen_at >= $2
+		ORDER BY last_seen_at DESC
+	`, userID, since)
+	return list, err
+}
+
+func (r *sessionRepo) Touch(id, ip string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE sessions SET ip = $2, last_seen_at = $3 WHERE id = $1`, id, ip, at)
+	return err
+}
+
+func (r *sessionRepo) Revoke(userID, id string, at time.Time) error {
+	res, err := r.db.Exec(`
+		UPDATE sessions SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, id, userID, at)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+type userTokenRepo struct {
+	db sqlx.Ext
+}
+
+func (r *userTokenRepo) Create(t *models.UserToken) error {
+	_, err := sqlx.NamedExec(r.db, `
+		INSERT INTO user_tokens (id, user_id, purpose, token_hash, expires_at, created_at)
+		VALUES (:id, :user_id, :purpose, :token_hash, :expires_at, :created_at)
+	`, t)
+	return err
+}
+
+func (r *userTokenRepo) Consume(hash, purpose string, at time.Time) (models.UserToken, error) {
+	var t models.UserToken
+	err := sqlx.Get(r.db, &t, `
+		UPDATE user_tokens SET used_at = $3
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING id, user_id, purpose, token_hash, expires_at, created_at, used_at
+	`, hash, purpose, at)
+	return t, notFound(err)
+}
+
+func (r *userTokenRepo) Peek(hash, purpose string, at time.Time) (models.UserToken, error) {
+	var t models.UserToken
+	err := sqlx.Get(r.db, &t, `
+		SELECT id, user_id, purpose, token_hash, expires_at, created_at, used_at
+		FROM user_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+	`, hash, purpose, at)
+	return t, notFound(err)
+}
+
+func (r *userTokenRepo) Invalidate(userID, purpose string, at time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE user_tokens SET used_at = $3
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`, userID, purpose, at)
+	return err
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+type twoFactorRepo struct {
+	db sqlx.Ext
+}
+
+func (r *twoFactorRepo) ByUser(userID string) (models.TOTP, error) {
+	var t models.TOTP
+	err := sqlx.Get(r.db, &t, `
+		SELECT user_id, secret, confirmed_at, last_step, created_at
+		FROM user_totp
+		WHERE user_id = $1
+	`, userID)
+	return t, notFound(err)
+}
+
+func (r *twoFactorRepo) Save(t *models.TOTP) error {
+	_, err := sqlx.NamedExec(r.db, `
+		INSERT INTO user_totp (user_id, secret, confirmed_at, last_step, created_at)
+		VALUES (:user_id, :secret, :confirmed_at, :last_step, :created_at)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, confirmed_at = EXCLUDED.confirmed_at,
+		    last_step = EXCLUDED.last_step, created_at = EXCLUDED.created_at
+	`, t)
+	return err
+}
+
+func (r *twoFactorRepo) Confirm(userID string, at time.Time) error {
+	res, err := r.db.Exec(`UPDATE user_totp SET confirmed_at = $2 WHERE user_id = $1`, userID, at)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *twoFactorRepo) UseStep(userID string, step int64) (bool, error) {
+	res, err := r.db.Exec(`
+		UPDATE user_totp SET last_step = $2
+		WHERE user_id = $1 AND last_step < $2
+	`, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *twoFactorRepo) Delete(userID string) error {
+	return withTx(r.db, func(tx sqlx.Ext) error {
+		if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = $1`, userID)
+		return err
+	})
+}
+
+func (r *twoFactorRepo) ReplaceRecoveryCodes(userID string, hashes []string) error {
+	return withTx(r.db, func(tx sqlx.Ext) error {
+		if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		for _, h := range hashes {
+			if _, err := tx.Exec(`
+				INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)
+			`, userID, h); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *twoFactorRepo) UseRecoveryCode(userID, hash string, at time.Time) error {
+	res, err := r.db.Exec(`
+		UPDATE recovery_codes SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hash, at)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
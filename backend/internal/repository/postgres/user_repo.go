@@ -0,0 +1,262 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+const userColumns = `id, name, email, password_hash, COALESCE(avatar_url, '') AS avatar_url, weight, height,
+	email_verified_at, role, suspended_at, delete_after, timezone`
+
+type userRepo struct {
+	db sqlx.Ext
+}
+
+func (r *userRepo) Create(u *models.User) error {
+	_, err := sqlx.NamedExec(r.db, `
+		INSERT INTO users (id, name, email, password_hash, avatar_url, email_verified_at, role, timezone)
+		VALUES (:id, :name, :email, :password_hash, :avatar_url, :email_verified_at,
+		        COALESCE(NULLIF(:role, ''), 'user'), COALESCE(NULLIF(:timezone, ''), 'UTC'))
+	`, u)
+	return conflict(err)
+}
+
+func (r *userRepo) ByID(id string) (models.User, error) {
+	var u models.User
+	err := sqlx.Get(r.db, &u, `SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+	return u, notFound(err)
+}
+
+func (r *userRepo) ByEmail(email string) (models.User, error) {
+	var u models.User
+	err := sqlx.Get(r.db, &u, `SELECT `+userColumns+` FROM users WHERE email = $1`, email)
+	return u, notFound(err)
+}
+
+func (r *userRepo) UpdateProfile(u *models.User) error {
+	_, err := r.db.Exec(`
+		UPDATE users
+		SET name = $1, avatar_url = $2, weight = $3, height = $4,
+		    timezone = COALESCE(NULLIF($5, ''), timezone)
+		WHERE id = $6
+	`, u.Name, u.AvatarURL, u.Weight, u.Height, u.Timezone, u.ID)
+	return err
+}
+
+func (r *userRepo) SetPassword(id, passwordHash string) error {
+	_, err := r.db.Exec(`UPDATE users SET password_hash = $1 WHERE id = $2`, passwordHash, id)
+	return err
+}
+
+func (r *userRepo) MarkEmailVerified(id string, at time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE users SET email_verified_at = $1
+		WHERE id = $2 AND email_verified_at IS NULL
+	`, at, id)
+	return err
+}
+func (r *userRepo) SetRole(id, role string) error {
+	_, err := r.db.Exec(`UPDATE users SET role = $1 WHERE id = $2`, role, id)
+	return err
+}
+
+func (r *userRepo) SetSuspended(id string, at *time.Time) error {
+	res, err := r.db.Exec(`UPDATE users SET suspended_at = $1 WHERE id = $2`, at, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *userRepo) SetDeleteAfter(id string, at *time.Time) error {
+	res, err := r.db.Exec(`UPDATE users SET delete_after = $1 WHERE id = $2`, at, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *userRepo) DueForDeletion(now time.Time) ([]string, error) {
+	var ids []string
+	err := sqlx.Select(r.db, &ids, `SELECT id FROM users WHERE delete_after <= $1 ORDER BY delete_after`, now)
+	return ids, err
+}
+
+func (r *userRepo) Search(query string, limit, offset int) ([]models.User, error) {
+	var list []models.User
+	err := sqlx.Select(r.db, &list, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE $1 = '' OR name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%'
+		ORDER BY email
+		LIMIT $2 OFFSET $3
+	`, escapeLike(query), limit, offset)
+	return list, err
+}
+
+type friendRepo struct {
+	db sqlx.Ext
+}
+
+func (r *friendRepo) CreateRequest(fr *models.FriendRequest) error {
+	if fr.ID == "" {
+		fr.ID = uuid.NewString()
+	}
+	if fr.CreatedAt.IsZero() {
+		fr.CreatedAt = time.Now()
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO friend_requests (id, requester_id, recipient_id, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, fr.ID, fr.RequesterID, fr.RecipientID, fr.CreatedAt)
+	return err
+}
+
+func (r *friendRepo) IncomingRequest(id, recipientID string) (models.FriendRequest, error) {
+	var fr models.FriendRequest
+	err := sqlx.Get(r.db, &fr, `
+		SELECT id, requester_id, recipient_id, created_at
+		FROM friend_requests
+		WHERE id = $1 AND recipient_id = $2
+	`, id, recipientID)
+	return fr, notFound(err)
+}
+
+func (r *friendRepo) DeleteRequest(id, recipientID string) error {
+	_, err := r.db.Exec(`
+		DELETE FROM friend_requests
+		WHERE id = $1 AND recipient_id = $2
+	`, id, recipientID)
+	return err
+}
+
+func (r *friendRepo) ListIncoming(recipientID string) ([]models.IncomingFriendRequest, error) {
+	var list []models.IncomingFriendRequest
+	err := sqlx.Select(r.db, &list, `
+		SELECT fr.id AS id, u.name, u.email
+		FROM friend_requests fr
+		JOIN users u ON u.id = fr.requester_id
+		WHERE fr.recipient_id = $1
+		ORDER BY fr.created_at
+	`, recipientID)
+	return list, err
+}
+
+func (r *friendRepo) AddFriendship(userID, friendID string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO friends (user_id, friend_id) VALUES ($1, $2), ($2, $1)
+		ON CONFLICT (user_id, friend_id) DO NOTHING
+	`, userID, friendID)
+	return err
+}
+
+func (r *friendRepo) ListFriends(userID string) ([]models.User, error) {
+	var list []models.User
+	err := sqlx.Select(r.db, &list, `
+		SELECT u.id, u.name, u.email, u.password_hash,
+		       COALESCE(u.avatar_url, '') AS avatar_url, u.weight, u.height, u.email_verified_at,
+		       u.role, u.suspended_at
+		FROM friends f
+		JOIN users u ON u.id = f.friend_id
+		WHERE f.user_id = $1
+		ORDER BY u.name
+	`, userID)
+	return list, err
+}
+
+func (r *friendRepo) FriendIDs(userID string) ([]string, error) {
+	var ids []string
+	err := sqlx.Select(r.db, &ids, `SELECT friend_id FROM friends WHERE user_id = $1`, userID)
+	return ids, err
+}
+
+const achievementColumns = `id, title, description, icon_url, created_at`
+
+type achievementRepo struct {
+	db sqlx.Ext
+}
+
+func (r *achievementRepo) List() ([]models.Achievement, error) {
+	var list []models.Achievement
+	err := sqlx.Select(r.db, &list, `SELECT `+achievementColumns+` FROM achievements ORDER BY created_at`)
+	return list, err
+}
+
+func (r *achievementRepo) ListUnlocked(userID string) ([]models.Achievement, error) {
+	var list []models.Achievement
+	err := sqlx.Select(r.db, &list, `
+		SELECT DISTINCT a.id, a.title, a.description, a.icon_url, a.created_at
+		FROM achievements a
+		JOIN user_achievements ua ON a.id = ua.achievement_id
+		WHERE ua.user_id = $1
+		ORDER BY a.created_at
+	`, userID)
+	return list, err
+}
+
+func (r *achievementRepo) ByTitle(title string) (models.Achievement, error) {
+	var a models.Achievement
+	err := sqlx.Get(r.db, &a, `SELECT `+achievementColumns+` FROM achievements WHERE title = $1`, title)
+	return a, notFound(err)
+}
+
+func (r *achievementRepo) Create(a *models.Achievement) error {
+	if a.ID == "" {
+		a.ID = uuid.NewString()
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	_, err := sqlx.NamedExec(r.db, `
+		INSERT INTO achievements (id, title, description, icon_url, created_at)
+		VALUES (:id, :title, :description, :icon_url, :created_at)
+	`, a)
+	return conflict(err)
+}
+
+func (r *achievementRepo) Update(a *models.Achievement) error {
+	res, err := sqlx.NamedExec(r.db, `
+		UPDATE achievements
+		SET title = :title, description = :description, icon_url = :icon_url
+		WHERE id = :id
+	`, a)
+	if err != nil {
+		return conflict(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *achievementRepo) Delete(id string) error {
+	return deleteByID(r.db, "achievements", id)
+}
+
+func (r *achievementRepo) Award(userID, achievementID, challengeID string) error {
+	var chID *string
+	if challengeID != "" {
+		chID = &challengeID
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO user_achievements (user_id, achievement_id, challenge_id)
+		SELECT $1::uuid, $2::uuid, $3::uuid
+		WHERE NOT EXISTS (
+			SELECT 1 FROM user_achievements
+			WHERE user_id = $1 AND achievement_id = $2
+			  AND challenge_id IS NOT DISTINCT FROM $3
+		)
+	`, userID, achievementID, chID)
+	return err
+}
@@ -0,0 +1,388 @@
+// Package repository declares the data access used by internal/services,
+// one interface per aggregate. Implementations live in sub-packages.
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/ratelimit"
+)
+
+// ErrNotFound is returned when a lookup by key matches no row.
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned when a write would break a uniqueness rule.
+var ErrConflict = errors.New("already exists")
+
+type UserRepository interface {
+	Create(u *models.User) error
+	ByID(id string) (models.User, error)
+	ByEmail(email string) (models.User, error)
+	// UpdateProfile stores name, avatar, weight and height of u, and its
+	// time zone unless that is empty.
+	UpdateProfile(u *models.User) error
+	SetPassword(id, passwordHash string) error
+	MarkEmailVerified(id string, at time.Time) error
+	SetRole(id, role string) error
+	// SetSuspended suspends the user at the given time, or reinstates the
+	// user when at is nil. It returns ErrNotFound for unknown users.
+	SetSuspended(id string, at *time.Time) error
+	// Search returns users whose name or email contains query, ignoring
+	// case, ordered by email. An empty query matches everyone.
+	Search(query string, limit, offset int) ([]models.User, error)
+	// SetDeleteAfter schedules the account for erasure, or cancels that
+	// when at is nil. It returns ErrNotFound for unknown users.
+	SetDeleteAfter(id string, at *time.Time) error
+	// DueForDeletion returns the users whose DeleteAfter is before now.
+	DueForDeletion(now time.Time) ([]string, error)
+}
+
+type FriendRepository interface {
+	CreateRequest(r *models.FriendRequest) error
+	// IncomingRequest returns request id if it is addressed to recipientID.
+	IncomingRequest(id, recipientID string) (models.FriendRequest, error)
+	DeleteRequest(id, recipientID string) error
+	ListIncoming(recipientID string) ([]models.IncomingFriendRequest, error)
+	// AddFriendship links both users to each other.
+	AddFriendship(userID, friendID string) error
+	ListFriends(userID string) ([]models.User, error)
+	FriendIDs(userID string) ([]string, error)
+}
+
+type AchievementRepository interface {
+	List() ([]models.Achievement, error)
+	ListUnlocked(userID string) ([]models.Achievement, error)
+	ByTitle(title string) (models.Achievement, error)
+	// Create adds a catalog entry; a taken title yields ErrConflict.
+	Create(a *models.Achievement) error
+	// Update stores title, description and icon of a.
+	Update(a *models.Achievement) error
+	// Delete removes the entry and every award of it.
+	Delete(id string) error
+	// Award unlocks an achievement once per (user, achievement, challenge);
+	// an empty challengeID means the award is not tied to a challenge.
+	Award(userID, achievementID, challengeID string) error
+}
+
+type ActivityRepository interface {
+	// Create stores a and fills in its ID and time. When the user already
+	// logged an activity under a's IdempotencyKey, a is filled in with that
+	// one instead and nothing is stored.
+	Create(a *models.Activity) error
+	// List returns the user's activities newest first, optionally of one type.
+	List(userID string, activityType *string) ([]models.Activity, error)
+	// ListBetween returns activities performed in [from, to).
+	ListBetween(userID string, from, to time.Time) ([]models.Activity, error)
+}
+
+type StepRepository interface {
+	// Upsert stores the total for day ("YYYY-MM-DD"), replacing any previous one.
+	Upsert(userID, day string, steps int) error
+	// ListSince returns daily totals from fromDay on, newest first.
+	ListSince(userID, fromDay string) ([]models.StepDay, error)
+	Total(userID string) (int, error)
+	// Delete removes the total and buckets of day, or returns ErrNotFound.
+	Delete(userID, day string) error
+	// SaveBuckets stores buckets, replacing those of the same source, day
+	// and hour, and re-merges the totals of the days they fall on: the sum
+	// over hours of the highest count any source has for the hour, or the
+	// highest whole-day count when that is more.
+	SaveBuckets(userID string, buckets []models.StepBucket) error
+	// Buckets returns the buckets from fromDay on, by day, source and hour.
+	Buckets(userID, fromDay string) ([]models.StepBucket, error)
+}
+
+type GoalRepository interface {
+	// Get returns ErrNotFound when the user never set this goal.
+	Get(userID string, kind models.GoalKind) (int, error)
+	Set(userID string, kind models.GoalKind, value int) error
+}
+
+type NutritionRepository interface {
+	// AddMeal and AddWater store the entry and fill in its ID and time.
+	// When the user already logged one under the entry's IdempotencyKey,
+	// the entry is filled in with that one instead and nothing is stored.
+	AddMeal(m *models.Meal) error
+	ListMeals(userID string) ([]models.Meal, error)
+	// MealsBetween returns meals logged in [from, to).
+	MealsBetween(userID string, from, to time.Time) ([]models.Meal, error)
+	AddWater(l *models.WaterLog) error
+	// WaterBetween returns water logged in [from, to).
+	WaterBetween(userID string, from, to time.Time) ([]models.WaterLog, error)
+}
+
+type MessageRepository interface {
+	Create(m *models.Message) error
+	// Conversation returns messages between the two users, oldest first.
+	Conversation(userID, friendID string) ([]models.Message, error)
+}
+
+type PostRepository interface {
+	Create(p *models.PostActivity) error
+	// Feed returns the latest posts of the given users with their names.
+	Feed(userIDs []string, limit int) ([]models.PostActivity, error)
+	Delete(id string) error
+}
+
+type ChallengeRepository interface {
+	// Create stores the challenge with its teams and enrolls participants
+	// atomically; a participant's TeamID must name one of teams.
+	Create(ch *models.Challenge, teams []models.ChallengeTeam, participants []models.ChallengeParticipant) error
+	ByID(id string) (models.Challenge, error)
+	// AddParticipant enrolls the user. In a team challenge they join the
+	// team with the fewest members, the earliest of those on a tie.
+	AddParticipant(challengeID, userID string) error
+	// Teams returns the challenge's teams in creation order.
+	Teams(challengeID string) ([]models.ChallengeTeam, error)
+	// Invite stores pending invitations and returns those stored. Declined
+	// invitations are opened again; participants and users with an open
+	// invitation are skipped.
+	Invite(invitations []models.ChallengeInvitation) ([]models.ChallengeInvitation, error)
+	// Invitation returns the invitation by ID.
+	Invitation(id string) (models.ChallengeInvitation, error)
+	// InvitationTo returns the user's invitation to the challenge.
+	InvitationTo(challengeID, userID string) (models.ChallengeInvitation, error)
+	// Invitations returns the user's invitations in status, or all of
+	// them when status is empty, newest first, with the challenge title
+	// and the inviter's name.
+	Invitations(userID, status string) ([]models.ChallengeInvitation, error)
+	// RespondInvitation accepts or declines a pending invitation, or
+	// returns ErrConflict when it was answered already. Accepting enrolls
+	// the invitee like AddParticipant, on the invitation's team if it has
+	// one.
+	RespondInvitation(id, status string) error
+	// RecordProgress adds events to the progress ledger. An event whose
+	// idempotency key the user already has replaces the earlier one.
+	RecordProgress(events []models.ProgressEvent) error
+	// ProgressEvents returns the user's ledger entries for metric that
+	// occurred at or after since, oldest first.
+	ProgressEvents(userID, metric string, since time.Time) ([]models.ProgressEvent, error)
+	// RecountProgress sets the user's progress in every active challenge of
+	// metric to the sum of the ledger entries inside the challenge's window
+	// from when they joined, capped at the target, and reports how many
+	// rows it covered. Entries spanning a period count by the part of the
+	// period inside the window, rounded down in total.
+	RecountProgress(userID, metric string) (int, error)
+	// Activate moves scheduled challenges that have started by now to
+	// active, counts their participants' progress and returns them.
+	Activate(now time.Time) ([]models.Challenge, error)
+	// Finish moves active challenges that have ended by now to finished,
+	// counting their progress a last time and freezing the standings into
+	// the participants' dense final ranks, and returns them.
+	Finish(now time.Time) ([]models.Challenge, error)
+	// Participants returns the challenge's participants with their names
+	// and avatars, best first; ties keep the order they joined in.
+	Participants(challengeID string) ([]models.ChallengeParticipant, error)
+	// ListForUser returns challenges the user created or joined, newest first.
+	ListForUser(userID string) ([]models.Challenge, error)
+	// Discover returns matching public challenges, the most popular first
+	// or, with ByStart, the earliest to start, ties broken by ID.
+	Discover(f ChallengeFilter) ([]models.ChallengeSummary, error)
+	// Completed returns IDs of challenges where the user reached the target.
+	Completed(userID string) ([]string, error)
+	// EndingBefore returns every participant still below the target of an
+	// active challenge that ends before until, with the participant's time
+	// zone.
+	EndingBefore(until time.Time) ([]models.ParticipantProgress, error)
+	// Delete removes the challenge with its participants and the
+	// achievements awarded for it.
+	Delete(id string) error
+}
+
+type AdminActionRepository interface {
+	Create(a *models.AdminAction) error
+	// List returns the latest actions, newest first.
+	List(limit int) ([]models.AdminAction, error)
+}
+
+// ChallengeFilter narrows ChallengeRepository.Discover; zero fields match
+// everything but finished challenges, which only match Status "finished".
+// Durations are in days and sizes count participants.
+type ChallengeFilter struct {
+	Type             string
+	Status           string
+	MinDays, MaxDays int
+	MinSize, MaxSize int
+	ByStart          bool
+	// After continues past the last challenge of the previous page.
+	After *ChallengeCursor
+	Limit int
+	// ViewerID is who Joined is reported for.
+	ViewerID string
+}
+
+// ChallengeCursor is where a page of discovered challenges ended: the
+// last challenge and the value it was sorted by.
+type ChallengeCursor struct {
+	ID           string    `json:"id"`
+	Participants int       `json:"participants,omitempty"`
+	StartsAt     time.Time `json:"starts_at"`
+}
+
+// AuditFilter narrows AuditRepository.List; zero fields match everything.
+type AuditFilter struct {
+	SubjectID string
+	Action    string
+	Limit     int
+	Offset    int
+}
+
+// AuditRepository stores audit events. Events are never changed or
+// removed once appended.
+type AuditRepository interface {
+	Append(e *models.AuditEvent) error
+	// List returns matching events, newest first.
+	List(f AuditFilter) ([]models.AuditEvent, error)
+}
+
+// PersonalDataRepository reads and erases everything stored about a user.
+type PersonalDataRepository interface {
+	// Export collects the user's rows from every table; ErrNotFound for
+	// unknown users.
+	Export(userID string) (models.PersonalData, error)
+	// Erase deletes the user and their rows from every table at once.
+	// Rows other users still rely on are kept without personal details:
+	// challenges lose their creator, audit events their IP, user agent
+	// and snapshots.
+	Erase(userID string) error
+}
+
+type ScheduleRepository interface {
+	AddWorkout(w *models.WorkoutSchedule) error
+	ListWorkouts(userID string) ([]models.WorkoutSchedule, error)
+	DeleteWorkout(userID, id string) error
+	// WorkoutsDue returns the schedules whose weekday and time, read in
+	// their owner's time zone, fall on the minute of at.
+	WorkoutsDue(at time.Time) ([]models.WorkoutSchedule, error)
+	// HydrationSettings returns every setting with its owner's time zone.
+	HydrationSettings() ([]models.HydrationSetting, error)
+}
+
+type RefreshTokenRepository interface {
+	Create(t *models.RefreshToken) error
+	ByHash(hash string) (models.RefreshToken, error)
+	// MarkUsed flags an unused, unrevoked token as exchanged. It reports
+	// false when another request got there first.
+	MarkUsed(id string, at time.Time) (bool, error)
+	// RevokeFamily revokes every token descended from the same login.
+	RevokeFamily(familyID string, at time.Time) error
+}
+
+type SessionRepository interface {
+	Create(s *models.Session) error
+	ByID(id string) (models.Session, error)
+	// ListActive returns the user's unrevoked sessions seen at or after
+	// since, most recently seen first.
+	ListActive(userID string, since time.Time) ([]models.Session, error)
+	// Touch records activity from ip at the given time.
+	Touch(id, ip string, at time.Time) error
+	// Revoke ends one of the user's sessions; it returns ErrNotFound when
+	// the user has no such active session.
+	Revoke(userID, id string, at time.Time) error
+}
+
+type UserTokenRepository interface {
+	Create(t *models.UserToken) error
+	// Consume marks the unexpired, unused token with this hash and purpose
+	// as used and returns it; any other token yields ErrNotFound.
+	Consume(hash, purpose string, at time.Time) (models.UserToken, error)
+	// Peek returns the unexpired, unused token with this hash and purpose
+	// without using it up; any other token yields ErrNotFound.
+	Peek(hash, purpose string, at time.Time) (models.UserToken, error)
+	// Invalidate uses up every open token of the user for purpose.
+	Invalidate(userID, purpose string, at time.Time) error
+}
+
+type TwoFactorRepository interface {
+	// ByUser returns the user's enrollment, ErrNotFound when there is none.
+	ByUser(userID string) (models.TOTP, error)
+	// Save stores an unconfirmed enrollment, replacing an earlier one.
+	Save(t *models.TOTP) error
+	Confirm(userID string, at time.Time) error
+	// UseStep records an accepted time step. It reports false when that
+	// step or a later one was accepted before.
+	UseStep(userID string, step int64) (bool, error)
+	// Delete removes the enrollment together with the recovery codes.
+	Delete(userID string) error
+	// ReplaceRecoveryCodes swaps the user's recovery codes for hashes.
+	ReplaceRecoveryCodes(userID string, hashes []string) error
+	// UseRecoveryCode marks an unused code as used; it returns ErrNotFound
+	// when the user has no such code.
+	UseRecoveryCode(userID, hash string, at time.Time) error
+}
+
+type AccessTokenRepository interface {
+	Create(t *models.AccessToken) error
+	// ByHash returns the token with this hash, revoked and expired ones
+	// included; ErrNotFound when there is none.
+	ByHash(hash string) (models.AccessToken, error)
+	// ListForUser returns the user's unrevoked tokens, newest first.
+	ListForUser(userID string) ([]models.AccessToken, error)
+	Touch(id string, at time.Time) error
+	// Revoke revokes one of the user's tokens; ErrNotFound when the user
+	// has no such unrevoked token.
+	Revoke(userID, id string, at time.Time) error
+}
+
+type IdentityRepository interface {
+	// Create links an identity; ErrConflict when the provider account or
+	// a second account at the same provider is already linked.
+	Create(i *models.Identity) error
+	// ByProviderSubject returns the identity for the provider's subject,
+	// ErrNotFound when it is not linked.
+	ByProviderSubject(provider, subject string) (models.Identity, error)
+	ListForUser(userID string) ([]models.Identity, error)
+	Touch(id string, at time.Time) error
+	// Delete unlinks one of the user's identities; ErrNotFound when the
+	// user has no such identity.
+	Delete(userID, id string) error
+
+	SaveLogin(l *models.OIDCLogin) error
+	// TakeLogin removes and returns the unexpired login with this state
+	// hash; ErrNotFound when there is none.
+	TakeLogin(stateHash string, at time.Time) (models.OIDCLogin, error)
+}
+
+// RateLimitRepository keeps the token buckets and failure counters of the
+// rate-limit middleware. Keys are opaque strings chosen by the caller.
+type RateLimitRepository interface {
+	// Take spends a token from the bucket under key and returns zero, or
+	// how long to wait when the bucket is empty.
+	Take(key string, limit ratelimit.Limit, now time.Time) (time.Duration, error)
+	// AddFailure counts a failure against key and returns the new state.
+	AddFailure(key string, policy ratelimit.Lockout, now time.Time) (ratelimit.Failures, error)
+	// Failures returns the state of key, zero when nothing was recorded.
+	Failures(key string) (ratelimit.Failures, error)
+	ClearFailures(key string) error
+	// Sweep deletes the buckets last used before before, and the failure
+	// counts whose window started and whose lock ended before it. It
+	// returns how many keys it deleted.
+	Sweep(before time.Time) (int, error)
+}
+
+// Store bundles one implementation of every repository.
+type Store struct {
+	Users         UserRepository
+	Friends       FriendRepository
+	Achievements  AchievementRepository
+	Activities    ActivityRepository
+	Steps         StepRepository
+	Goals         GoalRepository
+	Nutrition     NutritionRepository
+	Messages      MessageRepository
+	Posts         PostRepository
+	Challenges    ChallengeRepository
+	Schedules     ScheduleRepository
+	RefreshTokens RefreshTokenRepository
+	Sessions      SessionRepository
+	UserTokens    UserTokenRepository
+	RateLimits    RateLimitRepository
+	TwoFactor     TwoFactorRepository
+	AdminActions  AdminActionRepository
+	Audit         AuditRepository
+	PersonalData  PersonalDataRepository
+	Identities    IdentityRepository
+	AccessTokens  AccessTokenRepository
+}
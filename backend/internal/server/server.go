@@ -1,24 +1,75 @@
 package server
 
 import (
+	"log"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers/activity"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers/admin"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers/nutrition"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers/user"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers/wellness"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository/memory"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository/postgres"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/services"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/db"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/mailer"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/password"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/ratelimit"
 )
 
+// NewRouter opens the configured storage, wires the service container and
+// returns the API router.
 func NewRouter(cfg *config.Config) (*gin.Engine, error) {
 	_ = godotenv.Load()
 
-	if err := db.Init(cfg); err != nil {
+	store, err := OpenStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := password.NewPolicy(cfg.PasswordMinLength, cfg.BreachedPasswordsFile)
+	if err != nil {
+		return nil, err
+	}
+	c := services.NewContainer(cfg, store, mail, policy)
+	// limits refill within a minute and the 2FA lockout ends within the
+	// hour; the login lockout may take longer
+	middleware.SweepRateLimits(c.RateLimits, max(time.Hour, cfg.LoginLockout))
+	return NewRouterWithServices(cfg, c), nil
+}
+
+// OpenStore returns the repositories of the backend chosen by cfg.Storage,
+// connecting to the database when that backend is Postgres.
+func OpenStore(cfg *config.Config) (*repository.Store, error) {
+	if cfg.Storage == config.StorageMemory {
+		log.Println("⚠️  Using in-memory storage, data is lost on restart")
+		return memory.NewStore(), nil
+	}
+	conn, err := db.Open(cfg)
+	if err != nil {
 		return nil, err
 	}
+	return postgres.NewStore(conn), nil
+}
+
+// NewRouterWithServices installs c as the services behind the handlers and
+// returns the API router. Tests use it to run the HTTP layer on fakes.
+func NewRouterWithServices(cfg *config.Config, c *services.Container) *gin.Engine {
+	services.Use(c)
+	activity.ResetUserService(c.User)
+	activity.ResetStepService(c.Step)
+	activity.ResetChallengeService(c.Challenge)
 
 	if cfg.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -29,17 +80,60 @@ func NewRouter(cfg *config.Config) (*gin.Engine, error) {
 
 	router.GET("/health", handlers.HealthCheck)
 
+	// Sign-in style routes are limited per IP and per email, with failed
+	// logins locking the account; authenticated groups per user.
+	authLimit := middleware.RateLimit(c.RateLimits, "auth",
+		middleware.RateRule{Key: middleware.ByIP, Limit: ratelimit.PerMinute(cfg.AuthRateLimit)},
+		middleware.RateRule{Key: middleware.ByEmail, Limit: ratelimit.PerMinute(cfg.AuthRateLimit)},
+	)
+	loginLockout := middleware.Lockout(c.RateLimits, services.LoginLockoutGroup, middleware.ByEmail, ratelimit.Lockout{
+		MaxFailures: cfg.LoginMaxFailures,
+		Window:      cfg.LoginLockout,
+		Duration:    cfg.LoginLockout,
+	})
+	userLimit := func(group string) gin.HandlerFunc {
+		return middleware.RateLimit(c.RateLimits, group,
+			middleware.RateRule{Key: middleware.ByUser, Limit: ratelimit.PerMinute(cfg.APIRateLimit)},
+		)
+	}
+
 	api := router.Group("/api")
 	{
 		api.GET("/ping", handlers.Ping)
 
 		users := api.Group("/users")
 		{
-			users.POST("/register", user.Register)
-			users.POST("/login", user.Login)
-			users.Use(middleware.Auth())
+			users.POST("/register", authLimit, user.Register)
+			users.POST("/login", authLimit, loginLockout, user.Login)
+			users.POST("/login/2fa", authLimit, user.LoginTwoFactor)
+			users.POST("/token/refresh", authLimit, user.RefreshToken)
+			users.POST("/logout", user.Logout)
+			users.POST("/password/forgot", authLimit, user.ForgotPassword)
+			users.POST("/password/reset", authLimit, user.ResetPassword)
+			users.POST("/email/verify", authLimit, user.VerifyEmail)
+			users.GET("/oidc", user.ListOIDCProviders)
+			users.GET("/oidc/:provider/start", authLimit, user.StartOIDC)
+			users.POST("/oidc/:provider/callback", authLimit, user.FinishOIDC)
+			users.Use(middleware.Auth(), userLimit("users"))
 			users.GET("/profile", user.GetProfile)
 			users.PUT("/profile", user.UpdateProfile)
+			users.POST("/email/verify/resend", user.ResendVerification)
+			users.PUT("/password", user.ChangePassword)
+			users.POST("/2fa/enroll", user.EnrollTwoFactor)
+			users.POST("/2fa/confirm", user.ConfirmTwoFactor)
+			users.POST("/2fa/disable", user.DisableTwoFactor)
+			users.POST("/2fa/recovery-codes", user.RegenerateRecoveryCodes)
+			users.GET("/sessions", user.ListSessions)
+			users.DELETE("/sessions/:id", user.RevokeSession)
+			users.GET("/audit", user.ListAuditEvents)
+			users.GET("/export", user.ExportData)
+			users.GET("/identities", user.ListIdentities)
+			users.DELETE("/identities/:id", user.UnlinkIdentity)
+			users.GET("/tokens", user.ListAccessTokens)
+			users.POST("/tokens", user.CreateAccessToken)
+			users.DELETE("/tokens/:id", user.RevokeAccessToken)
+			users.DELETE("/me", user.DeleteAccount)
+			users.POST("/me/restore", user.RestoreAccount)
 			users.POST("/friends/request", user.RequestFriend)
 			users.GET("/friends/requests", user.ListFriendRequests)
 			users.GET("/friends", user.ListFriends)
@@ -49,24 +143,36 @@ func NewRouter(cfg *config.Config) (*gin.Engine, error) {
 			users.POST("/friends/requests/:id/decline", user.DeclineFriendRequest)
 		}
 
+		// Personal access tokens reach the data groups below with the
+		// matching scope; account routes above only take session tokens.
+		steps := api.Group("/activities")
+		steps.Use(middleware.Auth(middleware.Scopes{Read: models.ScopeStepsRead, Write: models.ScopeStepsWrite}), userLimit("activities"))
+		{
+			steps.POST("/steps", activity.AddSteps)
+			steps.PUT("/steps/:day", activity.UpdateStepDay)
+			steps.DELETE("/steps/:day", activity.DeleteStepDay)
+			steps.GET("/stats", activity.GetStepStats)
+			steps.GET("/analytics", activity.GetStepAnalytics)
+			steps.POST("/steps/goal", activity.SetStepGoal)
+			steps.GET("/steps/goal", activity.GetStepGoal)
+		}
+
 		acts := api.Group("/activities")
-		acts.Use(middleware.Auth())
+		acts.Use(middleware.Auth(middleware.Scopes{Read: models.ScopeActivitiesRead, Write: models.ScopeActivitiesWrite}), userLimit("activities"))
 		{
 			acts.POST("", activity.AddActivity)
 			acts.GET("", activity.ListActivities)
-			acts.POST("/steps", activity.AddSteps)
-			acts.GET("/stats", activity.GetStepStats)
-			acts.GET("/analytics", activity.GetStepAnalytics)
-			acts.POST("/steps/goal", activity.SetStepGoal)
-			acts.GET("/steps/goal", activity.GetStepGoal)
 			acts.POST("/goal", activity.SetActivityGoal)
 			acts.GET("/goal", activity.GetActivityGoal)
 			acts.GET("/today-calories", activity.GetTodayActivityCalories)
 			acts.GET("/activity/weekly", activity.GetWeeklyActivityStats)
+			acts.POST("/schedule/workouts", wellness.AddWorkout)
+			acts.GET("/schedule/workouts", wellness.ListWorkouts)
+			acts.DELETE("/schedule/workouts/:id", wellness.DeleteWorkout)
 		}
 
 		nut := api.Group("/nutrition")
-		nut.Use(middleware.Auth())
+		nut.Use(middleware.Auth(middleware.Scopes{Read: models.ScopeNutritionRead, Write: models.ScopeNutritionWrite}), userLimit("nutrition"))
 		{
 			nut.GET("/foods/search", handlers.SearchUSDAFoods)
 			nut.POST("/meals", nutrition.AddMeal)
@@ -85,7 +191,7 @@ func NewRouter(cfg *config.Config) (*gin.Engine, error) {
 		well := api.Group("/wellness")
 		{
 			well.GET("/ws", wellness.WebSocketHandler)
-			well.Use(middleware.Auth())
+			well.Use(middleware.Auth(middleware.Scopes{Read: models.ScopeWellnessRead, Write: models.ScopeWellnessWrite}), userLimit("wellness"))
 			well.POST("/activities", wellness.PostActivity)
 			well.GET("/activities", wellness.GetFriendsActivities)
 			well.GET("/ws/activity", wellness.ActivitySocket)
@@ -94,10 +200,32 @@ func NewRouter(cfg *config.Config) (*gin.Engine, error) {
 			well.POST("/messages", wellness.PostMessage)
 			well.POST("/challenges", wellness.CreateChallenge)
 			well.GET("/challenges", wellness.ListChallenges)
+			well.GET("/challenges/metrics", wellness.ListChallengeMetrics)
+			well.GET("/challenges/discover", wellness.DiscoverChallenges)
 			well.POST("/challenges/:id/join", wellness.JoinChallenge)
+			well.POST("/challenges/:id/invitations", wellness.InviteToChallenge)
+			well.GET("/challenges/invitations", wellness.ListInvitations)
+			well.POST("/challenges/invitations/:id/accept", wellness.AcceptInvitation)
+			well.POST("/challenges/invitations/:id/decline", wellness.DeclineInvitation)
 			well.GET("/challenges/:id/leaderboard", wellness.GetLeaderboard)
 		}
+
+		adm := api.Group("/admin")
+		adm.Use(middleware.Auth(), middleware.RequireRole(models.RoleAdmin), userLimit("admin"))
+		{
+			adm.GET("/users", admin.ListUsers)
+			adm.POST("/users/:id/suspend", admin.SuspendUser)
+			adm.POST("/users/:id/reinstate", admin.ReinstateUser)
+			adm.GET("/achievements", admin.ListAchievements)
+			adm.POST("/achievements", admin.CreateAchievement)
+			adm.PUT("/achievements/:id", admin.UpdateAchievement)
+			adm.DELETE("/achievements/:id", admin.DeleteAchievement)
+			adm.DELETE("/challenges/:id", admin.DeleteChallenge)
+			adm.DELETE("/posts/:id", admin.DeletePost)
+			adm.GET("/actions", admin.ListActions)
+			adm.GET("/audit", admin.ListAuditEvents)
+		}
 	}
 
-	return router, nil
+	return router
 }
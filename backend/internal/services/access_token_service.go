@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+var (
+	// ErrInvalidScope is returned when a token asks for an unknown scope.
+	ErrInvalidScope = errors.New("unknown scope")
+	// ErrInvalidAccessToken is returned for unknown, revoked or expired
+	// personal access tokens.
+	ErrInvalidAccessToken = errors.New("invalid or expired access token")
+	// ErrInvalidExpiry is returned for lifetimes over MaxAccessTokenDays.
+	ErrInvalidExpiry = fmt.Errorf("tokens expire after at most %d days", MaxAccessTokenDays)
+)
+
+// AccessTokenPrefix starts every personal access token, so they can be
+// told apart from JWTs and spotted by secret scanners.
+const AccessTokenPrefix = "hsp_"
+
+// Lifetimes of personal access tokens, in days.
+const (
+	DefaultAccessTokenDays = 90
+	MaxAccessTokenDays     = 365
+)
+
+// Actions recorded for personal access tokens.
+const (
+	AuditAccessTokenCreate = "access_token.create"
+	AuditAccessTokenRevoke = "access_token.revoke"
+)
+
+// AccessTokenInput creates a personal access token. ExpiresInDays
+// defaults to DefaultAccessTokenDays.
+type AccessTokenInput struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expiresInDays" binding:"min=0"`
+}
+
+// NewAccessToken is a just created token together with its secret, which
+// is never shown again.
+type NewAccessToken struct {
+	models.AccessToken
+	Token string `json:"token"`
+}
+
+type AccessTokenService interface {
+	Create(userID string, in AccessTokenInput) (NewAccessToken, error)
+	List(userID string) ([]models.AccessToken, error)
+	Revoke(userID, id string) error
+	// Authenticate returns the token and its owner for a secret sent by a
+	// client. Suspended owners get ErrAccountSuspended.
+	Authenticate(secret string) (models.AccessToken, models.User, error)
+}
+
+type accessTokenService struct {
+	tokens repository.AccessTokenRepository
+	users  repository.UserRepository
+}
+
+// AccessTokens is the exported singleton service, set up by Use.
+var AccessTokens AccessTokenService
+
+// NewAccessTokenService builds the AccessTokenService on the repositories
+// in store.
+func NewAccessTokenService(store *repository.Store) AccessTokenService {
+	return &accessTokenService{tokens: store.AccessTokens, users: store.Users}
+}
+
+// IsAccessToken reports whether a bearer token is a personal access token
+// rather than a JWT.
+func IsAccessToken(bearer string) bool {
+	return strings.HasPrefix(bearer, AccessTokenPrefix)
+}
+
+func (s *accessTokenService) Create(userID string, in AccessTokenInput) (NewAccessToken, error) {
+	days := in.ExpiresInDays
+	if days == 0 {
+		days = DefaultAccessTokenDays
+	}
+	if days > MaxAccessTokenDays {
+		return NewAccessToken{}, ErrInvalidExpiry
+	}
+	var scopes models.ScopeList
+	for _, scope := range in.Scopes {
+		if !models.ScopeList(models.AllScopes).Has(scope) {
+			return NewAccessToken{}, fmt.Errorf("%w %q", ErrInvalidScope, scope)
+		}
+		if !scopes.Has(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		return NewAccessToken{}, err
+	}
+	secret = AccessTokenPrefix + secret
+	now := time.Now()
+	t := models.AccessToken{
+		UserID:    userID,
+		Name:      strings.TrimSpace(in.Name),
+		Prefix:    secret[:len(AccessTokenPrefix)+6],
+		TokenHash: hashToken(secret),
+		Scopes:    scopes,
+		ExpiresAt: now.AddDate(0, 0, days),
+		CreatedAt: now,
+	}
+	if err := s.tokens.Create(&t); err != nil {
+		return NewAccessToken{}, err
+	}
+	return NewAccessToken{AccessToken: t, Token: secret}, nil
+}
+
+func (s *accessTokenService) List(userID string) ([]models.AccessToken, error) {
+	return s.tokens.ListForUser(userID)
+}
+
+func (s *accessTokenService) Revoke(userID, id string) error {
+	return s.tokens.Revoke(userID, id, time.Now())
+}
+
+func (s *accessTokenService) Authenticate(secret string) (models.AccessToken, models.User, error) {
+	now := time.Now()
+	t, err := s.tokens.ByHash(hashToken(secret))
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.AccessToken{}, models.User{}, ErrInvalidAccessToken
+	} else if err != nil {
+		return models.AccessToken{}, models.User{}, err
+	}
+	if t.RevokedAt != nil || !now.Before(t.ExpiresAt) {
+		return models.AccessToken{}, models.User{}, ErrInvalidAccessToken
+	}
+
+	user, err := s.users.ByID(t.UserID)
+	if err != nil {
+		return models.AccessToken{}, models.User{}, err
+	}
+	if user.SuspendedAt != nil {
+		return models.AccessToken{}, models.User{}, ErrAccountSuspended
+	}
+	if err := s.tokens.Touch(t.ID, now); err != nil {
+		log.Printf("[AccessTokens] touch %s: %v", t.ID, err)
+	}
+	return t, user, nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/mailer"
+)
+
+// ErrInvalidToken is returned for unknown, expired or already used
+// reset and verification tokens.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+const (
+	passwordResetTTL = time.Hour
+	emailVerifyTTL   = 48 * time.Hour
+)
+
+// RequestPasswordReset mails a reset link if email belongs to a user. The
+// lookup and the mail happen in the background, so neither the outcome
+// nor the time it takes can be used to probe for accounts.
+func (u *userService) RequestPasswordReset(email string) {
+	go func() {
+		if err := u.sendPasswordReset(email); err != nil {
+			log.Printf("RequestPasswordReset: mail failed: %v", err)
+		}
+	}()
+}
+
+// sendPasswordReset mails a reset link to the user with email; unknown
+// addresses are skipped.
+func (u *userService) sendPasswordReset(email string) error {
+	user, err := u.users.ByEmail(email)
+	if err != nil {
+		return nil
+	}
+	token, err := u.issueUserToken(user.ID, models.TokenPasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	return u.mail.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"someone asked to reset the password of your account. If it was you, open\n\n"+
+			"  %s\n\n"+
+			"within %s. Otherwise ignore this message, your password stays the same.\n",
+			user.Name, u.link("/reset-password", token), passwordResetTTL),
+	})
+}
+
+// ResetPassword sets a new password with a token from RequestPasswordReset
+// and signs the user out everywhere.
+func (u *userService) ResetPassword(token, newPassword string) error {
+	now := time.Now()
+	// a rejected password leaves the token usable for another try
+	t, err := u.userTokens.Peek(hashToken(token), models.TokenPasswordReset, now)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	user, err := u.users.ByID(t.UserID)
+	if err != nil {
+		return err
+	}
+	if err := u.opts.PasswordPolicy.Check(newPassword, user.Email); err != nil {
+		return err
+	}
+	if _, err := u.userTokens.Consume(hashToken(token), models.TokenPasswordReset, now); err != nil {
+		return ErrInvalidToken
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := u.users.SetPassword(t.UserID, string(hash)); err != nil {
+		return err
+	}
+	if err := u.userTokens.Invalidate(t.UserID, models.TokenPasswordReset, now); err != nil {
+		return err
+	}
+	return u.endAllSessionsExcept(t.UserID, "")
+}
+
+// SendVerificationEmail mails a link that confirms the user's address.
+// It does nothing for addresses that are already verified.
+func (u *userService) SendVerificationEmail(userID string) error {
+	user, err := u.users.ByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	token, err := u.issueUserToken(user.ID, models.TokenEmailVerify, emailVerifyTTL)
+	if err != nil {
+		return err
+	}
+	return u.mail.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"please confirm your email address by opening\n\n"+
+			"  %s\n\n"+
+			"The link works once and expires in %s.\n",
+			user.Name, u.link("/verify-email", token), emailVerifyTTL),
+	})
+}
+
+// VerifyEmail marks the address behind token as verified, promoting the
+// user when it is one of the admin emails.
+func (u *userService) VerifyEmail(token string) error {
+	now := time.Now()
+	t, err := u.userTokens.Consume(hashToken(token), models.TokenEmailVerify, now)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := u.users.MarkEmailVerified(t.UserID, now); err != nil {
+		return err
+	}
+	user, err := u.users.ByID(t.UserID)
+	if err != nil {
+		return err
+	}
+	if err := u.promoteAdmin(user); err != nil {
+		return err
+	}
+	return u.userTokens.Invalidate(t.UserID, models.TokenEmailVerify, now)
+}
+
+// issueUserToken stores a fresh single-use token, replacing any open one
+// of the same purpose, and returns it in clear.
+func (u *userService) issueUserToken(userID, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	if err := u.userTokens.Invalidate(userID, purpose, now); err != nil {
+		return "", err
+	}
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	err = u.userTokens.Create(&models.UserToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	return token, err
+}
+
+// link builds a web-app URL carrying token.
+func (u *userService) link(path, token string) string {
+	return u.opts.AppURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// SignOutEverywhere ends every session of the user, closing their sockets.
+func (u *userService) SignOutEverywhere(userID string) error {
+	return u.endAllSessionsExcept(userID, "")
+}
+
+// endAllSessionsExcept signs the user out of every device but keepID.
+func (u *userService) endAllSessionsExcept(userID, keepID string) error {
+	list, err := u.sessions.ListActive(userID, time.Time{})
+	if err != nil {
+		return err
+	}
+	for _, s := range list {
+		if s.ID == keepID {
+			continue
+		}
+		if err := u.endSession(userID, s.ID); err != nil {
+			log.Printf("ending session %s of %s: %v", s.ID, userID, err)
+		}
+	}
+	return nil
+}
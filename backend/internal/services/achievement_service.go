@@ -1,23 +1,19 @@
 package services
 
-import "github.com/timur-harin/sum25-go-flutter-course/backend/pkg/db"
+import "github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
 
-type achievementService struct{}
+// challengeAchievementTitle is the catalog entry unlocked by finishing a challenge.
+const challengeAchievementTitle = "Completed a Challenge"
 
-var Achieve = &achievementService{}
-
-func (s *achievementService) award(id, userID string) error {
-	// id = achievements.id (UUID)
-	_, err := db.DB.Exec(`
-        INSERT INTO user_achievements (achievement_id, user_id)
-        VALUES ($1, $2) ON CONFLICT DO NOTHING
-    `, id, userID)
-	return err
-}
-
-// helper that returns the canonical “Completed a Challenge” UUID
-func (s *achievementService) challengeAchievementID() (string, error) {
-	var id string
-	err := db.DB.Get(&id, `SELECT id FROM achievements WHERE title = 'Completed a Challenge' LIMIT 1`)
-	return id, err
+// awardChallengeCompletion unlocks the "Completed a Challenge" achievement
+// once for every challenge in challengeIDs.
+func awardChallengeCompletion(achievements repository.AchievementRepository, userID string, challengeIDs []string) error {
+	ach, err := achievements.ByTitle(challengeAchievementTitle)
+	if err != nil {
+		return err
+	}
+	for _, chID := range challengeIDs {
+		_ = achievements.Award(userID, ach.ID, chID)
+	}
+	return nil
 }
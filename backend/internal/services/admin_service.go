@@ -0,0 +1,203 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+var (
+	// ErrNotFound lets handlers answer 404 without importing repository.
+	ErrNotFound = repository.ErrNotFound
+	// ErrAchievementExists is returned for a catalog title already in use.
+	ErrAchievementExists = errors.New("an achievement with this title already exists")
+	// ErrSuspendSelf stops admins from locking themselves out.
+	ErrSuspendSelf = errors.New("admins cannot suspend themselves")
+)
+
+// Actions recorded in admin_actions.
+const (
+	ActionSuspendUser       = "user.suspend"
+	ActionReinstateUser     = "user.reinstate"
+	ActionCreateAchievement = "achievement.create"
+	ActionUpdateAchievement = "achievement.update"
+	ActionDeleteAchievement = "achievement.delete"
+	ActionDeleteChallenge   = "challenge.delete"
+	ActionDeletePost        = "post.delete"
+)
+
+// AdminUser is a user as listed to admins.
+type AdminUser struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	Email         string     `json:"email"`
+	Role          string     `json:"role"`
+	EmailVerified bool       `json:"emailVerified"`
+	SuspendedAt   *time.Time `json:"suspendedAt,omitempty"`
+}
+
+// AchievementInput creates or edits a catalog entry.
+type AchievementInput struct {
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	IconURL     string `json:"iconUrl"`
+}
+
+type AdminService interface {
+	ListUsers(query string, limit, offset int) ([]AdminUser, error)
+	SuspendUser(adminID, userID, reason string) error
+	ReinstateUser(adminID, userID string) error
+	ListAchievements() ([]models.Achievement, error)
+	CreateAchievement(adminID string, in AchievementInput) (models.Achievement, error)
+	UpdateAchievement(adminID, id string, in AchievementInput) (models.Achievement, error)
+	DeleteAchievement(adminID, id string) error
+	DeleteChallenge(adminID, id, reason string) error
+	DeletePost(adminID, id, reason string) error
+	ListActions(limit int) ([]models.AdminAction, error)
+}
+
+// adminService moderates content of other users; every change goes to
+// the admin_actions log.
+type adminService struct {
+	users        repository.UserRepository
+	achievements repository.AchievementRepository
+	challenges   repository.ChallengeRepository
+	posts        repository.PostRepository
+	actions      repository.AdminActionRepository
+	user         UserService
+}
+
+// Admin is the exported singleton service, set up by Use.
+var Admin AdminService
+
+// NewAdminService builds the AdminService on the repositories in store.
+// It signs suspended users out through user.
+func NewAdminService(store *repository.Store, user UserService) AdminService {
+	return &adminService{
+		users:        store.Users,
+		achievements: store.Achievements,
+		challenges:   store.Challenges,
+		posts:        store.Posts,
+		actions:      store.AdminActions,
+		user:         user,
+	}
+}
+
+// ListUsers searches users by name or email.
+func (s *adminService) ListUsers(query string, limit, offset int) ([]AdminUser, error) {
+	list, err := s.users.Search(strings.TrimSpace(query), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AdminUser, 0, len(list))
+	for _, u := range list {
+		out = append(out, AdminUser{
+			ID:            u.ID,
+			Name:          u.Name,
+			Email:         u.Email,
+			Role:          u.Role,
+			EmailVerified: u.EmailVerifiedAt != nil,
+			SuspendedAt:   u.SuspendedAt,
+		})
+	}
+	return out, nil
+}
+
+// SuspendUser blocks the user from signing in and ends their sessions.
+func (s *adminService) SuspendUser(adminID, userID, reason string) error {
+	if adminID == userID {
+		return ErrSuspendSelf
+	}
+	now := time.Now()
+	if err := s.users.SetSuspended(userID, &now); err != nil {
+		return err
+	}
+	if err := s.user.SignOutEverywhere(userID); err != nil {
+		return err
+	}
+	return s.record(adminID, ActionSuspendUser, "user", userID, reason)
+}
+
+// ReinstateUser lifts a suspension.
+func (s *adminService) ReinstateUser(adminID, userID string) error {
+	if err := s.users.SetSuspended(userID, nil); err != nil {
+		return err
+	}
+	return s.record(adminID, ActionReinstateUser, "user", userID, "")
+}
+
+func (s *adminService) ListAchievements() ([]models.Achievement, error) {
+	return s.achievements.List()
+}
+
+func (s *adminService) CreateAchievement(adminID string, in AchievementInput) (models.Achievement, error) {
+	a := models.Achievement{
+		Title:       strings.TrimSpace(in.Title),
+		Description: in.Description,
+		IconURL:     in.IconURL,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.achievements.Create(&a); errors.Is(err, repository.ErrConflict) {
+		return models.Achievement{}, ErrAchievementExists
+	} else if err != nil {
+		return models.Achievement{}, err
+	}
+	return a, s.record(adminID, ActionCreateAchievement, "achievement", a.ID, a.Title)
+}
+
+// UpdateAchievement edits an entry. Users keep what they unlocked.
+func (s *adminService) UpdateAchievement(adminID, id string, in AchievementInput) (models.Achievement, error) {
+	a := models.Achievement{
+		ID:          id,
+		Title:       strings.TrimSpace(in.Title),
+		Description: in.Description,
+		IconURL:     in.IconURL,
+	}
+	if err := s.achievements.Update(&a); errors.Is(err, repository.ErrConflict) {
+		return models.Achievement{}, ErrAchievementExists
+	} else if err != nil {
+		return models.Achievement{}, err
+	}
+	return a, s.record(adminID, ActionUpdateAchievement, "achievement", id, a.Title)
+}
+
+// DeleteAchievement removes an entry and takes it away from every user.
+func (s *adminService) DeleteAchievement(adminID, id string) error {
+	if err := s.achievements.Delete(id); err != nil {
+		return err
+	}
+	return s.record(adminID, ActionDeleteAchievement, "achievement", id, "")
+}
+
+func (s *adminService) DeleteChallenge(adminID, id, reason string) error {
+	if err := s.challenges.Delete(id); err != nil {
+		return err
+	}
+	return s.record(adminID, ActionDeleteChallenge, "challenge", id, reason)
+}
+
+func (s *adminService) DeletePost(adminID, id, reason string) error {
+	if err := s.posts.Delete(id); err != nil {
+		return err
+	}
+	return s.record(adminID, ActionDeletePost, "post", id, reason)
+}
+
+// ListActions returns the latest admin actions, newest first.
+func (s *adminService) ListActions(limit int) ([]models.AdminAction, error) {
+	return s.actions.List(limit)
+}
+
+func (s *adminService) record(adminID, action, targetType, targetID, details string) error {
+	return s.actions.Create(&models.AdminAction{
+		AdminID:    adminID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+		CreatedAt:  time.Now(),
+	})
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+// Actions recorded in audit_events.
+const (
+	AuditLogin           = "auth.login"
+	AuditLoginFailed     = "auth.login_failed"
+	AuditProfileUpdate   = "profile.update"
+	AuditFriendAccept    = "friend_request.accept"
+	AuditFriendDecline   = "friend_request.decline"
+	AuditChallengeCreate = "challenge.create"
+	AuditChallengeJoin   = "challenge.join"
+	AuditChallengeInvite = "challenge.invite"
+	AuditInviteDecline   = "challenge_invitation.decline"
+	AuditGoalUpdate      = "goal.update"
+)
+
+// AuditEntry is an event to record. Before and After are stored as JSON
+// snapshots; nil leaves them out.
+type AuditEntry struct {
+	ActorID    string
+	SubjectID  string
+	Action     string
+	TargetType string
+	TargetID   string
+	Client     ClientInfo
+	Before     interface{}
+	After      interface{}
+}
+
+type AuditService interface {
+	// Record appends an event. Failures are logged and never fail the
+	// action being audited.
+	Record(e AuditEntry)
+	// ListForUser returns the events about userID, newest first.
+	ListForUser(userID string, limit, offset int) ([]models.AuditEvent, error)
+	// List returns events across all users for admins.
+	List(f repository.AuditFilter) ([]models.AuditEvent, error)
+}
+
+type auditService struct {
+	events repository.AuditRepository
+}
+
+// Audit is the exported singleton service, set up by Use.
+var Audit AuditService
+
+// NewAuditService builds the AuditService on top of events.
+func NewAuditService(events repository.AuditRepository) AuditService {
+	return &auditService{events: events}
+}
+
+func (s *auditService) Record(e AuditEntry) {
+	ev := models.AuditEvent{
+		ActorID:    e.ActorID,
+		SubjectID:  e.SubjectID,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         e.Client.IP,
+		UserAgent:  e.Client.UserAgent,
+		Before:     snapshot(e.Before),
+		After:      snapshot(e.After),
+		CreatedAt:  time.Now(),
+	}
+	if err := s.events.Append(&ev); err != nil {
+		log.Printf("[Audit] recording %s for %s: %v", e.Action, e.SubjectID, err)
+	}
+}
+
+func (s *auditService) ListForUser(userID string, limit, offset int) ([]models.AuditEvent, error) {
+	return s.events.List(repository.AuditFilter{SubjectID: userID, Limit: limit, Offset: offset})
+}
+
+func (s *auditService) List(f repository.AuditFilter) ([]models.AuditEvent, error) {
+	return s.events.List(f)
+}
+
+func snapshot(v interface{}) *json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("[Audit] snapshot of %T: %v", v, err)
+		return nil
+	}
+	raw := json.RawMessage(b)
+	return &raw
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"math"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+// ErrUnknownMetric is returned for challenge types not in the registry.
+var ErrUnknownMetric = errors.New("unknown challenge metric")
+
+// Sources of challenge progress events.
+const (
+	ProgressSourceSteps    = "steps"
+	ProgressSourceActivity = "activity"
+	ProgressSourceMeal     = "meal"
+	ProgressSourceWater    = "water"
+	ProgressSourceGoal     = "goal"
+)
+
+// Daily goals used when the user never set one.
+const (
+	DefaultStepGoal     = 10000
+	DefaultWaterGoalML  = 2000
+	DefaultCalorieGoal  = 2000
+	DefaultActivityGoal = 500
+)
+
+// goalOrDefault returns the user's goal of kind, or def when they never
+// set one. Other errors are passed on, so a failing store does not pass
+// for the default.
+func goalOrDefault(goals repository.GoalRepository, userID string, kind models.GoalKind, def int) (int, error) {
+	goal, err := goals.Get(userID, kind)
+	if errors.Is(err, repository.ErrNotFound) {
+		return def, nil
+	}
+	return goal, err
+}
+
+// ChallengeMetric is something a challenge can track, named by the
+// challenge's type. A metric counts either every entry of a log, by the
+// amount the entry adds, or days in the user's time zone, by the day's
+// amount. A day's amount counts at the day's last instant unless the
+// metric knows its hours: then each hour counts for itself and what no
+// hour holds is spread over the day.
+type ChallengeMetric struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Unit  string `json:"unit"`
+
+	activity func(a models.Activity) int
+	meal     func(m models.Meal) int
+	water    func(l models.WaterLog) int
+
+	source string // of the daily events
+	daily  func(d dayLogs) int
+	hours  func(d dayLogs) []int
+}
+
+// dayLogs is what was logged on one day, with the goals of the day.
+type dayLogs struct {
+	Steps     int
+	StepHours []int // by local hour, nil without hourly counts
+	StepGoal  int
+	WaterML   int
+	WaterGoal int
+}
+
+// challengeMetrics is the registry of metrics, in the order clients list
+// them. Adding a metric takes an entry here and, to count what was logged
+// before, a migration seeding the ledger.
+var challengeMetrics = []ChallengeMetric{
+	{
+		Name: "steps", Label: "Steps", Unit: "steps",
+		source: ProgressSourceSteps,
+		daily:  func(d dayLogs) int { return d.Steps },
+		hours:  func(d dayLogs) []int { return d.StepHours },
+	},
+	{
+		Name: "workouts", Label: "Workouts", Unit: "workouts",
+		activity: func(models.Activity) int { return 1 },
+	},
+	{
+		Name: "active_minutes", Label: "Active minutes", Unit: "min",
+		activity: func(a models.Activity) int { return a.Duration },
+	},
+	{
+		Name: "calories_burned", Label: "Calories burned", Unit: "kcal",
+		activity: func(a models.Activity) int { return a.Calories },
+	},
+	{
+		Name: "distance", Label: "Distance", Unit: "m",
+		activity: func(a models.Activity) int { return a.Distance },
+	},
+	{
+		Name: "calories", Label: "Calories eaten", Unit: "kcal",
+		meal: func(m models.Meal) int { return int(math.Round(m.Calories)) },
+	},
+	{
+		Name: "water_ml", Label: "Water", Unit: "ml",
+		water: func(l models.WaterLog) int { return l.AmountML },
+	},
+	{
+		Name: "step_goal_days", Label: "Days step goal met", Unit: "days",
+		source: ProgressSourceGoal,
+		daily:  func(d dayLogs) int { return goalMet(d.Steps, d.StepGoal) },
+	},
+	{
+		Name: "water_goal_days", Label: "Days water goal met", Unit: "days",
+		source: ProgressSourceGoal,
+		daily:  func(d dayLogs) int { return goalMet(d.WaterML, d.WaterGoal) },
+	},
+}
+
+// ChallengeMetrics returns the registry.
+func ChallengeMetrics() []ChallengeMetric { return challengeMetrics }
+
+// LookupChallengeMetric returns the metric named name.
+func LookupChallengeMetric(name string) (ChallengeMetric, bool) {
+	for _, m := range challengeMetrics {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return ChallengeMetric{}, false
+}
+
+func goalMet(done, goal int) int {
+	if goal > 0 && done >= goal {
+		return 1
+	}
+	return 0
+}
@@ -1,161 +1,1043 @@
 package services
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"math"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
-	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/db"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
 )
 
+var (
+	// ErrInvalidChallenge is returned for a challenge target that cannot
+	// be reached.
+	ErrInvalidChallenge = errors.New("invalid challenge")
+	// ErrInvalidChallengeWindow is returned for challenge dates out of order
+	// or out of bounds.
+	ErrInvalidChallengeWindow = errors.New("invalid challenge dates")
+	// ErrChallengeFinished is returned when joining a finished challenge.
+	ErrChallengeFinished = errors.New("challenge has finished")
+	// ErrInvalidTeams is returned for team setups that cannot be used.
+	ErrInvalidTeams = errors.New("invalid teams")
+	// ErrNotTeamChallenge is returned when asking for the teams of an
+	// individual challenge.
+	ErrNotTeamChallenge = errors.New("not a team challenge")
+	// ErrChallengeForbidden is returned when the challenge's visibility
+	// keeps the user from joining, inviting to it or seeing its standings.
+	ErrChallengeForbidden = errors.New("challenge is not open to you")
+	// ErrInvitationAnswered is returned when responding to an invitation
+	// a second time.
+	ErrInvitationAnswered = errors.New("invitation already answered")
+	// ErrInvalidDiscovery is returned for discovery filters or cursors that
+	// cannot be used.
+	ErrInvalidDiscovery = errors.New("invalid discovery query")
+)
+
+// How long challenges run.
+const (
+	DefaultChallengeDays = 7
+	MaxChallengeDays     = 365
+	MinChallengeDuration = time.Hour
+)
+
+// Bounds of team challenges.
+const (
+	MaxChallengeTeams = 20
+	maxTeamNameLen    = 50
+)
+
+// Page sizes of challenge discovery.
+const (
+	DefaultDiscoverLimit = 20
+	MaxDiscoverLimit     = 50
+)
+
+// Page sizes of leaderboards.
+const (
+	DefaultLeaderboardLimit = 20
+	MaxLeaderboardLimit     = 100
+)
+
+// Placement achievements, by final rank.
+var placementAchievements = map[int]string{
+	1: "Challenge Winner",
+	2: "Challenge Runner-up",
+	3: "Challenge Third Place",
+}
+
+// ChallengeInput creates a challenge. It starts at StartsAt, or right away
+// when that is empty or past, and ends at EndsAt, DefaultChallengeDays
+// after the start by default. Participants and team members are user IDs
+// or emails.
+//
+// Participants and team members are invited rather than enrolled; the
+// challenge is private unless Visibility says otherwise.
+//
+// A team challenge has either Teams, named by the creator, or TeamCount
+// numbered ones. Participants not placed on a team, the creator included,
+// are spread over the teams to keep them even, as are those who join
+// later. TeamScoring ranks the teams, by "sum" unless set to "average".
+type ChallengeInput struct {
+	Title        string      `json:"title" binding:"required"`
+	Type         string      `json:"type" binding:"required"`
+	Target       int         `json:"target" binding:"required"`
+	Participants []string    `json:"participants"`
+	StartsAt     *time.Time  `json:"starts_at"`
+	EndsAt       *time.Time  `json:"ends_at"`
+	Teams        []TeamInput `json:"teams"`
+	TeamCount    int         `json:"team_count"`
+	TeamScoring  string      `json:"team_scoring" binding:"omitempty,oneof=sum average"`
+	Visibility   string      `json:"visibility" binding:"omitempty,oneof=private friends public"`
+}
+
+// TeamInput names a team and the participants placed on it.
+type TeamInput struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+// DiscoverQuery searches public challenges. Type is a metric, durations
+// are in days and sizes count participants; zero values match
+// everything. Without Status finished challenges are left out. Sort is
+// "popular", the default, or "starts_at", and Cursor continues a previous
+// page.
+type DiscoverQuery struct {
+	Type    string `form:"type"`
+	Status  string `form:"status" binding:"omitempty,oneof=scheduled active finished"`
+	MinDays int    `form:"min_days" binding:"min=0"`
+	MaxDays int    `form:"max_days" binding:"min=0"`
+	MinSize int    `form:"min_size" binding:"min=0"`
+	MaxSize int    `form:"max_size" binding:"min=0"`
+	Sort    string `form:"sort" binding:"omitempty,oneof=popular starts_at"`
+	Limit   int    `form:"limit" binding:"min=0"`
+	Cursor  string `form:"cursor"`
+}
+
+// DiscoverPage is one page of discovered challenges. NextCursor is empty
+// on the last page.
+type DiscoverPage struct {
+	Challenges []models.ChallengeSummary `json:"challenges"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+}
+
+// LeaderboardQuery pages through a leaderboard. Without Offset the page
+// is centred on the viewer, or starts at the top when they do not take
+// part.
+type LeaderboardQuery struct {
+	Offset *int `form:"offset" binding:"omitempty,min=0"`
+	Limit  int  `form:"limit" binding:"min=0"`
+}
+
+// LeaderboardEntry is a participant's place. Ranks are dense: equal
+// progress shares a rank and the next one follows without a gap. Percent
+// is the progress in percent of the target.
+type LeaderboardEntry struct {
+	Rank      int     `json:"rank"`
+	UserID    string  `json:"user_id"`
+	Name      string  `json:"name"`
+	AvatarURL string  `json:"avatar_url,omitempty"`
+	Progress  int     `json:"progress"`
+	Percent   float64 `json:"percent"`
+	TeamID    string  `json:"team_id,omitempty"`
+	FinalRank *int    `json:"final_rank,omitempty"`
+	You       bool    `json:"you,omitempty"`
+}
+
+// LeaderboardPage is a page of the leaderboard starting at Offset, out of
+// Total participants. You is the viewer's entry, also when it is off the
+// page.
+type LeaderboardPage struct {
+	Entries []LeaderboardEntry `json:"entries"`
+	Offset  int                `json:"offset"`
+	Total   int                `json:"total"`
+	You     *LeaderboardEntry  `json:"you,omitempty"`
+}
+
+// TeamStanding is a team's place on a team challenge's leaderboard.
+// Score is the sum or the average of its members' progress.
+type TeamStanding struct {
+	TeamID  string       `json:"team_id"`
+	Name    string       `json:"name"`
+	Rank    int          `json:"rank"`
+	Score   float64      `json:"score"`
+	Total   int          `json:"total"`
+	Members []TeamMember `json:"members"`
+}
+
+// TeamMember is what one member contributed: their progress and its
+// share of the team total, in percent.
+type TeamMember struct {
+	UserID    string  `json:"user_id"`
+	Name      string  `json:"name"`
+	AvatarURL string  `json:"avatar_url,omitempty"`
+	Progress  int     `json:"progress"`
+	Share     float64 `json:"share"`
+}
+
 type ChallengeService interface {
-	Create(creatorID, title, ctype string, target int, friends []string) (models.Challenge, error)
+	Create(creatorID string, in ChallengeInput) (models.Challenge, error)
+	// Join adds the user to the challenge unless it has finished or its
+	// visibility keeps them out. Joining with a pending invitation accepts
+	// it.
 	Join(chID, userID string) error
-	UpdateProgress(userID, activityType string, delta int)
-	Leaderboard(chID string) ([]models.ChallengeParticipant, error)
+	// Invite invites the participant's friends, by ID or email; only the
+	// creator invites to private challenges. Anyone else is skipped
+	// without telling the inviter why, so invites cannot be used to probe
+	// for accounts. Invitees are told through the ActivityHub.
+	Invite(chID, inviterID string, invitees []string) ([]models.ChallengeInvitation, error)
+	// Invitations returns the user's invitations in status, or all of them.
+	Invitations(userID, status string) ([]models.ChallengeInvitation, error)
+	// RespondInvitation accepts or declines the user's invitation and
+	// tells the inviter.
+	RespondInvitation(userID, invitationID string, accept bool) error
+	// Leaderboard returns a page of the challenge's standings as the
+	// viewer sees them. Only participants see the standings of challenges
+	// that are not public.
+	Leaderboard(chID, viewerID string, q LeaderboardQuery) (LeaderboardPage, error)
+	// TeamLeaderboard ranks the teams of a team challenge, best first;
+	// equal scores share a rank. It is visible like Leaderboard.
+	TeamLeaderboard(chID, viewerID string) ([]TeamStanding, error)
 	ListForUser(userID string) ([]models.Challenge, error)
-	BumpProgress(userID, ctype string, delta int) error
+	// Discover pages through the public challenges matching q, telling
+	// which ones the user joined already.
+	Discover(userID string, q DiscoverQuery) (DiscoverPage, error)
+	// RecordActivity, RecordMeal and RecordWater put what a logged entry
+	// adds to each metric on the progress ledger, keyed by the entry's ID,
+	// and recount the user's challenges. Recording an entry again replaces
+	// what it counted before.
+	RecordActivity(a models.Activity) error
+	RecordMeal(m models.Meal) error
+	RecordWater(l models.WaterLog) error
+	// RecountDays writes the user's daily metrics, such as step totals and
+	// days a goal was met, to the ledger and recounts their challenges, so
+	// edited and backfilled days are counted exactly once.
+	RecountDays(userID string) error
+	// Advance starts the scheduled challenges due by now and finishes the
+	// active ones that have ended, telling their participants.
+	Advance(now time.Time) error
 }
 
-type challengeService struct{}
+type challengeService struct {
+	challenges   repository.ChallengeRepository
+	steps        repository.StepRepository
+	nutrition    repository.NutritionRepository
+	goals        repository.GoalRepository
+	users        repository.UserRepository
+	friends      repository.FriendRepository
+	achievements repository.AchievementRepository
+}
+
+// Challenge is the exported singleton service, set up by Use.
+var Challenge ChallengeService
 
-var Challenge ChallengeService = &challengeService{}
+// NewChallengeService builds the ChallengeService on top of the given repositories.
+func NewChallengeService(
+	challenges repository.ChallengeRepository,
+	steps repository.StepRepository,
+	nutrition repository.NutritionRepository,
+	goals repository.GoalRepository,
+	users repository.UserRepository,
+	friends repository.FriendRepository,
+	achievements repository.AchievementRepository,
+) ChallengeService {
+	return &challengeService{
+		challenges: challenges, steps: steps, nutrition: nutrition, goals: goals,
+		users: users, friends: friends, achievements: achievements,
+	}
+}
 
-func (s *challengeService) Create(
-	creatorID, title, ctype string, target int, friends []string,
-) (models.Challenge, error) {
+func (s *challengeService) Create(creatorID string, in ChallengeInput) (models.Challenge, error) {
+	if _, ok := LookupChallengeMetric(in.Type); !ok {
+		return models.Challenge{}, fmt.Errorf("%w: %q", ErrUnknownMetric, in.Type)
+	}
+	now := time.Now()
+	start := now
+	if in.StartsAt != nil && in.StartsAt.After(now) {
+		start = *in.StartsAt
+	}
+	end := start.AddDate(0, 0, DefaultChallengeDays)
+	if in.EndsAt != nil {
+		end = *in.EndsAt
+	}
+	switch {
+	case in.Target <= 0:
+		return models.Challenge{}, fmt.Errorf("%w: target must be positive", ErrInvalidChallenge)
+	case end.Sub(start) < MinChallengeDuration:
+		return models.Challenge{}, fmt.Errorf("%w: ends_at must be at least an hour after the start", ErrInvalidChallengeWindow)
+	case end.After(start.AddDate(0, 0, MaxChallengeDays)):
+		return models.Challenge{}, fmt.Errorf("%w: challenges last at most %d days", ErrInvalidChallengeWindow, MaxChallengeDays)
+	}
 
+	status := models.ChallengeActive
+	if start.After(now) {
+		status = models.ChallengeScheduled
+	}
 	ch := models.Challenge{
 		ID: uuid.NewString(), CreatorID: creatorID,
-		Title: title, Type: ctype, Target: target,
-		CreatedAt: time.Now(),
+		Title: in.Title, Type: in.Type, Target: in.Target,
+		StartsAt: start, EndsAt: end, Status: status,
+		Visibility: in.Visibility, CreatedAt: now,
 	}
-
-	tx, _ := db.DB.Beginx()
-	if _, err := tx.NamedExec(`
-        INSERT INTO challenges (id, creator_id, type, target, title, created_at)
-        VALUES (:id,:creator_id,:type,:target,:title,:created_at)`, &ch); err != nil {
-		tx.Rollback()
-		return ch, err
+	if ch.Visibility == "" {
+		ch.Visibility = models.ChallengePrivate
 	}
 
-	seen := map[string]bool{creatorID: true}
-	ids := []string{creatorID}
+	teams, err := challengeTeams(in)
+	if err != nil {
+		return models.Challenge{}, err
+	}
+	if len(teams) > 0 {
+		ch.TeamScoring = in.TeamScoring
+		if ch.TeamScoring == "" {
+			ch.TeamScoring = models.TeamScoringSum
+		}
+	}
 
-	for _, ident := range friends {
-		var uid = ident
-		if !isUUID(ident) {
-			var err error
-			uid, err = User.IDByEmail(ident)
-			if err != nil || uid == "" {
-				log.Printf("[Challenge] invite skipped, user not found: %v", ident)
+	members := []models.ChallengeParticipant{{UserID: creatorID}}
+	index := map[string]int{creatorID: 0}
+	enroll := func(ident string) int {
+		uid, ok := s.resolveUser(ident)
+		if !ok {
+			return -1
+		}
+		if i, ok := index[uid]; ok {
+			return i
+		}
+		index[uid] = len(members)
+		members = append(members, models.ChallengeParticipant{UserID: uid})
+		return index[uid]
+	}
+	for t, team := range in.Teams {
+		for _, ident := range team.Members {
+			i := enroll(ident)
+			if i < 0 {
 				continue
 			}
+			if id := members[i].TeamID; id != "" && id != teams[t].ID {
+				return models.Challenge{}, fmt.Errorf("%w: %s is on two teams", ErrInvalidTeams, ident)
+			}
+			members[i].TeamID = teams[t].ID
 		}
-		if !seen[uid] {
-			ids = append(ids, uid)
-			seen[uid] = true
-		}
+	}
+	for _, ident := range in.Participants {
+		enroll(ident)
 	}
 
-	for _, uid := range ids {
-		if _, err := tx.Exec(`
-            INSERT INTO challenge_participants (challenge_id,user_id,progress,joined_at)
-            VALUES ($1,$2,0,$3)`, ch.ID, uid, time.Now()); err != nil {
-			tx.Rollback()
-			return ch, err
+	// the creator takes part right away, on their team or the smallest;
+	// everyone else is invited
+	creator, invitees := members[0], members[1:]
+	if len(teams) > 0 && creator.TeamID == "" {
+		creator.TeamID = smallestTeam(teams, invitees)
+	}
+	if err := s.challenges.Create(&ch, teams, []models.ChallengeParticipant{creator}); err != nil {
+		return ch, err
+	}
+	if err := s.recount(creatorID, ch.Type); err != nil {
+		log.Printf("[Challenge] recount %s: %v", creatorID, err)
+	}
+	invitations := make([]models.ChallengeInvitation, len(invitees))
+	for i, p := range invitees {
+		invitations[i] = models.ChallengeInvitation{
+			ChallengeID: ch.ID, InviterID: creatorID, InviteeID: p.UserID, TeamID: p.TeamID,
 		}
 	}
+	if _, err := s.invite(ch, invitations); err != nil {
+		return ch, err
+	}
+	return ch, nil
+}
 
-	return ch, tx.Commit()
+// resolveUser returns the ID of the user named by ID or email.
+func (s *challengeService) resolveUser(ident string) (string, bool) {
+	var u models.User
+	var err error
+	if isUUID(ident) {
+		u, err = s.users.ByID(ident)
+	} else {
+		u, err = s.users.ByEmail(ident)
+	}
+	if err != nil || u.ID == "" {
+		log.Printf("[Challenge] invite skipped, user not found: %v", ident)
+		return "", false
+	}
+	return u.ID, true
 }
 
 func (s *challengeService) Join(chID, userID string) error {
-	_, err := db.DB.Exec(`INSERT INTO challenge_participants (challenge_id,user_id) VALUES ($1,$2) ON CONFLICT DO NOTHING`, chID, userID)
-	return err
+	ch, err := s.challenges.ByID(chID)
+	if err != nil {
+		return err
+	}
+	if ch.Status == models.ChallengeFinished {
+		return ErrChallengeFinished
+	}
+	if ok, err := s.participating(chID, userID); err != nil || ok {
+		return err
+	}
+	// joining with an open invitation accepts it
+	if inv, err := s.challenges.InvitationTo(chID, userID); err == nil && inv.Status == models.InvitationPending {
+		return s.RespondInvitation(userID, inv.ID, true)
+	}
+
+	switch ch.Visibility {
+	case models.ChallengePublic:
+	case models.ChallengeFriends:
+		friends, err := s.friends.FriendIDs(ch.CreatorID)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(friends, userID) {
+			return ErrChallengeForbidden
+		}
+	default:
+		return ErrChallengeForbidden
+	}
+	if err := s.challenges.AddParticipant(chID, userID); err != nil {
+		return err
+	}
+	return s.recount(userID, ch.Type)
+}
+
+func (s *challengeService) participating(chID, userID string) (bool, error) {
+	board, err := s.challenges.Participants(chID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(participantIDs(board), userID), nil
+}
+
+func (s *challengeService) Invite(chID, inviterID string, invitees []string) ([]models.ChallengeInvitation, error) {
+	ch, err := s.challenges.ByID(chID)
+	if err != nil {
+		return nil, err
+	}
+	if ch.Status == models.ChallengeFinished {
+		return nil, ErrChallengeFinished
+	}
+	if ch.Visibility == models.ChallengePrivate && inviterID != ch.CreatorID {
+		return nil, ErrChallengeForbidden
+	}
+	if ok, err := s.participating(chID, inviterID); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrChallengeForbidden
+	}
+
+	friends, err := s.friends.FriendIDs(inviterID)
+	if err != nil {
+		return nil, err
+	}
+	var invitations []models.ChallengeInvitation
+	for _, ident := range invitees {
+		if uid, ok := s.resolveUser(ident); ok && slices.Contains(friends, uid) {
+			invitations = append(invitations, models.ChallengeInvitation{ChallengeID: chID, InviterID: inviterID, InviteeID: uid})
+		}
+	}
+	return s.invite(ch, invitations)
+}
+
+// invite stores the invitations and tells each invitee right away.
+func (s *challengeService) invite(ch models.Challenge, invitations []models.ChallengeInvitation) ([]models.ChallengeInvitation, error) {
+	if len(invitations) == 0 {
+		return []models.ChallengeInvitation{}, nil
+	}
+	stored, err := s.challenges.Invite(invitations)
+	if err != nil {
+		return nil, err
+	}
+	inviter, _ := s.users.ByID(invitations[0].InviterID)
+	for _, inv := range stored {
+		ActivityHub.Broadcast(ActivityMessage{
+			RecipientIDs: []string{inv.InviteeID},
+			Data: gin.H{
+				"kind":         "challenge",
+				"type":         "invited",
+				"invitationId": inv.ID,
+				"challengeId":  ch.ID,
+				"title":        ch.Title,
+				"from":         inviter.Name,
+			},
+		})
+	}
+	if stored == nil {
+		stored = []models.ChallengeInvitation{}
+	}
+	return stored, nil
+}
+
+func (s *challengeService) Invitations(userID, status string) ([]models.ChallengeInvitation, error) {
+	return s.challenges.Invitations(userID, status)
+}
+
+func (s *challengeService) RespondInvitation(userID, invitationID string, accept bool) error {
+	inv, err := s.challenges.Invitation(invitationID)
+	if err != nil {
+		return err
+	}
+	if inv.InviteeID != userID {
+		return ErrNotFound
+	}
+	ch, err := s.challenges.ByID(inv.ChallengeID)
+	if err != nil {
+		return err
+	}
+	status := models.InvitationDeclined
+	if accept {
+		if ch.Status == models.ChallengeFinished {
+			return ErrChallengeFinished
+		}
+		status = models.InvitationAccepted
+	}
+	switch err := s.challenges.RespondInvitation(inv.ID, status); {
+	case errors.Is(err, repository.ErrConflict):
+		return ErrInvitationAnswered
+	case err != nil:
+		return err
+	}
+
+	if inv.InviterID != "" {
+		invitee, _ := s.users.ByID(userID)
+		ActivityHub.Broadcast(ActivityMessage{
+			RecipientIDs: []string{inv.InviterID},
+			Data: gin.H{
+				"kind":         "challenge",
+				"type":         "invitation_" + status,
+				"invitationId": inv.ID,
+				"challengeId":  ch.ID,
+				"title":        ch.Title,
+				"from":         invitee.Name,
+			},
+		})
+	}
+	if !accept {
+		return nil
+	}
+	return s.recount(userID, ch.Type)
+}
+
+// standings loads the challenge and its participants, best first, if the
+// viewer may see them.
+func (s *challengeService) standings(chID, viewerID string) (models.Challenge, []models.ChallengeParticipant, error) {
+	ch, err := s.challenges.ByID(chID)
+	if err != nil {
+		return models.Challenge{}, nil, err
+	}
+	board, err := s.challenges.Participants(chID)
+	if err != nil {
+		return models.Challenge{}, nil, err
+	}
+	if ch.Visibility != models.ChallengePublic && !slices.Contains(participantIDs(board), viewerID) {
+		return models.Challenge{}, nil, ErrChallengeForbidden
+	}
+	return ch, board, nil
 }
 
-func (s *challengeService) UpdateProgress(userID, activityType string, delta int) {
-	query := `UPDATE challenge_participants cp
-            SET progress = progress + $1
-            FROM challenges c
-            WHERE cp.challenge_id = c.id AND cp.user_id = $2 AND c.type = $3`
-	_, _ = db.DB.Exec(query, delta, userID, activityType)
+func (s *challengeService) Leaderboard(chID, viewerID string, q LeaderboardQuery) (LeaderboardPage, error) {
+	ch, board, err := s.standings(chID, viewerID)
+	if err != nil {
+		return LeaderboardPage{}, err
+	}
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultLeaderboardLimit
+	}
+	limit = min(limit, MaxLeaderboardLimit)
+
+	entries := make([]LeaderboardEntry, len(board))
+	page := LeaderboardPage{Total: len(board)}
+	for i, p := range board {
+		e := LeaderboardEntry{
+			Rank: 1, UserID: p.UserID, Name: p.Name, AvatarURL: p.AvatarURL, Progress: p.Progress,
+			TeamID: p.TeamID, FinalRank: p.FinalRank, You: p.UserID == viewerID,
+		}
+		if i > 0 {
+			e.Rank = entries[i-1].Rank
+			if p.Progress != board[i-1].Progress {
+				e.Rank++
+			}
+		}
+		if ch.Target > 0 {
+			e.Percent = math.Round(float64(p.Progress)*1000/float64(ch.Target)) / 10
+		}
+		entries[i] = e
+		if e.You {
+			page.You = &entries[i]
+			page.Offset = max(0, min(i-limit/2, len(board)-limit))
+		}
+	}
+	if q.Offset != nil {
+		page.Offset = *q.Offset
+	}
+	page.Entries = entries[min(page.Offset, len(entries)):min(page.Offset+limit, len(entries))]
+	return page, nil
 }
 
-func (s *challengeService) Leaderboard(chID string) ([]models.ChallengeParticipant, error) {
-	var list []models.ChallengeParticipant
-	err := db.DB.Select(&list, `SELECT * FROM challenge_participants WHERE challenge_id=$1 ORDER BY progress DESC`, chID)
-	return list, err
+func (s *challengeService) TeamLeaderboard(chID, viewerID string) ([]TeamStanding, error) {
+	ch, board, err := s.standings(chID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.teamStandings(ch, board)
+}
+
+// teamStandings ranks the teams of ch from its participants, best first.
+func (s *challengeService) teamStandings(ch models.Challenge, board []models.ChallengeParticipant) ([]TeamStanding, error) {
+	if ch.TeamScoring == "" {
+		return nil, ErrNotTeamChallenge
+	}
+	teams, err := s.challenges.Teams(ch.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]TeamStanding, len(teams))
+	at := make(map[string]int, len(teams))
+	for i, t := range teams {
+		list[i] = TeamStanding{TeamID: t.ID, Name: t.Name, Members: []TeamMember{}}
+		at[t.ID] = i
+	}
+	for _, p := range board { // best first
+		if i, ok := at[p.TeamID]; ok {
+			list[i].Members = append(list[i].Members, TeamMember{UserID: p.UserID, Name: p.Name, AvatarURL: p.AvatarURL, Progress: p.Progress})
+			list[i].Total += p.Progress
+		}
+	}
+	for i := range list {
+		t := &list[i]
+		t.Score = float64(t.Total)
+		if ch.TeamScoring == models.TeamScoringAverage && len(t.Members) > 0 {
+			t.Score = math.Round(float64(t.Total)/float64(len(t.Members))*10) / 10
+		}
+		for j := range t.Members {
+			if t.Total > 0 {
+				t.Members[j].Share = math.Round(float64(t.Members[j].Progress)*1000/float64(t.Total)) / 10
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Score > list[j].Score })
+	for i := range list {
+		list[i].Rank = i + 1
+		if i > 0 && list[i].Score == list[i-1].Score {
+			list[i].Rank = list[i-1].Rank
+		}
+	}
+	return list, nil
+}
+
+func (s *challengeService) Discover(userID string, q DiscoverQuery) (DiscoverPage, error) {
+	if _, ok := LookupChallengeMetric(q.Type); q.Type != "" && !ok {
+		return DiscoverPage{}, fmt.Errorf("%w: unknown type %q", ErrInvalidDiscovery, q.Type)
+	}
+	switch {
+	case q.MaxDays > 0 && q.MinDays > q.MaxDays:
+		return DiscoverPage{}, fmt.Errorf("%w: min_days is above max_days", ErrInvalidDiscovery)
+	case q.MaxSize > 0 && q.MinSize > q.MaxSize:
+		return DiscoverPage{}, fmt.Errorf("%w: min_size is above max_size", ErrInvalidDiscovery)
+	}
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultDiscoverLimit
+	}
+	limit = min(limit, MaxDiscoverLimit)
+
+	f := repository.ChallengeFilter{
+		Type: q.Type, Status: q.Status,
+		MinDays: q.MinDays, MaxDays: q.MaxDays, MinSize: q.MinSize, MaxSize: q.MaxSize,
+		ByStart:  q.Sort == "starts_at",
+		Limit:    limit + 1, // one more tells whether there is a next page
+		ViewerID: userID,
+	}
+	if q.Cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+		var after repository.ChallengeCursor
+		if err != nil || json.Unmarshal(raw, &after) != nil || !isUUID(after.ID) {
+			return DiscoverPage{}, fmt.Errorf("%w: bad cursor", ErrInvalidDiscovery)
+		}
+		f.After = &after
+	}
+
+	list, err := s.challenges.Discover(f)
+	if err != nil {
+		return DiscoverPage{}, err
+	}
+	page := DiscoverPage{Challenges: list}
+	if len(list) > limit {
+		page.Challenges = list[:limit]
+		last := list[limit-1]
+		raw, _ := json.Marshal(repository.ChallengeCursor{ID: last.ID, Participants: last.Participants, StartsAt: last.StartsAt})
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	if page.Challenges == nil {
+		page.Challenges = []models.ChallengeSummary{}
+	}
+	return page, nil
 }
 
 func (s *challengeService) ListForUser(userID string) ([]models.Challenge, error) {
-	var list []models.Challenge
-	err := db.DB.Select(&list, `
-        SELECT DISTINCT c.*
-        FROM challenges c
-        LEFT JOIN challenge_participants p
-               ON p.challenge_id = c.id
-        WHERE c.creator_id = $1 OR p.user_id = $1
-        ORDER BY c.created_at DESC
-    `, userID)
-	return list, err
-}
-
-func isUUID(s string) bool { return len(s) == 36 && s[8] == '-' && s[13] == '-' }
-
-func (s *challengeService) BumpProgress(userID, ctype string, delta int) error {
-	res, err := db.DB.Exec(`
-        UPDATE challenge_participants cp
-        SET    progress = LEAST(cp.progress + $3, c.target)
-        FROM   challenges c
-        WHERE  cp.challenge_id = c.id
-          AND  cp.user_id      = $1
-          AND  c.type          = $2
-    `, userID, ctype, delta)
+	return s.challenges.ListForUser(userID)
+}
+
+// challengeTeams builds the teams asked for, named by the creator or
+// "Team 1" to "Team N" for team_count.
+func challengeTeams(in ChallengeInput) ([]models.ChallengeTeam, error) {
+	switch {
+	case len(in.Teams) > 0 && in.TeamCount > 0:
+		return nil, fmt.Errorf("%w: send either teams or team_count", ErrInvalidTeams)
+	case len(in.Teams) == 0 && in.TeamCount == 0:
+		if in.TeamScoring != "" {
+			return nil, fmt.Errorf("%w: team_scoring needs teams", ErrInvalidTeams)
+		}
+		return nil, nil
+	}
+
+	n := max(len(in.Teams), in.TeamCount)
+	if n < 2 || n > MaxChallengeTeams {
+		return nil, fmt.Errorf("%w: a challenge has between 2 and %d teams", ErrInvalidTeams, MaxChallengeTeams)
+	}
+	teams := make([]models.ChallengeTeam, n)
+	seen := map[string]bool{}
+	for i := range teams {
+		name := fmt.Sprintf("Team %d", i+1)
+		if len(in.Teams) > 0 {
+			name = strings.TrimSpace(in.Teams[i].Name)
+		}
+		key := strings.ToLower(name)
+		switch {
+		case name == "" || len(name) > maxTeamNameLen:
+			return nil, fmt.Errorf("%w: team names have 1 to %d characters", ErrInvalidTeams, maxTeamNameLen)
+		case seen[key]:
+			return nil, fmt.Errorf("%w: two teams are named %q", ErrInvalidTeams, name)
+		}
+		seen[key] = true
+		teams[i] = models.ChallengeTeam{ID: uuid.NewString(), Name: name, Position: i}
+	}
+	return teams, nil
+}
+
+// smallestTeam returns the ID of the team with the fewest members,
+// the earliest of those on a tie.
+func smallestTeam(teams []models.ChallengeTeam, members []models.ChallengeParticipant) string {
+	size := map[string]int{}
+	for _, p := range members {
+		size[p.TeamID]++
+	}
+	best := teams[0].ID
+	for _, t := range teams[1:] {
+		if size[t.ID] < size[best] {
+			best = t.ID
+		}
+	}
+	return best
+}
+
+// isUUID reports whether s is a UUID in the dashed form postgres parses.
+func isUUID(s string) bool {
+	_, err := uuid.Parse(s)
+	return err == nil && len(s) == 36
+}
+
+func (s *challengeService) RecordActivity(a models.Activity) error {
+	return s.record(a.UserID, ProgressSourceActivity, a.ID, a.PerformedAt, func(m ChallengeMetric) (int, bool) {
+		if m.activity == nil {
+			return 0, false
+		}
+		return m.activity(a), true
+	})
+}
+
+func (s *challengeService) RecordMeal(meal models.Meal) error {
+	return s.record(meal.UserID, ProgressSourceMeal, meal.ID, meal.CreatedAt, func(m ChallengeMetric) (int, bool) {
+		if m.meal == nil {
+			return 0, false
+		}
+		return m.meal(meal), true
+	})
+}
+
+func (s *challengeService) RecordWater(l models.WaterLog) error {
+	err := s.record(l.UserID, ProgressSourceWater, l.ID, l.CreatedAt, func(m ChallengeMetric) (int, bool) {
+		if m.water == nil {
+			return 0, false
+		}
+		return m.water(l), true
+	})
 	if err != nil {
 		return err
 	}
+	return s.RecountDays(l.UserID)
+}
 
-	rows, _ := res.RowsAffected()
-	if rows == 0 {
+// record puts one event per metric counting the entry on the ledger, each
+// keyed by the source, the entry's ID and the metric, and recounts those
+// metrics.
+func (s *challengeService) record(userID, source, id string, at time.Time, amount func(ChallengeMetric) (int, bool)) error {
+	if id == "" {
+		id = uuid.NewString()
+	}
+	key := source + ":" + id
+	if at.IsZero() {
+		at = time.Now()
+	}
+	var events []models.ProgressEvent
+	for _, m := range challengeMetrics {
+		if n, ok := amount(m); ok {
+			events = append(events, models.ProgressEvent{
+				UserID: userID, Metric: m.Name, Source: source, Amount: max(n, 0),
+				IdempotencyKey: key + ":" + m.Name, OccurredAt: at,
+			})
+		}
+	}
+	if len(events) == 0 {
 		return nil
 	}
+	if err := s.challenges.RecordProgress(events); err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := s.recount(userID, e.Metric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecountDays keeps the ledger events of the daily metrics, keyed by the
+// metric and the day in the user's time zone. A day's amount occurs at
+// its last instant, so the day counts for the window it ends in. Metrics
+// that know their hours keep an event per hour, keyed by the day and the
+// hour, and spread the rest over the day, so only the part of the day
+// inside a window counts for it. Only days that can still be edited are
+// compared, and unchanged events are skipped.
+func (s *challengeService) RecountDays(userID string) error {
+	loc := userZone(s.users, userID)
+	from := startOfDay(time.Now(), loc).AddDate(0, 0, -StepBackfillDays+1)
+	days, err := s.dayLogs(userID, from, loc)
+	if err != nil {
+		return err
+	}
 
+	for _, m := range challengeMetrics {
+		if m.daily == nil {
+			continue
+		}
+		recorded, err := s.challenges.ProgressEvents(userID, m.Name, from)
+		if err != nil {
+			return err
+		}
+		amounts := make(map[string]int, len(recorded))
+		for _, e := range recorded {
+			amounts[e.IdempotencyKey] = e.Amount
+		}
+
+		var events []models.ProgressEvent
+		seen := map[string]bool{}
+		put := func(key string, amount int, at time.Time, last *time.Time) {
+			seen[key] = true
+			if old, ok := amounts[key]; old == amount && (ok || amount == 0) {
+				return
+			}
+			events = append(events, models.ProgressEvent{
+				UserID: userID, Metric: m.Name, Source: m.source, Amount: amount,
+				IdempotencyKey: key, OccurredAt: at, LastsUntil: last,
+			})
+		}
+		for d, logs := range days {
+			start, err := time.ParseInLocation(dayLayout, d, loc)
+			if err != nil {
+				continue
+			}
+			last := start.AddDate(0, 0, 1).Add(-time.Microsecond)
+			amount := m.daily(logs)
+			if m.hours == nil {
+				put(m.Name+":"+d, amount, last, nil)
+				continue
+			}
+			for h, n := range m.hours(logs) {
+				at := time.Date(start.Year(), start.Month(), start.Day(), h, 0, 0, 0, loc)
+				end := at.Add(time.Hour - time.Microsecond)
+				put(fmt.Sprintf("%s:%sT%02d", m.Name, d, h), n, at, &end)
+				amount -= n
+			}
+			put(m.Name+":"+d, max(amount, 0), start, &last)
+		}
+		// deleted days and hours stay on the ledger with nothing to count
+		for _, e := range recorded {
+			if !seen[e.IdempotencyKey] && e.Amount != 0 {
+				e.Amount = 0
+				events = append(events, e)
+			}
+		}
+
+		if len(events) > 0 {
+			if err := s.challenges.RecordProgress(events); err != nil {
+				return err
+			}
+		}
+		if err := s.recount(userID, m.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dayLogs returns what the user logged on each day from from on, by day.
+// Days are judged by today's goals, as goals keep no history.
+func (s *challengeService) dayLogs(userID string, from time.Time, loc *time.Location) (map[string]dayLogs, error) {
+	stepGoal, err := goalOrDefault(s.goals, userID, models.GoalSteps, DefaultStepGoal)
+	if err != nil {
+		return nil, err
+	}
+	waterGoal, err := goalOrDefault(s.goals, userID, models.GoalWater, DefaultWaterGoalML)
+	if err != nil {
+		return nil, err
+	}
+
+	days := map[string]dayLogs{}
+	totals, err := s.steps.ListSince(userID, dayKey(from, loc))
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range totals {
+		d := days[t.Day]
+		d.Steps = t.Steps
+		days[t.Day] = d
+	}
+	buckets, err := s.steps.Buckets(userID, dayKey(from, loc))
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range buckets {
+		d, ok := days[b.Day]
+		if !ok || b.Hour == nil {
+			continue
+		}
+		if d.StepHours == nil {
+			d.StepHours = make([]int, 24)
+		}
+		// sources are merged per hour like the day totals
+		d.StepHours[*b.Hour] = max(d.StepHours[*b.Hour], b.Steps)
+		days[b.Day] = d
+	}
+	water, err := s.nutrition.WaterBetween(userID, from, startOfDay(time.Now(), loc).AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range water {
+		day := dayKey(l.CreatedAt, loc)
+		d := days[day]
+		d.WaterML += l.AmountML
+		days[day] = d
+	}
+	for day, d := range days {
+		d.StepGoal, d.WaterGoal = stepGoal, waterGoal
+		days[day] = d
+	}
+	return days, nil
+}
+
+func (s *challengeService) recount(userID, metric string) error {
+	rows, err := s.challenges.RecountProgress(userID, metric)
+	if err != nil || rows == 0 {
+		return err
+	}
+	return s.awardCompleted(userID)
+}
+
+func (s *challengeService) awardCompleted(userID string) error {
 	// ── award achievement for every challenge the user just completed ──
 	// (progress == target)
-	var completedIDs []string
-	if err := db.DB.Select(&completedIDs, `
-        SELECT cp.challenge_id
-        FROM   challenge_participants cp
-        JOIN   challenges c ON c.id = cp.challenge_id
-        WHERE  cp.user_id = $1 AND cp.progress >= c.target
-          AND  NOT EXISTS (
-              SELECT 1 FROM user_achievements ua
-              WHERE ua.user_id = $1
-                AND ua.challenge_id = cp.challenge_id
-          )
-    `, userID); err != nil {
+	completedIDs, err := s.challenges.Completed(userID)
+	if err != nil {
 		return err
 	}
-
 	if len(completedIDs) == 0 {
 		return nil
 	}
+	return awardChallengeCompletion(s.achievements, userID, completedIDs)
+}
 
-	achID, err := Achieve.challengeAchievementID()
+func (s *challengeService) Advance(now time.Time) error {
+	started, err := s.challenges.Activate(now)
 	if err != nil {
 		return err
 	}
+	for _, ch := range started {
+		board, err := s.challenges.Participants(ch.ID)
+		if err != nil {
+			return err
+		}
+		ActivityHub.Broadcast(ActivityMessage{
+			RecipientIDs: participantIDs(board),
+			Data: gin.H{
+				"kind":        "challenge",
+				"type":        "started",
+				"title":       ch.Title,
+				"challengeId": ch.ID,
+				"endsAt":      ch.EndsAt,
+			},
+		})
+	}
 
-	// insert one row per completed challenge
-	for _, chID := range completedIDs {
-		_, _ = db.DB.Exec(`
-  INSERT INTO user_achievements (user_id, achievement_id, challenge_id)
-  VALUES ($1, $2, $3) ON CONFLICT DO NOTHING
-`, userID, achID, chID)
-
+	finished, err := s.challenges.Finish(now)
+	if err != nil {
+		return err
+	}
+	for _, ch := range finished {
+		board, err := s.challenges.Participants(ch.ID)
+		if err != nil {
+			return err
+		}
+		s.awardPlacements(ch.ID, board)
+		note := gin.H{
+			"kind":        "challenge",
+			"type":        "finished",
+			"title":       ch.Title,
+			"challengeId": ch.ID,
+			"standings":   board,
+		}
+		if ch.TeamScoring != "" {
+			if teams, err := s.teamStandings(ch, board); err == nil {
+				note["teams"] = teams
+			}
+		}
+		ActivityHub.Broadcast(ActivityMessage{RecipientIDs: participantIDs(board), Data: note})
 	}
 	return nil
 }
+
+// awardPlacements unlocks the placement achievements of the podium. Ties
+// share a placement; nobody places without progress.
+func (s *challengeService) awardPlacements(chID string, board []models.ChallengeParticipant) {
+	for _, p := range board {
+		if p.FinalRank == nil || p.Progress == 0 {
+			continue
+		}
+		title, ok := placementAchievements[*p.FinalRank]
+		if !ok {
+			continue
+		}
+		ach, err := s.achievements.ByTitle(title)
+		if err != nil {
+			continue
+		}
+		_ = s.achievements.Award(p.UserID, ach.ID, chID)
+	}
+}
+
+func participantIDs(board []models.ChallengeParticipant) []string {
+	ids := make([]string, len(board))
+	for i, p := range board {
+		ids[i] = p.UserID
+	}
+	return ids
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository/memory"
+)
+
+func TestRecountDays(t *testing.T) {
+	store := memory.NewStore()
+	id := newTestUser(t, store)
+	steps := NewStepService(store.Steps, store.Goals, store.Achievements, store.Users)
+	svc := NewChallengeService(store.Challenges, store.Steps, store.Nutrition, store.Goals, store.Users, store.Friends, store.Achievements)
+
+	today := startOfDay(time.Now(), time.UTC)
+	yesterday, todayKey := dayKey(today.AddDate(0, 0, -1), time.UTC), dayKey(today, time.UTC)
+	from := today.AddDate(0, 0, -StepBackfillDays)
+	ledger := func(metric string) map[string]models.ProgressEvent {
+		events, err := store.Challenges.ProgressEvents(id, metric, from)
+		require.NoError(t, err)
+		byKey := map[string]models.ProgressEvent{}
+		for _, e := range events {
+			byKey[e.IdempotencyKey] = e
+		}
+		return byKey
+	}
+	amounts := func(metric string) map[string]int {
+		byKey := map[string]int{}
+		for key, e := range ledger(metric) {
+			byKey[key] = e.Amount
+		}
+		return byKey
+	}
+
+	require.NoError(t, steps.SaveStepDays(id, "band", []StepDay{{Day: yesterday, Steps: 12000}}))
+	require.NoError(t, steps.SaveStepHours(id, "watch", []StepHour{{Start: today, Steps: 300}}))
+	require.NoError(t, svc.RecountDays(id))
+
+	// a day's total counts once, and a day known by the hour counts hour
+	// by hour, with nothing left over for the rest of the day
+	assert.Equal(t, map[string]int{
+		"steps:" + yesterday:        12000,
+		"steps:" + todayKey + "T00": 300,
+	}, amounts("steps"))
+	assert.Equal(t, map[string]int{"step_goal_days:" + yesterday: 1}, amounts("step_goal_days"))
+	// steps without hours are spread over the whole day
+	e := ledger("steps")["steps:"+yesterday]
+	assert.Equal(t, today.AddDate(0, 0, -1), e.OccurredAt.UTC())
+	require.NotNil(t, e.LastsUntil)
+	assert.Equal(t, today.Add(-time.Microsecond), e.LastsUntil.UTC())
+	// goal days occur at their last instant
+	e = ledger("step_goal_days")["step_goal_days:"+yesterday]
+	assert.Equal(t, today.Add(-time.Microsecond), e.OccurredAt.UTC())
+
+	// recounting without changes writes nothing
+	before := ledger("steps")
+	require.NoError(t, svc.RecountDays(id))
+	for key, e := range ledger("steps") {
+		assert.Equal(t, before[key].RecordedAt, e.RecordedAt, key)
+	}
+
+	// edited days replace their events, deleted ones keep them at zero
+	require.NoError(t, steps.ReplaceStepDay(id, StepDay{Day: yesterday, Steps: 4000}))
+	require.NoError(t, steps.DeleteStepDay(id, todayKey))
+	require.NoError(t, svc.RecountDays(id))
+	assert.Equal(t, map[string]int{
+		"steps:" + yesterday:        4000,
+		"steps:" + todayKey + "T00": 0,
+	}, amounts("steps"))
+	assert.Equal(t, map[string]int{"step_goal_days:" + yesterday: 0}, amounts("step_goal_days"))
+}
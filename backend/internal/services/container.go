@@ -0,0 +1,83 @@
+package services
+
+import (
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/mailer"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/oidc"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/password"
+)
+
+// Container holds one instance of every service, all built on the same
+// repository.Store.
+type Container struct {
+	User      UserService
+	Step      StepService
+	Nutrition NutritionService
+	Challenge ChallengeService
+	Message   MessageService
+	Post      PostService
+	Schedule  ScheduleService
+	Admin     AdminService
+	Audit     AuditService
+
+	PersonalData PersonalDataService
+	AccessTokens AccessTokenService
+
+	// RateLimits backs the rate-limit middleware; nil disables it.
+	RateLimits repository.RateLimitRepository
+}
+
+// NewContainer wires every service to the repositories in store.
+func NewContainer(cfg *config.Config, store *repository.Store, mail mailer.Mailer, policy *password.Policy) *Container {
+	providers := map[string]*oidc.Provider{}
+	for _, p := range cfg.OIDCProviders {
+		providers[p.Name] = oidc.New(oidc.Config{
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+		})
+	}
+	user := NewUserService(store, mail, UserOptions{
+		RefreshTokenTTL: cfg.RefreshTokenTTL,
+		AppURL:          cfg.AppURL,
+		PasswordPolicy:  policy,
+		TOTPIssuer:      cfg.TOTPIssuer,
+		AdminEmails:     cfg.AdminEmails,
+		OIDC:            providers,
+	})
+	challenge := NewChallengeService(store.Challenges, store.Steps, store.Nutrition, store.Goals, store.Users, store.Friends, store.Achievements)
+	return &Container{
+		User:      user,
+		Step:      NewStepService(store.Steps, store.Goals, store.Achievements, store.Users),
+		Nutrition: NewNutritionService(store.Nutrition, store.Goals, store.Users),
+		Challenge: challenge,
+		Message:   NewMessageService(store.Messages),
+		Post:      NewPostService(store.Posts),
+		Schedule:  NewScheduleService(store.Schedules, store.Challenges, challenge),
+		Admin:     NewAdminService(store, user),
+		Audit:     NewAuditService(store.Audit),
+
+		PersonalData: NewPersonalDataService(store, cfg.AccountDeletionGrace),
+		AccessTokens: NewAccessTokenService(store),
+
+		RateLimits: store.RateLimits,
+	}
+}
+
+// Use installs c behind the package-level singletons (User, Step, …)
+// that the HTTP handlers call.
+func Use(c *Container) {
+	User = c.User
+	Step = c.Step
+	Nutrition = c.Nutrition
+	Challenge = c.Challenge
+	Message = c.Message
+	Post = c.Post
+	Schedule = c.Schedule
+	Admin = c.Admin
+	Audit = c.Audit
+	PersonalData = c.PersonalData
+	AccessTokens = c.AccessTokens
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+const dayLayout = "2006-01-02"
+
+// ErrInvalidTimezone is returned for names that are not IANA time zones.
+var ErrInvalidTimezone = errors.New("unknown time zone")
+
+// startOfDay returns midnight in loc of the day t falls on there.
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// dayKey formats the date of t in loc as "YYYY-MM-DD".
+func dayKey(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(dayLayout)
+}
+
+var zones sync.Map // name -> *time.Location
+
+// loadZone returns the IANA zone name, or UTC when it is empty or unknown.
+// Zones are cached as the scheduler looks them up every minute.
+func loadZone(name string) *time.Location {
+	if name == "" || name == "UTC" {
+		return time.UTC
+	}
+	if loc, ok := zones.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	zones.Store(name, loc)
+	return loc
+}
+
+// userZone returns the time zone the user's days are counted in.
+func userZone(users repository.UserRepository, userID string) *time.Location {
+	u, err := users.ByID(userID)
+	if err != nil {
+		return time.UTC
+	}
+	return loadZone(u.Timezone)
+}
+
+// validTimezone reports whether name is an IANA zone such as
+// "Europe/Moscow". "Local" is refused as it means the server's zone.
+func validTimezone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
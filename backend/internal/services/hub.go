@@ -4,12 +4,13 @@ package services
 import (
 	"log"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 type Hub struct {
-	clients     map[string]map[*websocket.Conn]bool
+	clients     map[string]map[*websocket.Conn]string // user id -> conn -> session id
 	broadcastCh chan ActivityMessage
 	mu          sync.RWMutex
 }
@@ -23,20 +24,22 @@ var ActivityHub = NewHub()
 
 func NewHub() *Hub {
 	h := &Hub{
-		clients:     make(map[string]map[*websocket.Conn]bool),
+		clients:     make(map[string]map[*websocket.Conn]string),
 		broadcastCh: make(chan ActivityMessage, 100),
 	}
 	go h.run()
 	return h
 }
 
-func (h *Hub) Register(userID string, conn *websocket.Conn) {
+// Register adds a connection opened by userID from the given session
+// (empty for tokens issued before sessions existed).
+func (h *Hub) Register(userID, sessionID string, conn *websocket.Conn) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	if h.clients[userID] == nil {
-		h.clients[userID] = make(map[*websocket.Conn]bool)
+		h.clients[userID] = make(map[*websocket.Conn]string)
 	}
-	h.clients[userID][conn] = true
+	h.clients[userID][conn] = sessionID
 }
 
 func (h *Hub) Unregister(userID string, conn *websocket.Conn) {
@@ -48,6 +51,28 @@ func (h *Hub) Unregister(userID string, conn *websocket.Conn) {
 	}
 }
 
+// DisconnectSession closes every connection of userID opened from
+// sessionID and reports how many were closed. The handlers' read loops
+// then fail and unregister them.
+func (h *Hub) DisconnectSession(userID, sessionID string) int {
+	h.mu.RLock()
+	var conns []*websocket.Conn
+	for conn, sid := range h.clients[userID] {
+		if sid == sessionID {
+			conns = append(conns, conn)
+		}
+	}
+	h.mu.RUnlock()
+
+	deadline := time.Now().Add(time.Second)
+	msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked")
+	for _, conn := range conns {
+		_ = conn.WriteControl(websocket.CloseMessage, msg, deadline)
+		conn.Close()
+	}
+	return len(conns)
+}
+
 func (h *Hub) Broadcast(msg ActivityMessage) {
 	h.broadcastCh <- msg
 }
@@ -1,18 +1,20 @@
 package services
 
 import (
-	//"log"
 	"time"
 
-	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/db"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
 )
 
 type NutritionService interface {
-	AddMeal(userID string, meal Meal) error
+	// AddMeal and AddWaterLog log the entry and return it. A key the user
+	// already logged an entry under returns that one instead.
+	AddMeal(userID string, meal Meal) (Meal, error)
 	ListMeals(userID string) ([]Meal, error)
 	GetNutritionStats(userID string) (NutritionStats, error)
 	GetWeeklyNutritionStats(userID string) ([]NutritionStats, error)
-	AddWaterLog(userID string, amount int) error
+	AddWaterLog(userID string, amount int, idempotencyKey string) (WaterLog, error)
 	GetTodayWaterStats(userID string) (DailyWaterStats, error)
 	GetWeeklyWaterStats(userID string) ([]DailyWaterStats, error)
 	SetWaterGoal(userID string, goalML int) error
@@ -21,19 +23,7 @@ type NutritionService interface {
 	GetCalorieGoal(userID string) (int, error)
 }
 
-type Meal struct {
-	ID          string    `db:"id" json:"id"`
-	UserID      string    `db:"user_id" json:"user_id"`
-	FdcID       int       `db:"fdc_id" json:"fdcId"`
-	Description string    `db:"description" json:"description"`
-	Calories    float64   `db:"calories" json:"calories"`
-	Protein     float64   `db:"protein" json:"protein"`
-	Fat         float64   `db:"fat" json:"fat"`
-	Carbs       float64   `db:"carbs" json:"carbs"`
-	Quantity    float64   `db:"quantity" json:"quantity"`
-	Unit        string    `db:"unit" json:"unit"`
-	CreatedAt   time.Time `db:"created_at" json:"created_at"`
-}
+type Meal = models.Meal
 
 type NutritionStats struct {
 	Date     string  `db:"date" json:"date"`
@@ -44,12 +34,7 @@ type NutritionStats struct {
 	Goal     float64 `json:"goal"`
 }
 
-type WaterLog struct {
-	ID        string    `db:"id" json:"id"`
-	UserID    string    `db:"user_id" json:"user_id"`
-	AmountML  int       `db:"amount_ml" json:"amount_ml"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
-}
+type WaterLog = models.WaterLog
 
 type DailyWaterStats struct {
 	Date    string `db:"date" json:"date"`
@@ -57,67 +42,77 @@ type DailyWaterStats struct {
 	GoalML  int    `db:"goal_ml" json:"goal_ml"`
 }
 
-type nutritionService struct{}
+type nutritionService struct {
+	nutrition repository.NutritionRepository
+	goals     repository.GoalRepository
+	users     repository.UserRepository
+}
 
-var Nutrition NutritionService = &nutritionService{}
+// Nutrition is the exported singleton service, set up by Use.
+var Nutrition NutritionService
+
+// NewNutritionService builds the NutritionService on top of the given repositories.
+func NewNutritionService(
+	nutrition repository.NutritionRepository,
+	goals repository.GoalRepository,
+	users repository.UserRepository,
+) NutritionService {
+	return &nutritionService{nutrition: nutrition, goals: goals, users: users}
+}
 
-func (s *nutritionService) AddMeal(userID string, meal Meal) error {
-	_, err := db.DB.Exec(`
-		INSERT INTO meals (user_id, fdc_id, description, calories, protein, fat, carbs, quantity, unit)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
-	`, userID, meal.FdcID, meal.Description, meal.Calories, meal.Protein, meal.Fat, meal.Carbs, meal.Quantity, meal.Unit)
-	return err
+func (s *nutritionService) AddMeal(userID string, meal Meal) (Meal, error) {
+	meal.UserID = userID
+	err := s.nutrition.AddMeal(&meal)
+	return meal, err
 }
 
 func (s *nutritionService) ListMeals(userID string) ([]Meal, error) {
-	var meals []Meal
-	err := db.DB.Select(&meals, `
-		SELECT * FROM meals WHERE user_id = $1 ORDER BY created_at DESC
-	`, userID)
-	return meals, err
+	return s.nutrition.ListMeals(userID)
 }
 
 func (s *nutritionService) GetNutritionStats(userID string) (NutritionStats, error) {
-	var stats NutritionStats
-	err := db.DB.Get(&stats, `
-		SELECT
-			TO_CHAR(CURRENT_DATE, 'YYYY-MM-DD') as date,
-			COALESCE(SUM(calories),0) as calories,
-			COALESCE(SUM(protein),0) as protein,
-			COALESCE(SUM(fat),0) as fat,
-			COALESCE(SUM(carbs),0) as carbs
-		FROM meals
-		WHERE user_id = $1 AND created_at::date = CURRENT_DATE
-	`, userID)
+	loc := userZone(s.users, userID)
+	from := startOfDay(time.Now(), loc)
+	stats := NutritionStats{Date: dayKey(from, loc)}
+
+	meals, err := s.nutrition.MealsBetween(userID, from, from.AddDate(0, 0, 1))
 	if err != nil {
 		return stats, err
 	}
+	for _, m := range meals {
+		stats.add(m)
+	}
 
-	goal, _ := s.GetCalorieGoal(userID)
+	goal, err := s.GetCalorieGoal(userID)
+	if err != nil {
+		return stats, err
+	}
 	stats.Goal = float64(goal)
 
 	return stats, nil
 }
 
 func (s *nutritionService) GetWeeklyNutritionStats(userID string) ([]NutritionStats, error) {
-	var stats []NutritionStats
-	err := db.DB.Select(&stats, `
-		SELECT
-			TO_CHAR(DATE(created_at), 'YYYY-MM-DD') as date,
-			COALESCE(SUM(calories), 0) as calories,
-			COALESCE(SUM(protein), 0) as protein,
-			COALESCE(SUM(fat), 0) as fat,
-			COALESCE(SUM(carbs), 0) as carbs
-		FROM meals
-		WHERE user_id = $1 AND created_at >= NOW() - INTERVAL '6 days'
-		GROUP BY DATE(created_at)
-		ORDER BY DATE(created_at)
-	`, userID)
+	loc := userZone(s.users, userID)
+	today := startOfDay(time.Now(), loc)
+	meals, err := s.nutrition.MealsBetween(userID, today.AddDate(0, 0, -6), today.AddDate(0, 0, 1))
 	if err != nil {
-		return stats, err
+		return nil, err
+	}
+
+	var stats []NutritionStats
+	for _, m := range meals {
+		date := dayKey(m.CreatedAt, loc)
+		if n := len(stats); n == 0 || stats[n-1].Date != date {
+			stats = append(stats, NutritionStats{Date: date})
+		}
+		stats[len(stats)-1].add(m)
 	}
 
-	goal, _ := s.GetCalorieGoal(userID)
+	goal, err := s.GetCalorieGoal(userID)
+	if err != nil {
+		return nil, err
+	}
 	for i := range stats {
 		stats[i].Goal = float64(goal)
 	}
@@ -125,84 +120,71 @@ func (s *nutritionService) GetWeeklyNutritionStats(userID string) ([]NutritionSt
 	return stats, nil
 }
 
-func (s *nutritionService) AddWaterLog(userID string, amount int) error {
-	_, err := db.DB.Exec(`
-		INSERT INTO water_logs (user_id, amount_ml) VALUES ($1, $2)
-	`, userID, amount)
-	return err
+func (st *NutritionStats) add(m Meal) {
+	st.Calories += m.Calories
+	st.Protein += m.Protein
+	st.Fat += m.Fat
+	st.Carbs += m.Carbs
 }
 
-func (s *nutritionService) GetTodayWaterStats(userID string) (DailyWaterStats, error) {
-	var stats DailyWaterStats
-	goal, _ := s.GetWaterGoal(userID)
-
-	err := db.DB.Get(&stats, `
-		SELECT
-			TO_CHAR(CURRENT_DATE, 'YYYY-MM-DD') AS date,
-			COALESCE(SUM(amount_ml), 0) as total_ml
-		FROM water_logs
-		WHERE user_id = $1 AND created_at::date = CURRENT_DATE
-	`, userID)
+func (s *nutritionService) AddWaterLog(userID string, amount int, idempotencyKey string) (WaterLog, error) {
+	l := WaterLog{UserID: userID, AmountML: amount, IdempotencyKey: idempotencyKey}
+	err := s.nutrition.AddWater(&l)
+	return l, err
+}
 
+func (s *nutritionService) GetTodayWaterStats(userID string) (DailyWaterStats, error) {
+	loc := userZone(s.users, userID)
+	from := startOfDay(time.Now(), loc)
+	stats := DailyWaterStats{Date: dayKey(from, loc)}
+	goal, err := s.GetWaterGoal(userID)
+	if err != nil {
+		return stats, err
+	}
 	stats.GoalML = goal
+
+	logs, err := s.nutrition.WaterBetween(userID, from, from.AddDate(0, 0, 1))
+	for _, l := range logs {
+		stats.TotalML += l.AmountML
+	}
 	return stats, err
 }
 
 func (s *nutritionService) GetWeeklyWaterStats(userID string) ([]DailyWaterStats, error) {
+	loc := userZone(s.users, userID)
+	today := startOfDay(time.Now(), loc)
+	logs, err := s.nutrition.WaterBetween(userID, today.AddDate(0, 0, -6), today.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	goal, err := s.GetWaterGoal(userID)
+	if err != nil {
+		return nil, err
+	}
+
 	var stats []DailyWaterStats
-	err := db.DB.Select(&stats, `
-		SELECT
-			TO_CHAR(created_at::date, 'YYYY-MM-DD') as date,
-			COALESCE(SUM(amount_ml), 0) as total_ml,
-			2000 as goal_ml
-		FROM water_logs
-		WHERE user_id = $1 AND created_at >= NOW() - INTERVAL '6 days'
-		GROUP BY created_at::date
-		ORDER BY created_at::date
-	`, userID)
-	return stats, err
+	for _, l := range logs {
+		date := dayKey(l.CreatedAt, loc)
+		if n := len(stats); n == 0 || stats[n-1].Date != date {
+			stats = append(stats, DailyWaterStats{Date: date, GoalML: goal})
+		}
+		stats[len(stats)-1].TotalML += l.AmountML
+	}
+	return stats, nil
 }
 
 func (s *nutritionService) SetWaterGoal(userID string, goalML int) error {
-	_, err := db.DB.Exec(`
-		INSERT INTO water_goals (user_id, goal_ml)
-		VALUES ($1, $2)
-		ON CONFLICT (user_id)
-		DO UPDATE SET goal_ml = EXCLUDED.goal_ml, updated_at = now()
-	`, userID, goalML)
-	return err
+	return s.goals.Set(userID, models.GoalWater, goalML)
 }
 
 func (s *nutritionService) GetWaterGoal(userID string) (int, error) {
-	var goal int
-	err := db.DB.Get(&goal, `
-		SELECT goal_ml FROM water_goals WHERE user_id = $1
-	`, userID)
-
-	if err != nil {
-		return 2000, nil
-	}
-
-	return goal, nil
+	return goalOrDefault(s.goals, userID, models.GoalWater, DefaultWaterGoalML)
 }
 
 func (s *nutritionService) SetCalorieGoal(userID string, goal int) error {
-	_, err := db.DB.Exec(`
-		INSERT INTO calorie_goals (user_id, goal)
-		VALUES ($1, $2)
-		ON CONFLICT (user_id)
-		DO UPDATE SET goal = EXCLUDED.goal, updated_at = now()
-	`, userID, goal)
-	return err
+	return s.goals.Set(userID, models.GoalCalories, goal)
 }
 
 func (s *nutritionService) GetCalorieGoal(userID string) (int, error) {
-	var goal int
-	err := db.DB.Get(&goal, `
-		SELECT goal FROM calorie_goals WHERE user_id = $1
-	`, userID)
-	if err != nil {
-		return 2000, nil
-	}
-	return goal, nil
+	return goalOrDefault(s.goals, userID, models.GoalCalories, DefaultCalorieGoal)
 }
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository/memory"
+)
+
+// failingGoals is a goal store that cannot be reached.
+type failingGoals struct{ err error }
+
+func (f failingGoals) Get(userID string, kind models.GoalKind) (int, error)     { return 0, f.err }
+func (f failingGoals) Set(userID string, kind models.GoalKind, value int) error { return f.err }
+
+func TestGoals_DefaultOnlyWhenUnset(t *testing.T) {
+	store := memory.NewStore()
+	id := newTestUser(t, store)
+	svc := NewNutritionService(store.Nutrition, store.Goals, store.Users)
+
+	water, err := svc.GetWaterGoal(id)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultWaterGoalML, water)
+	calories, err := svc.GetCalorieGoal(id)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultCalorieGoal, calories)
+
+	require.NoError(t, svc.SetWaterGoal(id, 1500))
+	water, err = svc.GetWaterGoal(id)
+	require.NoError(t, err)
+	assert.Equal(t, 1500, water)
+
+	down := errors.New("db down")
+	svc = NewNutritionService(store.Nutrition, failingGoals{down}, store.Users)
+	_, err = svc.GetWaterGoal(id)
+	assert.ErrorIs(t, err, down)
+	_, err = svc.GetCalorieGoal(id)
+	assert.ErrorIs(t, err, down)
+	_, err = svc.GetTodayWaterStats(id)
+	assert.ErrorIs(t, err, down)
+	_, err = svc.GetNutritionStats(id)
+	assert.ErrorIs(t, err, down)
+
+	steps := NewStepService(store.Steps, failingGoals{down}, store.Achievements, store.Users)
+	_, err = steps.GetStepGoal(id)
+	assert.ErrorIs(t, err, down)
+	goal, err := goalOrDefault(failingGoals{repository.ErrNotFound}, id, models.GoalSteps, DefaultStepGoal)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultStepGoal, goal)
+}
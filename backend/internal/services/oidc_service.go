@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/oidc"
+)
+
+var (
+	// ErrUnknownProvider is returned for providers that are not configured.
+	ErrUnknownProvider = errors.New("unknown sign-in provider")
+	// ErrOIDCFailed is returned when the provider's callback cannot be
+	// trusted: unknown or expired state, a refused code or a bad ID token.
+	ErrOIDCFailed = errors.New("sign-in with the provider failed")
+	// ErrEmailNotVerified is returned when a new provider account has no
+	// verified email to link or register with.
+	ErrEmailNotVerified = errors.New("the provider did not confirm the email address")
+	// ErrProviderLinked is returned when the user already linked a
+	// different account at the same provider.
+	ErrProviderLinked = errors.New("another account at this provider is already linked")
+	// ErrAccountUnverified is returned when the provider's email belongs
+	// to an account that never proved it owns the address. Whoever
+	// registered it may not be the owner, so it is not linked.
+	ErrAccountUnverified = errors.New("an account with this email exists but its address is unverified; sign in with its password and confirm the email first")
+	// ErrLastLoginMethod stops users without a password from unlinking
+	// their only identity.
+	ErrLastLoginMethod = errors.New("set a password before unlinking the last sign-in provider")
+)
+
+// oidcLoginTTL is how long the user has to finish signing in at the
+// provider.
+const oidcLoginTTL = 10 * time.Minute
+
+// AuditIdentityLink is recorded when a provider account gets linked.
+const AuditIdentityLink = "identity.link"
+
+func (u *userService) OIDCProviders() []string {
+	names := make([]string, 0, len(u.opts.OIDC))
+	for name := range u.opts.OIDC {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartOIDC begins a sign-in at provider and returns the URL to send the
+// user to. State, nonce and PKCE verifier stay on the server.
+func (u *userService) StartOIDC(provider string) (string, error) {
+	p, ok := u.opts.OIDC[provider]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	if err := u.identities.SaveLogin(&models.OIDCLogin{
+		StateHash:    hashToken(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	}); err != nil {
+		return "", err
+	}
+	return p.AuthCodeURL(context.Background(), state, nonce, verifier)
+}
+
+// FinishOIDC completes a sign-in with the code and state the provider
+// redirected back with. The provider account signs in the user it is
+// linked to; otherwise it is linked to the user with the same verified
+// email, or a new user is registered.
+func (u *userService) FinishOIDC(provider, state, code string, client ClientInfo) (LoginResult, error) {
+	p, ok := u.opts.OIDC[provider]
+	if !ok {
+		return LoginResult{}, ErrUnknownProvider
+	}
+	login, err := u.identities.TakeLogin(hashToken(state), time.Now())
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && login.Provider != provider) {
+		return LoginResult{}, ErrOIDCFailed
+	} else if err != nil {
+		return LoginResult{}, err
+	}
+
+	ctx := context.Background()
+	tokens, err := p.Exchange(ctx, code, login.CodeVerifier)
+	if err != nil {
+		log.Printf("FinishOIDC: %s exchange: %v", provider, err)
+		return LoginResult{}, ErrOIDCFailed
+	}
+	claims, err := p.Verify(ctx, tokens.IDToken, login.Nonce)
+	if err != nil {
+		log.Printf("FinishOIDC: %s id token: %v", provider, err)
+		return LoginResult{}, ErrOIDCFailed
+	}
+
+	user, identity, err := u.userForIdentity(provider, claims, client)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if user.SuspendedAt != nil {
+		u.auditLogin(AuditLoginFailed, user.ID, client, map[string]string{"provider": provider, "reason": "suspended"})
+		return LoginResult{}, ErrAccountSuspended
+	}
+	if err := u.identities.Touch(identity.ID, time.Now()); err != nil {
+		return LoginResult{}, err
+	}
+	if err := u.promoteAdmin(user); err != nil {
+		return LoginResult{}, err
+	}
+	return u.startLogin(user.ID, "oidc:"+provider, client)
+}
+
+// userForIdentity finds or creates the user behind the provider account.
+func (u *userService) userForIdentity(provider string, claims *oidc.Claims, client ClientInfo) (models.User, models.Identity, error) {
+	identity, err := u.identities.ByProviderSubject(provider, claims.Subject)
+	if err == nil {
+		user, err := u.users.ByID(identity.UserID)
+		return user, identity, err
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return models.User{}, models.Identity{}, err
+	}
+
+	// Linking by email is only safe when the provider vouches for it.
+	email := strings.TrimSpace(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		return models.User{}, models.Identity{}, ErrEmailNotVerified
+	}
+	user, err := u.users.ByEmail(email)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		if user, err = u.createOIDCUser(email, claims); err != nil {
+			return models.User{}, models.Identity{}, err
+		}
+	case err != nil:
+		return models.User{}, models.Identity{}, err
+	case user.EmailVerifiedAt == nil:
+		// linking would hand the owner an account whose password and
+		// sessions still belong to whoever registered the address
+		return models.User{}, models.Identity{}, ErrAccountUnverified
+	}
+
+	identity = models.Identity{UserID: user.ID, Provider: provider, Subject: claims.Subject, Email: email}
+	if err := u.identities.Create(&identity); errors.Is(err, repository.ErrConflict) {
+		return models.User{}, models.Identity{}, ErrProviderLinked
+	} else if err != nil {
+		return models.User{}, models.Identity{}, err
+	}
+	u.audit.Record(AuditEntry{
+		ActorID:    user.ID,
+		SubjectID:  user.ID,
+		Action:     AuditIdentityLink,
+		TargetType: "identity",
+		TargetID:   identity.ID,
+		Client:     client,
+		After:      map[string]string{"provider": provider, "email": email},
+	})
+	return user, identity, nil
+}
+
+// createOIDCUser registers a user without a password; one can be set
+// later through the password reset flow.
+func (u *userService) createOIDCUser(email string, claims *oidc.Claims) (models.User, error) {
+	name := strings.TrimSpace(claims.Name)
+	if name == "" {
+		name = strings.SplitN(email, "@", 2)[0]
+	}
+	now := time.Now()
+	user := models.User{
+		ID:              uuid.NewString(),
+		Name:            name,
+		Email:           email,
+		AvatarURL:       claims.Picture,
+		EmailVerifiedAt: &now,
+		Role:            models.RoleUser,
+	}
+	if err := u.users.Create(&user); errors.Is(err, repository.ErrConflict) {
+		return models.User{}, ErrEmailTaken
+	} else if err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+func (u *userService) ListIdentities(userID string) ([]models.Identity, error) {
+	return u.identities.ListForUser(userID)
+}
+
+// UnlinkIdentity removes a linked provider account. Users who never set a
+// password keep at least one.
+func (u *userService) UnlinkIdentity(userID, id string) error {
+	user, err := u.users.ByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.PasswordHash == "" {
+		list, err := u.identities.ListForUser(userID)
+		if err != nil {
+			return err
+		}
+		if len(list) <= 1 {
+			return ErrLastLoginMethod
+		}
+	}
+	return u.identities.Delete(userID, id)
+}
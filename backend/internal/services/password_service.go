@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/password"
+)
+
+var (
+	// ErrInvalidCredentials is returned by Authenticate for an unknown
+	// email or a wrong password alike.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrWrongPassword is returned when the current password does not match.
+	ErrWrongPassword = errors.New("current password is incorrect")
+	// ErrWeakPassword wraps every password policy violation.
+	ErrWeakPassword = password.ErrWeak
+	// ErrEmailTaken is returned when registering an address twice.
+	ErrEmailTaken = errors.New("email already registered")
+	// ErrAccountSuspended is returned when a suspended user signs in.
+	ErrAccountSuspended = errors.New("account suspended")
+)
+
+// dummyHash is compared against when the email is unknown.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// ChangePassword replaces the password after checking the current one
+// and signs out every other session; the caller's session stays.
+func (u *userService) ChangePassword(userID, sessionID, current, next string) error {
+	user, err := u.users.ByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(current)); err != nil {
+		return ErrWrongPassword
+	}
+	if err := u.opts.PasswordPolicy.Check(next, user.Email); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(next), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := u.users.SetPassword(userID, string(hash)); err != nil {
+		return err
+	}
+	if err := u.userTokens.Invalidate(userID, models.TokenPasswordReset, time.Now()); err != nil {
+		return err
+	}
+	return u.endAllSessionsExcept(userID, sessionID)
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+// Actions recorded around account deletion.
+const (
+	AuditDeletionRequested = "account.delete_requested"
+	AuditDeletionCancelled = "account.delete_cancelled"
+	AuditAccountErased     = "account.erase"
+)
+
+type PersonalDataService interface {
+	// Export collects everything stored about userID.
+	Export(userID string) (models.PersonalData, error)
+	// RequestDeletion schedules the account for erasure after the grace
+	// period and returns when that happens. The password must match.
+	RequestDeletion(userID, password string) (time.Time, error)
+	// CancelDeletion keeps the account while the grace period runs.
+	CancelDeletion(userID string) error
+	// PurgeDue erases every account whose grace period ended before now.
+	PurgeDue(now time.Time) (int, error)
+	StartPurger()
+}
+
+type personalDataService struct {
+	users repository.UserRepository
+	data  repository.PersonalDataRepository
+	audit AuditService
+	grace time.Duration
+}
+
+// PersonalData is the exported singleton service, set up by Use.
+var PersonalData PersonalDataService
+
+// NewPersonalDataService builds the PersonalDataService on the
+// repositories in store. Deletion requests wait grace before erasure.
+func NewPersonalDataService(store *repository.Store, grace time.Duration) PersonalDataService {
+	return &personalDataService{
+		users: store.Users,
+		data:  store.PersonalData,
+		audit: NewAuditService(store.Audit),
+		grace: grace,
+	}
+}
+
+func (s *personalDataService) Export(userID string) (models.PersonalData, error) {
+	return s.data.Export(userID)
+}
+
+func (s *personalDataService) RequestDeletion(userID, password string) (time.Time, error) {
+	user, err := s.users.ByID(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return time.Time{}, ErrWrongPassword
+	}
+	if user.DeleteAfter != nil {
+		return *user.DeleteAfter, nil
+	}
+	at := time.Now().Add(s.grace).UTC()
+	if err := s.users.SetDeleteAfter(userID, &at); err != nil {
+		return time.Time{}, err
+	}
+	return at, nil
+}
+
+func (s *personalDataService) CancelDeletion(userID string) error {
+	return s.users.SetDeleteAfter(userID, nil)
+}
+
+func (s *personalDataService) PurgeDue(now time.Time) (int, error) {
+	ids, err := s.users.DueForDeletion(now)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, id := range ids {
+		// read the sessions first to close their sockets afterwards
+		before, err := s.data.Export(id)
+		if err != nil && err != repository.ErrNotFound {
+			return n, err
+		}
+		if err := s.data.Erase(id); err != nil {
+			if err == repository.ErrNotFound {
+				continue
+			}
+			return n, err
+		}
+		for _, sess := range before.Sessions {
+			ActivityHub.DisconnectSession(id, sess.ID)
+		}
+		// Only the bare event survives, so support can tell the account
+		// was erased on request.
+		s.audit.Record(AuditEntry{SubjectID: id, Action: AuditAccountErased, TargetType: "user", TargetID: id})
+		n++
+	}
+	return n, nil
+}
+
+// StartPurger erases due accounts once an hour.
+func (s *personalDataService) StartPurger() {
+	ticker := time.NewTicker(time.Hour)
+	go func() {
+		for now := range ticker.C {
+			n, err := s.PurgeDue(now)
+			if err != nil {
+				log.Printf("[PersonalData] purge: %v", err)
+			}
+			if n > 0 {
+				log.Printf("[PersonalData] erased %d account(s)", n)
+			}
+		}
+	}()
+}
+
+// WriteArchive writes d as a ZIP with one JSON file per table, plus CSV
+// copies of the day-to-day logs for spreadsheets.
+func WriteArchive(w io.Writer, d models.PersonalData) error {
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"profile.json", d.User},
+		{"goals.json", d.Goals},
+		{"two_factor.json", map[string]*time.Time{"enabledAt": d.TwoFactorSince}},
+		{"friends.json", d.Friends},
+		{"friend_requests.json", d.FriendRequests},
+		{"achievements.json", d.Achievements},
+		{"activities.json", d.Activities},
+		{"steps.json", d.Steps},
+		{"step_buckets.json", d.StepBuckets},
+		{"meals.json", d.Meals},
+		{"water_logs.json", d.WaterLogs},
+		{"posts.json", d.Posts},
+		{"messages.json", d.Messages},
+		{"challenges.json", d.Challenges},
+		{"challenge_participations.json", d.Participations},
+		{"challenge_progress.json", d.Progress},
+		{"challenge_invitations.json", d.Invitations},
+		{"workout_schedules.json", d.Workouts},
+		{"hydration_settings.json", d.Hydration},
+		{"sessions.json", d.Sessions},
+		{"linked_identities.json", d.Identities},
+		{"access_tokens.json", d.AccessTokens},
+		{"audit_events.json", d.AuditEvents},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.v); err != nil {
+			return err
+		}
+	}
+
+	tables := []struct {
+		name string
+		rows [][]string
+	}{
+		{"activities.csv", activityRows(d.Activities)},
+		{"steps.csv", stepRows(d.Steps)},
+		{"meals.csv", mealRows(d.Meals)},
+		{"water_logs.csv", waterRows(d.WaterLogs)},
+	}
+	for _, t := range tables {
+		fw, err := zw.Create(t.name)
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(fw)
+		if err := cw.WriteAll(t.rows); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func activityRows(list []models.Activity) [][]string {
+	rows := [][]string{{"id", "type", "name", "duration", "intensity", "calories", "location", "distance", "performed_at"}}
+	for _, a := range list {
+		rows = append(rows, []string{a.ID, a.Type, a.Name, strconv.Itoa(a.Duration), a.Intensity,
+			strconv.Itoa(a.Calories), a.Location, strconv.Itoa(a.Distance), a.PerformedAt.Format(time.RFC3339)})
+	}
+	return rows
+}
+
+func stepRows(list []models.StepDay) [][]string {
+	rows := [][]string{{"day", "steps"}}
+	for _, s := range list {
+		rows = append(rows, []string{s.Day, strconv.Itoa(s.Steps)})
+	}
+	return rows
+}
+
+func mealRows(list []models.Meal) [][]string {
+	rows := [][]string{{"id", "fdc_id", "description", "calories", "protein", "fat", "carbs", "quantity", "unit", "created_at"}}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, m := range list {
+		rows = append(rows, []string{m.ID, strconv.Itoa(m.FdcID), m.Description, f(m.Calories), f(m.Protein),
+			f(m.Fat), f(m.Carbs), f(m.Quantity), m.Unit, m.CreatedAt.Format(time.RFC3339)})
+	}
+	return rows
+}
+
+func waterRows(list []models.WaterLog) [][]string {
+	rows := [][]string{{"id", "amount_ml", "created_at"}}
+	for _, w := range list {
+		rows = append(rows, []string{w.ID, strconv.Itoa(w.AmountML), w.CreatedAt.Format(time.RFC3339)})
+	}
+	return rows
+}
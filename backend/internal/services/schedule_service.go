@@ -5,32 +5,38 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jmoiron/sqlx"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
-	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/db"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
 )
 
 /* -------------------------------------------------------------------------- */
 /*                                STRUCTURE                                   */
 /* -------------------------------------------------------------------------- */
 
-type scheduleService struct {
-	db *sqlx.DB // will be filled lazily
+type ScheduleService interface {
+	AddWorkout(id, uid string, wk int, at, title string) error
+	ListWorkouts(uid string) ([]models.WorkoutSchedule, error)
+	DeleteWorkout(uid, id string) error
+	StartTicker()
 }
 
-// exported singleton (⚠️ do NOT grab db.DB here!)
-var Schedule = &scheduleService{}
-
-/* -------------------------------------------------------------------------- */
-/*                          INTERNAL DB HELPER                                */
-/* -------------------------------------------------------------------------- */
+type scheduleService struct {
+	schedules  repository.ScheduleRepository
+	challenges repository.ChallengeRepository
+	lifecycle  ChallengeService
+}
 
-// always returns a *live* connection; nil while DB isn’t ready.
-func (s *scheduleService) conn() *sqlx.DB {
-	if s.db == nil {
-		s.db = db.DB
-	}
-	return s.db
+// Schedule is the exported singleton service, set up by Use.
+var Schedule ScheduleService
+
+// NewScheduleService builds the ScheduleService on top of the given
+// repositories; lifecycle starts and finishes challenges on every tick.
+func NewScheduleService(
+	schedules repository.ScheduleRepository,
+	challenges repository.ChallengeRepository,
+	lifecycle ChallengeService,
+) ScheduleService {
+	return &scheduleService{schedules: schedules, challenges: challenges, lifecycle: lifecycle}
 }
 
 /* -------------------------------------------------------------------------- */
@@ -38,23 +44,17 @@ func (s *scheduleService) conn() *sqlx.DB {
 /* -------------------------------------------------------------------------- */
 
 func (s *scheduleService) AddWorkout(id, uid string, wk int, at, title string) error {
-	_, err := s.conn().Exec(`
-		INSERT INTO workout_schedules (id,user_id,weekday,at_time,title)
-		VALUES ($1,$2,$3,$4,$5)`, id, uid, wk, at, title)
-	return err
+	return s.schedules.AddWorkout(&models.WorkoutSchedule{
+		ID: id, UserID: uid, Weekday: wk, AtTime: at, Title: title,
+	})
 }
 
 func (s *scheduleService) ListWorkouts(uid string) ([]models.WorkoutSchedule, error) {
-	var list []models.WorkoutSchedule
-	err := s.conn().Select(&list,
-		`SELECT * FROM workout_schedules WHERE user_id=$1`, uid)
-	return list, err
+	return s.schedules.ListWorkouts(uid)
 }
 
 func (s *scheduleService) DeleteWorkout(uid, id string) error {
-	_, err := s.conn().Exec(
-		`DELETE FROM workout_schedules WHERE id=$1 AND user_id=$2`, id, uid)
-	return err
+	return s.schedules.DeleteWorkout(uid, id)
 }
 
 /* -------------------------------------------------------------------------- */
@@ -75,44 +75,27 @@ func (s *scheduleService) StartTicker() {
 				log.Printf("[Schedule] fireWorkout: %v", err)
 			}
 			s.fireHydration(now)
-			s.fireChallengeDeadline(now)
+			if err := s.lifecycle.Advance(now); err != nil {
+				log.Printf("[Schedule] challenges: %v", err)
+			}
+			if err := s.fireChallengeDeadline(now); err != nil {
+				log.Printf("[Schedule] fireChallengeDeadline: %v", err)
+			}
 		}
 	}()
 }
 
-// ---------------- fireWorkout ----------------
 // ---------------- fireWorkout ----------------
 func (s *scheduleService) fireWorkout(now time.Time) error {
-	// always grab a *live* handle – the first minute after boot
-	// `db.DB` can still be nil while migrations run
-	dbx := s.conn()
-	if dbx == nil {
-		return nil
-	}
-
-	// Monday = 0 … Sunday = 6 (client sends the same index)
-	weekday := int(now.Weekday()+6) % 7
-	cur := now.Format("15:04") // e.g. "18:30"
-
-	var rows []struct {
-		UserID string `db:"user_id"`
-		Title  string `db:"title"`
-	}
-
-	// match ANY schedule within this exact minute
-	if err := dbx.Select(&rows, `
-		SELECT user_id, title
-		FROM   workout_schedules
-		WHERE  weekday               = $1
-		  AND  to_char(at_time,'HH24:MI') = $2`,
-		weekday, cur,
-	); err != nil {
+	// match ANY schedule within this exact minute of its owner's day
+	rows, err := s.schedules.WorkoutsDue(now)
+	if err != nil {
 		return err
 	}
 
 	if len(rows) > 0 {
 		log.Printf("[Schedule] %s – fired workout reminders for %d user(s)",
-			cur, len(rows))
+			now.UTC().Format("15:04"), len(rows))
 	}
 
 	for _, r := range rows {
@@ -129,20 +112,11 @@ func (s *scheduleService) fireWorkout(now time.Time) error {
 }
 
 func (s *scheduleService) fireHydration(now time.Time) {
-	dbx := s.conn()
-	if dbx == nil {
-		return
-	}
-
-	curMin := now.Hour()*60 + now.Minute()
-
-	var rows []struct {
-		UserID   string `db:"user_id"`
-		Interval int    `db:"interval"`
-	}
-	_ = dbx.Select(&rows, `SELECT user_id,interval FROM hydration_settings`)
+	rows, _ := s.schedules.HydrationSettings()
 
 	for _, r := range rows {
+		local := now.In(loadZone(r.Timezone))
+		curMin := local.Hour()*60 + local.Minute()
 		if r.Interval > 0 && curMin%r.Interval == 0 {
 			ActivityHub.Broadcast(ActivityMessage{
 				RecipientIDs: []string{r.UserID},
@@ -155,40 +129,35 @@ func (s *scheduleService) fireHydration(now time.Time) {
 	}
 }
 
+// challengeReminderHour is when, in their zone, participants still below
+// the target hear that a challenge ends within a day.
+const challengeReminderHour = 20
+
 func (s *scheduleService) fireChallengeDeadline(now time.Time) error {
-	if now.Hour() != 23 || now.Minute() != 0 {
-		return nil // run once a day at 23:00
-	}
-	dbx := s.conn()
-	if dbx == nil {
+	// a zone is at 20:00 only on whole, half or quarter hours
+	if now.Minute()%15 != 0 {
 		return nil
 	}
-
-	var rows []struct {
-		UserID string `db:"user_id"`
-		Title  string `db:"title"`
-		ID     string `db:"id"`
-		Target int    `db:"target"`
-		Prog   int    `db:"progress"`
-	}
-	if err := dbx.Select(&rows, `
-		SELECT cp.user_id,c.title,c.id,c.target,cp.progress
-		FROM   challenge_participants cp
-		JOIN   challenges            c ON c.id = cp.challenge_id
-		WHERE  cp.progress < c.target
-	`); err != nil {
+	rows, err := s.challenges.EndingBefore(now.Add(24 * time.Hour))
+	if err != nil {
 		return err
 	}
 
 	for _, r := range rows {
+		// once, at 20:00 in the participant's zone on the last day
+		local := now.In(loadZone(r.Timezone))
+		if local.Hour() != challengeReminderHour || local.Minute() != 0 || !now.Before(r.EndsAt) {
+			continue
+		}
 		ActivityHub.Broadcast(ActivityMessage{
 			RecipientIDs: []string{r.UserID},
 			Data: gin.H{
 				"kind":        "reminder",
 				"type":        "challenge",
 				"title":       r.Title,
-				"challengeId": r.ID,
-				"remaining":   r.Target - r.Prog,
+				"challengeId": r.ChallengeID,
+				"remaining":   r.Target - r.Progress,
+				"endsAt":      r.EndsAt,
 			},
 		})
 	}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+)
+
+// ErrSessionRevoked is returned for tokens of a signed-out or revoked session.
+var ErrSessionRevoked = errors.New("session revoked")
+
+// ErrSessionNotFound is returned when revoking a session the user does not have.
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionTouchInterval limits how often a request refreshes last-seen.
+const sessionTouchInterval = time.Minute
+
+// ClientInfo describes the device a request came from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// Session for client responses.
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	Current    bool      `json:"current"`
+}
+
+// startSession records a new signed-in device and returns its ID.
+func (u *userService) startSession(userID string, client ClientInfo) (string, error) {
+	now := time.Now()
+	s := models.Session{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	return s.ID, u.sessions.Create(&s)
+}
+
+// CheckSession fails for revoked sessions and updates last-seen at most
+// once per sessionTouchInterval.
+func (u *userService) CheckSession(sessionID, ip string) error {
+	s, err := u.sessions.ByID(sessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrSessionRevoked
+	}
+	if err != nil {
+		return err
+	}
+	if s.RevokedAt != nil {
+		return ErrSessionRevoked
+	}
+	if now := time.Now(); now.Sub(s.LastSeenAt) >= sessionTouchInterval || s.IP != ip {
+		return u.sessions.Touch(sessionID, ip, now)
+	}
+	return nil
+}
+
+// ListSessions returns the user's signed-in devices; currentID marks the
+// one making the request.
+func (u *userService) ListSessions(userID, currentID string) ([]Session, error) {
+	list, err := u.sessions.ListActive(userID, time.Now().Add(-u.opts.RefreshTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Session, 0, len(list))
+	for _, s := range list {
+		out = append(out, Session{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			Current:    s.ID == currentID,
+		})
+	}
+	return out, nil
+}
+
+// RevokeSession signs the device out: its refresh tokens stop working,
+// its access tokens are rejected and its WebSocket connections are closed.
+func (u *userService) RevokeSession(userID, sessionID string) error {
+	err := u.endSession(userID, sessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+func (u *userService) endSession(userID, sessionID string) error {
+	now := time.Now()
+	if err := u.sessions.Revoke(userID, sessionID, now); err != nil {
+		return err
+	}
+	if err := u.tokens.RevokeFamily(sessionID, now); err != nil {
+		return err
+	}
+	if n := ActivityHub.DisconnectSession(userID, sessionID); n > 0 {
+		log.Printf("session %s revoked, closed %d socket(s)", sessionID, n)
+	}
+	return nil
+}
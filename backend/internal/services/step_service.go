@@ -1,16 +1,96 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
-	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/db"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
 )
 
+// Bounds on step counts clients may write.
+const (
+	// MaxDailySteps is more than anyone walks in a day.
+	MaxDailySteps = 100000
+	// MaxHourlySteps is more than anyone runs in an hour.
+	MaxHourlySteps = 30000
+	// StepBackfillDays is how far back, counting today, counts may be
+	// written or deleted.
+	StepBackfillDays = 90
+	// maxStepSourceLen bounds device names.
+	maxStepSourceLen = 64
+)
+
+// Sources steps are recorded under when the client names none, and for
+// corrections made by hand.
+const (
+	DefaultStepSource = "app"
+	ManualStepSource  = "manual"
+)
+
+// ErrInvalidStepDay is returned for counts outside the bounds above,
+// days in the future or dates that do not parse.
+var ErrInvalidStepDay = errors.New("invalid step entry")
+
+// StepHour is what a source counted in the hour containing Start.
+type StepHour struct {
+	Start time.Time `json:"start"`
+	Steps int       `json:"steps"`
+}
+
+// StepDayBreakdown is a daily total with, on request, what each source
+// counted that day and the merged count of every local hour. Hours is
+// left out for days no source reported hourly.
+type StepDayBreakdown struct {
+	Day     string         `json:"day"`
+	Steps   int            `json:"steps"`
+	Sources map[string]int `json:"sources,omitempty"`
+	Hours   []int          `json:"hours,omitempty"`
+}
+
+// Every write is stored per source; a day's total is merged from what its
+// sources counted, so a phone and a watch worn together count once.
+type StepService interface {
+	AddOrUpdateSteps(userID string, steps int) error
+	// SaveStepDays stores whole-day counts of source, replacing its earlier
+	// ones for the same days. An empty Day means today. Nothing is stored
+	// when any entry is invalid.
+	SaveStepDays(userID, source string, days []StepDay) error
+	// SaveStepHours stores hourly counts of source the same way.
+	SaveStepHours(userID, source string, hours []StepHour) error
+	// ReplaceStepDay drops everything recorded for the day and keeps the
+	// given total as a manual count.
+	ReplaceStepDay(userID string, day StepDay) error
+	DeleteStepDay(userID, day string) error
+	GetStepBreakdown(userID string, days int, bySource, byHour bool) ([]StepDayBreakdown, error)
+	AwardStepAchievements(userID string) error
+	GetStepStats(userID string, goal int) (StepStats, error)
+	GetStepAnalytics(userID string, days int) ([]StepDay, error)
+	SetStepGoal(userID string, goal int) error
+	GetStepGoal(userID string) (int, error)
+}
+
 type stepService struct {
+	steps        repository.StepRepository
+	goals        repository.GoalRepository
+	achievements repository.AchievementRepository
+	users        repository.UserRepository
 }
 
-// User is the exported singleton service.
-var Step = &stepService{}
+// Step is the exported singleton service, set up by Use.
+var Step StepService
+
+// NewStepService builds the StepService on top of the given repositories.
+func NewStepService(
+	steps repository.StepRepository,
+	goals repository.GoalRepository,
+	achievements repository.AchievementRepository,
+	users repository.UserRepository,
+) StepService {
+	return &stepService{steps: steps, goals: goals, achievements: achievements, users: users}
+}
 
 type StepStats struct {
 	Today        int     `json:"today"`
@@ -20,42 +100,134 @@ type StepStats struct {
 	MonthlyTotal int     `json:"monthly_total"`
 }
 
-type StepDay struct {
-	Day   string `db:"day" json:"day"`
-	Steps int    `db:"steps" json:"steps"`
-}
+type StepDay = models.StepDay
 
 func (s *stepService) AddOrUpdateSteps(userID string, steps int) error {
-	today := time.Now().UTC().Format("2006-01-02")
-	result, err := db.DB.Exec(`
-        UPDATE user_steps SET steps = $1 WHERE user_id = $2 AND day = $3
-    `, steps, userID, today)
+	return s.SaveStepDays(userID, DefaultStepSource, []StepDay{{Steps: steps}})
+}
+
+func (s *stepService) SaveStepDays(userID, source string, days []StepDay) error {
+	source, err := stepSource(source)
+	if err != nil {
+		return err
+	}
+	now, loc := time.Now(), userZone(s.users, userID)
+	buckets := make([]models.StepBucket, 0, len(days))
+	for _, d := range days {
+		if d.Day == "" {
+			d.Day = dayKey(now, loc)
+		}
+		if err := checkStepDay(d.Day, now, loc); err != nil {
+			return err
+		}
+		if d.Steps < 0 || d.Steps > MaxDailySteps {
+			return fmt.Errorf("%w: steps must be between 0 and %d", ErrInvalidStepDay, MaxDailySteps)
+		}
+		buckets = append(buckets, models.StepBucket{Source: source, Day: d.Day, Steps: d.Steps})
+	}
+	return s.steps.SaveBuckets(userID, buckets)
+}
+
+func (s *stepService) SaveStepHours(userID, source string, hours []StepHour) error {
+	source, err := stepSource(source)
 	if err != nil {
 		return err
 	}
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		_, err = db.DB.Exec(`
-            INSERT INTO user_steps (user_id, steps, day)
-            VALUES ($1, $2, $3)
-            ON CONFLICT (user_id, day) DO UPDATE SET steps = $2
-        `, userID, steps, today)
+	now, loc := time.Now(), userZone(s.users, userID)
+	buckets := make([]models.StepBucket, 0, len(hours))
+	for _, h := range hours {
+		if h.Start.After(now) {
+			return fmt.Errorf("%w: %s is in the future", ErrInvalidStepDay, h.Start.Format(time.RFC3339))
+		}
+		day := dayKey(h.Start, loc)
+		if err := checkStepDay(day, now, loc); err != nil {
+			return err
+		}
+		if h.Steps < 0 || h.Steps > MaxHourlySteps {
+			return fmt.Errorf("%w: steps in an hour must be between 0 and %d", ErrInvalidStepDay, MaxHourlySteps)
+		}
+		hour := h.Start.In(loc).Hour()
+		buckets = append(buckets, models.StepBucket{Source: source, Day: day, Hour: &hour, Steps: h.Steps})
+	}
+	return s.steps.SaveBuckets(userID, buckets)
+}
+
+// stepSource normalises the device name steps are recorded under.
+func stepSource(source string) (string, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return DefaultStepSource, nil
+	}
+	if len(source) > maxStepSourceLen {
+		return "", fmt.Errorf("%w: source must be at most %d characters", ErrInvalidStepDay, maxStepSourceLen)
+	}
+	return source, nil
+}
+
+func (s *stepService) ReplaceStepDay(userID string, day StepDay) error {
+	if err := checkStepDay(day.Day, time.Now(), userZone(s.users, userID)); err != nil {
+		return err
+	}
+	if day.Steps < 0 || day.Steps > MaxDailySteps {
+		return fmt.Errorf("%w: steps must be between 0 and %d", ErrInvalidStepDay, MaxDailySteps)
+	}
+	if err := s.steps.Delete(userID, day.Day); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	return s.steps.SaveBuckets(userID, []models.StepBucket{{Source: ManualStepSource, Day: day.Day, Steps: day.Steps}})
+}
+
+func (s *stepService) DeleteStepDay(userID, day string) error {
+	if err := checkStepDay(day, time.Now(), userZone(s.users, userID)); err != nil {
+		return err
 	}
-	return err
+	return s.steps.Delete(userID, day)
+}
+
+// checkStepDay accepts "YYYY-MM-DD" days from the last StepBackfillDays
+// up to today in loc.
+func checkStepDay(day string, now time.Time, loc *time.Location) error {
+	t, err := time.ParseInLocation(dayLayout, day, loc)
+	if err != nil {
+		return fmt.Errorf("%w: day must look like 2006-01-02", ErrInvalidStepDay)
+	}
+	today := startOfDay(now, loc)
+	if t.After(today) {
+		return fmt.Errorf("%w: %s is in the future", ErrInvalidStepDay, day)
+	}
+	if t.Before(today.AddDate(0, 0, -StepBackfillDays+1)) {
+		return fmt.Errorf("%w: only the last %d days can be changed", ErrInvalidStepDay, StepBackfillDays)
+	}
+	return nil
 }
 
 func (s *stepService) GetStepStats(userID string, goal int) (StepStats, error) {
 	var stats StepStats
-	today := time.Now().UTC().Format("2006-01-02")
-	weekStart := time.Now().UTC().AddDate(0, 0, -int(time.Now().UTC().Weekday())).Format("2006-01-02")
-	monthStart := time.Now().UTC().Format("2006-01-02")[:8] + "01"
+	loc := userZone(s.users, userID)
+	now := time.Now().In(loc)
+	today := dayKey(now, loc)
+	weekStart := dayKey(now.AddDate(0, 0, -int(now.Weekday())), loc)
+	monthStart := today[:8] + "01"
 
-	// Today
-	_ = db.DB.Get(&stats.Today, `SELECT steps FROM user_steps WHERE user_id = $1 AND day = $2`, userID, today)
-	// Weekly total
-	_ = db.DB.Get(&stats.WeeklyTotal, `SELECT COALESCE(SUM(steps),0) FROM user_steps WHERE user_id = $1 AND day >= $2`, userID, weekStart)
-	// Monthly total
-	_ = db.DB.Get(&stats.MonthlyTotal, `SELECT COALESCE(SUM(steps),0) FROM user_steps WHERE user_id = $1 AND day >= $2`, userID, monthStart)
+	from := monthStart
+	if weekStart < from {
+		from = weekStart
+	}
+	days, err := s.steps.ListSince(userID, from)
+	if err != nil {
+		return StepStats{}, err
+	}
+	for _, d := range days {
+		if d.Day == today {
+			stats.Today = d.Steps
+		}
+		if d.Day >= weekStart {
+			stats.WeeklyTotal += d.Steps
+		}
+		if d.Day >= monthStart {
+			stats.MonthlyTotal += d.Steps
+		}
+	}
 
 	stats.Goal = goal
 	if goal > 0 {
@@ -67,19 +239,72 @@ func (s *stepService) GetStepStats(userID string, goal int) (StepStats, error) {
 }
 
 func (s *stepService) GetStepAnalytics(userID string, days int) ([]StepDay, error) {
-	var result []StepDay
-	fromDay := time.Now().UTC().AddDate(0, 0, -days+1).Format("2006-01-02")
-	err := db.DB.Select(&result, `
-        SELECT day, steps FROM user_steps 
-        WHERE user_id = $1 AND day >= $2 
-        ORDER BY day DESC
-    `, userID, fromDay)
-	return result, err
+	loc := userZone(s.users, userID)
+	fromDay := dayKey(time.Now().In(loc).AddDate(0, 0, -days+1), loc)
+	return s.steps.ListSince(userID, fromDay)
 }
 
+func (s *stepService) GetStepBreakdown(userID string, days int, bySource, byHour bool) ([]StepDayBreakdown, error) {
+	loc := userZone(s.users, userID)
+	fromDay := dayKey(time.Now().In(loc).AddDate(0, 0, -days+1), loc)
+	totals, err := s.steps.ListSince(userID, fromDay)
+	if err != nil {
+		return nil, err
+	}
+	buckets, err := s.steps.Buckets(userID, fromDay)
+	if err != nil {
+		return nil, err
+	}
+
+	type dayBuckets struct {
+		whole  map[string]int // source -> whole-day count
+		hourly map[string]int // source -> sum of its hours
+		hours  []int
+	}
+	byDay := map[string]*dayBuckets{}
+	for _, b := range buckets {
+		d := byDay[b.Day]
+		if d == nil {
+			d = &dayBuckets{whole: map[string]int{}, hourly: map[string]int{}}
+			byDay[b.Day] = d
+		}
+		if b.Hour == nil {
+			d.whole[b.Source] = b.Steps
+			continue
+		}
+		d.hourly[b.Source] += b.Steps
+		if d.hours == nil {
+			d.hours = make([]int, 24)
+		}
+		d.hours[*b.Hour] = max(d.hours[*b.Hour], b.Steps)
+	}
+
+	list := make([]StepDayBreakdown, 0, len(totals))
+	for _, t := range totals {
+		entry := StepDayBreakdown{Day: t.Day, Steps: t.Steps}
+		if d := byDay[t.Day]; d != nil {
+			if bySource {
+				entry.Sources = map[string]int{}
+				for src, n := range d.whole {
+					entry.Sources[src] = n
+				}
+				for src, n := range d.hourly {
+					entry.Sources[src] = max(entry.Sources[src], n)
+				}
+			}
+			if byHour {
+				entry.Hours = d.hours
+			}
+		}
+		list = append(list, entry)
+	}
+	return list, nil
+}
+
+// AwardStepAchievements awards the milestones the user's total has reached.
+// Milestones stay awarded when totals are later corrected downwards.
 func (s *stepService) AwardStepAchievements(userID string) error {
-	var totalSteps int
-	err := db.DB.Get(&totalSteps, `SELECT COALESCE(SUM(steps),0) FROM user_steps WHERE user_id = $1`, userID)
+	totalSteps, err := s.steps.Total(userID)
 	if err != nil {
 		return err
 	}
@@ -92,29 +317,20 @@ func (s *stepService) AwardStepAchievements(userID string) error {
 
 	for steps, title := range milestones {
 		if totalSteps >= steps {
-			err := User.AwardAchievementToUserID(userID, title)
+			ach, err := s.achievements.ByTitle(title)
 			if err != nil {
 				continue
 			}
+			_ = s.achievements.Award(userID, ach.ID, "")
 		}
 	}
 	return nil
 }
 
 func (s *stepService) SetStepGoal(userID string, goal int) error {
-	_, err := db.DB.Exec(`
-		INSERT INTO step_goals (user_id, goal)
-		VALUES ($1, $2)
-		ON CONFLICT (user_id) DO UPDATE SET goal = EXCLUDED.goal, updated_at = now()
-	`, userID, goal)
-	return err
+	return s.goals.Set(userID, models.GoalSteps, goal)
 }
 
 func (s *stepService) GetStepGoal(userID string) (int, error) {
-	var goal int
-	err := db.DB.Get(&goal, `SELECT goal FROM step_goals WHERE user_id = $1`, userID)
-	if err != nil {
-		return 10000, nil
-	}
-	return goal, nil
+	return goalOrDefault(s.goals, userID, models.GoalSteps, DefaultStepGoal)
 }
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository/memory"
+)
+
+// newTestUser stores a user who lives in UTC and returns their ID.
+func newTestUser(t *testing.T, store *repository.Store) string {
+	t.Helper()
+	u := models.User{Name: "Ann", Email: "ann@test.com", Timezone: "UTC"}
+	require.NoError(t, store.Users.Create(&u))
+	return u.ID
+}
+
+// stepDay returns the breakdown of day from list.
+func stepDay(t *testing.T, list []StepDayBreakdown, day string) StepDayBreakdown {
+	t.Helper()
+	for _, d := range list {
+		if d.Day == day {
+			return d
+		}
+	}
+	t.Fatalf("no steps on %s", day)
+	return StepDayBreakdown{}
+}
+
+func TestStepMerge(t *testing.T) {
+	store := memory.NewStore()
+	id := newTestUser(t, store)
+	svc := NewStepService(store.Steps, store.Goals, store.Achievements, store.Users)
+
+	yesterday := startOfDay(time.Now(), time.UTC).AddDate(0, 0, -1)
+	day := dayKey(yesterday, time.UTC)
+	at := func(hour int) time.Time { return yesterday.Add(time.Duration(hour) * time.Hour) }
+
+	// a phone and a watch worn together count their busiest hour once
+	require.NoError(t, svc.SaveStepHours(id, "phone", []StepHour{{Start: at(8), Steps: 1000}, {Start: at(9), Steps: 500}}))
+	require.NoError(t, svc.SaveStepHours(id, "watch", []StepHour{{Start: at(8), Steps: 1200}}))
+	require.NoError(t, svc.SaveStepDays(id, "band", []StepDay{{Day: day, Steps: 1500}}))
+
+	list, err := svc.GetStepBreakdown(id, 2, true, true)
+	require.NoError(t, err)
+	got := stepDay(t, list, day)
+	assert.Equal(t, 1700, got.Steps, "the merged hours beat the best whole-day count")
+	assert.Equal(t, map[string]int{"phone": 1500, "watch": 1200, "band": 1500}, got.Sources)
+	require.Len(t, got.Hours, 24)
+	assert.Equal(t, 1200, got.Hours[8])
+	assert.Equal(t, 500, got.Hours[9])
+
+	// a source replaces its own count instead of adding to it
+	require.NoError(t, svc.SaveStepDays(id, "band", []StepDay{{Day: day, Steps: 2500}}))
+	list, err = svc.GetStepBreakdown(id, 2, true, false)
+	require.NoError(t, err)
+	got = stepDay(t, list, day)
+	assert.Equal(t, 2500, got.Steps, "the best whole-day count beats the merged hours")
+	assert.Equal(t, 2500, got.Sources["band"])
+	assert.Nil(t, got.Hours)
+
+	// a correction by hand drops what the devices counted
+	require.NoError(t, svc.ReplaceStepDay(id, StepDay{Day: day, Steps: 900}))
+	list, err = svc.GetStepBreakdown(id, 2, true, true)
+	require.NoError(t, err)
+	got = stepDay(t, list, day)
+	assert.Equal(t, 900, got.Steps)
+	assert.Equal(t, map[string]int{ManualStepSource: 900}, got.Sources)
+	assert.Nil(t, got.Hours)
+
+	// nothing is stored when any entry is invalid
+	err = svc.SaveStepDays(id, "band", []StepDay{{Day: day, Steps: 100}, {Day: day, Steps: -1}})
+	assert.ErrorIs(t, err, ErrInvalidStepDay)
+	err = svc.SaveStepHours(id, "phone", []StepHour{{Start: time.Now().Add(time.Hour), Steps: 10}})
+	assert.ErrorIs(t, err, ErrInvalidStepDay)
+	list, err = svc.GetStepBreakdown(id, 2, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, 900, stepDay(t, list, day).Steps)
+}
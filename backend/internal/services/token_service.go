@@ -0,0 +1,128 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/auth"
+)
+
+// ErrInvalidRefreshToken is returned for unknown, expired, revoked or
+// already used refresh tokens.
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// TokenPair is what login, register and refresh hand to the client.
+// Token is the short-lived JWT sent as "Authorization: Bearer".
+type TokenPair struct {
+	Token        string    `json:"token"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	RefreshToken string    `json:"refreshToken"`
+}
+
+// issueTokens signs an access token for the session, carrying the user's
+// current role, and stores a new refresh token in the session's family.
+// Suspended users get nothing.
+func (u *userService) issueTokens(userID, sessionID string) (TokenPair, error) {
+	user, err := u.users.ByID(userID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if user.SuspendedAt != nil {
+		return TokenPair{}, ErrAccountSuspended
+	}
+
+	now := time.Now()
+	access, err := auth.GenerateToken(auth.Claims{UserID: userID, SessionID: sessionID, Role: user.Role})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	claims, err := auth.ParseToken(access)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refresh, err := newToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	err = u.tokens.Create(&models.RefreshToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		FamilyID:  sessionID,
+		TokenHash: hashToken(refresh),
+		ExpiresAt: now.Add(u.opts.RefreshTokenTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{Token: access, ExpiresAt: claims.ExpiresAt.Time, RefreshToken: refresh}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new pair. Each refresh
+// token works once; presenting one that was already exchanged means it
+// leaked, so the whole session is revoked.
+func (u *userService) RefreshToken(refreshToken string, client ClientInfo) (TokenPair, error) {
+	t, err := u.tokens.ByHash(hashToken(refreshToken))
+	if err != nil {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	now := time.Now()
+	if t.RevokedAt != nil || !now.Before(t.ExpiresAt) {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	ok, err := u.tokens.MarkUsed(t.ID, now)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !ok {
+		log.Printf("refresh token reuse for user %s, revoking session %s", t.UserID, t.FamilyID)
+		if err := u.endSession(t.UserID, t.FamilyID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	if err := u.sessions.Touch(t.FamilyID, client.IP, now); err != nil {
+		return TokenPair{}, err
+	}
+	return u.issueTokens(t.UserID, t.FamilyID)
+}
+
+// Logout ends the session that refreshToken belongs to, signing out the
+// device that holds it. Unknown tokens are ignored.
+func (u *userService) Logout(refreshToken string) error {
+	t, err := u.tokens.ByHash(hashToken(refreshToken))
+	if err != nil {
+		return nil
+	}
+	err = u.endSession(t.UserID, t.FamilyID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil // already signed out
+	}
+	return err
+}
+
+// newToken returns 256 random bits, URL-safe encoded.
+func newToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
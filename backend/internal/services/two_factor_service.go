@@ -0,0 +1,309 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/ratelimit"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/totp"
+)
+
+var (
+	ErrTwoFactorEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorDisabled = errors.New("two-factor authentication is not enabled")
+	// ErrInvalidCode is returned for wrong, reused or malformed codes.
+	ErrInvalidCode = errors.New("invalid code")
+	// ErrTooManyAttempts is wrapped by LockedError.
+	ErrTooManyAttempts = errors.New("too many attempts")
+)
+
+// LockedError is returned while code checks of a user are locked after
+// repeated wrong codes.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string { return ErrTooManyAttempts.Error() }
+func (e *LockedError) Unwrap() error { return ErrTooManyAttempts }
+
+const (
+	loginChallengeTTL = 5 * time.Minute
+	recoveryCodeCount = 10
+	totpSkew          = 1 // accept the codes of the neighbouring periods
+)
+
+// codeAttempts bounds guessing: six digits are too few to go unlimited.
+var codeAttempts = ratelimit.Lockout{MaxFailures: 5, Window: 15 * time.Minute, Duration: 15 * time.Minute}
+
+// LoginLockoutGroup is the rate-limit group of the lockout in front of
+// password logins.
+const LoginLockoutGroup = "login"
+
+// LoginFailuresKey is the key that lockout counts the wrong passwords for
+// email under. A password accepted with 2FA on leaves the count alone
+// until VerifyLogin completes the sign-in and clears it.
+func LoginFailuresKey(email string) string {
+	return LoginLockoutGroup + ":email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// TOTPEnrollment is what an authenticator app needs to start producing
+// codes. URI is usually shown as a QR code.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// LoginChallenge stands in for the tokens when the account has 2FA on;
+// it is exchanged together with a code through VerifyLogin.
+type LoginChallenge struct {
+	TwoFactorRequired bool      `json:"twoFactorRequired"`
+	ChallengeToken    string    `json:"challengeToken"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+}
+
+// LoginResult holds either Tokens or, for accounts with 2FA, a Challenge.
+type LoginResult struct {
+	Tokens    *TokenPair
+	Challenge *LoginChallenge
+}
+
+// startLogin finishes a login whose first factor (method) was correct: it
+// returns tokens right away, or a challenge when the user has 2FA enabled.
+func (u *userService) startLogin(userID, method string, client ClientInfo) (LoginResult, error) {
+	enabled, err := u.twoFactorEnabled(userID)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if enabled {
+		token, err := u.issueUserToken(userID, models.TokenLoginChallenge, loginChallengeTTL)
+		if err != nil {
+			return LoginResult{}, err
+		}
+		return LoginResult{Challenge: &LoginChallenge{
+			TwoFactorRequired: true,
+			ChallengeToken:    token,
+			ExpiresAt:         time.Now().Add(loginChallengeTTL),
+		}}, nil
+	}
+
+	sessionID, err := u.startSession(userID, client)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	tokens, err := u.issueTokens(userID, sessionID)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	u.auditLogin(AuditLogin, userID, client, map[string]string{"method": method, "session": sessionID})
+	return LoginResult{Tokens: &tokens}, nil
+}
+
+// VerifyLogin completes a login challenged for 2FA. code may be a TOTP
+// code or an unused recovery code. A wrong code leaves the challenge
+// usable until it expires; a right one clears the user's failed logins.
+func (u *userService) VerifyLogin(challengeToken, code string, client ClientInfo) (TokenPair, error) {
+	now := time.Now()
+	t, err := u.userTokens.Peek(hashToken(challengeToken), models.TokenLoginChallenge, now)
+	if err != nil {
+		return TokenPair{}, ErrInvalidToken
+	}
+	if err := u.checkCode(t.UserID, code, true); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			u.auditLogin(AuditLoginFailed, t.UserID, client, map[string]string{"reason": "wrong code"})
+		}
+		return TokenPair{}, err
+	}
+	if _, err := u.userTokens.Consume(hashToken(challengeToken), models.TokenLoginChallenge, now); err != nil {
+		return TokenPair{}, ErrInvalidToken
+	}
+
+	sessionID, err := u.startSession(t.UserID, client)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	tokens, err := u.issueTokens(t.UserID, sessionID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if user, err := u.users.ByID(t.UserID); err != nil {
+		log.Printf("VerifyLogin: loading %s: %v", t.UserID, err)
+	} else if err := u.rateLimits.ClearFailures(LoginFailuresKey(user.Email)); err != nil {
+		log.Printf("VerifyLogin: clearing failed logins of %s: %v", t.UserID, err)
+	}
+	u.auditLogin(AuditLogin, t.UserID, client, map[string]string{"method": "2fa", "session": sessionID})
+	return tokens, nil
+}
+
+// EnrollTOTP starts, or restarts, setting up an authenticator app. 2FA is
+// only enforced after ConfirmTOTP.
+func (u *userService) EnrollTOTP(userID string) (TOTPEnrollment, error) {
+	user, err := u.users.ByID(userID)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	if enabled, err := u.twoFactorEnabled(userID); err != nil {
+		return TOTPEnrollment{}, err
+	} else if enabled {
+		return TOTPEnrollment{}, ErrTwoFactorEnabled
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	if err := u.twoFactor.Save(&models.TOTP{UserID: userID, Secret: secret, CreatedAt: time.Now()}); err != nil {
+		return TOTPEnrollment{}, err
+	}
+	return TOTPEnrollment{Secret: secret, URI: totp.URI(u.opts.TOTPIssuer, user.Email, secret)}, nil
+}
+
+// ConfirmTOTP turns 2FA on once the app produced a matching code and
+// returns the recovery codes, which are never shown again.
+func (u *userService) ConfirmTOTP(userID, code string) ([]string, error) {
+	t, err := u.twoFactor.ByUser(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrTwoFactorDisabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.ConfirmedAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+	if err := u.checkCode(userID, code, false); err != nil {
+		return nil, err
+	}
+	if err := u.twoFactor.Confirm(userID, time.Now()); err != nil {
+		return nil, err
+	}
+	return u.newRecoveryCodes(userID)
+}
+
+// DisableTOTP turns 2FA off. It takes the password and a code so that a
+// stolen session alone cannot weaken the account.
+func (u *userService) DisableTOTP(userID, pass, code string) error {
+	user, err := u.users.ByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(pass)); err != nil {
+		return ErrWrongPassword
+	}
+	if enabled, err := u.twoFactorEnabled(userID); err != nil {
+		return err
+	} else if !enabled {
+		return ErrTwoFactorDisabled
+	}
+	if err := u.checkCode(userID, code, true); err != nil {
+		return err
+	}
+	return u.twoFactor.Delete(userID)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, used or not.
+func (u *userService) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	if enabled, err := u.twoFactorEnabled(userID); err != nil {
+		return nil, err
+	} else if !enabled {
+		return nil, ErrTwoFactorDisabled
+	}
+	if err := u.checkCode(userID, code, false); err != nil {
+		return nil, err
+	}
+	return u.newRecoveryCodes(userID)
+}
+
+func (u *userService) twoFactorEnabled(userID string) (bool, error) {
+	t, err := u.twoFactor.ByUser(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil && t.ConfirmedAt != nil, err
+}
+
+// checkCode verifies a TOTP code, or a recovery code when allowed, and
+// locks the user's code checks after too many misses.
+func (u *userService) checkCode(userID, code string, allowRecovery bool) error {
+	key := "2fa:user:" + userID
+	f, err := u.rateLimits.Failures(key)
+	if err != nil {
+		return err
+	}
+	if wait := f.Locked(time.Now()); wait > 0 {
+		return &LockedError{RetryAfter: wait}
+	}
+
+	ok, err := u.matchCode(userID, code, allowRecovery)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if _, err := u.rateLimits.AddFailure(key, codeAttempts, time.Now()); err != nil {
+			log.Printf("checkCode: counting failure of %s: %v", userID, err)
+		}
+		return ErrInvalidCode
+	}
+	if f.Count > 0 {
+		return u.rateLimits.ClearFailures(key)
+	}
+	return nil
+}
+
+func (u *userService) matchCode(userID, code string, allowRecovery bool) (bool, error) {
+	t, err := u.twoFactor.ByUser(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if step, ok := totp.Validate(t.Secret, code, now, totpSkew); ok {
+		// refuse a code that was already used, even within its period
+		return u.twoFactor.UseStep(userID, step)
+	}
+	if !allowRecovery {
+		return false, nil
+	}
+	err = u.twoFactor.UseRecoveryCode(userID, hashToken(normalizeRecoveryCode(code)), now)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCodes stores fresh recovery codes and returns them in clear,
+// formatted as "xxxxx-xxxxx".
+func (u *userService) newRecoveryCodes(userID string) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		c := strings.ToLower(recoveryEncoding.EncodeToString(raw))[:10]
+		codes[i] = c[:5] + "-" + c[5:]
+		hashes[i] = hashToken(c)
+	}
+	if err := u.twoFactor.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode accepts codes typed with or without the dash and
+// in either case.
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
@@ -2,17 +2,19 @@
 package services
 
 import (
-	//"database/sql"
 	"errors"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
-	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/auth"
-	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/db"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/mailer"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/oidc"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/password"
 )
 
 // ProfileUpdateInput matches your JSON input for updating profile.
@@ -21,6 +23,8 @@ type ProfileUpdateInput struct {
 	AvatarURL string   `json:"avatarUrl"`
 	Weight    *float64 `json:"weight"`
 	Height    *float64 `json:"height"`
+	// Timezone is an IANA zone name; empty keeps the current one.
+	Timezone string `json:"timezone"`
 }
 
 // UserProfile is what you return to the client.
@@ -31,6 +35,13 @@ type UserProfile struct {
 	AvatarURL string   `json:"avatarUrl"`
 	Weight    *float64 `json:"weight"`
 	Height    *float64 `json:"height"`
+	Timezone  string   `json:"timezone"`
+
+	Role             string `json:"role"`
+	EmailVerified    bool   `json:"emailVerified"`
+	TwoFactorEnabled bool   `json:"twoFactorEnabled"`
+	// DeleteAfter is set while the account is scheduled for deletion.
+	DeleteAfter *time.Time `json:"deleteAfter,omitempty"`
 }
 
 // Friend for client responses.
@@ -42,10 +53,11 @@ type Friend struct {
 
 // Achievement for client responses.
 type Achievement struct {
-	ID       string `json:"id"`
-	Title    string `json:"title"`
-	IconURL  string `json:"iconUrl"`
-	Unlocked bool   `json:"unlocked"`
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	IconURL     string `json:"iconUrl"`
+	Unlocked    bool   `json:"unlocked"`
 }
 
 type ActivityStats struct {
@@ -56,7 +68,28 @@ type ActivityStats struct {
 
 type UserService interface {
 	CreateUser(name, email, pass string) (string, error)
-	Authenticate(email, pass string) (string, error)
+	Authenticate(email, pass string, client ClientInfo) (LoginResult, error)
+	VerifyLogin(challengeToken, code string, client ClientInfo) (TokenPair, error)
+	OIDCProviders() []string
+	StartOIDC(provider string) (string, error)
+	FinishOIDC(provider, state, code string, client ClientInfo) (LoginResult, error)
+	ListIdentities(userID string) ([]models.Identity, error)
+	UnlinkIdentity(userID, id string) error
+	EnrollTOTP(userID string) (TOTPEnrollment, error)
+	ConfirmTOTP(userID, code string) ([]string, error)
+	DisableTOTP(userID, pass, code string) error
+	RegenerateRecoveryCodes(userID, code string) ([]string, error)
+	RefreshToken(refreshToken string, client ClientInfo) (TokenPair, error)
+	Logout(refreshToken string) error
+	CheckSession(sessionID, ip string) error
+	ListSessions(userID, currentID string) ([]Session, error)
+	RevokeSession(userID, sessionID string) error
+	SignOutEverywhere(userID string) error
+	RequestPasswordReset(email string)
+	ResetPassword(token, newPassword string) error
+	SendVerificationEmail(userID string) error
+	VerifyEmail(token string) error
+	ChangePassword(userID, sessionID, current, next string) error
 	GetProfile(userID string) (UserProfile, error)
 	UpdateProfile(userID string, in ProfileUpdateInput) error
 	SendFriendRequest(userID, friendEmail string) error
@@ -69,7 +102,9 @@ type UserService interface {
 	ListAllAchievements() ([]Achievement, error)
 	AwardAchievementToUserID(userID, title string) error
 
-	AddActivity(userID, activityType, name string, duration int, intensity string, calories int, location string) error
+	// AddActivity logs an activity and returns it. A key the user already
+	// logged an activity under returns that one instead.
+	AddActivity(userID, activityType, name string, duration int, intensity string, calories int, location string, distance int, idempotencyKey string) (models.Activity, error)
 	ListActivities(userID string, filterType *string) ([]models.Activity, error)
 
 	SetActivityGoal(userID string, goal int) error
@@ -80,64 +115,151 @@ type UserService interface {
 	IDByEmail(email string) (string, error)
 }
 
-// userService holds the DB handle.
+// userService works on the user-owned repositories.
 type userService struct {
+	users        repository.UserRepository
+	friends      repository.FriendRepository
+	achievements repository.AchievementRepository
+	activities   repository.ActivityRepository
+	goals        repository.GoalRepository
+	tokens       repository.RefreshTokenRepository
+	sessions     repository.SessionRepository
+	userTokens   repository.UserTokenRepository
+	twoFactor    repository.TwoFactorRepository
+	identities   repository.IdentityRepository
+	rateLimits   repository.RateLimitRepository
+	audit        AuditService
+	mail         mailer.Mailer
+	opts         UserOptions
+}
+
+// UserOptions tunes the UserService.
+type UserOptions struct {
+	RefreshTokenTTL time.Duration
+	AppURL          string // base of the links in mailed tokens
+	PasswordPolicy  *password.Policy
+	TOTPIssuer      string // shown next to the codes in authenticator apps
+	// AdminEmails are made admins once they verify the address.
+	AdminEmails []string
+	// OIDC are the external sign-in providers by name.
+	OIDC map[string]*oidc.Provider
 }
 
-// User is the exported singleton service.
-var User UserService = &userService{}
+// User is the exported singleton service, set up by Use.
+var User UserService
+
+// NewUserService builds the UserService on top of the repositories in
+// store, mailing tokens through mail.
+func NewUserService(store *repository.Store, mail mailer.Mailer, opts UserOptions) UserService {
+	return &userService{
+		users:        store.Users,
+		friends:      store.Friends,
+		achievements: store.Achievements,
+		activities:   store.Activities,
+		goals:        store.Goals,
+		tokens:       store.RefreshTokens,
+		sessions:     store.Sessions,
+		userTokens:   store.UserTokens,
+		twoFactor:    store.TwoFactor,
+		identities:   store.Identities,
+		rateLimits:   store.RateLimits,
+		audit:        NewAuditService(store.Audit),
+		mail:         mail,
+		opts:         opts,
+	}
+}
 
 // CreateUser inserts a new user (with hashed password) into the DB.
 func (u *userService) CreateUser(name, email, pass string) (string, error) {
+	if err := u.opts.PasswordPolicy.Check(pass, email); err != nil {
+		return "", err
+	}
 	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
 	if err != nil {
 		return "", err
 	}
 
-	// model.User has db tags matching your users table.
 	newUser := models.User{
 		ID:           uuid.NewString(),
 		Name:         name,
 		Email:        email,
 		PasswordHash: string(hash),
+		Role:         models.RoleUser,
 	}
+	if err := u.users.Create(&newUser); errors.Is(err, repository.ErrConflict) {
+		return "", ErrEmailTaken
+	} else if err != nil {
+		return "", err
+	}
+	if err := u.SendVerificationEmail(newUser.ID); err != nil {
+		log.Printf("CreateUser: verification mail to %s failed: %v", email, err)
+	}
+	return newUser.ID, nil
+}
 
-	_, err = db.DB.NamedExec(`
-		INSERT INTO users (id, name, email, password_hash, avatar_url)
-		VALUES (:id, :name, :email, :password_hash, :avatar_url)
-	`, &newUser)
-	return newUser.ID, err
-}
-
-// Authenticate verifies credentials and returns a JWT on success.
-func (u *userService) Authenticate(email, pass string) (string, error) {
-	var user models.User
-	err := db.DB.Get(&user, `
-		SELECT id, password_hash 
-		FROM users 
-		WHERE email = $1
-	`, email)
+// Authenticate verifies credentials. With 2FA off it starts a session for
+// the client and returns tokens; with 2FA on it returns a challenge for
+// VerifyLogin instead.
+func (u *userService) Authenticate(email, pass string, client ClientInfo) (LoginResult, error) {
+	user, err := u.users.ByEmail(email)
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		// Spend the same bcrypt time as for a real account so response
+		// times do not reveal which emails are registered.
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(pass))
+		u.auditLogin(AuditLoginFailed, "", client, map[string]string{"email": email, "reason": "unknown email"})
+		return LoginResult{}, ErrInvalidCredentials
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(pass)); err != nil {
-		return "", errors.New("invalid credentials")
+		u.auditLogin(AuditLoginFailed, user.ID, client, map[string]string{"email": email, "reason": "wrong password"})
+		return LoginResult{}, ErrInvalidCredentials
+	}
+	if user.SuspendedAt != nil {
+		u.auditLogin(AuditLoginFailed, user.ID, client, map[string]string{"email": email, "reason": "suspended"})
+		return LoginResult{}, ErrAccountSuspended
 	}
+	if err := u.promoteAdmin(user); err != nil {
+		return LoginResult{}, err
+	}
+	return u.startLogin(user.ID, "password", client)
+}
 
-	// Generate JWT with your pkg/auth
-	token, err := auth.GenerateToken(auth.Claims{UserID: user.ID})
-	return token, err
+// auditLogin records a sign-in attempt; userID is empty for unknown emails.
+func (u *userService) auditLogin(action, userID string, client ClientInfo, details map[string]string) {
+	u.audit.Record(AuditEntry{
+		ActorID:   userID,
+		SubjectID: userID,
+		Action:    action,
+		Client:    client,
+		After:     details,
+	})
+}
+
+// promoteAdmin gives user the admin role when their email is one of
+// AdminEmails and they proved they own it.
+func (u *userService) promoteAdmin(user models.User) error {
+	if user.EmailVerifiedAt == nil || user.Role == models.RoleAdmin || !u.isAdminEmail(user.Email) {
+		return nil
+	}
+	return u.users.SetRole(user.ID, models.RoleAdmin)
+}
+
+func (u *userService) isAdminEmail(email string) bool {
+	for _, admin := range u.opts.AdminEmails {
+		if strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+	return false
 }
 
 // GetProfile loads the user's profile.
 func (u *userService) GetProfile(userID string) (UserProfile, error) {
-	var user models.User
-	err := db.DB.Get(&user, `
-		SELECT id, name, email, avatar_url, weight, height 
-		FROM users 
-		WHERE id = $1
-	`, userID)
+	user, err := u.users.ByID(userID)
+	if err != nil {
+		return UserProfile{}, err
+	}
+	twoFactor, err := u.twoFactorEnabled(userID)
 	if err != nil {
 		return UserProfile{}, err
 	}
@@ -149,16 +271,29 @@ func (u *userService) GetProfile(userID string) (UserProfile, error) {
 		AvatarURL: user.AvatarURL,
 		Weight:    user.Weight,
 		Height:    user.Height,
+		Timezone:  user.Timezone,
+
+		Role:             user.Role,
+		EmailVerified:    user.EmailVerifiedAt != nil,
+		TwoFactorEnabled: twoFactor,
+		DeleteAfter:      user.DeleteAfter,
 	}, nil
 }
 
-// UpdateProfile updates name & email for the given user.
+// UpdateProfile updates name, avatar, body measurements and time zone for
+// the given user.
 func (u *userService) UpdateProfile(userID string, in ProfileUpdateInput) error {
-	_, err := db.DB.Exec(`
-		UPDATE users 
-		SET name = $1, avatar_url = $2, weight = $3, height = $4
-		WHERE id = $5
-	`, in.Name, in.AvatarURL, in.Weight, in.Height, userID)
+	if in.Timezone != "" && !validTimezone(in.Timezone) {
+		return ErrInvalidTimezone
+	}
+	err := u.users.UpdateProfile(&models.User{
+		ID:        userID,
+		Name:      in.Name,
+		AvatarURL: in.AvatarURL,
+		Weight:    in.Weight,
+		Height:    in.Height,
+		Timezone:  in.Timezone,
+	})
 	if err != nil {
 		log.Printf("UpdateProfile error: %v", err)
 	}
@@ -167,213 +302,163 @@ func (u *userService) UpdateProfile(userID string, in ProfileUpdateInput) error
 
 // SendFriendRequest creates a friend_request from the current user to another.
 func (u *userService) SendFriendRequest(userID, friendEmail string) error {
-	// 1) find recipient's ID
-	var recipientID string
-	err := db.DB.Get(&recipientID, `
-		SELECT id 
-		FROM users 
-		WHERE email = $1
-	`, friendEmail)
+	recipient, err := u.users.ByEmail(friendEmail)
 	if err != nil {
 		return errors.New("user not found")
 	}
 
-	// 2) insert into friend_requests
-	_, err = db.DB.Exec(`
-		INSERT INTO friend_requests (id, requester_id, recipient_id, created_at)
-		VALUES ($1, $2, $3, $4)
-	`, uuid.NewString(), userID, recipientID, time.Now())
-	return err
+	return u.friends.CreateRequest(&models.FriendRequest{
+		ID:          uuid.NewString(),
+		RequesterID: userID,
+		RecipientID: recipient.ID,
+		CreatedAt:   time.Now(),
+	})
 }
 
 // ListFriends returns the list of accepted friends for a user.
-// Assumes you have a 'friends' table populated elsewhere.
 func (u *userService) ListFriends(userID string) ([]Friend, error) {
+	users, err := u.friends.ListFriends(userID)
+	if err != nil {
+		return nil, err
+	}
 	var friends []Friend
-	err := db.DB.Select(&friends, `
-		SELECT u.id, u.name, u.email
-		FROM friends f
-		JOIN users u ON u.id = f.friend_id
-		WHERE f.user_id = $1
-	`, userID)
-	return friends, err
+	for _, f := range users {
+		friends = append(friends, Friend{ID: f.ID, Name: f.Name, Email: f.Email})
+	}
+	return friends, nil
 }
 
 // List only unlocked achievements for the user
 func (u *userService) ListUnlockedAchievements(userID string) ([]Achievement, error) {
-	var achievements []Achievement
-	err := db.DB.Select(&achievements, `
-        SELECT a.id, a.title, true as unlocked
-        FROM achievements a
-        JOIN user_achievements ua ON a.id = ua.achievement_id
-        WHERE ua.user_id = $1
-    `, userID)
-	return achievements, err
+	list, err := u.achievements.ListUnlocked(userID)
+	return toAchievements(list, true), err
 }
 
 // List all possible achievements (for the "all achievements" page)
 func (u *userService) ListAllAchievements() ([]Achievement, error) {
-	var achievements []Achievement
-	err := db.DB.Select(&achievements, `
-        SELECT a.id, a.title, false as unlocked
-        FROM achievements a
-    `)
-	return achievements, err
+	list, err := u.achievements.List()
+	return toAchievements(list, false), err
+}
+
+func toAchievements(list []models.Achievement, unlocked bool) []Achievement {
+	var out []Achievement
+	for _, a := range list {
+		out = append(out, Achievement{
+			ID: a.ID, Title: a.Title, Description: a.Description, IconURL: a.IconURL, Unlocked: unlocked,
+		})
+	}
+	return out
 }
 
 func (u *userService) AwardAchievementToUserID(userID, title string) error {
-	var achID string
-	err := db.DB.Get(&achID, `SELECT id FROM achievements WHERE title = $1`, title)
+	ach, err := u.achievements.ByTitle(title)
 	if err != nil {
 		return err
 	}
-	// Check if already awarded to avoid duplicate
-	var exists bool
-	err = db.DB.Get(&exists, `SELECT EXISTS (
-        SELECT 1 FROM user_achievements WHERE user_id = $1 AND achievement_id = $2
-    )`, userID, achID)
-	if err == nil && exists {
-		return nil
-	}
-	// Insert award
-	_, err = db.DB.Exec(`
-        INSERT INTO user_achievements (user_id, achievement_id)
-        VALUES ($1, $2)
-    `, userID, achID)
-	return err
+	return u.achievements.Award(userID, ach.ID, "")
 }
 
-func (s *userService) AddActivity(userID, activityType string, name string, duration int, intensity string, calories int, location string) error {
-	_, err := db.DB.Exec(`
-        INSERT INTO activities (user_id, type, name, duration, intensity, calories, location)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
-    `, userID, activityType, name, duration, intensity, calories, location)
-	return err
+func (s *userService) AddActivity(userID, activityType string, name string, duration int, intensity string, calories int, location string, distance int, idempotencyKey string) (models.Activity, error) {
+	a := models.Activity{
+		UserID:         userID,
+		Type:           activityType,
+		Name:           name,
+		Duration:       duration,
+		Intensity:      intensity,
+		Calories:       calories,
+		Location:       location,
+		Distance:       distance,
+		IdempotencyKey: idempotencyKey,
+	}
+	err := s.activities.Create(&a)
+	return a, err
 }
 
 func (s *userService) ListActivities(userID string, filterType *string) ([]models.Activity, error) {
-	var activities []models.Activity
-	query := `SELECT * FROM activities WHERE user_id = $1`
-	args := []interface{}{userID}
-	if filterType != nil {
-		query += ` AND type = $2`
-		args = append(args, *filterType)
-	}
-	query += ` ORDER BY performed_at DESC`
-	err := db.DB.Select(&activities, query, args...)
-	return activities, err
+	return s.activities.List(userID, filterType)
 }
 
 func (s *userService) SetActivityGoal(userID string, goal int) error {
-	_, err := db.DB.Exec(`
-    INSERT INTO activity_goals (user_id, goal)
-    VALUES ($1, $2)
-    ON CONFLICT (user_id) DO UPDATE SET goal = EXCLUDED.goal, updated_at = now()
-  `, userID, goal)
-	return err
+	return s.goals.Set(userID, models.GoalActivity, goal)
 }
 
 func (s *userService) GetActivityGoal(userID string) (int, error) {
-	var goal int
-	err := db.DB.Get(&goal, `SELECT goal FROM activity_goals WHERE user_id = $1`, userID)
-	if err != nil {
-		return 500, nil
-	}
-	return goal, nil
+	return goalOrDefault(s.goals, userID, models.GoalActivity, DefaultActivityGoal)
 }
 
 func (s *userService) GetTodayCalories(userID string) (int, error) {
-	var total int
-	err := db.DB.Get(&total, `
-    SELECT COALESCE(SUM(calories), 0)
-    FROM activities
-    WHERE user_id = $1
-      AND DATE(performed_at) = CURRENT_DATE
-  `, userID)
-
+	from := startOfDay(time.Now(), userZone(s.users, userID))
+	list, err := s.activities.ListBetween(userID, from, from.AddDate(0, 0, 1))
 	if err != nil {
 		return 0, err
 	}
+	total := 0
+	for _, a := range list {
+		total += a.Calories
+	}
 	return total, nil
 }
 
 func (s *userService) GetWeeklyStats(userID string) ([]ActivityStats, error) {
-	var stats []ActivityStats
-	query := `
-		SELECT
-			TO_CHAR(performed_at::date, 'YYYY-MM-DD') AS date,
-			COALESCE(SUM(calories), 0) AS calories,
-			COALESCE(SUM(duration), 0) AS duration
-		FROM activities
-		WHERE user_id = $1
-		  AND performed_at >= NOW() - INTERVAL '6 days'
-		GROUP BY performed_at::date
-		ORDER BY performed_at::date
-	`
-	if err := db.DB.Select(&stats, query, userID); err != nil {
+	loc := userZone(s.users, userID)
+	today := startOfDay(time.Now(), loc)
+	list, err := s.activities.ListBetween(userID, today.AddDate(0, 0, -6), today.AddDate(0, 0, 1))
+	if err != nil {
 		log.Printf("Error fetching weekly activity stats for user %s: %v", userID, err)
 		return nil, err
 	}
+
+	var stats []ActivityStats
+	for _, a := range list {
+		date := dayKey(a.PerformedAt, loc)
+		if n := len(stats); n == 0 || stats[n-1].Date != date {
+			stats = append(stats, ActivityStats{Date: date})
+		}
+		stats[len(stats)-1].Calories += float64(a.Calories)
+		stats[len(stats)-1].Duration += float64(a.Duration)
+	}
 	return stats, nil
 }
 
 func (u *userService) AcceptFriendRequest(userID, requestID string) error {
 	// 1. Check request exists and user is recipient
-	var requesterID string
-	err := db.DB.Get(&requesterID, `
-		SELECT requester_id FROM friend_requests
-		WHERE id = $1 AND recipient_id = $2
-	`, requestID, userID)
+	req, err := u.friends.IncomingRequest(requestID, userID)
 	if err != nil {
 		return errors.New("request not found")
 	}
 
 	// 2. Add both friends to the 'friends' table (bi-directional)
-	_, err = db.DB.Exec(`
-		INSERT INTO friends (user_id, friend_id) VALUES ($1, $2), ($2, $1)
-	`, userID, requesterID)
-	if err != nil {
+	if err := u.friends.AddFriendship(userID, req.RequesterID); err != nil {
 		return err
 	}
 
 	// 3. Delete the request
-	_, err = db.DB.Exec(`DELETE FROM friend_requests WHERE id = $1`, requestID)
-	return err
+	return u.friends.DeleteRequest(requestID, userID)
 }
 
 func (u *userService) DeclineFriendRequest(userID, requestID string) error {
 	// Just delete the request if user is recipient
-	_, err := db.DB.Exec(`
-		DELETE FROM friend_requests
-		WHERE id = $1 AND recipient_id = $2
-	`, requestID, userID)
-	return err
+	return u.friends.DeleteRequest(requestID, userID)
 }
 
 // Returns friend requests where current user is recipient
 func (u *userService) ListPendingFriendRequests(userID string) ([]Friend, error) {
+	list, err := u.friends.ListIncoming(userID)
+	if err != nil {
+		return nil, err
+	}
 	var requests []Friend
-	err := db.DB.Select(&requests, `
-		SELECT fr.id as id, u.name, u.email
-		FROM friend_requests fr
-		JOIN users u ON u.id = fr.requester_id
-		WHERE fr.recipient_id = $1
-	`, userID)
-	return requests, err
+	for _, r := range list {
+		requests = append(requests, Friend{ID: r.ID, Name: r.Name, Email: r.Email})
+	}
+	return requests, nil
 }
 
 func (u *userService) GetFriendIDs(userID string) ([]string, error) {
-	var ids []string
-	err := db.DB.Select(&ids, `
-		SELECT friend_id
-		FROM friends
-		WHERE user_id = $1
-	`, userID)
-	return ids, err
+	return u.friends.FriendIDs(userID)
 }
 
 func (s *userService) IDByEmail(email string) (string, error) {
-	var id string
-	err := db.DB.Get(&id, `SELECT id FROM users WHERE email=$1`, email)
-	return id, err
+	user, err := s.users.ByEmail(email)
+	return user.ID, err
 }
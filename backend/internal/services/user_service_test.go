@@ -0,0 +1,169 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/models"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/repository/memory"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/auth"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/mailer"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/ratelimit"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/totp"
+)
+
+// fakeMailer keeps the messages instead of sending them.
+type fakeMailer struct {
+	sent []mailer.Message
+}
+
+func (m *fakeMailer) Send(msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+const testPassword = "correct-horse-battery"
+
+var testClient = ClientInfo{UserAgent: "test", IP: "10.0.0.1"}
+
+func newTestUserService(t *testing.T) (*userService, *repository.Store) {
+	t.Helper()
+	t.Setenv("STORAGE", config.StorageMemory) // tokens are signed with the config
+	store := memory.NewStore()
+	svc := NewUserService(store, &fakeMailer{}, UserOptions{RefreshTokenTTL: time.Hour}).(*userService)
+	return svc, store
+}
+
+// enableTOTP turns 2FA on for userID and returns the secret.
+func enableTOTP(t *testing.T, svc *userService, userID string) string {
+	t.Helper()
+	enrollment, err := svc.EnrollTOTP(userID)
+	require.NoError(t, err)
+	code, err := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	_, err = svc.ConfirmTOTP(userID, code)
+	require.NoError(t, err)
+	return enrollment.Secret
+}
+
+func TestAuthenticate(t *testing.T) {
+	svc, _ := newTestUserService(t)
+	id, err := svc.CreateUser("Ann", "ann@test.com", testPassword)
+	require.NoError(t, err)
+
+	_, err = svc.Authenticate("ann@test.com", "wrong-password", testClient)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = svc.Authenticate("nobody@test.com", testPassword, testClient)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	res, err := svc.Authenticate("ann@test.com", testPassword, testClient)
+	require.NoError(t, err)
+	require.NotNil(t, res.Tokens)
+	assert.Nil(t, res.Challenge)
+	claims, err := auth.ParseToken(res.Tokens.Token)
+	require.NoError(t, err)
+	assert.Equal(t, id, claims.UserID)
+	assert.NotEmpty(t, claims.SessionID)
+}
+
+func TestAuthenticate_PromotesOnlyVerifiedAdminEmails(t *testing.T) {
+	svc, store := newTestUserService(t)
+	svc.opts.AdminEmails = []string{"Root@test.com"}
+	id, err := svc.CreateUser("Root", "root@test.com", testPassword)
+	require.NoError(t, err)
+
+	_, err = svc.Authenticate("root@test.com", testPassword, testClient)
+	require.NoError(t, err)
+	user, err := store.Users.ByID(id)
+	require.NoError(t, err)
+	assert.NotEqual(t, models.RoleAdmin, user.Role, "the address is not proven yet")
+
+	require.NoError(t, store.Users.MarkEmailVerified(id, time.Now()))
+	res, err := svc.Authenticate("root@test.com", testPassword, testClient)
+	require.NoError(t, err)
+	claims, err := auth.ParseToken(res.Tokens.Token)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleAdmin, claims.Role)
+}
+
+func TestVerifyLogin(t *testing.T) {
+	svc, store := newTestUserService(t)
+	id, err := svc.CreateUser("Ann", "ann@test.com", testPassword)
+	require.NoError(t, err)
+	secret := enableTOTP(t, svc, id)
+
+	// a failed password attempt is on record until the second factor
+	// completes the sign-in
+	key := LoginFailuresKey("ann@test.com")
+	policy := ratelimit.Lockout{MaxFailures: 5, Window: time.Minute, Duration: time.Minute}
+	_, err = store.RateLimits.AddFailure(key, policy, time.Now())
+	require.NoError(t, err)
+
+	res, err := svc.Authenticate("ann@test.com", testPassword, testClient)
+	require.NoError(t, err)
+	assert.Nil(t, res.Tokens)
+	require.NotNil(t, res.Challenge)
+	assert.True(t, res.Challenge.TwoFactorRequired)
+
+	_, err = svc.VerifyLogin(res.Challenge.ChallengeToken, "000000", testClient)
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	f, err := store.RateLimits.Failures(key)
+	require.NoError(t, err)
+	assert.Equal(t, 1, f.Count)
+
+	// a wrong code leaves the challenge usable
+	code, err := totp.Code(secret, totp.Step(time.Now().Add(30*time.Second)))
+	require.NoError(t, err)
+	tokens, err := svc.VerifyLogin(res.Challenge.ChallengeToken, code, testClient)
+	require.NoError(t, err)
+	claims, err := auth.ParseToken(tokens.Token)
+	require.NoError(t, err)
+	assert.Equal(t, id, claims.UserID)
+	f, err = store.RateLimits.Failures(key)
+	require.NoError(t, err)
+	assert.Zero(t, f.Count)
+
+	// the challenge works once
+	_, err = svc.VerifyLogin(res.Challenge.ChallengeToken, code, testClient)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = svc.VerifyLogin("forged", code, testClient)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestRefreshToken(t *testing.T) {
+	svc, _ := newTestUserService(t)
+	_, err := svc.CreateUser("Ann", "ann@test.com", testPassword)
+	require.NoError(t, err)
+	res, err := svc.Authenticate("ann@test.com", testPassword, testClient)
+	require.NoError(t, err)
+	first := *res.Tokens
+
+	second, err := svc.RefreshToken(first.RefreshToken, testClient)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	claims, err := auth.ParseToken(second.Token)
+	require.NoError(t, err)
+	session := claims.SessionID
+
+	third, err := svc.RefreshToken(second.RefreshToken, testClient)
+	require.NoError(t, err)
+	claims, err = auth.ParseToken(third.Token)
+	require.NoError(t, err)
+	assert.Equal(t, session, claims.SessionID, "refreshing stays in the session")
+
+	// replaying a used token revokes the session, and with it the newest
+	// token
+	_, err = svc.RefreshToken(first.RefreshToken, testClient)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	_, err = svc.RefreshToken(third.RefreshToken, testClient)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	assert.Error(t, svc.CheckSession(session, testClient.IP))
+
+	_, err = svc.RefreshToken("unknown", testClient)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
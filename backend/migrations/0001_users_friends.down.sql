@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS friends;
+DROP TABLE IF EXISTS friend_requests;
+DROP TABLE IF EXISTS user_achievements;
+DROP TABLE IF EXISTS achievements;
+DROP TABLE IF EXISTS users;
+DROP EXTENSION IF EXISTS "uuid-ossp";
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS step_goals;
+DROP TABLE IF EXISTS user_steps;
+DROP TABLE IF EXISTS activity_goals;
+DROP TABLE IF EXISTS activities;
+DROP TYPE IF EXISTS activity_type;
@@ -0,0 +1,35 @@
+CREATE TYPE activity_type AS ENUM ('running', 'swimming', 'cycling', 'yoga');
+
+CREATE TABLE activities (
+  id           UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type         activity_type NOT NULL,
+  name         TEXT NOT NULL,       -- custom name from user
+  duration     INT,
+  intensity    TEXT,
+  calories     INT,
+  location     TEXT,
+  performed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX activities_user_performed_idx ON activities (user_id, performed_at DESC);
+
+CREATE TABLE activity_goals (
+  user_id    UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  goal       INT NOT NULL DEFAULT 500,
+  updated_at TIMESTAMP DEFAULT now()
+);
+
+CREATE TABLE user_steps (
+  id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  steps      INT NOT NULL,
+  day        DATE NOT NULL,   -- Store as date for daily stats
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (user_id, day)
+);
+
+CREATE TABLE step_goals (
+  user_id    UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  goal       INT NOT NULL DEFAULT 10000,
+  updated_at TIMESTAMP DEFAULT now()
+);
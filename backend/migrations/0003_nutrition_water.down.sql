@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS water_goals;
+DROP TABLE IF EXISTS water_logs;
+DROP TABLE IF EXISTS calorie_goals;
+DROP TABLE IF EXISTS meals;
@@ -0,0 +1,34 @@
+CREATE TABLE meals (
+  id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  fdc_id      INTEGER,
+  description TEXT,
+  calories    DOUBLE PRECISION,
+  protein     DOUBLE PRECISION,
+  fat         DOUBLE PRECISION,
+  carbs       DOUBLE PRECISION,
+  quantity    DOUBLE PRECISION,
+  unit        TEXT,
+  created_at  TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX meals_user_created_idx ON meals (user_id, created_at DESC);
+
+CREATE TABLE calorie_goals (
+  user_id    UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  goal       INT NOT NULL DEFAULT 2000,
+  updated_at TIMESTAMP DEFAULT now()
+);
+
+CREATE TABLE water_logs (
+  id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  amount_ml  INT NOT NULL,
+  created_at TIMESTAMP DEFAULT now()
+);
+CREATE INDEX water_logs_user_created_idx ON water_logs (user_id, created_at DESC);
+
+CREATE TABLE water_goals (
+  user_id    UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  goal_ml    INT NOT NULL DEFAULT 2000,
+  updated_at TIMESTAMP DEFAULT now()
+);
@@ -0,0 +1,18 @@
+CREATE TABLE post_activities (
+  id         UUID PRIMARY KEY,
+  user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type       TEXT NOT NULL,
+  message    TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX post_activities_user_created_idx ON post_activities (user_id, created_at DESC);
+
+CREATE TABLE messages (
+  id          UUID PRIMARY KEY,
+  sender_id   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  receiver_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  text        TEXT NOT NULL,
+  created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+CREATE INDEX messages_pair_created_idx ON messages (sender_id, receiver_id, created_at);
+CREATE INDEX messages_receiver_idx ON messages (receiver_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS ua_unique_without_challenge;
+DROP INDEX IF EXISTS ua_unique;
+ALTER TABLE user_achievements DROP COLUMN IF EXISTS challenge_id;
+
+DROP TABLE IF EXISTS challenge_participants;
+DROP TABLE IF EXISTS challenges;
@@ -0,0 +1,28 @@
+CREATE TABLE challenges (
+  id           UUID PRIMARY KEY,
+  creator_id   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type         VARCHAR(32)  NOT NULL CHECK (type IN ('steps','workouts','calories')),
+  target       INT          NOT NULL CHECK (target > 0),   -- steps / workouts / kcal
+  title        TEXT         NOT NULL,
+  created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+CREATE INDEX challenges_creator_idx ON challenges (creator_id);
+
+CREATE TABLE challenge_participants (
+  challenge_id UUID NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+  user_id      UUID NOT NULL REFERENCES users(id)      ON DELETE CASCADE,
+  progress     INT  NOT NULL DEFAULT 0,
+  joined_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (challenge_id, user_id)
+);
+CREATE INDEX challenge_participants_user_idx ON challenge_participants (user_id);
+
+-- Achievements earned by completing a challenge point back at it; the
+-- same achievement can be earned once per challenge and once without one.
+ALTER TABLE user_achievements
+  ADD COLUMN challenge_id UUID REFERENCES challenges(id) ON DELETE CASCADE;
+
+CREATE UNIQUE INDEX ua_unique
+  ON user_achievements (user_id, achievement_id, challenge_id);
+CREATE UNIQUE INDEX ua_unique_without_challenge
+  ON user_achievements (user_id, achievement_id) WHERE challenge_id IS NULL;
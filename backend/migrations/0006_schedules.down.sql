@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS hydration_settings;
+DROP TABLE IF EXISTS workout_schedules;
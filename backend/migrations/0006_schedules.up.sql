@@ -0,0 +1,15 @@
+CREATE TABLE workout_schedules (
+  id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  weekday     INT  NOT NULL CHECK (weekday BETWEEN 0 AND 6),  -- 0 = Monday … 6 = Sunday
+  at_time     TIME NOT NULL,                                  -- 24-h HH:MM:SS
+  title       TEXT NOT NULL,
+  created_at  TIMESTAMPTZ DEFAULT now()
+);
+CREATE INDEX workout_schedules_user_idx ON workout_schedules (user_id);
+CREATE INDEX workout_schedules_slot_idx ON workout_schedules (weekday, at_time);
+
+CREATE TABLE hydration_settings (
+  user_id   UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  interval  INT  NOT NULL DEFAULT 7 -- minutes
+);
@@ -0,0 +1,15 @@
+-- Refresh tokens are opaque random strings; only their SHA-256 is stored.
+-- Every rotation issues a new row in the same family, so reuse of an old
+-- token can revoke the whole chain.
+CREATE TABLE refresh_tokens (
+  id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  family_id   UUID NOT NULL,
+  token_hash  TEXT NOT NULL UNIQUE,
+  expires_at  TIMESTAMPTZ NOT NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  used_at     TIMESTAMPTZ,   -- set once the token was exchanged for a new one
+  revoked_at  TIMESTAMPTZ
+);
+CREATE INDEX refresh_tokens_user_idx   ON refresh_tokens (user_id);
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens (family_id);
@@ -0,0 +1,23 @@
+-- One row per signed-in device. The refresh token family of a login is
+-- keyed by its session id.
+CREATE TABLE sessions (
+  id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id       UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  user_agent    TEXT NOT NULL DEFAULT '',
+  ip            TEXT NOT NULL DEFAULT '',
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_seen_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  revoked_at    TIMESTAMPTZ
+);
+CREATE INDEX sessions_user_idx ON sessions (user_id);
+
+-- Families issued before sessions existed become sessions without device info.
+INSERT INTO sessions (id, user_id, created_at, last_seen_at, revoked_at)
+SELECT family_id, user_id, MIN(created_at), MAX(created_at),
+       CASE WHEN bool_and(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM refresh_tokens
+GROUP BY family_id, user_id;
+
+ALTER TABLE refresh_tokens
+  ADD CONSTRAINT refresh_tokens_session_fk
+  FOREIGN KEY (family_id) REFERENCES sessions(id) ON DELETE CASCADE;
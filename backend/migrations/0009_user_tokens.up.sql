@@ -0,0 +1,14 @@
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
+
+-- Single-use tokens mailed to the user (password reset, email
+-- verification). Only the SHA-256 of the token is stored.
+CREATE TABLE user_tokens (
+  id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  purpose     TEXT NOT NULL CHECK (purpose IN ('password_reset', 'email_verify')),
+  token_hash  TEXT NOT NULL UNIQUE,
+  expires_at  TIMESTAMPTZ NOT NULL,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  used_at     TIMESTAMPTZ
+);
+CREATE INDEX user_tokens_user_idx ON user_tokens (user_id, purpose);
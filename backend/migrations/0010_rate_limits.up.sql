@@ -0,0 +1,16 @@
+-- Token buckets of the rate-limit middleware, keyed by route group and
+-- client (IP, user or email), so limits survive restarts.
+CREATE TABLE rate_limit_buckets (
+  key         TEXT PRIMARY KEY,
+  tokens      DOUBLE PRECISION NOT NULL,
+  updated_at  TIMESTAMPTZ NOT NULL
+);
+
+-- Failed sign-in attempts per account; locked_until is set once too many
+-- failures fall within one window.
+CREATE TABLE auth_failures (
+  key           TEXT PRIMARY KEY,
+  count         INTEGER NOT NULL DEFAULT 0,
+  window_start  TIMESTAMPTZ NOT NULL,
+  locked_until  TIMESTAMPTZ
+);
@@ -0,0 +1,7 @@
+DELETE FROM user_tokens WHERE purpose = 'login_challenge';
+ALTER TABLE user_tokens DROP CONSTRAINT user_tokens_purpose_check;
+ALTER TABLE user_tokens ADD CONSTRAINT user_tokens_purpose_check
+  CHECK (purpose IN ('password_reset', 'email_verify'));
+
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS user_totp;
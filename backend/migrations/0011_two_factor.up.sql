@@ -0,0 +1,22 @@
+-- Authenticator app enrollment; logins need a code once confirmed_at is set.
+CREATE TABLE user_totp (
+  user_id       UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  secret        TEXT NOT NULL,
+  confirmed_at  TIMESTAMPTZ,
+  last_step     BIGINT NOT NULL DEFAULT 0,
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One-time recovery codes, stored as SHA-256.
+CREATE TABLE recovery_codes (
+  id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash  TEXT NOT NULL,
+  used_at    TIMESTAMPTZ,
+  UNIQUE (user_id, code_hash)
+);
+
+-- The second login step is a short-lived user token.
+ALTER TABLE user_tokens DROP CONSTRAINT user_tokens_purpose_check;
+ALTER TABLE user_tokens ADD CONSTRAINT user_tokens_purpose_check
+  CHECK (purpose IN ('password_reset', 'email_verify', 'login_challenge'));
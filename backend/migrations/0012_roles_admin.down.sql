@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS admin_actions;
+ALTER TABLE achievements DROP COLUMN IF EXISTS icon_url;
+ALTER TABLE achievements DROP COLUMN IF EXISTS description;
+ALTER TABLE users DROP COLUMN IF EXISTS suspended_at;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,20 @@
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+  CHECK (role IN ('user', 'admin'));
+ALTER TABLE users ADD COLUMN suspended_at TIMESTAMPTZ;
+
+-- The catalog is now edited through the admin API.
+ALTER TABLE achievements ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE achievements ADD COLUMN icon_url TEXT NOT NULL DEFAULT '';
+
+-- Every change made through the admin API. admin_id survives as NULL when
+-- the admin account is deleted.
+CREATE TABLE admin_actions (
+  id           UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  admin_id     UUID REFERENCES users(id) ON DELETE SET NULL,
+  action       TEXT NOT NULL,
+  target_type  TEXT NOT NULL,
+  target_id    TEXT NOT NULL,
+  details      TEXT NOT NULL DEFAULT '',
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX admin_actions_created_idx ON admin_actions (created_at DESC);
@@ -0,0 +1,27 @@
+-- Security-relevant and data-changing actions. actor_id and subject_id
+-- carry no foreign keys so the history outlives the accounts it mentions.
+CREATE TABLE audit_events (
+  id           UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  actor_id     UUID,
+  subject_id   UUID,
+  action       TEXT NOT NULL,
+  target_type  TEXT NOT NULL DEFAULT '',
+  target_id    TEXT NOT NULL DEFAULT '',
+  ip           TEXT NOT NULL DEFAULT '',
+  user_agent   TEXT NOT NULL DEFAULT '',
+  state_before JSONB,
+  state_after  JSONB,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX audit_events_subject_idx ON audit_events (subject_id, created_at DESC);
+CREATE INDEX audit_events_created_idx ON audit_events (created_at DESC);
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+  BEFORE UPDATE OR DELETE ON audit_events
+  FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,14 @@
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DELETE FROM challenges WHERE creator_id IS NULL;
+ALTER TABLE challenges DROP CONSTRAINT challenges_creator_id_fkey;
+ALTER TABLE challenges ADD CONSTRAINT challenges_creator_id_fkey
+  FOREIGN KEY (creator_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE challenges ALTER COLUMN creator_id SET NOT NULL;
+
+DROP INDEX IF EXISTS users_delete_after_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS delete_after;
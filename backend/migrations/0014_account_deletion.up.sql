@@ -0,0 +1,29 @@
+-- Accounts the user asked to delete are erased once delete_after passes.
+ALTER TABLE users ADD COLUMN delete_after TIMESTAMPTZ;
+CREATE INDEX users_delete_after_idx ON users (delete_after) WHERE delete_after IS NOT NULL;
+
+-- Challenges outlive their creator so the other participants keep them.
+ALTER TABLE challenges ALTER COLUMN creator_id DROP NOT NULL;
+ALTER TABLE challenges DROP CONSTRAINT challenges_creator_id_fkey;
+ALTER TABLE challenges ADD CONSTRAINT challenges_creator_id_fkey
+  FOREIGN KEY (creator_id) REFERENCES users(id) ON DELETE SET NULL;
+
+-- Erasing an account blanks the personal fields of its audit events. That
+-- is the only change the table accepts; the events themselves stay.
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'UPDATE'
+     AND NEW.id = OLD.id
+     AND NEW.actor_id IS NOT DISTINCT FROM OLD.actor_id
+     AND NEW.subject_id IS NOT DISTINCT FROM OLD.subject_id
+     AND NEW.action = OLD.action
+     AND NEW.target_type = OLD.target_type
+     AND NEW.target_id = OLD.target_id
+     AND NEW.created_at = OLD.created_at
+     AND NEW.ip = '' AND NEW.user_agent = ''
+     AND NEW.state_before IS NULL AND NEW.state_after IS NULL THEN
+    RETURN NEW;
+  END IF;
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,22 @@
+-- Accounts at external OpenID Connect providers linked to users.
+CREATE TABLE user_identities (
+  id             UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id        UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  provider       TEXT NOT NULL,
+  subject        TEXT NOT NULL,
+  email          TEXT NOT NULL DEFAULT '',
+  created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_login_at  TIMESTAMPTZ,
+  UNIQUE (provider, subject),
+  UNIQUE (user_id, provider)
+);
+
+-- Sign-ins between the redirect to the provider and its callback.
+CREATE TABLE oidc_logins (
+  state_hash     TEXT PRIMARY KEY,
+  provider       TEXT NOT NULL,
+  nonce          TEXT NOT NULL,
+  code_verifier  TEXT NOT NULL,
+  expires_at     TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX oidc_logins_expires_idx ON oidc_logins (expires_at);
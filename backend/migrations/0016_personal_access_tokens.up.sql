@@ -0,0 +1,15 @@
+-- Long-lived tokens users create for scripts and device bridges, stored
+-- as SHA-256. scopes is space separated, e.g. 'steps:read steps:write'.
+CREATE TABLE personal_access_tokens (
+  id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id       UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name          TEXT NOT NULL,
+  prefix        TEXT NOT NULL,
+  token_hash    TEXT NOT NULL UNIQUE,
+  scopes        TEXT NOT NULL,
+  expires_at    TIMESTAMPTZ NOT NULL,
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_used_at  TIMESTAMPTZ,
+  revoked_at    TIMESTAMPTZ
+);
+CREATE INDEX personal_access_tokens_user_idx ON personal_access_tokens (user_id);
@@ -0,0 +1,3 @@
+-- IANA time zone the user's days are counted in: "today" totals, weekly
+-- and monthly stats and reminder times.
+ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,19 @@
+-- Steps as counted by each of the user's devices ("source"), per hour of
+-- the user's local day, or for the whole day when hour is NULL. The daily
+-- user_steps total is merged from these rows.
+CREATE TABLE step_buckets (
+  user_id    UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  source     TEXT        NOT NULL,
+  day        DATE        NOT NULL,
+  hour       SMALLINT    CHECK (hour BETWEEN 0 AND 23),
+  steps      INT         NOT NULL CHECK (steps >= 0),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX step_buckets_slot_idx ON step_buckets (user_id, day, source, (COALESCE(hour, -1)));
+
+-- Days logged before buckets existed become whole-day counts of the
+-- default source, so the next upload merges with them instead of
+-- replacing them.
+INSERT INTO step_buckets (user_id, source, day, steps)
+SELECT user_id, 'app', day, GREATEST(steps, 0)
+FROM   user_steps;
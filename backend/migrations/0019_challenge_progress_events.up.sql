@@ -0,0 +1,47 @@
+-- Everything that counts towards challenges, per user and metric. A
+-- participant's progress is the sum of the events of the challenge's
+-- metric that occurred after they joined. Re-sending an event under the
+-- same idempotency key replaces it, so retries and corrections never add
+-- up twice.
+CREATE TABLE challenge_progress_events (
+  id              UUID        PRIMARY KEY,
+  user_id         UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  metric          VARCHAR(32) NOT NULL,
+  source          VARCHAR(32) NOT NULL,
+  amount          INT         NOT NULL CHECK (amount >= 0),
+  idempotency_key TEXT        NOT NULL,
+  occurred_at     TIMESTAMPTZ NOT NULL,
+  recorded_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (user_id, idempotency_key)
+);
+CREATE INDEX challenge_progress_events_metric_idx
+  ON challenge_progress_events (user_id, metric, occurred_at);
+
+-- Seed the ledger from what has been logged so far: a day's step total
+-- counts as occurring at the end of that day in the user's time zone.
+INSERT INTO challenge_progress_events (id, user_id, metric, source, amount, idempotency_key, occurred_at)
+SELECT gen_random_uuid(), us.user_id, 'steps', 'steps', us.steps, 'steps:' || TO_CHAR(us.day, 'YYYY-MM-DD'),
+       ((us.day + 1)::timestamp AT TIME ZONE u.timezone) - INTERVAL '1 microsecond'
+FROM   user_steps us
+JOIN   users u ON u.id = us.user_id;
+
+INSERT INTO challenge_progress_events (id, user_id, metric, source, amount, idempotency_key, occurred_at)
+SELECT gen_random_uuid(), user_id, 'workouts', 'activity', 1, 'activity:' || id,
+       COALESCE(performed_at AT TIME ZONE 'UTC', NOW())
+FROM   activities;
+
+INSERT INTO challenge_progress_events (id, user_id, metric, source, amount, idempotency_key, occurred_at)
+SELECT gen_random_uuid(), user_id, 'calories', 'meal', GREATEST(ROUND(COALESCE(calories, 0))::int, 0), 'meal:' || id,
+       COALESCE(created_at, NOW())
+FROM   meals;
+
+UPDATE challenge_participants cp
+SET    progress = LEAST(c.target, COALESCE((
+           SELECT SUM(e.amount)
+           FROM   challenge_progress_events e
+           WHERE  e.user_id     = cp.user_id
+             AND  e.metric      = c.type
+             AND  e.occurred_at >= cp.joined_at
+       ), 0))
+FROM   challenges c
+WHERE  c.id = cp.challenge_id;
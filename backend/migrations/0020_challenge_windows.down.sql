@@ -0,0 +1,14 @@
+DELETE FROM achievements WHERE title IN ('Challenge Winner', 'Challenge Runner-up', 'Challenge Third Place');
+
+UPDATE challenge_progress_events
+SET    occurred_at = lasts_until
+WHERE  lasts_until IS NOT NULL;
+ALTER TABLE challenge_progress_events DROP COLUMN IF EXISTS lasts_until;
+
+ALTER TABLE challenge_participants DROP COLUMN IF EXISTS final_rank;
+
+DROP INDEX IF EXISTS challenges_status_idx;
+ALTER TABLE challenges DROP CONSTRAINT IF EXISTS challenges_window_check;
+ALTER TABLE challenges DROP COLUMN IF EXISTS status;
+ALTER TABLE challenges DROP COLUMN IF EXISTS ends_at;
+ALTER TABLE challenges DROP COLUMN IF EXISTS starts_at;
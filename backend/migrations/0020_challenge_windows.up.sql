@@ -0,0 +1,37 @@
+-- Challenges run from starts_at until ends_at. The scheduler moves them
+-- from scheduled to active to finished; finishing freezes the standings
+-- into final_rank.
+ALTER TABLE challenges ADD COLUMN starts_at TIMESTAMPTZ;
+ALTER TABLE challenges ADD COLUMN ends_at   TIMESTAMPTZ;
+ALTER TABLE challenges ADD COLUMN status    VARCHAR(16) NOT NULL DEFAULT 'active'
+  CHECK (status IN ('scheduled', 'active', 'finished'));
+
+-- challenges so far had no end; give them a week from now to wrap up
+UPDATE challenges SET starts_at = created_at, ends_at = GREATEST(created_at, NOW()) + INTERVAL '7 days';
+
+ALTER TABLE challenges ALTER COLUMN starts_at SET NOT NULL;
+ALTER TABLE challenges ALTER COLUMN ends_at   SET NOT NULL;
+ALTER TABLE challenges ADD CONSTRAINT challenges_window_check CHECK (ends_at > starts_at);
+CREATE INDEX challenges_status_idx ON challenges (status, starts_at, ends_at);
+
+ALTER TABLE challenge_participants ADD COLUMN final_rank INT;
+
+-- A day's step total covers the whole day: it now occurs at the start of
+-- the day and lasts until its last instant, so it counts for a challenge
+-- whose window overlaps the day.
+ALTER TABLE challenge_progress_events ADD COLUMN lasts_until TIMESTAMPTZ;
+UPDATE challenge_progress_events e
+SET    occurred_at = (SUBSTRING(e.idempotency_key FROM 7)::date)::timestamp AT TIME ZONE u.timezone,
+       lasts_until = e.occurred_at
+FROM   users u
+WHERE  u.id = e.user_id AND e.source = 'steps' AND e.idempotency_key LIKE 'steps:%';
+
+-- Databases baselined at version 6 lack the unique title of version 1 that
+-- the seed below relies on; elsewhere this is a no-op.
+CREATE UNIQUE INDEX IF NOT EXISTS achievements_title_key ON achievements (title);
+
+INSERT INTO achievements (title, description) VALUES
+  ('Challenge Winner',      'Finished a challenge in first place.'),
+  ('Challenge Runner-up',   'Finished a challenge in second place.'),
+  ('Challenge Third Place', 'Finished a challenge in third place.')
+ON CONFLICT (title) DO NOTHING;
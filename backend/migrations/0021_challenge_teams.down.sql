@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS challenge_participants_team_idx;
+ALTER TABLE challenge_participants DROP COLUMN IF EXISTS team_id;
+DROP TABLE IF EXISTS challenge_teams;
+ALTER TABLE challenges DROP COLUMN IF EXISTS team_scoring;
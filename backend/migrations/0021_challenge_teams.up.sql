@@ -0,0 +1,16 @@
+-- Team challenges split their participants into named teams, ranked by
+-- the sum or the average of their members' progress.
+ALTER TABLE challenges ADD COLUMN team_scoring VARCHAR(16)
+  CHECK (team_scoring IN ('sum', 'average'));
+
+CREATE TABLE challenge_teams (
+  id           UUID    PRIMARY KEY,
+  challenge_id UUID    NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+  name         TEXT    NOT NULL,
+  position     INT     NOT NULL,
+  UNIQUE (challenge_id, name)
+);
+
+ALTER TABLE challenge_participants
+  ADD COLUMN team_id UUID REFERENCES challenge_teams(id) ON DELETE SET NULL;
+CREATE INDEX challenge_participants_team_idx ON challenge_participants (team_id);
@@ -0,0 +1,11 @@
+DELETE FROM challenge_progress_events
+WHERE  metric NOT IN ('steps', 'workouts', 'calories');
+UPDATE challenge_progress_events
+SET    idempotency_key = LEFT(idempotency_key, LENGTH(idempotency_key) - LENGTH(':' || metric))
+WHERE  source IN ('activity', 'meal') AND idempotency_key LIKE '%:' || metric;
+
+ALTER TABLE activities DROP COLUMN IF EXISTS distance;
+
+DELETE FROM challenges WHERE type NOT IN ('steps', 'workouts', 'calories');
+ALTER TABLE challenges ADD CONSTRAINT challenges_type_check
+  CHECK (type IN ('steps', 'workouts', 'calories'));
@@ -0,0 +1,23 @@
+-- Challenge types come from the metric registry of the challenge service
+-- rather than a fixed list.
+ALTER TABLE challenges DROP CONSTRAINT IF EXISTS challenges_type_check;
+
+ALTER TABLE activities ADD COLUMN distance INT CHECK (distance >= 0); -- meters
+
+-- One logged entry now counts towards every metric it feeds, each under
+-- the entry's key followed by the metric.
+UPDATE challenge_progress_events
+SET    idempotency_key = idempotency_key || ':' || metric
+WHERE  source IN ('activity', 'meal');
+
+INSERT INTO challenge_progress_events (id, user_id, metric, source, amount, idempotency_key, occurred_at)
+SELECT gen_random_uuid(), a.user_id, m.metric, 'activity', GREATEST(m.amount, 0), 'activity:' || a.id || ':' || m.metric,
+       COALESCE(a.performed_at AT TIME ZONE 'UTC', NOW())
+FROM   activities a
+CROSS  JOIN LATERAL (VALUES ('active_minutes', COALESCE(a.duration, 0)),
+                            ('calories_burned', COALESCE(a.calories, 0))) AS m(metric, amount);
+
+INSERT INTO challenge_progress_events (id, user_id, metric, source, amount, idempotency_key, occurred_at)
+SELECT gen_random_uuid(), user_id, 'water_ml', 'water', GREATEST(amount_ml, 0), 'water:' || id || ':water_ml',
+       COALESCE(created_at AT TIME ZONE 'UTC', NOW())
+FROM   water_logs;
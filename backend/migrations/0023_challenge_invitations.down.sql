@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS challenge_invitations;
+ALTER TABLE challenges DROP COLUMN IF EXISTS visibility;
@@ -0,0 +1,19 @@
+-- Challenges are private, friends-only or public. Everyone but the creator
+-- is invited and takes part once they accept; joining without an
+-- invitation depends on the visibility.
+ALTER TABLE challenges ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'private'
+  CHECK (visibility IN ('private', 'friends', 'public'));
+
+CREATE TABLE challenge_invitations (
+  id           UUID        PRIMARY KEY,
+  challenge_id UUID        NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+  inviter_id   UUID        REFERENCES users(id) ON DELETE SET NULL,
+  invitee_id   UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  team_id      UUID        REFERENCES challenge_teams(id) ON DELETE SET NULL,
+  status       VARCHAR(16) NOT NULL DEFAULT 'pending'
+               CHECK (status IN ('pending', 'accepted', 'declined')),
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  responded_at TIMESTAMPTZ,
+  UNIQUE (challenge_id, invitee_id)
+);
+CREATE INDEX challenge_invitations_invitee_idx ON challenge_invitations (invitee_id, status);
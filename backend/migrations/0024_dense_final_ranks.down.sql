@@ -0,0 +1,7 @@
+UPDATE challenge_participants cp
+SET    final_rank = r.rank
+FROM  (SELECT challenge_id, user_id,
+              RANK() OVER (PARTITION BY challenge_id ORDER BY progress DESC) AS rank
+       FROM   challenge_participants
+       WHERE  final_rank IS NOT NULL) r
+WHERE  cp.challenge_id = r.challenge_id AND cp.user_id = r.user_id;
@@ -0,0 +1,9 @@
+-- Final ranks of finished challenges become dense, like the leaderboard's:
+-- equal progress shares a rank and the next one follows without a gap.
+UPDATE challenge_participants cp
+SET    final_rank = r.rank
+FROM  (SELECT challenge_id, user_id,
+              DENSE_RANK() OVER (PARTITION BY challenge_id ORDER BY progress DESC) AS rank
+       FROM   challenge_participants
+       WHERE  final_rank IS NOT NULL) r
+WHERE  cp.challenge_id = r.challenge_id AND cp.user_id = r.user_id;
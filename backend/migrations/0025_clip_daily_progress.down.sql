@@ -0,0 +1,12 @@
+DELETE FROM challenge_progress_events
+WHERE  source = 'steps' AND idempotency_key LIKE 'steps:%T%';
+
+UPDATE challenge_progress_events e
+SET    amount = us.steps
+FROM   user_steps us
+WHERE  e.user_id = us.user_id AND e.source = 'steps'
+  AND  e.idempotency_key = 'steps:' || TO_CHAR(us.day, 'YYYY-MM-DD');
+
+UPDATE challenge_progress_events
+SET    lasts_until = occurred_at, occurred_at = occurred_at - INTERVAL '1 day' + INTERVAL '1 microsecond'
+WHERE  source = 'goal' AND lasts_until IS NULL;
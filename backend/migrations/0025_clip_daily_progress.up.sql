@@ -0,0 +1,24 @@
+-- Daily progress now counts only inside a challenge's window. A goal day
+-- counts at its last instant, for the window it ends in. Step totals
+-- still last the day, but a period counts by the part of it inside the
+-- window; the next recount splits recent days into their hours.
+UPDATE challenge_progress_events
+SET    occurred_at = lasts_until, lasts_until = NULL
+WHERE  source = 'goal' AND lasts_until IS NOT NULL;
+
+UPDATE challenge_participants cp
+SET    progress = LEAST(c.target, FLOOR(COALESCE((
+           SELECT SUM(CASE
+                    WHEN e.lasts_until IS NULL OR e.lasts_until <= e.occurred_at THEN e.amount
+                    ELSE e.amount * GREATEST(0, EXTRACT(EPOCH FROM
+                           LEAST(e.lasts_until, c.ends_at) - GREATEST(e.occurred_at, cp.joined_at, c.starts_at)))
+                         / EXTRACT(EPOCH FROM e.lasts_until - e.occurred_at)
+                  END)
+           FROM   challenge_progress_events e
+           WHERE  e.user_id = cp.user_id
+             AND  e.metric  = c.type
+             AND  COALESCE(e.lasts_until, e.occurred_at) >= GREATEST(cp.joined_at, c.starts_at)
+             AND  e.occurred_at < c.ends_at
+       ), 0) + 1e-9)::int)
+FROM   challenges c
+WHERE  c.id = cp.challenge_id AND c.status = 'active';
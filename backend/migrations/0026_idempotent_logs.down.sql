@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS water_logs_idempotency_key_idx;
+DROP INDEX IF EXISTS meals_idempotency_key_idx;
+DROP INDEX IF EXISTS activities_idempotency_key_idx;
+ALTER TABLE water_logs DROP COLUMN IF EXISTS idempotency_key;
+ALTER TABLE meals      DROP COLUMN IF EXISTS idempotency_key;
+ALTER TABLE activities DROP COLUMN IF EXISTS idempotency_key;
@@ -0,0 +1,9 @@
+-- A request retried with the same Idempotency-Key returns the entry the
+-- first attempt logged instead of logging it again. Entries logged without
+-- a key have none, and NULLs never collide.
+ALTER TABLE activities ADD COLUMN idempotency_key TEXT;
+ALTER TABLE meals      ADD COLUMN idempotency_key TEXT;
+ALTER TABLE water_logs ADD COLUMN idempotency_key TEXT;
+CREATE UNIQUE INDEX activities_idempotency_key_idx ON activities (user_id, idempotency_key);
+CREATE UNIQUE INDEX meals_idempotency_key_idx      ON meals (user_id, idempotency_key);
+CREATE UNIQUE INDEX water_logs_idempotency_key_idx ON water_logs (user_id, idempotency_key);
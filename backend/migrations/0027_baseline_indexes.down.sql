@@ -0,0 +1,3 @@
+-- The indexes, constraints and keys belong to versions 1-6, which drop them
+-- on their way down.
+SELECT 1;
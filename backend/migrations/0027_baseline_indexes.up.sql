@@ -0,0 +1,76 @@
+-- Databases baselined at version 6 were created by the old init script,
+-- which lacked the indexes, unique constraints and foreign keys that
+-- versions 1-6 add. Create whatever is missing; on migrated databases this
+-- changes nothing.
+CREATE INDEX IF NOT EXISTS user_achievements_user_idx       ON user_achievements (user_id);
+CREATE INDEX IF NOT EXISTS friend_requests_recipient_idx    ON friend_requests (recipient_id);
+CREATE INDEX IF NOT EXISTS friends_friend_idx               ON friends (friend_id);
+CREATE INDEX IF NOT EXISTS activities_user_performed_idx    ON activities (user_id, performed_at DESC);
+CREATE INDEX IF NOT EXISTS meals_user_created_idx           ON meals (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS water_logs_user_created_idx      ON water_logs (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS post_activities_user_created_idx ON post_activities (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS messages_pair_created_idx        ON messages (sender_id, receiver_id, created_at);
+CREATE INDEX IF NOT EXISTS messages_receiver_idx            ON messages (receiver_id);
+CREATE INDEX IF NOT EXISTS challenges_creator_idx           ON challenges (creator_id);
+CREATE INDEX IF NOT EXISTS challenge_participants_user_idx  ON challenge_participants (user_id);
+CREATE INDEX IF NOT EXISTS workout_schedules_user_idx       ON workout_schedules (user_id);
+CREATE INDEX IF NOT EXISTS workout_schedules_slot_idx       ON workout_schedules (weekday, at_time);
+
+-- AddFriendship relies on the unique pair with ON CONFLICT; the unique
+-- achievement title already came back in version 20, whose seed needs it.
+-- The names are the ones versions 1 and 5 give, so the statements are
+-- no-ops where those exist. Duplicate friendships would block the index
+-- and carry nothing, so they go first.
+DELETE FROM friends a USING friends b
+WHERE  a.user_id = b.user_id AND a.friend_id = b.friend_id AND a.ctid > b.ctid;
+CREATE UNIQUE INDEX IF NOT EXISTS friends_user_id_friend_id_key ON friends (user_id, friend_id);
+CREATE UNIQUE INDEX IF NOT EXISTS ua_unique_without_challenge
+  ON user_achievements (user_id, achievement_id) WHERE challenge_id IS NULL;
+
+-- Rows of users deleted before the foreign keys existed are dropped, as the
+-- cascades would have done. Each key is recreated under its usual name, so
+-- a missing or non-cascading one is replaced and a correct one is rebuilt
+-- as it was.
+DELETE FROM activities      WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM meals           WHERE user_id IS NULL OR user_id NOT IN (SELECT id FROM users);
+DELETE FROM water_logs      WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM water_goals     WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM calorie_goals   WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM step_goals      WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM activity_goals  WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM post_activities WHERE user_id NOT IN (SELECT id FROM users);
+
+ALTER TABLE activities
+  DROP CONSTRAINT IF EXISTS activities_user_id_fkey,
+  ADD CONSTRAINT activities_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE meals
+  ALTER COLUMN user_id SET NOT NULL,
+  DROP CONSTRAINT IF EXISTS meals_user_id_fkey,
+  ADD CONSTRAINT meals_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE water_logs
+  DROP CONSTRAINT IF EXISTS water_logs_user_id_fkey,
+  ADD CONSTRAINT water_logs_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE water_goals
+  DROP CONSTRAINT IF EXISTS water_goals_user_id_fkey,
+  ADD CONSTRAINT water_goals_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE calorie_goals
+  DROP CONSTRAINT IF EXISTS calorie_goals_user_id_fkey,
+  ADD CONSTRAINT calorie_goals_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE step_goals
+  DROP CONSTRAINT IF EXISTS step_goals_user_id_fkey,
+  ADD CONSTRAINT step_goals_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE activity_goals
+  DROP CONSTRAINT IF EXISTS activity_goals_user_id_fkey,
+  ADD CONSTRAINT activity_goals_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE post_activities
+  DROP CONSTRAINT IF EXISTS post_activities_user_id_fkey,
+  ADD CONSTRAINT post_activities_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE messages
+  DROP CONSTRAINT IF EXISTS messages_sender_id_fkey,
+  DROP CONSTRAINT IF EXISTS messages_receiver_id_fkey,
+  ADD CONSTRAINT messages_sender_id_fkey   FOREIGN KEY (sender_id)   REFERENCES users(id) ON DELETE CASCADE,
+  ADD CONSTRAINT messages_receiver_id_fkey FOREIGN KEY (receiver_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE user_achievements
+  DROP CONSTRAINT IF EXISTS user_achievements_challenge_id_fkey,
+  ADD CONSTRAINT user_achievements_challenge_id_fkey
+    FOREIGN KEY (challenge_id) REFERENCES challenges(id) ON DELETE CASCADE;
@@ -0,0 +1,10 @@
+// Package migrations bundles the SQL migration files into the binaries so
+// cmd/migrate works regardless of the working directory.
+package migrations
+
+import "embed"
+
+// FS holds every *.up.sql / *.down.sql file in this directory.
+//
+//go:embed *.sql
+var FS embed.FS
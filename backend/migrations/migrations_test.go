@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/migrate"
+)
+
+// Every migration must be reversible and versions must not leave gaps, so
+// `cmd/migrate down` can always walk back to an empty schema.
+func TestEmbeddedMigrations(t *testing.T) {
+	list, err := migrate.Load(FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+
+	for i, m := range list {
+		assert.Equal(t, int64(i+1), m.Version, "versions must be contiguous")
+		assert.NotEmpty(t, m.Down, "version %d (%s) has no down script", m.Version, m.Name)
+	}
+}
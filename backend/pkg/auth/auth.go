@@ -1,38 +1,66 @@
 package auth
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
 )
 
+// ErrNoExpiry rejects tokens signed before access tokens had a lifetime.
+var ErrNoExpiry = errors.New("token has no expiry")
+
 type Claims struct {
 	UserID string `json:"userId"`
+	// SessionID names the row in `sessions` the token was issued for.
+	SessionID string `json:"sid,omitempty"`
+	// Role is the user's role when the token was issued.
+	Role string `json:"role,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// GenerateToken signs claims as an access token. IssuedAt and ExpiresAt
+// default to now and now + ACCESS_TOKEN_TTL.
 func GenerateToken(claims Claims) (string, error) {
 	cfg := config.Load()
+	now := time.Now()
+	if claims.IssuedAt == nil {
+		claims.IssuedAt = jwt.NewNumericDate(now)
+	}
+	if claims.ExpiresAt == nil {
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(cfg.AccessTokenTTL))
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(cfg.JWTSecret))
 }
 
-// ParseToken verifies the JWT and returns the custom claims
+// ParseToken verifies the JWT and returns the custom claims. Expired
+// tokens fail with an error matching jwt.ErrTokenExpired.
 func ParseToken(tokenStr string) (*Claims, error) {
 	cfg := config.Load()
 
-	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
 		return []byte(cfg.JWTSecret), nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		log.Printf("Parsed JWT claims in ParseToken: userId=%v", claims.UserID)
-		log.Println("JWT Secret in ParseToken:", cfg.JWTSecret)
-		return claims, nil
+	if !token.Valid {
+		return nil, errors.New("invalid token")
 	}
-	return nil, err
+	if claims.ExpiresAt == nil {
+		return nil, ErrNoExpiry
+	}
+	return claims, nil
+}
+
+// IsExpired reports whether err came from parsing an expired token.
+func IsExpired(err error) bool {
+	return errors.Is(err, jwt.ErrTokenExpired)
 }
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAndParseToken(t *testing.T) {
+	t.Setenv("STORAGE", "memory")
+
+	tok, err := GenerateToken(Claims{UserID: "u1"})
+	require.NoError(t, err)
+
+	claims, err := ParseToken(tok)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", claims.UserID)
+	require.NotNil(t, claims.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), claims.ExpiresAt.Time, 5*time.Second)
+}
+
+func TestParseToken_RejectsExpiredAndUnbounded(t *testing.T) {
+	t.Setenv("STORAGE", "memory")
+
+	past := jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	tok, err := GenerateToken(Claims{UserID: "u1", RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: past}})
+	require.NoError(t, err)
+	_, err = ParseToken(tok)
+	assert.True(t, IsExpired(err), "got %v", err)
+
+	// a token without exp, as issued before expiry was introduced
+	raw := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"userId": "u1"})
+	tok, err = raw.SignedString([]byte("your-jwt-secret-key"))
+	require.NoError(t, err)
+	_, err = ParseToken(tok)
+	assert.ErrorIs(t, err, ErrNoExpiry)
+
+	_, err = ParseToken(tok + "x")
+	assert.Error(t, err)
+}
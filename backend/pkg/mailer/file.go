@@ -0,0 +1,33 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9@.+_-]+`)
+
+// File is the development Mailer. It writes every message as a .eml file
+// into Dir, or prints it to the log when Dir is empty.
+type File struct {
+	Dir  string
+	From string
+}
+
+func (f *File) Send(msg Message) error {
+	now := time.Now()
+	raw := format(f.From, msg, now)
+	if f.Dir == "" {
+		log.Printf("📧 mail to %s\n%s", msg.To, raw)
+		return nil
+	}
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405.000000000"), unsafeName.ReplaceAllString(msg.To, "_"))
+	return os.WriteFile(filepath.Join(f.Dir, name), raw, 0o644)
+}
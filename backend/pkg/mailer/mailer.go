@@ -0,0 +1,60 @@
+// Package mailer sends transactional email (password resets, address
+// verification) through a pluggable Mailer.
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"time"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New returns the Mailer selected by cfg.Mailer: "smtp" for a real
+// server, "file" (or "log") for local development.
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.Mailer {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("mailer: SMTP_HOST is required for MAILER=smtp")
+		}
+		return &SMTP{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}, nil
+	case "file", "log", "":
+		return &File{Dir: cfg.MailDir, From: cfg.MailFrom}, nil
+	default:
+		return nil, fmt.Errorf("mailer: unknown MAILER %q, use smtp, file or log", cfg.Mailer)
+	}
+}
+
+// format renders msg as an RFC 5322 message.
+func format(from string, msg Message, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+	return b.Bytes()
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+)
+
+func TestFile_WritesEML(t *testing.T) {
+	dir := t.TempDir()
+	m := &File{Dir: dir, From: "noreply@test.com"}
+
+	require.NoError(t, m.Send(Message{To: "ann@test.com", Subject: "Reset your password", Body: "token: abc"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.True(t, strings.HasSuffix(files[0], "ann@test.com.eml"))
+
+	raw, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "To: ann@test.com\r\n")
+	assert.Contains(t, string(raw), "\r\n\r\ntoken: abc")
+}
+
+func TestNew(t *testing.T) {
+	m, err := New(&config.Config{Mailer: "log"})
+	require.NoError(t, err)
+	assert.IsType(t, &File{}, m)
+
+	_, err = New(&config.Config{Mailer: "smtp"})
+	assert.Error(t, err, "smtp without a host")
+
+	m, err = New(&config.Config{Mailer: "smtp", SMTPHost: "mail", SMTPPort: "25"})
+	require.NoError(t, err)
+	assert.IsType(t, &SMTP{}, m)
+
+	_, err = New(&config.Config{Mailer: "pigeon"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTP sends mail through an SMTP server, authenticating with PLAIN when
+// a username is set.
+type SMTP struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTP) Send(msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") {
+		return fmt.Errorf("mailer: invalid recipient %q", msg.To)
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	addr := net.JoinHostPort(s.Host, s.Port)
+	return smtp.SendMail(addr, auth, s.From, []string{msg.To}, format(s.From, msg, time.Now()))
+}
@@ -0,0 +1,498 @@
+// Package migrate applies numbered SQL migrations and tracks them in a
+// schema table, so every environment can be brought to a known version.
+//
+// Migration files are named "<version>_<name>.up.sql" and
+// "<version>_<name>.down.sql", e.g. 0002_activities.up.sql.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Table is the name of the table that records applied versions.
+const Table = "schema_migrations"
+
+// lockID is the pg_advisory_lock key that keeps two migrators apart.
+const lockID = 8_310_025
+
+var fileRe = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_\-]+)\.(up|down)\.sql$`)
+
+// ErrDirty is returned when a previous run failed half-way. The schema has
+// to be repaired by hand and the version pinned with Force.
+var ErrDirty = errors.New("database is dirty")
+
+// ErrPartialSchema is returned when an untracked database has some of the
+// baseline tables but not all, so neither baselining it nor migrating it
+// from scratch would be safe.
+var ErrPartialSchema = errors.New("database has a partial untracked schema")
+
+// Migration is one numbered step with its up and (optional) down script.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Baseline describes a schema created before the migrations were tracked.
+// A database whose schema table is still empty but that already has every
+// one of Tables is taken to be at Version: the versions up to it are
+// recorded as applied without running them. One that has only some of them
+// was left half-built and is refused with ErrPartialSchema.
+type Baseline struct {
+	Version int64
+	Tables  []string
+}
+
+// Status describes one migration as seen by the database.
+type Status struct {
+	Migration
+	Applied   bool
+	Dirty     bool
+	AppliedAt *time.Time
+}
+
+// Load reads every migration file in the root of fsys and returns them
+// ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		m := fileRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migrate: malformed file name %q", e.Name())
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrate: bad version in %q", e.Name())
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migrate: version %d used by %q and %q", version, mig.Name, m[2])
+		}
+
+		switch m[3] {
+		case "up":
+			if mig.Up != "" {
+				return nil, fmt.Errorf("migrate: duplicate up script for version %d", version)
+			}
+			mig.Up = string(body)
+		case "down":
+			if mig.Down != "" {
+				return nil, fmt.Errorf("migrate: duplicate down script for version %d", version)
+			}
+			mig.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migrate: version %d (%s) has no up script", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Migrator runs a fixed set of migrations against one database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+
+	// Log, when set, receives a line for every applied or reverted step.
+	Log func(format string, args ...any)
+	// Baseline, when set, stamps databases that predate the schema table
+	// before any command runs.
+	Baseline *Baseline
+}
+
+// New returns a Migrator for the given migrations, which must be sorted
+// (as returned by Load).
+func New(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Up applies up to n pending migrations; n <= 0 applies all of them.
+func (m *Migrator) Up(n int) error {
+	return m.withLock(func(conn *sql.Conn) error {
+		cur, err := m.current(conn)
+		if err != nil {
+			return err
+		}
+		applied := 0
+		for _, mig := range m.migrations {
+			if mig.Version <= cur {
+				continue
+			}
+			if n > 0 && applied == n {
+				break
+			}
+			if err := m.apply(conn, mig, true); err != nil {
+				return err
+			}
+			applied++
+		}
+		if applied == 0 {
+			m.logf("no change, database is at version %d", cur)
+		}
+		return nil
+	})
+}
+
+// Down reverts up to n applied migrations, newest first; n <= 0 reverts
+// all of them.
+func (m *Migrator) Down(n int) error {
+	return m.withLock(func(conn *sql.Conn) error {
+		cur, err := m.current(conn)
+		if err != nil {
+			return err
+		}
+		reverted := 0
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if mig.Version > cur {
+				continue
+			}
+			if n > 0 && reverted == n {
+				break
+			}
+			if err := m.apply(conn, mig, false); err != nil {
+				return err
+			}
+			reverted++
+		}
+		if reverted == 0 {
+			m.logf("no change, database is at version %d", cur)
+		}
+		return nil
+	})
+}
+
+// Goto migrates up or down until version is the newest applied one.
+// Version 0 means an empty schema.
+func (m *Migrator) Goto(version int64) error {
+	if version != 0 && m.find(version) < 0 {
+		return fmt.Errorf("migrate: unknown version %d", version)
+	}
+	return m.withLock(func(conn *sql.Conn) error {
+		cur, err := m.current(conn)
+		if err != nil {
+			return err
+		}
+		if version >= cur {
+			for _, mig := range m.migrations {
+				if mig.Version > cur && mig.Version <= version {
+					if err := m.apply(conn, mig, true); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if mig.Version <= cur && mig.Version > version {
+				if err := m.apply(conn, mig, false); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Force records version as the current, clean state without running any
+// SQL. Use it after repairing a dirty database by hand.
+func (m *Migrator) Force(version int64) error {
+	if version != 0 && m.find(version) < 0 {
+		return fmt.Errorf("migrate: unknown version %d", version)
+	}
+	return m.withLock(func(conn *sql.Conn) error {
+		ctx := context.Background()
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+Table+` WHERE version > $1 OR dirty`, version); err != nil {
+			return err
+		}
+		if err := m.stamp(ctx, tx, version); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		m.logf("forced version %d", version)
+		return nil
+	})
+}
+
+// Status reports every known migration together with its state in the
+// database, plus the current version: the newest one applied cleanly.
+func (m *Migrator) Status() ([]Status, int64, error) {
+	var (
+		list []Status
+		cur  int64
+	)
+	err := m.withLock(func(conn *sql.Conn) error {
+		rows, err := conn.QueryContext(context.Background(),
+			`SELECT version, dirty, applied_at FROM `+Table)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		type row struct {
+			dirty bool
+			at    time.Time
+		}
+		seen := map[int64]row{}
+		for rows.Next() {
+			var (
+				v int64
+				r row
+			)
+			if err := rows.Scan(&v, &r.dirty, &r.at); err != nil {
+				return err
+			}
+			seen[v] = r
+			if !r.dirty && v > cur {
+				cur = v
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			st := Status{Migration: mig}
+			if r, ok := seen[mig.Version]; ok {
+				at := r.at
+				st.Applied = !r.dirty
+				st.Dirty = r.dirty
+				st.AppliedAt = &at
+			}
+			list = append(list, st)
+		}
+		return nil
+	})
+	return list, cur, err
+}
+
+// stamp records every migration up to version as applied and clean.
+func (m *Migrator) stamp(ctx context.Context, tx *sql.Tx, version int64) error {
+	for _, mig := range m.migrations {
+		if mig.Version > version {
+			break
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO `+Table+` (version, name, dirty)
+			VALUES ($1, $2, false)
+			ON CONFLICT (version) DO NOTHING`, mig.Version, mig.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// baseline stamps a database that has the baseline tables but has never
+// recorded a version, so the first run does not recreate them.
+func (m *Migrator) baseline(conn *sql.Conn) error {
+	if m.Baseline == nil {
+		return nil
+	}
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var tracked bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM `+Table+`)`).Scan(&tracked); err != nil || tracked {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name = ANY($1)`,
+		pq.Array(m.Baseline.Tables))
+	if err != nil {
+		return err
+	}
+	found := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		found[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	var missing []string
+	for _, name := range m.Baseline.Tables {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing %s, repair it by hand", ErrPartialSchema, strings.Join(missing, ", "))
+	}
+	if err := m.stamp(ctx, tx, m.Baseline.Version); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	m.logf("found an untracked schema, baselined at version %d", m.Baseline.Version)
+	return nil
+}
+
+// apply runs one migration in the given direction. The version row is
+// marked dirty before the script runs, so a crash or a failing statement
+// leaves a visible trace that has to be cleared with Force.
+func (m *Migrator) apply(conn *sql.Conn, mig Migration, up bool) error {
+	ctx := context.Background()
+	script, verb := mig.Up, "up"
+	if !up {
+		script, verb = mig.Down, "down"
+		if script == "" {
+			return fmt.Errorf("migrate: version %d (%s) has no down script", mig.Version, mig.Name)
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, `
+		INSERT INTO `+Table+` (version, name, dirty)
+		VALUES ($1, $2, true)
+		ON CONFLICT (version) DO UPDATE SET dirty = true`, mig.Version, mig.Name); err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migrate: %d_%s.%s.sql failed, database left dirty at version %d: %w",
+			mig.Version, mig.Name, verb, mig.Version, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE `+Table+` SET dirty = false, applied_at = now() WHERE version = $1`, mig.Version)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM `+Table+` WHERE version = $1`, mig.Version)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	m.logf("%s %d_%s", verb, mig.Version, mig.Name)
+	return nil
+}
+
+// current returns the newest applied version and refuses to continue
+// while any version is dirty.
+func (m *Migrator) current(conn *sql.Conn) (int64, error) {
+	var (
+		version sql.NullInt64
+		dirty   sql.NullInt64
+	)
+	err := conn.QueryRowContext(context.Background(), `
+		SELECT MAX(version), MAX(version) FILTER (WHERE dirty)
+		FROM `+Table).Scan(&version, &dirty)
+	if err != nil {
+		return 0, err
+	}
+	if dirty.Valid {
+		return 0, fmt.Errorf("%w at version %d, repair it and run force", ErrDirty, dirty.Int64)
+	}
+	return version.Int64, nil
+}
+
+// withLock runs fn on a single connection that holds the migration
+// advisory lock, creating the schema table on first use and baselining
+// an untracked database.
+func (m *Migrator) withLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockID)
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS `+Table+` (
+			version    BIGINT      PRIMARY KEY,
+			name       TEXT        NOT NULL,
+			dirty      BOOLEAN     NOT NULL DEFAULT false,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`); err != nil {
+		return err
+	}
+	if err := m.baseline(conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) find(version int64) int {
+	for i, mig := range m.migrations {
+		if mig.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Migrator) logf(format string, args ...any) {
+	if m.Log != nil {
+		m.Log(format, args...)
+	}
+}
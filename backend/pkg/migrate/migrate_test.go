@@ -0,0 +1,320 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_OrdersAndPairsFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0010_schedules.up.sql":   {Data: []byte("CREATE TABLE s ();")},
+		"0002_steps.down.sql":     {Data: []byte("DROP TABLE st;")},
+		"0002_steps.up.sql":       {Data: []byte("CREATE TABLE st ();")},
+		"0001_users.up.sql":       {Data: []byte("CREATE TABLE u ();")},
+		"0001_users.down.sql":     {Data: []byte("DROP TABLE u;")},
+		"embed.go":                {Data: []byte("package migrations")},
+		"README.md":               {Data: []byte("notes")},
+		"0010_schedules.down.sql": {Data: []byte("DROP TABLE s;")},
+	}
+
+	list, err := Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+
+	assert.Equal(t, int64(1), list[0].Version)
+	assert.Equal(t, "users", list[0].Name)
+	assert.Equal(t, "DROP TABLE u;", list[0].Down)
+	assert.Equal(t, int64(2), list[1].Version)
+	assert.Equal(t, int64(10), list[2].Version)
+	assert.Equal(t, "CREATE TABLE s ();", list[2].Up)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"malformed name": {
+			"users.up.sql": {Data: []byte("x")},
+		},
+		"missing up": {
+			"0001_users.down.sql": {Data: []byte("x")},
+		},
+		"version reused": {
+			"0001_users.up.sql":   {Data: []byte("x")},
+			"0001_friends.up.sql": {Data: []byte("y")},
+		},
+		"zero version": {
+			"0000_init.up.sql": {Data: []byte("x")},
+		},
+	}
+	for name, fsys := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// fakeDB stands in for Postgres. It understands the statements the
+// Migrator sends about its schema table, treats everything else as a
+// migration script and records it, and fails scripts that say FAIL.
+type fakeDB struct {
+	mu      sync.Mutex
+	tables  []string // tables the schema already has
+	rows    map[int64]fakeRow
+	scripts []string
+}
+
+type fakeRow struct {
+	name  string
+	dirty bool
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+// versions lists the recorded versions, marking dirty ones with a *.
+func (f *fakeDB) versions() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []string
+	for v, r := range f.rows {
+		s := strconv.FormatInt(v, 10)
+		if r.dirty {
+			s += "*"
+		}
+		list = append(list, s)
+	}
+	sort.Strings(list)
+	return list
+}
+
+type fakeConn struct {
+	db *fakeDB
+	tx *fakeTx
+}
+
+// fakeTx keeps what the database looked like when it began, to restore it
+// on rollback.
+type fakeTx struct {
+	conn    *fakeConn
+	rows    map[int64]fakeRow
+	scripts int
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.tx = &fakeTx{conn: c, rows: maps.Clone(c.db.rows), scripts: len(c.db.scripts)}
+	return c.tx, nil
+}
+
+func (t *fakeTx) Commit() error { t.conn.tx = nil; return nil }
+func (t *fakeTx) Rollback() error {
+	db := t.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.rows = t.rows
+	db.scripts = db.scripts[:t.scripts]
+	t.conn.tx = nil
+	return nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	arg := func(i int) int64 { return args[i].Value.(int64) }
+	switch {
+	case strings.Contains(query, "pg_advisory"), strings.Contains(query, "CREATE TABLE IF NOT EXISTS "+Table):
+	case strings.Contains(query, "INSERT INTO "+Table) && strings.Contains(query, "DO NOTHING"):
+		if _, ok := db.rows[arg(0)]; !ok {
+			db.rows[arg(0)] = fakeRow{name: args[1].Value.(string)}
+		}
+	case strings.Contains(query, "INSERT INTO "+Table):
+		db.rows[arg(0)] = fakeRow{name: args[1].Value.(string), dirty: true}
+	case strings.Contains(query, "UPDATE "+Table):
+		r := db.rows[arg(0)]
+		r.dirty = false
+		db.rows[arg(0)] = r
+	case strings.Contains(query, "DELETE FROM "+Table) && strings.Contains(query, "OR dirty"):
+		for v, r := range db.rows {
+			if v > arg(0) || r.dirty {
+				delete(db.rows, v)
+			}
+		}
+	case strings.Contains(query, "DELETE FROM "+Table):
+		delete(db.rows, arg(0))
+	default:
+		if strings.Contains(query, "FAIL") {
+			return nil, errors.New("syntax error")
+		}
+		db.scripts = append(db.scripts, query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	rows := &fakeRows{}
+	switch {
+	case strings.Contains(query, "SELECT EXISTS"):
+		rows.values = [][]driver.Value{{len(db.rows) > 0}}
+	case strings.Contains(query, "information_schema.tables"):
+		for _, t := range db.tables {
+			rows.values = append(rows.values, []driver.Value{t})
+		}
+	case strings.Contains(query, "MAX(version)"):
+		var cur, dirty driver.Value
+		for v, r := range db.rows {
+			if cur == nil || v > cur.(int64) {
+				cur = v
+			}
+			if r.dirty && (dirty == nil || v > dirty.(int64)) {
+				dirty = v
+			}
+		}
+		rows.values = [][]driver.Value{{cur, dirty}}
+	case strings.Contains(query, "applied_at FROM"):
+		for v, r := range db.rows {
+			rows.values = append(rows.values, []driver.Value{v, r.dirty, time.Now()})
+		}
+	default:
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.values) == 0 {
+		return []string{"?"}
+	}
+	return make([]string, len(r.values[0]))
+}
+func (r *fakeRows) Close() error { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "users", Up: "up 1", Down: "down 1"},
+	{Version: 2, Name: "steps", Up: "up 2", Down: "down 2"},
+	{Version: 3, Name: "meals", Up: "up 3", Down: "down 3"},
+}
+
+func newFake(tables ...string) (*Migrator, *fakeDB) {
+	fake := &fakeDB{tables: tables, rows: map[int64]fakeRow{}}
+	return New(sql.OpenDB(fake), testMigrations), fake
+}
+
+func TestUpDownGoto(t *testing.T) {
+	m, fake := newFake()
+
+	require.NoError(t, m.Up(2))
+	assert.Equal(t, []string{"1", "2"}, fake.versions())
+	require.NoError(t, m.Up(0))
+	assert.Equal(t, []string{"1", "2", "3"}, fake.versions())
+	require.NoError(t, m.Up(0))
+	assert.Equal(t, []string{"up 1", "up 2", "up 3"}, fake.scripts)
+
+	require.NoError(t, m.Down(1))
+	assert.Equal(t, []string{"1", "2"}, fake.versions())
+	require.NoError(t, m.Goto(3))
+	require.NoError(t, m.Goto(1))
+	assert.Equal(t, []string{"1"}, fake.versions())
+	assert.Equal(t, []string{"up 1", "up 2", "up 3", "down 3", "up 3", "down 3", "down 2"}, fake.scripts)
+
+	require.NoError(t, m.Down(0))
+	assert.Empty(t, fake.versions())
+	assert.Error(t, m.Goto(7), "unknown version")
+}
+
+func TestUp_FailureLeavesDirtyUntilForced(t *testing.T) {
+	m, fake := newFake()
+	m.migrations = []Migration{testMigrations[0], {Version: 2, Name: "steps", Up: "FAIL", Down: "down 2"}, testMigrations[2]}
+
+	require.Error(t, m.Up(0))
+	assert.Equal(t, []string{"1", "2*"}, fake.versions())
+	assert.ErrorIs(t, m.Up(0), ErrDirty)
+	assert.ErrorIs(t, m.Down(1), ErrDirty)
+
+	list, cur, err := m.Status()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cur)
+	assert.True(t, list[1].Dirty)
+	assert.False(t, list[1].Applied)
+
+	// repaired by hand: the version is pinned and the rest applies
+	require.NoError(t, m.Force(2))
+	assert.Equal(t, []string{"1", "2"}, fake.versions())
+	require.NoError(t, m.Up(0))
+	assert.Equal(t, []string{"1", "2", "3"}, fake.versions())
+	assert.Equal(t, []string{"up 1", "up 3"}, fake.scripts)
+
+	require.NoError(t, m.Force(1))
+	assert.Equal(t, []string{"1"}, fake.versions())
+	require.NoError(t, m.Force(0))
+	assert.Empty(t, fake.versions())
+	assert.Error(t, m.Force(7), "unknown version")
+}
+
+func TestBaseline(t *testing.T) {
+	baseline := &Baseline{Version: 2, Tables: []string{"users", "steps"}}
+
+	// an untracked schema with every table is stamped, not recreated
+	m, fake := newFake("users", "steps")
+	m.Baseline = baseline
+	require.NoError(t, m.Up(0))
+	assert.Equal(t, []string{"1", "2", "3"}, fake.versions())
+	assert.Equal(t, []string{"up 3"}, fake.scripts)
+
+	// an empty schema is migrated from scratch
+	m, fake = newFake()
+	m.Baseline = baseline
+	require.NoError(t, m.Up(0))
+	assert.Equal(t, []string{"up 1", "up 2", "up 3"}, fake.scripts)
+
+	// a half-built one is left alone
+	m, fake = newFake("users")
+	m.Baseline = baseline
+	err := m.Up(0)
+	assert.ErrorIs(t, err, ErrPartialSchema)
+	assert.ErrorContains(t, err, "steps")
+	assert.Empty(t, fake.versions())
+	assert.Empty(t, fake.scripts)
+
+	// once versions are tracked the tables no longer matter
+	m, fake = newFake("users")
+	m.Baseline = baseline
+	fake.rows[1] = fakeRow{name: "users"}
+	require.NoError(t, m.Up(0))
+	assert.Equal(t, []string{"up 2", "up 3"}, fake.scripts)
+}
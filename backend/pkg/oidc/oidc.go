@@ -0,0 +1,308 @@
+// Package oidc is a small OpenID Connect relying party: provider
+// discovery, the authorization code flow with PKCE (S256) and ID token
+// verification against the provider's JWKS. Only RS256 signed ID tokens
+// are accepted.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	// ErrInvalidToken is returned for ID tokens that fail verification.
+	ErrInvalidToken = errors.New("oidc: invalid id token")
+	// ErrExchange is returned when the token endpoint refuses a code.
+	ErrExchange = errors.New("oidc: code exchange failed")
+)
+
+// Config describes one provider as registered with it.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty for public clients, which rely on PKCE alone
+	RedirectURL  string
+	// Scopes default to openid, email and profile.
+	Scopes []string
+	// HTTPClient defaults to a client with a 10 second timeout.
+	HTTPClient *http.Client
+}
+
+// Metadata is the part of the discovery document the client uses.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the verified claims of an ID token.
+type Claims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+	Nonce         string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// Tokens is the token endpoint response.
+type Tokens struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// Provider talks to one issuer. Discovery and keys are fetched on first
+// use and cached; keys are refetched when a token names an unknown kid.
+type Provider struct {
+	cfg Config
+
+	mu   sync.Mutex
+	meta *Metadata
+	keys map[string]*rsa.PublicKey
+}
+
+// New returns a Provider for cfg. It does not contact the issuer yet.
+func New(cfg Config) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{cfg: cfg}
+}
+
+// Discover returns the issuer's metadata from
+// /.well-known/openid-configuration.
+func (p *Provider) Discover(ctx context.Context) (Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return *p.meta, nil
+	}
+
+	var m Metadata
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &m); err != nil {
+		return Metadata{}, fmt.Errorf("oidc: discovery: %w", err)
+	}
+	if m.Issuer != p.cfg.Issuer {
+		return Metadata{}, fmt.Errorf("oidc: discovery: issuer %q does not match %q", m.Issuer, p.cfg.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return Metadata{}, errors.New("oidc: discovery: incomplete metadata")
+	}
+	p.meta = &m
+	return m, nil
+}
+
+// AuthCodeURL returns where to send the user to sign in. state and nonce
+// should come from RandomString, verifier from NewVerifier; all three
+// must be kept until the callback.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	m, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return m.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange trades an authorization code for tokens.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (Tokens, error) {
+	m, err := p.Discover(ctx)
+	if err != nil {
+		return Tokens{}, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Tokens{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return Tokens{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Tokens{}, fmt.Errorf("%w: %s: %s", ErrExchange, resp.Status, body)
+	}
+
+	var t Tokens
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return Tokens{}, err
+	}
+	if t.IDToken == "" {
+		return Tokens{}, fmt.Errorf("%w: no id_token in response", ErrExchange)
+	}
+	return t, nil
+}
+
+// Verify checks the signature, issuer, audience, expiry and nonce of an
+// ID token and returns its claims.
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	m, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256"}))
+	_, err = parser.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, m.JWKSURI, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	switch {
+	case claims.Issuer != m.Issuer:
+		return nil, fmt.Errorf("%w: issuer %q", ErrInvalidToken, claims.Issuer)
+	case !claims.VerifyAudience(p.cfg.ClientID, true):
+		return nil, fmt.Errorf("%w: wrong audience", ErrInvalidToken)
+	case claims.ExpiresAt == nil:
+		return nil, fmt.Errorf("%w: no expiry", ErrInvalidToken)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	case nonce == "" || claims.Nonce != nonce:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// key returns the signing key kid, refetching the JWKS once when it is
+// not known yet, as providers rotate keys.
+func (p *Provider) key(ctx context.Context, jwksURI, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k := p.pick(kid); k != nil {
+		return k, nil
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc: jwks: %w", err)
+	}
+	p.keys = map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		p.keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if k := p.pick(kid); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("oidc: no signing key %q", kid)
+}
+
+// pick finds kid among the cached keys. A token without kid is accepted
+// when the provider publishes a single key.
+func (p *Provider) pick(kid string) *rsa.PublicKey {
+	if k, ok := p.keys[kid]; ok {
+		return k
+	}
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k
+		}
+	}
+	return nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// RandomString returns 32 random bytes, base64url encoded, for state and
+// nonce values.
+func RandomString() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// NewVerifier returns a PKCE code verifier (RFC 7636).
+func NewVerifier() (string, error) {
+	return RandomString()
+}
+
+// Challenge derives the S256 code challenge sent for verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+package oidc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/oidc"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/oidc/oidctest"
+)
+
+func TestCodeFlowWithPKCE(t *testing.T) {
+	issuer := oidctest.NewServer("app")
+	defer issuer.Close()
+	ctx := context.Background()
+	p := oidc.New(oidc.Config{Issuer: issuer.URL, ClientID: "app", RedirectURL: "http://localhost:3000/callback"})
+
+	verifier, err := oidc.NewVerifier()
+	require.NoError(t, err)
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	require.NoError(t, err)
+	assert.Contains(t, authURL, "code_challenge="+oidc.Challenge(verifier))
+
+	back, err := issuer.Authorize(authURL, oidctest.User{Subject: "sub-1", Email: "ann@test.com", EmailVerified: true})
+	require.NoError(t, err)
+	assert.Equal(t, "state-1", back.Query().Get("state"))
+	code := back.Query().Get("code")
+
+	_, err = p.Exchange(ctx, code, "wrong-verifier")
+	assert.ErrorIs(t, err, oidc.ErrExchange)
+
+	back, _ = issuer.Authorize(authURL, oidctest.User{Subject: "sub-1", Email: "ann@test.com", EmailVerified: true})
+	tokens, err := p.Exchange(ctx, back.Query().Get("code"), verifier)
+	require.NoError(t, err)
+
+	claims, err := p.Verify(ctx, tokens.IDToken, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "sub-1", claims.Subject)
+	assert.Equal(t, "ann@test.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+
+	_, err = p.Verify(ctx, tokens.IDToken, "other-nonce")
+	assert.ErrorIs(t, err, oidc.ErrInvalidToken)
+}
+
+func TestVerifyRejectsForeignTokens(t *testing.T) {
+	issuer := oidctest.NewServer("app")
+	defer issuer.Close()
+	ctx := context.Background()
+	p := oidc.New(oidc.Config{Issuer: issuer.URL, ClientID: "app"})
+
+	claims := func(iss, aud string, exp time.Time) oidc.Claims {
+		return oidc.Claims{Nonce: "n", RegisteredClaims: jwt.RegisteredClaims{
+			Issuer: iss, Subject: "s", Audience: jwt.ClaimStrings{aud}, ExpiresAt: jwt.NewNumericDate(exp),
+		}}
+	}
+	later := time.Now().Add(time.Minute)
+
+	for name, c := range map[string]oidc.Claims{
+		"other audience": claims(issuer.URL, "someone-else", later),
+		"other issuer":   claims("https://evil.example", "app", later),
+		"expired":        claims(issuer.URL, "app", time.Now().Add(-time.Minute)),
+	} {
+		raw, err := issuer.SignIDToken(c)
+		require.NoError(t, err)
+		_, err = p.Verify(ctx, raw, "n")
+		assert.ErrorIs(t, err, oidc.ErrInvalidToken, name)
+	}
+
+	// an HMAC token signed with a guessable secret must not pass
+	hs, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(issuer.URL, "app", later)).SignedString([]byte("secret"))
+	_, err := p.Verify(ctx, hs, "n")
+	assert.ErrorIs(t, err, oidc.ErrInvalidToken)
+
+	raw, _ := issuer.SignIDToken(claims(issuer.URL, "app", later))
+	_, err = p.Verify(ctx, raw, "n")
+	assert.NoError(t, err)
+}
+
+func TestDiscoveryChecksIssuer(t *testing.T) {
+	issuer := oidctest.NewServer("app")
+	defer issuer.Close()
+
+	p := oidc.New(oidc.Config{Issuer: issuer.URL + "/", ClientID: "app"})
+	_, err := p.Discover(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,191 @@
+// Package oidctest runs a local OpenID Connect issuer for tests. It
+// serves discovery, JWKS and a token endpoint that checks PKCE, and signs
+// ID tokens with a throwaway RSA key. Users "sign in" through Authorize
+// instead of a login page.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/oidc"
+)
+
+const keyID = "test-key"
+
+// User is who signs in at the issuer.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type grant struct {
+	user        User
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+// Server is a running mock issuer. Its URL is the issuer identifier.
+type Server struct {
+	*httptest.Server
+	ClientID string
+
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+// NewServer starts an issuer that accepts clientID. Close it when done.
+func NewServer(clientID string) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{ClientID: clientID, key: key, grants: map[string]grant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/token", s.token)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Authorize plays the user approving the request at authURL, as built by
+// oidc.Provider.AuthCodeURL, and returns the redirect back to the client
+// carrying code and state.
+func (s *Server) Authorize(authURL string, u User) (*url.URL, error) {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		return nil, err
+	}
+	q := parsed.Query()
+	if q.Get("client_id") != s.ClientID {
+		return nil, errors.New("oidctest: unknown client")
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		return nil, errors.New("oidctest: PKCE required")
+	}
+	code, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.grants[code] = grant{
+		user:        u,
+		clientID:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+	}
+	s.mu.Unlock()
+
+	back, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		return nil, err
+	}
+	bq := back.Query()
+	bq.Set("code", code)
+	bq.Set("state", q.Get("state"))
+	back.RawQuery = bq.Encode()
+	return back, nil
+}
+
+// SignIDToken signs claims with the issuer's key, for tests that need
+// tokens the token endpoint would not hand out.
+func (s *Server) SignIDToken(claims jwt.Claims) (string, error) {
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = keyID
+	return t.SignedString(s.key)
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, oidc.Metadata{
+		Issuer:                s.URL,
+		AuthorizationEndpoint: s.URL + "/authorize",
+		TokenEndpoint:         s.URL + "/token",
+		JWKSURI:               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	code := r.PostForm.Get("code")
+
+	s.mu.Lock()
+	g, ok := s.grants[code]
+	delete(s.grants, code) // codes are single use
+	s.mu.Unlock()
+
+	switch {
+	case !ok || r.PostForm.Get("grant_type") != "authorization_code":
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	case r.PostForm.Get("client_id") != g.clientID || r.PostForm.Get("redirect_uri") != g.redirectURI:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	case oidc.Challenge(r.PostForm.Get("code_verifier")) != g.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	idToken, err := s.SignIDToken(oidc.Claims{
+		Email:         g.user.Email,
+		EmailVerified: g.user.EmailVerified,
+		Name:          g.user.Name,
+		Nonce:         g.nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.URL,
+			Subject:   g.user.Subject,
+			Audience:  jwt.ClaimStrings{g.clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, oidc.Tokens{AccessToken: "access-" + code, TokenType: "Bearer", IDToken: idToken})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,74 @@
+// Package password decides whether a new password is acceptable.
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrWeak wraps every policy violation.
+var ErrWeak = errors.New("weak password")
+
+//go:embed breached.txt
+var bundled string
+
+// Policy holds the rules a new password must pass.
+type Policy struct {
+	MinLength int
+	breached  map[string]struct{}
+}
+
+// NewPolicy returns a policy requiring minLength characters and rejecting
+// the passwords listed in breachedFile, or in the bundled list when
+// breachedFile is empty.
+func NewPolicy(minLength int, breachedFile string) (*Policy, error) {
+	var src io.Reader = strings.NewReader(bundled)
+	if breachedFile != "" {
+		f, err := os.Open(breachedFile)
+		if err != nil {
+			return nil, fmt.Errorf("password: breached list: %w", err)
+		}
+		defer f.Close()
+		src = f
+	}
+
+	p := &Policy{MinLength: minLength, breached: map[string]struct{}{}}
+	sc := bufio.NewScanner(src)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.breached[strings.ToLower(line)] = struct{}{}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("password: breached list: %w", err)
+	}
+	return p, nil
+}
+
+// Check returns nil when pw may be used by the account with this email,
+// or an error wrapping ErrWeak that says why not. A nil policy accepts
+// any password.
+func (p *Policy) Check(pw, email string) error {
+	if p == nil {
+		return nil
+	}
+	if utf8.RuneCountInString(pw) < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeak, p.MinLength)
+	}
+	lower := strings.ToLower(pw)
+	if email != "" && lower == strings.ToLower(strings.TrimSpace(email)) {
+		return fmt.Errorf("%w: must not be your email address", ErrWeak)
+	}
+	if _, ok := p.breached[lower]; ok {
+		return fmt.Errorf("%w: appears in a list of breached passwords", ErrWeak)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Bundled(t *testing.T) {
+	p, err := NewPolicy(8, "")
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		pw   string
+		weak bool
+	}{
+		"ok":                {"correct horse battery", false},
+		"too short":         {"abc12", true},
+		"breached":          {"password123", true},
+		"breached any case": {"PassWord123", true},
+		"equals email":      {"Ann@Test.com", true},
+		"multibyte length":  {"пароль-дл", false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := p.Check(tc.pw, "ann@test.com")
+			if tc.weak {
+				assert.ErrorIs(t, err, ErrWeak)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_CustomList(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "list.txt")
+	require.NoError(t, os.WriteFile(file, []byte("# comment\nhorsebattery\n"), 0o644))
+
+	p, err := NewPolicy(4, file)
+	require.NoError(t, err)
+	assert.ErrorIs(t, p.Check("HorseBattery", ""), ErrWeak)
+	assert.NoError(t, p.Check("password123", ""), "bundled list is replaced")
+
+	_, err = NewPolicy(4, filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
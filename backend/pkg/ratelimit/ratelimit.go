@@ -0,0 +1,92 @@
+// Package ratelimit implements token buckets and failure lockouts as plain
+// values. Storing them between requests is left to the caller.
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Limit describes a token bucket: Burst tokens at most, refilled at Rate
+// tokens per second. A zero Limit allows everything.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute allows n requests a minute, all of which may come at once.
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+// Enabled reports whether the limit restricts anything.
+func (l Limit) Enabled() bool {
+	return l.Burst > 0 && l.Rate > 0
+}
+
+// Bucket is the state of one token bucket. The zero Bucket is full.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Take refills the bucket up to now and spends one token. It returns zero
+// when a token was spent, or how long until the next one is available.
+func (b *Bucket) Take(l Limit, now time.Time) time.Duration {
+	if !l.Enabled() {
+		return 0
+	}
+	switch {
+	case b.UpdatedAt.IsZero():
+		b.Tokens = float64(l.Burst)
+		b.UpdatedAt = now
+	case now.After(b.UpdatedAt):
+		b.Tokens = math.Min(float64(l.Burst), b.Tokens+now.Sub(b.UpdatedAt).Seconds()*l.Rate)
+		b.UpdatedAt = now
+	}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return 0
+	}
+	return time.Duration((1 - b.Tokens) / l.Rate * float64(time.Second))
+}
+
+// Lockout locks a key for Duration once MaxFailures failures happen within
+// Window. A zero MaxFailures disables it.
+type Lockout struct {
+	MaxFailures int
+	Window      time.Duration
+	Duration    time.Duration
+}
+
+// Failures is the failure count of one key.
+type Failures struct {
+	Count       int
+	WindowStart time.Time
+	LockedUntil time.Time
+}
+
+// Add counts one failure at now, locking the key when the policy says so.
+func (f *Failures) Add(p Lockout, now time.Time) {
+	if p.MaxFailures <= 0 {
+		return
+	}
+	if now.Sub(f.WindowStart) >= p.Window {
+		f.Count = 0
+		f.WindowStart = now
+	}
+	f.Count++
+	if f.Count >= p.MaxFailures {
+		f.LockedUntil = now.Add(p.Duration)
+		f.Count = 0
+		f.WindowStart = now
+	}
+}
+
+// Locked returns how long the key stays locked after now.
+func (f Failures) Locked(now time.Time) time.Duration {
+	if now.Before(f.LockedUntil) {
+		return f.LockedUntil.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucket_SpendsBurstThenRefills(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	limit := PerMinute(3)
+	var b Bucket
+
+	for i := 0; i < 3; i++ {
+		assert.Zero(t, b.Take(limit, now), "request %d", i)
+	}
+	assert.Equal(t, 20*time.Second, b.Take(limit, now))
+
+	now = now.Add(20 * time.Second)
+	assert.Zero(t, b.Take(limit, now))
+	assert.Equal(t, 20*time.Second, b.Take(limit, now))
+
+	// a long pause refills no further than the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.Zero(t, b.Take(limit, now))
+	}
+	assert.NotZero(t, b.Take(limit, now))
+}
+
+func TestBucket_ZeroLimitAllowsAll(t *testing.T) {
+	var b Bucket
+	for i := 0; i < 100; i++ {
+		assert.Zero(t, b.Take(Limit{}, time.Now()))
+	}
+}
+
+func TestFailures_LockAfterMax(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	p := Lockout{MaxFailures: 3, Window: time.Minute, Duration: 10 * time.Minute}
+	var f Failures
+
+	f.Add(p, now)
+	f.Add(p, now.Add(30*time.Second))
+	assert.Zero(t, f.Locked(now))
+
+	// the window restarts, so these two do not reach the limit
+	f.Add(p, now.Add(2*time.Minute))
+	f.Add(p, now.Add(2*time.Minute))
+	assert.Zero(t, f.Locked(now.Add(2*time.Minute)))
+
+	f.Add(p, now.Add(2*time.Minute+time.Second))
+	assert.Equal(t, 10*time.Minute, f.Locked(now.Add(2*time.Minute+time.Second)))
+	assert.Zero(t, f.Locked(now.Add(13*time.Minute)))
+}
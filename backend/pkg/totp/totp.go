@@ -0,0 +1,94 @@
+// Package totp implements time-based one-time passwords (RFC 6238) with
+// the parameters authenticator apps expect by default: HMAC-SHA1, six
+// digits and a 30 second period.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Period is how long one code is valid.
+	Period = 30 * time.Second
+	// Digits is the length of a code.
+	Digits = 6
+)
+
+// ErrBadSecret is returned for secrets that are not valid base32.
+var ErrBadSecret = errors.New("totp: secret is not base32")
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns 160 random bits, base32 encoded.
+func NewSecret() (string, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(raw), nil
+}
+
+// URI returns the otpauth:// URI that authenticator apps scan as a QR code.
+func URI(issuer, account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(int(Period.Seconds())))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// Step returns the time step t falls into.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// Code returns the code for time step step.
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return "", ErrBadSecret
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation, RFC 4226 section 5.3
+	off := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, bin%1_000_000), nil
+}
+
+// Validate checks code against the steps within skew of t, tolerating
+// clocks that drift apart. It returns the matching step, which callers
+// should remember to refuse the same code twice.
+func Validate(secret, code string, t time.Time, skew int) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+	now := Step(t)
+	for d := -int64(skew); d <= int64(skew); d++ {
+		want, err := Code(secret, now+d)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(want), []byte(code)) {
+			return now + d, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,56 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238Vectors(t *testing.T) {
+	// the RFC lists eight digits; six-digit codes are their last six
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		got, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "t=%d", unix)
+	}
+}
+
+func TestValidate_AllowsSkew(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+	code, _ := Code(rfcSecret, Step(at))
+
+	step, ok := Validate(rfcSecret, code, at.Add(Period), 1)
+	assert.True(t, ok)
+	assert.Equal(t, Step(at), step)
+
+	_, ok = Validate(rfcSecret, code, at.Add(2*Period), 1)
+	assert.False(t, ok)
+	_, ok = Validate(rfcSecret, "12345", at, 1)
+	assert.False(t, ok)
+	_, ok = Validate("not base32!", code, at, 1)
+	assert.False(t, ok)
+}
+
+func TestNewSecretAndURI(t *testing.T) {
+	secret, err := NewSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	uri := URI("Wellness", "ann@test.com", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Wellness:ann@test.com?"), uri)
+	assert.Contains(t, uri, "secret="+secret)
+	assert.Contains(t, uri, "issuer=Wellness")
+}
@@ -15,11 +15,34 @@ class Challenge {
 
 class Participant {
   final String userId;
+  final String name;
+  final int    rank;
   final int    progress;
-  Participant(this.userId,this.progress);
+  final double percent;
+  Participant(this.userId, this.name, this.rank, this.progress, this.percent);
 
   factory Participant.fromJson(Map<String,dynamic> j) => Participant(
   j['user_id'],
+  j['name'] ?? '',
+  (j['rank'] as num).toInt(),
   (j['progress'] as num).toInt(),
+  (j['percent'] as num).toDouble(),
+);
+}
+
+/// One page of a leaderboard; [you] is the signed-in user's entry even
+/// when it is not on the page.
+class Leaderboard {
+  final List<Participant> entries;
+  final Participant?      you;
+  final int               offset;
+  final int               total;
+  Leaderboard(this.entries, this.you, this.offset, this.total);
+
+  factory Leaderboard.fromJson(Map<String,dynamic> j) => Leaderboard(
+  (j['entries'] as List).map((e) => Participant.fromJson(e)).toList(),
+  j['you'] == null ? null : Participant.fromJson(j['you']),
+  (j['offset'] as num).toInt(),
+  (j['total'] as num).toInt(),
 );
 }
\ No newline at end of file
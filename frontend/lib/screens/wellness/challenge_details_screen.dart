@@ -23,24 +23,22 @@ class ChallengeDetailScreen extends ConsumerWidget {
         backgroundColor: Colors.white,
       ),
       body: lbAsync.when(
-        data: (rows) => ListView(
+        data: (lb) => ListView(
           padding: const EdgeInsets.all(16),
-          children: rows.asMap().entries.map((e) {
-            final idx = e.key;
-            final p   = e.value;
-            final pct = (p.progress / target).clamp(0.0, 1.0);
+          children: lb.entries.map((p) {
+            final pct = (p.percent / 100).clamp(0.0, 1.0);
 
             return ListTile(
               leading: CircleAvatar(
                 backgroundColor: Colors.pink[100],
-                child: Text('#${idx + 1}'),
+                child: Text('#${p.rank}'),
               ),
               title: LinearProgressIndicator(
                 value: pct,
                 color: Colors.pink,
                 backgroundColor: Colors.pink[50],
               ),
-              subtitle: Text('${p.progress} / $target'),
+              subtitle: Text('${p.name}  ${p.progress} / $target'),
             );
           }).toList(),
         ),
@@ -19,7 +19,6 @@ import 'package:sum25_flutter_frontend/services/nutrition/nutrition_api.dart';
 import 'package:sum25_flutter_frontend/services/wellness/challenge_api.dart';
 import 'package:sum25_flutter_frontend/services/wellness/chat_api.dart';
 import 'package:sum25_flutter_frontend/services/wellness/wellness_api.dart';
-import 'package:collection/collection.dart';
 import 'package:shared_preferences/shared_preferences.dart';
 import 'package:web_socket_channel/web_socket_channel.dart';
 
@@ -502,7 +501,7 @@ final challengesProvider = FutureProvider<List<Challenge>>(
     (ref) => ref.read(challengeApiProvider).list());
 
 final leaderboardProvider =
-    FutureProvider.family<List<Participant>, String>((ref, id) {
+    FutureProvider.family<Leaderboard, String>((ref, id) {
   return ref.read(challengeApiProvider).leaderboard(id);
 });
 
@@ -519,9 +518,8 @@ final createChallengeProvider =
 //  Returns true when *you* reached the target of this challenge
 final challengeCompletedProvider =
     FutureProvider.family<bool, Challenge>((ref, ch) async {
-  final lbs = await ref.watch(leaderboardProvider(ch.id).future);
-  final uid = ref.read(userIdProvider).valueOrNull;
-  final me = lbs.firstWhereOrNull((p) => p.userId == uid);
+  final lb = await ref.watch(leaderboardProvider(ch.id).future);
+  final me = lb.you;
   return me != null && me.progress >= ch.target;
 });
 
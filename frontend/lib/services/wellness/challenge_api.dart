@@ -47,7 +47,7 @@ class ChallengeApi {
     }
   }
 
-  Future<List<Participant>> leaderboard(String id) async {
+  Future<Leaderboard> leaderboard(String id) async {
     final token = (await _prefs()).getString('jwt_token');
     final res   = await http.get(
       Uri.parse('$base/challenges/$id/leaderboard'),
@@ -58,8 +58,7 @@ class ChallengeApi {
       throw Exception('Fetch leaderboard failed: ${res.body}');
     }
 
-    final List decoded = jsonDecode(res.body) as List;
-    return decoded.map((e) => Participant.fromJson(e)).toList();
+    return Leaderboard.fromJson(jsonDecode(res.body) as Map<String, dynamic>);
   }
 
   Future<SharedPreferences> _prefs() => SharedPreferences.getInstance();